@@ -1,15 +1,18 @@
 package auth
 
 import (
+	"crypto/rand"
 	"errors"
 	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/monteirobsb/user-management/backend/database"
 	"github.com/monteirobsb/user-management/backend/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/monteirobsb/user-management/backend/password"
+	"github.com/monteirobsb/user-management/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -28,57 +31,335 @@ func init() {
 // tokenDuration define o tempo de expiração do token.
 // Idealmente, este valor viria de uma configuração (ex: variável de ambiente).
 const tokenDuration = 24 * time.Hour
+
+// ServiceTokenDuration é a validade dos tokens emitidos para clientes de serviço
+// (grant client_credentials), que podem pedir um novo token a qualquer momento.
+const ServiceTokenDuration = time.Hour
 const errorInvalidCredentials = "usuário não encontrado ou credenciais inválidas"
 
+// Claims são as claims dos tokens da API. Tokens de usuário têm UserID; tokens de clientes
+// de serviço (service principals) têm ClientID e Scope, e UserID vazio.
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"sid,omitempty"` // sessão aberta no login (ver StartSession)
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"` // escopos separados por espaço (apenas clientes de serviço)
+	Act       *Actor `json:"act,omitempty"`   // administrador que personifica o usuário (RFC 8693)
+	// AuthTime é o momento em que o usuário provou sua identidade (login ou reautenticação).
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// LoginUser verifica as credenciais e retorna um token se forem válidas.
-func LoginUser(email, password string) (string, error) {
-	// A verificação de jwtKey vazia foi movida para a função init().
-	// Se a chave não estiver configurada, a aplicação já terá sido encerrada.
+// Actor identifica quem age em nome do usuário do token (claim act da RFC 8693).
+type Actor struct {
+	Subject string `json:"sub"` // ID do administrador
+}
+
+// ImpersonationDuration é a validade máxima de um token de personificação.
+const ImpersonationDuration = time.Hour
+
+// IsService indica se o token pertence a um cliente de serviço, e não a um usuário.
+func (claims *Claims) IsService() bool {
+	return claims.UserID == "" && claims.ClientID != ""
+}
+
+// ExternalAuthenticator verifica credenciais em um diretório externo (ex: LDAP/Active Directory)
+// e retorna o usuário local correspondente (conta sombra), criando-o se necessário.
+type ExternalAuthenticator interface {
+	Authenticate(login, plainPassword string) (models.User, error)
+}
+
+// Erros que um ExternalAuthenticator deve retornar quando a verificação local ainda pode ser
+// tentada. Qualquer outro erro (ex: senha incorreta no diretório) encerra o login.
+var (
+	ErrExternalUserNotFound = errors.New("usuário não encontrado no diretório externo")
+	ErrExternalUnavailable  = errors.New("diretório externo indisponível")
+)
+
+// Modos de uso do autenticador externo.
+const (
+	ExternalFirst = "first" // tenta o diretório e, se o usuário não existir nele, a senha local
+	ExternalOnly  = "only"  // apenas o diretório; a senha local não é verificada
+)
+
+var (
+	externalAuth ExternalAuthenticator
+	externalMode string
+)
+
+// UseExternalAuthenticator configura um autenticador externo para ser tentado antes
+// (ExternalFirst) ou no lugar (ExternalOnly) da verificação de senha local.
+func UseExternalAuthenticator(authenticator ExternalAuthenticator, mode string) {
+	externalAuth = authenticator
+	externalMode = mode
+}
+
+// SessionInfo descreve o dispositivo que fez o login, registrado na sessão.
+type SessionInfo struct {
+	UserAgent   string
+	IP          string
+	DeviceLabel string // opcional; se vazio, é derivado do user agent
+}
+
+// LoginUser verifica as credenciais e, se forem válidas, abre uma sessão e retorna seu token.
+func LoginUser(email, plainPassword string, info SessionInfo) (string, error) {
+	user, err := AuthenticateUser(email, plainPassword)
+	if err != nil {
+		return "", err
+	}
+	return StartSession(user, info)
+}
+
+// StartSession registra uma sessão para o usuário já autenticado e emite o token vinculado
+// a ela (claim sid). Usada por todos os fluxos de login da API.
+func StartSession(user models.User, info SessionInfo) (string, error) {
+	expirationTime := time.Now().Add(tokenDuration)
+	session := models.Session{
+		UserID:      user.ID,
+		UserAgent:   info.UserAgent,
+		IP:          info.IP,
+		DeviceLabel: info.DeviceLabel,
+		ExpiresAt:   expirationTime,
+	}
+	if err := services.CreateSession(&session); err != nil {
+		return "", errors.New("erro ao processar login")
+	}
+	log.Printf("INFO: Sessão %s aberta para o usuário ID %s (%s, IP: %s).", session.ID, user.ID, session.DeviceLabel, info.IP)
+	return signToken(&Claims{
+		UserID:    user.ID.String(),
+		SessionID: session.ID.String(),
+		AuthTime:  jwt.NewNumericDate(session.CreatedAt),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	})
+}
+
+// Reauthenticate confirma a senha do usuário de uma sessão já aberta e emite um novo token
+// da mesma sessão, com auth_time atualizado, para operações que exigem autenticação recente.
+func Reauthenticate(user models.User, session models.Session, plainPassword string) (string, error) {
+	if _, err := AuthenticateUser(user.Email, plainPassword); err != nil {
+		log.Printf("WARN: Reautenticação recusada para o usuário ID %s (sessão %s).", user.ID, session.ID)
+		return "", err
+	}
+	log.Printf("INFO: Usuário ID %s reautenticado na sessão %s.", user.ID, session.ID)
+	return signToken(&Claims{
+		UserID:    user.ID.String(),
+		SessionID: session.ID.String(),
+		AuthTime:  jwt.NewNumericDate(time.Now()),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		},
+	})
+}
+
+// StartImpersonation abre uma sessão do usuário target em nome do administrador actor e
+// emite um token com a claim act. A sessão expira em duration (no máximo
+// ImpersonationDuration) e pode ser encerrada como qualquer outra. O token não tem
+// auth_time: o administrador não provou ser o usuário, e operações que exigem
+// autenticação recente ficam indisponíveis.
+func StartImpersonation(actor, target models.User, info SessionInfo, duration time.Duration) (string, models.Session, error) {
+	if duration <= 0 || duration > ImpersonationDuration {
+		duration = ImpersonationDuration
+	}
+	session := models.Session{
+		UserID:         target.ID,
+		UserAgent:      info.UserAgent,
+		IP:             info.IP,
+		DeviceLabel:    "Personificação por " + actor.Email,
+		ExpiresAt:      time.Now().Add(duration),
+		ImpersonatorID: &actor.ID,
+	}
+	if err := services.CreateSession(&session); err != nil {
+		return "", session, errors.New("erro ao iniciar personificação")
+	}
+	log.Printf("WARN: Administrador ID %s iniciou personificação do usuário ID %s (sessão %s, até %s, IP: %s).",
+		actor.ID, target.ID, session.ID, session.ExpiresAt.Format(time.RFC3339), info.IP)
+	token, err := signToken(&Claims{
+		UserID:    target.ID.String(),
+		SessionID: session.ID.String(),
+		Act:       &Actor{Subject: actor.ID.String()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		},
+	})
+	return token, session, err
+}
+
+// AuthenticateUser verifica as credenciais e retorna o usuário correspondente.
+// É a base de todos os fluxos de login com senha (API e provedor OpenID Connect).
+func AuthenticateUser(email, plainPassword string) (models.User, error) {
+	if externalAuth != nil {
+		user, err := externalAuth.Authenticate(email, plainPassword)
+		switch {
+		case err == nil:
+			if !user.Active {
+				log.Printf("WARN: Tentativa de login em conta desativada (ID: %s).", user.ID.String())
+				return models.User{}, errors.New(errorInvalidCredentials)
+			}
+			return user, nil
+		case externalMode == ExternalOnly:
+			if errors.Is(err, ErrExternalUnavailable) {
+				return models.User{}, errors.New("erro ao processar login")
+			}
+			return models.User{}, errors.New(errorInvalidCredentials)
+		case !errors.Is(err, ErrExternalUserNotFound) && !errors.Is(err, ErrExternalUnavailable):
+			// Usuário existe no diretório, mas a senha não confere: não tenta a senha local.
+			return models.User{}, errors.New(errorInvalidCredentials)
+		}
+		// Usuário fora do diretório (ou diretório indisponível): segue para a verificação local.
+	}
 
 	var user models.User
 	result := database.DB.Where("email = ?", email).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			// Não logar "record not found" como erro, pois é um caso de login esperado (usuário não existe).
-			return "", errors.New(errorInvalidCredentials)
+			return models.User{}, errors.New(errorInvalidCredentials)
 		}
 		log.Printf("ERROR: Falha ao buscar usuário com email %s: %v", email, result.Error)
-		return "", errors.New("erro ao processar login") // Mensagem genérica para outros erros de DB
+		return models.User{}, errors.New("erro ao processar login") // Mensagem genérica para outros erros de DB
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if !user.Active {
+		// Conta desativada (ex: desprovisionada via SCIM). Mesma mensagem para evitar enumeração.
+		log.Printf("WARN: Tentativa de login em conta desativada (ID: %s).", user.ID.String())
+		return models.User{}, errors.New(errorInvalidCredentials)
+	}
+
+	ok, needsRehash, err := password.Verify(user.PasswordHash, plainPassword)
 	if err != nil {
-		// O erro aqui pode ser bcrypt.ErrMismatchedHashAndPassword (que não é um erro do sistema, mas falha de autenticação)
-		// ou outro erro (problema com o hash armazenado, etc., que seria um erro do sistema).
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			// Não logar como erro do sistema, é uma falha de login esperada.
-			// Poderia ter um log de DEBUG/INFO aqui se houvesse níveis e necessidade de rastrear tentativas.
-		} else {
-			// Logar outros erros de bcrypt como erro do sistema.
-			log.Printf("ERROR: Falha ao comparar hash para usuário com email %s: %v", email, err)
-		}
-		return "", errors.New(errorInvalidCredentials) // Mesma mensagem para evitar enumeração de usuários
+		// Erros aqui indicam problema com o hash armazenado (formato desconhecido ou corrompido),
+		// não uma simples senha incorreta, por isso são logados como erro do sistema.
+		log.Printf("ERROR: Falha ao verificar hash para usuário com email %s: %v", email, err)
+		return models.User{}, errors.New(errorInvalidCredentials)
+	}
+	if !ok {
+		// Senha incorreta: falha de login esperada, não é logada como erro do sistema.
+		return models.User{}, errors.New(errorInvalidCredentials) // Mesma mensagem para evitar enumeração de usuários
 	}
 
-	expirationTime := time.Now().Add(tokenDuration)
+	if needsRehash {
+		rehashPassword(&user, plainPassword)
+	}
+
+	return user, nil
+}
+
+// signToken assina as claims de um token da API.
+func signToken(claims *Claims) (string, error) {
+	// A verificação de jwtKey vazia foi movida para a função init().
+	// Se a chave não estiver configurada, a aplicação já terá sido encerrada.
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	if err != nil {
+		log.Printf("ERROR: Falha ao assinar token (usuário ID %q, cliente %q): %v", claims.UserID, claims.ClientID, err)
+		return "", errors.New("erro ao gerar token de autenticação")
+	}
+	return tokenString, nil
+}
+
+// GenerateServiceToken emite um token para um cliente de serviço com os escopos concedidos.
+func GenerateServiceToken(clientID, scope string) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID.String(),
+		ClientID: clientID,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			Subject:   clientID,
+			ID:        rand.Text(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ServiceTokenDuration)),
 		},
 	}
+	return signToken(claims)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+// ParseToken valida a assinatura e a validade de um token da API e retorna suas claims.
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
-		log.Printf("ERROR: Falha ao assinar token para usuário ID %s: %v", user.ID.String(), err)
-		return "", errors.New("erro ao gerar token de autenticação")
+		return nil, err
 	}
+	return claims, nil
+}
 
-	return tokenString, nil
+// Erros de ValidateUserToken. Sessões encerradas ou expiradas retornam services.ErrSessionInvalid.
+var (
+	ErrTokenRevoked         = errors.New("token revogado")
+	ErrUserInactive         = errors.New("usuário inativo ou inexistente")
+	ErrImpersonationInvalid = errors.New("personificação encerrada ou inválida")
+)
+
+// ValidateUserToken confere um token de usuário, já verificado por ParseToken (ou pelo
+// AuthMiddleware), contra o estado atual: o jti não foi revogado, a sessão continua aberta, o
+// usuário está ativo e, na personificação, o administrador continua ativo e com o papel de
+// administrador. Com clientIP não vazio, o acesso é registrado na sessão (veja
+// services.TouchSession); a introspecção consulta o token sem registrar acesso.
+func ValidateUserToken(claims *Claims, clientIP string) (models.Session, models.User, error) {
+	var session models.Session
+	var user models.User
+	if claims.ID != "" {
+		revoked, err := services.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return session, user, err
+		}
+		if revoked {
+			return session, user, ErrTokenRevoked
+		}
+	}
+	userID, errUser := uuid.Parse(claims.UserID)
+	sessionID, errSession := uuid.Parse(claims.SessionID)
+	if errUser != nil || errSession != nil {
+		return session, user, services.ErrSessionInvalid
+	}
+
+	var err error
+	if clientIP != "" {
+		session, err = services.TouchSession(sessionID, userID, clientIP)
+	} else if session, err = services.GetSession(sessionID); err == nil && session.UserID != userID {
+		err = services.ErrSessionInvalid
+	}
+	if err != nil {
+		return session, user, err
+	}
+
+	// A desativação encerra as sessões, mas a verificação garante que um usuário inativo
+	// nunca seja aceito, mesmo que uma sessão tenha sobrado.
+	user, err = services.GetUserByID(userID)
+	if err != nil || !user.Active {
+		return session, user, ErrUserInactive
+	}
+
+	if claims.Act != nil || session.ImpersonatorID != nil {
+		if claims.Act == nil || session.ImpersonatorID == nil || claims.Act.Subject != session.ImpersonatorID.String() {
+			log.Printf("WARN: Token de personificação inconsistente com a sessão %s.", session.ID)
+			return session, user, ErrImpersonationInvalid
+		}
+		actor, err := services.GetUserByID(*session.ImpersonatorID)
+		if err != nil || !actor.Active || actor.Role != models.RoleAdmin {
+			log.Printf("WARN: Personificação da sessão %s recusada: administrador ID %s inativo ou sem papel de administrador.", session.ID, session.ImpersonatorID)
+			return session, user, ErrImpersonationInvalid
+		}
+	}
+	return session, user, nil
+}
+
+// rehashPassword regera o hash da senha do usuário com o algoritmo e os parâmetros atuais.
+// Falhas são apenas logadas: o login já foi validado e não deve ser interrompido por isso.
+func rehashPassword(user *models.User, plainPassword string) {
+	newHash, err := password.Hash(plainPassword)
+	if err != nil {
+		log.Printf("ERROR: Falha ao regerar hash de senha para usuário ID %s: %v", user.ID.String(), err)
+		return
+	}
+	result := database.DB.Model(&models.User{}).Where("id = ? AND password_hash = ?", user.ID, user.PasswordHash).Update("password_hash", newHash)
+	if result.Error != nil {
+		log.Printf("ERROR: Falha ao salvar novo hash de senha para usuário ID %s: %v", user.ID.String(), result.Error)
+		return
+	}
+	user.PasswordHash = newHash
+	log.Printf("INFO: Hash de senha do usuário ID %s atualizado para o algoritmo atual.", user.ID.String())
 }
@@ -0,0 +1,53 @@
+// Package blobstore guarda arquivos enviados pelos usuários (ex: avatares) fora do banco de
+// dados, em disco local ou em um bucket compatível com S3. Os arquivos são públicos: quem
+// tem a URL retornada por BlobStore.URL consegue baixá-los.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// BlobStore grava e remove arquivos identificados por uma chave com "/" como separador (ex:
+// avatars/<usuário>/<id>-128.jpg). Put sobrescreve um arquivo existente com a mesma chave;
+// Delete de uma chave inexistente não é erro. Implementações devem ser seguras para uso
+// concorrente.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL retorna o endereço público do arquivo, sem verificar se ele existe.
+	URL(key string) string
+}
+
+// ErrInvalidKey indica uma chave vazia, absoluta ou com segmentos "." ou "..".
+var ErrInvalidKey = errors.New("chave de arquivo inválida")
+
+// NewFromEnv cria o BlobStore a partir de BLOB_STORE (local, o padrão, ou s3) e das
+// variáveis BLOB_LOCAL_* ou S3_* correspondentes.
+func NewFromEnv() (BlobStore, error) {
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		return NewLocalStoreFromEnv()
+	case "s3":
+		return NewS3StoreFromEnv()
+	default:
+		return nil, fmt.Errorf("BLOB_STORE inválido (use local ou s3): %q", backend)
+	}
+}
+
+// checkKey recusa chaves que poderiam sair do diretório ou do bucket configurado.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.Contains(key, "\\") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
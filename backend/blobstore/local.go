@@ -0,0 +1,94 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore grava os arquivos em um diretório do servidor. Os arquivos são servidos pelo
+// próprio backend (em BaseURL, quando é um caminho como /uploads) ou por um servidor web
+// que publique o diretório (BaseURL com a URL completa).
+type LocalStore struct {
+	Dir     string
+	BaseURL string // prefixo das URLs públicas, sem a barra final
+}
+
+// NewLocalStoreFromEnv cria o LocalStore a partir de BLOB_LOCAL_DIR (padrão ./uploads) e
+// BLOB_LOCAL_URL (padrão /uploads). O diretório é criado se não existir.
+func NewLocalStoreFromEnv() (*LocalStore, error) {
+	dir := os.Getenv("BLOB_LOCAL_DIR")
+	if dir == "" {
+		dir = "./uploads"
+	}
+	baseURL := os.Getenv("BLOB_LOCAL_URL")
+	if baseURL == "" {
+		baseURL = "/uploads"
+	}
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme == "" && !strings.HasPrefix(baseURL, "/")) {
+		return nil, fmt.Errorf("BLOB_LOCAL_URL inválida (use um caminho como /uploads ou uma URL completa): %q", baseURL)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("falha ao criar BLOB_LOCAL_DIR: %w", err)
+	}
+	return &LocalStore{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put implementa BlobStore. O arquivo é gravado em um temporário e renomeado, para que um
+// leitor nunca veja um arquivo pela metade.
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Delete implementa BlobStore.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// URL implementa BlobStore.
+func (s *LocalStore) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// Path retorna o prefixo de rota sob o qual o backend deve servir o diretório, ou "" se
+// BaseURL aponta para outro servidor.
+func (s *LocalStore) Path() string {
+	if strings.HasPrefix(s.BaseURL, "/") {
+		return s.BaseURL
+	}
+	return ""
+}
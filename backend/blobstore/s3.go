@@ -0,0 +1,167 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Store grava os arquivos em um bucket do Amazon S3 ou de um serviço compatível (MinIO,
+// Cloudflare R2, DigitalOcean Spaces...). Usa endereços no estilo de caminho
+// (<endpoint>/<bucket>/<chave>), aceitos por todos eles, e assina as requisições com AWS
+// Signature Version 4. A leitura pública dos arquivos é configurada no próprio bucket (ou
+// em uma CDN à frente dele, em PublicURL).
+type S3Store struct {
+	Client          *http.Client
+	Endpoint        string // ex: https://s3.us-east-1.amazonaws.com ou http://minio:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PublicURL       string // prefixo das URLs públicas; padrão <Endpoint>/<Bucket>
+}
+
+// NewS3StoreFromEnv cria o S3Store a partir das variáveis S3_*.
+func NewS3StoreFromEnv() (*S3Store, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido (use https://host ou http://host:porta): %q", endpoint)
+	}
+	store := &S3Store{
+		Client:          &http.Client{Timeout: 30 * time.Second},
+		Endpoint:        strings.TrimSuffix(endpoint, "/"),
+		Region:          os.Getenv("S3_REGION"),
+		Bucket:          os.Getenv("S3_BUCKET"),
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		PublicURL:       strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/"),
+	}
+	if store.Region == "" {
+		store.Region = "us-east-1"
+	}
+	if store.Bucket == "" || store.AccessKeyID == "" || store.SecretAccessKey == "" {
+		return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID e S3_SECRET_ACCESS_KEY são obrigatórias para BLOB_STORE=s3")
+	}
+	if store.PublicURL == "" {
+		store.PublicURL = store.Endpoint + "/" + store.Bucket
+	}
+	return store, nil
+}
+
+// Put implementa BlobStore. As chaves devem mudar junto com o conteúdo: os arquivos são
+// enviados com cache de um ano.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	return s.do(ctx, http.MethodPut, key, header, data)
+}
+
+// Delete implementa BlobStore. O S3 responde 204 também para chaves inexistentes.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, http.MethodDelete, key, http.Header{}, nil)
+}
+
+// URL implementa BlobStore.
+func (s *S3Store) URL(key string) string {
+	return s.PublicURL + "/" + escapePath(key)
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, header http.Header, body []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.Endpoint+"/"+escapePath(s.Bucket+"/"+key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3: %s %s respondeu %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// sign adiciona a assinatura AWS Signature Version 4 a req, cobrindo o host e todos os
+// cabeçalhos já definidos.
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + s.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath codifica cada segmento da chave como o S3 espera na assinatura (apenas
+// letras, números e -_.~ ficam como estão).
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-_.~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
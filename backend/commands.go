@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// commands lista os subcomandos administrativos disponíveis no binário.
+// Eles são executados com `./main <comando> [flags]` em vez de iniciar o servidor.
+var commands = map[string]func(args []string) int{
+	"import-users":             runImportUsers,
+	"create-oauth-client":      runCreateOAuthClient,
+	"verify-audit":             runVerifyAudit,
+	"export-audit-checkpoints": runExportAuditCheckpoints,
+}
+
+// runCommand executa o subcomando informado e retorna o código de saída do processo.
+func runCommand(name string, args []string) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\n", name)
+		fmt.Fprintln(os.Stderr, "Comandos disponíveis:")
+		for _, n := range slices.Sorted(maps.Keys(commands)) {
+			fmt.Fprintf(os.Stderr, "  %s\n", n)
+		}
+		return 2
+	}
+	return cmd(args)
+}
+
+// runImportUsers importa usuários de dumps de outros sistemas mantendo os hashes de senha
+// originais, que são convertidos para o algoritmo atual no primeiro login.
+//
+//	./main import-users -file users.csv [-format csv|json|keycloak] [-dry-run]
+func runImportUsers(args []string) int {
+	fs := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	file := fs.String("file", "", "Arquivo a importar (use - para stdin)")
+	format := fs.String("format", "", "Formato do arquivo: csv, json ou keycloak (padrão: deduzido pela extensão)")
+	dryRun := fs.Bool("dry-run", false, "Apenas valida os registros, sem gravar no banco")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "O parâmetro -file é obrigatório.")
+		fs.Usage()
+		return 2
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Printf("ERROR: Não foi possível abrir o arquivo %s: %v", *file, err)
+			return 1
+		}
+		defer f.Close()
+		input = f
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	var (
+		records []services.LegacyUserRecord
+		err     error
+	)
+	switch *format {
+	case "csv":
+		records, err = services.ParseLegacyUsersCSV(input)
+	case "json":
+		records, err = services.ParseLegacyUsersJSON(input)
+	case "keycloak":
+		records, err = services.ParseKeycloakExport(input)
+	default:
+		fmt.Fprintf(os.Stderr, "Formato não suportado: '%s' (use csv, json ou keycloak).\n", *format)
+		return 2
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao ler o arquivo de importação: %v", err)
+		return 1
+	}
+
+	database.InitDatabase()
+
+	result, err := services.ImportLegacyUsers(records, *dryRun)
+	for _, f := range result.Failed {
+		fmt.Printf("REJEITADO linha %d (%s): %s\n", f.Line, f.Email, f.Reason)
+	}
+	prefix := ""
+	if *dryRun {
+		prefix = "[dry-run] "
+	}
+	fmt.Printf("%sImportados: %d, já existentes: %d, rejeitados: %d\n", prefix, result.Imported, result.Skipped, len(result.Failed))
+	if err != nil {
+		log.Printf("ERROR: Importação interrompida: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// runCreateOAuthClient registra uma aplicação que usará este serviço como provedor OpenID Connect
+// ou, com -service, um cliente de serviço que acessa a API pelo grant client_credentials.
+// O client_secret é exibido apenas uma vez.
+//
+//	./main create-oauth-client -name "Wiki" -redirect-uri https://wiki.exemplo.com/callback [-public] [-skip-consent]
+//	./main create-oauth-client -name "Billing" -service -scopes "users:read"
+func runCreateOAuthClient(args []string) int {
+	fs := flag.NewFlagSet("create-oauth-client", flag.ContinueOnError)
+	name := fs.String("name", "", "Nome da aplicação exibido na tela de consentimento")
+	redirectURIs := fs.String("redirect-uri", "", "URIs de redirecionamento permitidas, separadas por vírgula")
+	scopes := fs.String("scopes", "openid profile email", "Escopos que o cliente pode solicitar, separados por espaço")
+	public := fs.Bool("public", false, "Cliente público (SPA ou app nativo), sem client_secret")
+	skipConsent := fs.Bool("skip-consent", false, "Não exibir a tela de consentimento (aplicações internas confiáveis)")
+	service := fs.Bool("service", false, "Cliente de serviço (máquina), que usa o grant client_credentials com os escopos users:read e/ou users:write")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *service {
+		scopesSet := false
+		fs.Visit(func(f *flag.Flag) { scopesSet = scopesSet || f.Name == "scopes" })
+		if !scopesSet {
+			*scopes = models.ScopeUsersRead
+		}
+		for _, scope := range strings.Fields(*scopes) {
+			if !slices.Contains(models.APIScopes, scope) {
+				fmt.Fprintf(os.Stderr, "Escopo inválido para cliente de serviço: %s (use %s).\n", scope, strings.Join(models.APIScopes, " ou "))
+				return 2
+			}
+		}
+		if *name == "" || *public || *redirectURIs != "" {
+			fmt.Fprintln(os.Stderr, "Clientes de serviço exigem -name e não aceitam -public nem -redirect-uri.")
+			fs.Usage()
+			return 2
+		}
+	} else if *name == "" || *redirectURIs == "" {
+		fmt.Fprintln(os.Stderr, "Os parâmetros -name e -redirect-uri são obrigatórios.")
+		fs.Usage()
+		return 2
+	}
+
+	var uris []string
+	for _, uri := range strings.Split(*redirectURIs, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+
+	database.InitDatabase()
+
+	client := models.OAuthClient{
+		Name:         *name,
+		RedirectURIs: strings.Join(uris, " "),
+		Scopes:       strings.Join(strings.Fields(*scopes), " "),
+		Public:       *public,
+		SkipConsent:  *skipConsent,
+		Service:      *service,
+	}
+	secret, err := services.CreateOAuthClient(&client)
+	if err != nil {
+		log.Printf("ERROR: Não foi possível registrar o cliente: %v", err)
+		return 1
+	}
+
+	fmt.Printf("client_id:     %s\n", client.ClientID)
+	if secret != "" {
+		fmt.Printf("client_secret: %s\n", secret)
+		fmt.Println("Guarde o client_secret agora: ele não poderá ser consultado novamente.")
+	}
+	return 0
+}
+
+// loadAuditSigningKey carrega a chave Ed25519 de AUDIT_SIGNING_KEY, que assina os checkpoints
+// do log de auditoria. Retorna nil se a variável não estiver definida.
+func loadAuditSigningKey() (ed25519.PrivateKey, error) {
+	path := os.Getenv("AUDIT_SIGNING_KEY")
+	if path == "" {
+		return nil, nil
+	}
+	return services.LoadAuditSigningKey(path)
+}
+
+// runVerifyAudit recalcula a cadeia de hashes do log de auditoria e confere os checkpoints
+// assinados (os do banco e, com -checkpoints, os de um arquivo exportado), informando o
+// primeiro elo quebrado. A chave pública vem de -public-key ou é derivada de AUDIT_SIGNING_KEY.
+//
+//	./main verify-audit [-checkpoints checkpoints.ndjson] [-public-key audit-public.pem]
+func runVerifyAudit(args []string) int {
+	fs := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	checkpointsFile := fs.String("checkpoints", "", "Arquivo NDJSON de checkpoints exportado por export-audit-checkpoints")
+	publicKeyFile := fs.String("public-key", "", "Chave pública Ed25519 (PEM) que assinou os checkpoints")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var publicKey ed25519.PublicKey
+	if *publicKeyFile != "" {
+		key, err := services.LoadAuditPublicKey(*publicKeyFile)
+		if err != nil {
+			log.Printf("ERROR: Não foi possível ler a chave pública %s: %v", *publicKeyFile, err)
+			return 1
+		}
+		publicKey = key
+	} else if signingKey, err := loadAuditSigningKey(); err != nil {
+		log.Printf("ERROR: Chave de assinatura do log de auditoria inválida: %v", err)
+		return 1
+	} else if signingKey != nil {
+		publicKey = signingKey.Public().(ed25519.PublicKey)
+	}
+
+	var external []models.AuditCheckpoint
+	if *checkpointsFile != "" {
+		f, err := os.Open(*checkpointsFile)
+		if err != nil {
+			log.Printf("ERROR: Não foi possível abrir o arquivo %s: %v", *checkpointsFile, err)
+			return 1
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var cp models.AuditCheckpoint
+			if err := json.Unmarshal(scanner.Bytes(), &cp); err != nil {
+				log.Printf("ERROR: Checkpoint inválido na linha %d de %s: %v", line, *checkpointsFile, err)
+				return 1
+			}
+			external = append(external, cp)
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("ERROR: Falha ao ler %s: %v", *checkpointsFile, err)
+			return 1
+		}
+	}
+
+	database.InitDatabase()
+
+	report, err := services.VerifyAuditChain(publicKey, external)
+	if err != nil {
+		log.Printf("ERROR: Não foi possível verificar o log de auditoria: %v", err)
+		return 1
+	}
+	if !report.OK() {
+		if report.BrokenAt != 0 {
+			fmt.Printf("FALHA no registro ID %d: %s\n", report.BrokenAt, report.Problem)
+		} else {
+			fmt.Printf("FALHA: %s\n", report.Problem)
+		}
+		return 1
+	}
+	fmt.Printf("OK: %d registros encadeados e %d checkpoints verificados", report.Entries, report.Checkpoints)
+	if report.Unchained > 0 {
+		fmt.Printf(" (%d registros anteriores ao encadeamento não verificáveis)", report.Unchained)
+	}
+	fmt.Println()
+	return 0
+}
+
+// runExportAuditCheckpoints grava os checkpoints do log de auditoria em NDJSON, para serem
+// guardados fora do banco (ex: armazenamento WORM) e usados por verify-audit -checkpoints.
+// Com -create, assina antes um checkpoint do último registro (exige AUDIT_SIGNING_KEY).
+//
+//	./main export-audit-checkpoints [-out checkpoints.ndjson] [-create]
+func runExportAuditCheckpoints(args []string) int {
+	fs := flag.NewFlagSet("export-audit-checkpoints", flag.ContinueOnError)
+	out := fs.String("out", "-", "Arquivo de saída (use - para stdout)")
+	create := fs.Bool("create", false, "Cria um checkpoint do último registro antes de exportar")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var signingKey ed25519.PrivateKey
+	if *create {
+		key, err := loadAuditSigningKey()
+		if err != nil {
+			log.Printf("ERROR: Chave de assinatura do log de auditoria inválida: %v", err)
+			return 1
+		}
+		if key == nil {
+			fmt.Fprintln(os.Stderr, "O parâmetro -create exige a variável AUDIT_SIGNING_KEY.")
+			return 2
+		}
+		signingKey = key
+	}
+
+	database.InitDatabase()
+
+	if signingKey != nil {
+		if _, _, err := services.CreateAuditCheckpoint(signingKey); err != nil {
+			return 1
+		}
+	}
+	checkpoints, err := services.ListAuditCheckpoints()
+	if err != nil {
+		return 1
+	}
+
+	var output io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Printf("ERROR: Não foi possível criar o arquivo %s: %v", *out, err)
+			return 1
+		}
+		defer f.Close()
+		output = f
+	}
+	enc := json.NewEncoder(output)
+	for _, cp := range checkpoints {
+		if err := enc.Encode(cp); err != nil {
+			log.Printf("ERROR: Falha ao gravar checkpoints: %v", err)
+			return 1
+		}
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "%d checkpoints exportados para %s\n", len(checkpoints), *out)
+	}
+	return 0
+}
@@ -45,9 +45,34 @@ func InitDatabase() { // Alterado para não retornar erro, pois usará log.Fatal
 	log.Print("INFO: Conexão com o banco de dados estabelecida com sucesso.")
 
 	log.Print("INFO: Iniciando migração do schema do banco de dados...")
-	err = DB.AutoMigrate(&models.User{})
+	err = Migrate(DB)
 	if err != nil {
 		log.Fatalf("CRITICAL: Falha ao migrar o schema do banco de dados: %v. A aplicação não pode iniciar.", err)
 	}
 	log.Print("INFO: Schema do banco de dados migrado com sucesso.")
 }
+
+// Migrate cria ou atualiza as tabelas de todos os modelos da aplicação.
+// Também é usada pelos testes para preparar bancos SQLite em memória.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.User{},
+		&models.Group{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthConsent{},
+		&models.FederatedIdentity{},
+		&models.FederatedLoginState{},
+		&models.APIToken{},
+		&models.RevokedToken{},
+		&models.Session{},
+		&models.MagicLink{},
+		&models.AuditLog{},
+		&models.AuditCheckpoint{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.IdempotencyKey{},
+		&models.UserAttributeSchema{},
+	)
+}
@@ -0,0 +1,182 @@
+// Package events publica os eventos gravados no outbox (tabela outbox_events) pela camada de
+// serviços. O Relay lê os eventos pendentes e os entrega a um EventPublisher (webhooks, NATS,
+// Kafka ou memória), marcando-os como publicados só depois do sucesso: a entrega é
+// at-least-once, e o ID do evento permite aos consumidores descartar duplicatas.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// EventPublisher entrega um evento do outbox a um destino. Publish só deve retornar nil
+// depois que o destino aceitou o evento; em caso de erro, o evento é publicado de novo,
+// com o mesmo ID. Implementações devem ser seguras para uso concorrente.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// MultiPublisher publica cada evento em todos os destinos. Se algum falhar, o evento é
+// publicado de novo em todos: os destinos que já o receberam devem deduplicar pelo ID.
+type MultiPublisher []EventPublisher
+
+// Publish implementa EventPublisher.
+func (publishers MultiPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	var errs []error
+	for _, publisher := range publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+const (
+	// baseBackoff é o intervalo antes da segunda tentativa de publicar um evento; dobra a
+	// cada falha, até maxBackoff.
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+	// pruneEvery é o intervalo entre as remoções dos eventos publicados antigos.
+	pruneEvery = time.Hour
+)
+
+// Relay publica os eventos pendentes do outbox. Várias instâncias podem rodar ao mesmo
+// tempo: cada evento é reservado antes da publicação. A ordem de criação é respeitada em
+// cada lote, mas um evento com falha não bloqueia os seguintes.
+type Relay struct {
+	Publisher    EventPublisher
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration // tempo de reserva de um evento durante a publicação
+	Retention    time.Duration // eventos publicados são removidos após esse prazo (0 = nunca)
+}
+
+// NewFromEnv cria o Relay a partir de EVENT_PUBLISHERS (lista separada por vírgulas de
+// webhook, nats e kafka; padrão webhook) e das variáveis OUTBOX_*.
+func NewFromEnv() (*Relay, error) {
+	names := os.Getenv("EVENT_PUBLISHERS")
+	if names == "" {
+		names = "webhook"
+	}
+	var publishers MultiPublisher
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "webhook":
+			publishers = append(publishers, WebhookPublisher{})
+		case "nats":
+			publisher, err := NewNATSPublisherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, publisher)
+		case "kafka":
+			publisher, err := NewKafkaPublisherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, publisher)
+		default:
+			return nil, fmt.Errorf("publicador de eventos desconhecido em EVENT_PUBLISHERS: %q", name)
+		}
+	}
+
+	relay := &Relay{Publisher: publishers, BatchSize: 100, PollInterval: time.Second, Lease: time.Minute, Retention: 7 * 24 * time.Hour}
+	if len(publishers) == 1 {
+		relay.Publisher = publishers[0]
+	}
+	if raw := os.Getenv("OUTBOX_POLL_INTERVAL"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("valor inválido para OUTBOX_POLL_INTERVAL: %q", raw)
+		}
+		relay.PollInterval = value
+	}
+	if raw := os.Getenv("OUTBOX_RETENTION"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("valor inválido para OUTBOX_RETENTION: %q", raw)
+		}
+		relay.Retention = value
+	}
+	return relay, nil
+}
+
+// Run publica os eventos pendentes a cada PollInterval até ctx ser cancelado.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	var lastPrune time.Time
+	for {
+		// Esvazia a fila antes de esperar o próximo ciclo.
+		for ctx.Err() == nil {
+			if r.PublishPending(ctx) < r.BatchSize {
+				break
+			}
+		}
+		if r.Retention > 0 && time.Since(lastPrune) >= pruneEvery {
+			if removed, err := services.PruneOutbox(time.Now().Add(-r.Retention)); err == nil && removed > 0 {
+				log.Printf("INFO: %d eventos publicados removidos do outbox.", removed)
+			}
+			lastPrune = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending reserva e publica um lote de eventos pendentes, retornando quantos foram
+// reservados (publicados ou não).
+func (r *Relay) PublishPending(ctx context.Context) int {
+	pending, err := services.ClaimOutboxEvents(r.BatchSize, r.Lease)
+	if err != nil {
+		return 0
+	}
+	for i := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		event := &pending[i]
+		if err := r.Publisher.Publish(ctx, *event); err != nil {
+			next := time.Now().Add(Backoff(event.Attempts + 1))
+			log.Printf("WARN: Falha ao publicar evento %s ID %s (tentativa %d): %v", event.EventType, event.ID, event.Attempts+1, err)
+			services.RecordOutboxEventFailure(event, err, next)
+			continue
+		}
+		services.MarkOutboxEventPublished(event)
+	}
+	return len(pending)
+}
+
+// Backoff retorna o intervalo antes da próxima tentativa após attempts falhas: 1s, 2s, 4s...
+// até 5min.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// WebhookPublisher entrega os eventos às assinaturas de webhook, criando as entregas que o
+// webhooks.Dispatcher envia. Publicar o mesmo evento de novo não duplica as entregas.
+type WebhookPublisher struct{}
+
+// Publish implementa EventPublisher.
+func (WebhookPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	_, err := services.EnqueueWebhookDeliveries(event)
+	return err
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// flakyPublisher falha nas primeiras failures publicações e depois repassa ao destino.
+type flakyPublisher struct {
+	failures int
+	next     EventPublisher
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("destino indisponível")
+	}
+	return p.next.Publish(ctx, event)
+}
+
+func TestRelay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	originalGlobalDB := database.DB
+	database.DB = db
+	defer func() { database.DB = originalGlobalDB }()
+
+	// O evento é gravado na transação da alteração: uma criação que falha não gera evento.
+	user := models.User{Name: "Outbox", Email: "outbox@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	duplicate := models.User{Name: "Duplicado", Email: "outbox@example.com"}
+	require.Error(t, services.CreateUser(services.SystemActor, &duplicate, "password123"))
+	var pending []models.OutboxEvent
+	require.NoError(t, db.Find(&pending).Error)
+	require.Len(t, pending, 1)
+	assert.Equal(t, models.EventUserCreated, pending[0].EventType)
+	assert.Equal(t, user.ID.String(), pending[0].AggregateID)
+	var payload services.UserEvent
+	require.NoError(t, json.Unmarshal([]byte(pending[0].Payload), &payload))
+	assert.Equal(t, pending[0].ID, payload.ID, "o ID do evento no corpo é o ID de deduplicação")
+
+	memory := NewMemoryPublisher()
+	received, cancel := memory.Subscribe(10)
+	defer cancel()
+	relay := &Relay{Publisher: &flakyPublisher{failures: 1, next: memory}, BatchSize: 10, Lease: time.Minute}
+
+	// Falha: o evento continua pendente, com nova tentativa agendada.
+	assert.Equal(t, 1, relay.PublishPending(context.Background()))
+	var event models.OutboxEvent
+	require.NoError(t, db.First(&event, "id = ?", pending[0].ID).Error)
+	assert.Nil(t, event.PublishedAt)
+	assert.Equal(t, 1, event.Attempts)
+	assert.Equal(t, "destino indisponível", event.LastError)
+	assert.Zero(t, relay.PublishPending(context.Background()), "nova tentativa antes do backoff")
+
+	require.NoError(t, db.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	assert.Equal(t, 1, relay.PublishPending(context.Background()))
+	select {
+	case published := <-received:
+		assert.Equal(t, event.ID, published.ID)
+	default:
+		t.Fatal("evento não publicado")
+	}
+	event = models.OutboxEvent{}
+	require.NoError(t, db.First(&event, "id = ?", pending[0].ID).Error)
+	assert.NotNil(t, event.PublishedAt)
+	assert.Nil(t, event.NextAttemptAt)
+	assert.Zero(t, relay.PublishPending(context.Background()), "eventos publicados não são reenviados")
+
+	// Remoção dos eventos publicados antigos.
+	removed, err := services.PruneOutbox(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+	removed, err = services.PruneOutbox(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, removed)
+}
+
+func TestMemoryPublisherOverflow(t *testing.T) {
+	memory := NewMemoryPublisher()
+	received, cancel := memory.Subscribe(1)
+	defer cancel()
+	require.NoError(t, memory.Publish(context.Background(), models.OutboxEvent{ID: uuid.New()}))
+	require.NoError(t, memory.Publish(context.Background(), models.OutboxEvent{ID: uuid.New()}))
+	<-received
+	_, open := <-received
+	assert.False(t, open, "o assinante lento é desconectado")
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var body map[string][]kafkaRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/user-events", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["records"][0].Key == "falha" {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"timeout"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	publisher := &KafkaPublisher{Client: server.Client(), BaseURL: server.URL, Topic: "user-events"}
+	event := models.OutboxEvent{ID: uuid.New(), AggregateID: uuid.NewString(), EventType: models.EventUserCreated, Payload: `{"id":"1"}`}
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	assert.Equal(t, event.AggregateID, body["records"][0].Key)
+	assert.JSONEq(t, event.Payload, string(body["records"][0].Value))
+
+	event.AggregateID = "falha"
+	assert.ErrorContains(t, publisher.Publish(context.Background(), event), "timeout")
+}
+
+// TestHubSameTimestamp confere que a paginação do Hub avança mesmo quando mais eventos que
+// o tamanho do buffer têm o mesmo created_at.
+func TestHubSameTimestamp(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	originalGlobalDB := database.DB
+	database.DB = db
+	defer func() { database.DB = originalGlobalDB }()
+
+	createdAt := time.Now().Truncate(time.Millisecond)
+	addEvents := func(n int) []models.OutboxEvent {
+		events := make([]models.OutboxEvent, n)
+		for i := range events {
+			events[i] = models.OutboxEvent{AggregateType: "user", AggregateID: uuid.NewString(), EventType: models.EventUserUpdated, Payload: "{}", CreatedAt: createdAt}
+			require.NoError(t, db.Create(&events[i]).Error)
+		}
+		return events
+	}
+
+	hub := NewHub(1, time.Hour)
+	hub.Poll()
+	_, _, received, cancel := hub.Subscribe("", 10)
+	defer cancel()
+
+	added := addEvents(4)
+	done := make(chan struct{})
+	go func() {
+		hub.Poll()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll não terminou com vários eventos no mesmo instante")
+	}
+	var got []uuid.UUID
+	for len(received) > 0 {
+		got = append(got, (<-received).ID)
+	}
+	var want []uuid.UUID
+	for _, event := range added {
+		want = append(want, event.ID)
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+// startNATS inicia um servidor NATS embutido, com JetStream, e devolve sua URL.
+func startNATS(t *testing.T) string {
+	server, err := natsserver.NewServer(&natsserver.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	server.Start()
+	t.Cleanup(server.Shutdown)
+	require.True(t, server.ReadyForConnections(5*time.Second))
+	return server.ClientURL()
+}
+
+func TestNATSPublisher(t *testing.T) {
+	url := startNATS(t)
+	conn, err := nats.Connect(url)
+	require.NoError(t, err)
+	defer conn.Close()
+	event := models.OutboxEvent{ID: uuid.New(), EventType: models.EventUserDeleted, Payload: `{"type":"user.deleted"}`}
+
+	// Sem JetStream: a mensagem chega aos assinantes com o ID do evento no cabeçalho.
+	subscription, err := conn.SubscribeSync("events.>")
+	require.NoError(t, err)
+	require.NoError(t, conn.Flush())
+	publisher := &NATSPublisher{URL: url, SubjectPrefix: "events", Timeout: 5 * time.Second}
+	defer publisher.close()
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	msg, err := subscription.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "events.user.deleted", msg.Subject)
+	assert.Equal(t, event.ID.String(), msg.Header.Get("Nats-Msg-Id"))
+	assert.Equal(t, event.Payload, string(msg.Data))
+	require.NoError(t, subscription.Unsubscribe())
+
+	// Com JetStream, a publicação falha enquanto nenhum stream armazena o assunto.
+	jsPublisher := &NATSPublisher{URL: url, SubjectPrefix: "events", JetStream: true, Timeout: 5 * time.Second}
+	defer jsPublisher.close()
+	assert.ErrorContains(t, jsPublisher.Publish(context.Background(), event), "nenhum stream")
+
+	js, err := jetstream.New(conn)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "USERS", Subjects: []string{"events.>"}})
+	require.NoError(t, err)
+	require.NoError(t, jsPublisher.Publish(context.Background(), event))
+	// A republicação do mesmo evento (ex: após uma falha do relay) é descartada pelo stream.
+	require.NoError(t, jsPublisher.Publish(context.Background(), event))
+	info, err := stream.Info(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, info.State.Msgs)
+
+	// Após o fechamento da conexão, a próxima publicação abre outra.
+	jsPublisher.close()
+	event.ID = uuid.New()
+	require.NoError(t, jsPublisher.Publish(context.Background(), event))
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// hubLag é a janela relida a cada busca do Hub: uma transação confirmada alguns segundos
+// depois de gravar o evento (created_at) ainda é vista, sem repetir os eventos já lidos.
+const hubLag = 10 * time.Second
+
+// Hub acompanha o outbox e mantém os eventos de usuários mais recentes em um buffer circular
+// limitado, distribuindo-os aos assinantes do processo (ex: streams SSE). Como lê a tabela
+// diretamente, cada instância do backend vê todos os eventos, independentemente de qual
+// instância do relay os publicou.
+type Hub struct {
+	mu       sync.Mutex
+	size     int
+	buffer   []models.OutboxEvent // do mais antigo para o mais recente, no máximo size
+	seen     map[uuid.UUID]time.Time
+	since    time.Time // maior created_at já lido
+	loaded   bool
+	memory   *MemoryPublisher
+	interval time.Duration
+}
+
+// NewHub cria um Hub que guarda até size eventos e busca novos eventos a cada interval.
+func NewHub(size int, interval time.Duration) *Hub {
+	return &Hub{size: size, seen: make(map[uuid.UUID]time.Time), memory: NewMemoryPublisher(), interval: interval}
+}
+
+// Run busca novos eventos a cada interval até ctx ser cancelado.
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.Poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll lê os eventos novos do outbox e os distribui. Na primeira chamada, apenas preenche o
+// buffer com os eventos mais recentes, para que clientes reconectados possam retomar.
+func (h *Hub) Poll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// As páginas seguem o cursor (created_at, id) do último evento lido: mesmo com mais de
+	// size eventos no mesmo instante, cada página avança.
+	since, afterID := time.Time{}, uuid.Nil
+	if h.loaded {
+		since = h.since.Add(-hubLag)
+	}
+	for {
+		batch, err := services.ListOutboxEvents(since, afterID, h.size)
+		if err != nil {
+			return
+		}
+		for _, event := range batch {
+			if _, ok := h.seen[event.ID]; ok {
+				continue
+			}
+			h.seen[event.ID] = event.CreatedAt
+			if event.CreatedAt.After(h.since) {
+				h.since = event.CreatedAt
+			}
+			h.buffer = append(h.buffer, event)
+			if len(h.buffer) > h.size {
+				h.buffer = h.buffer[len(h.buffer)-h.size:]
+			}
+			if h.loaded {
+				h.memory.Publish(context.Background(), event)
+			}
+		}
+		if !h.loaded || len(batch) < h.size {
+			break
+		}
+		last := batch[len(batch)-1]
+		since, afterID = last.CreatedAt, last.ID
+	}
+	h.loaded = true
+
+	for id, createdAt := range h.seen {
+		if createdAt.Before(h.since.Add(-2 * hubLag)) {
+			delete(h.seen, id)
+		}
+	}
+}
+
+// Subscribe registra um assinante com buffer de até buffer eventos (veja
+// MemoryPublisher.Subscribe). Com lastEventID, retorna também os eventos do buffer
+// posteriores a ele; se o evento não está mais no buffer (ou é desconhecido), resumed é
+// false e o assinante deve recarregar o estado completo.
+func (h *Hub) Subscribe(lastEventID string, buffer int) (replay []models.OutboxEvent, resumed bool, events <-chan models.OutboxEvent, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events, cancel = h.memory.Subscribe(buffer)
+	if lastEventID == "" {
+		return nil, true, events, cancel
+	}
+	for i := len(h.buffer) - 1; i >= 0; i-- {
+		if h.buffer[i].ID.String() == lastEventID {
+			return append([]models.OutboxEvent(nil), h.buffer[i+1:]...), true, events, cancel
+		}
+	}
+	return nil, false, events, cancel
+}
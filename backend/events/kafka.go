@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// KafkaPublisher publica os eventos em um tópico Kafka pelo REST Proxy (API v2 do Confluent
+// REST Proxy ou compatível). A chave da mensagem é o ID do usuário, para manter os eventos
+// de um mesmo usuário na mesma partição; o ID do evento vai no campo id do valor.
+type KafkaPublisher struct {
+	Client   *http.Client
+	BaseURL  string // ex: http://kafka-rest:8082
+	Topic    string
+	Username string // autenticação básica, se o proxy exigir
+	Password string
+}
+
+// NewKafkaPublisherFromEnv cria o KafkaPublisher a partir das variáveis KAFKA_REST_*.
+func NewKafkaPublisherFromEnv() (*KafkaPublisher, error) {
+	baseURL := os.Getenv("KAFKA_REST_URL")
+	if baseURL == "" {
+		return nil, errors.New("KAFKA_REST_URL é obrigatória para o publicador kafka")
+	}
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("KAFKA_REST_URL inválida: %w", err)
+	}
+	topic := os.Getenv("KAFKA_TOPIC")
+	if topic == "" {
+		topic = "user-events"
+	}
+	return &KafkaPublisher{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Topic:    topic,
+		Username: os.Getenv("KAFKA_REST_USERNAME"),
+		Password: os.Getenv("KAFKA_REST_PASSWORD"),
+	}, nil
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish implementa EventPublisher.
+func (p *KafkaPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{
+		"records": {{Key: event.AggregateID, Value: json.RawMessage(event.Payload)}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/topics/"+url.PathEscape(p.Topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.Username != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kafka: REST proxy respondeu %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var result kafkaProduceResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("kafka: resposta inválida do REST proxy: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil || offset.Error != "" {
+			return fmt.Errorf("kafka: falha ao gravar no tópico %s: %s", p.Topic, offset.Error)
+		}
+	}
+	return nil
+}
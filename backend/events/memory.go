@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// MemoryPublisher distribui os eventos aos assinantes do próprio processo. Cada assinante
+// tem um buffer limitado: se ele não consumir a tempo e o buffer encher, a assinatura é
+// encerrada (o canal é fechado) em vez de bloquear o relay ou perder eventos em silêncio.
+type MemoryPublisher struct {
+	mu          sync.Mutex
+	subscribers map[chan models.OutboxEvent]struct{}
+}
+
+// NewMemoryPublisher cria um MemoryPublisher sem assinantes.
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{subscribers: make(map[chan models.OutboxEvent]struct{})}
+}
+
+// Publish implementa EventPublisher.
+func (p *MemoryPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ch := range p.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Subscribe registra um assinante com buffer de até buffer eventos. O canal é fechado quando
+// o buffer estoura ou quando a função retornada (que cancela a assinatura) é chamada.
+func (p *MemoryPublisher) Subscribe(buffer int) (<-chan models.OutboxEvent, func()) {
+	ch := make(chan models.OutboxEvent, buffer)
+	p.mu.Lock()
+	p.subscribers[ch] = struct{}{}
+	p.mu.Unlock()
+
+	return ch, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.subscribers[ch]; ok {
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+}
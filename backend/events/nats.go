@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSPublisher publica os eventos no NATS, no assunto "<prefixo>.<tipo>" (ex:
+// events.user.created), com o ID do evento no cabeçalho Nats-Msg-Id, usado pelo JetStream
+// para descartar duplicatas. A conexão (e a reconexão após quedas) fica a cargo do cliente
+// oficial (nats.go); se o cliente desistir de reconectar, a próxima publicação abre outra.
+//
+// Sem JetStream, a publicação é confirmada apenas pelo servidor (flush após o envio); com
+// JetStream, o relay espera a confirmação do stream que armazena o assunto.
+type NATSPublisher struct {
+	URL           string // nats://[usuário:senha@]host:4222 ou tls://host:4222
+	SubjectPrefix string
+	Token         string
+	JetStream     bool
+	Timeout       time.Duration
+	TLSConfig     *tls.Config
+
+	mu   sync.Mutex
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// NewNATSPublisherFromEnv cria o NATSPublisher a partir das variáveis NATS_*.
+func NewNATSPublisherFromEnv() (*NATSPublisher, error) {
+	rawURL := os.Getenv("NATS_URL")
+	if rawURL == "" {
+		return nil, errors.New("NATS_URL é obrigatória para o publicador nats")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return nil, fmt.Errorf("NATS_URL inválida (use nats://host:4222 ou tls://host:4222): %q", rawURL)
+	}
+	prefix := os.Getenv("NATS_SUBJECT_PREFIX")
+	if prefix == "" {
+		prefix = "events"
+	}
+	return &NATSPublisher{
+		URL:           rawURL,
+		SubjectPrefix: prefix,
+		Token:         os.Getenv("NATS_TOKEN"),
+		JetStream:     os.Getenv("NATS_JETSTREAM") == "true",
+		Timeout:       10 * time.Second,
+	}, nil
+}
+
+// Publish implementa EventPublisher.
+func (p *NATSPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil || p.conn.IsClosed() {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	msg := nats.NewMsg(p.SubjectPrefix + "." + event.EventType)
+	msg.Header.Set(jetstream.MsgIDHeader, event.ID.String())
+	msg.Data = []byte(event.Payload)
+	if p.JetStream {
+		if _, err := p.js.PublishMsg(ctx, msg); err != nil {
+			if errors.Is(err, jetstream.ErrNoStreamResponse) {
+				return fmt.Errorf("nenhum stream do JetStream armazena o assunto %s: %w", msg.Subject, err)
+			}
+			return err
+		}
+		return nil
+	}
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// connect abre a conexão com as credenciais configuradas. O servidor precisa suportar
+// cabeçalhos (NATS 2.2 ou superior).
+func (p *NATSPublisher) connect() error {
+	options := []nats.Option{nats.Name("user-management"), nats.Timeout(p.Timeout)}
+	if p.Token != "" {
+		options = append(options, nats.Token(p.Token))
+	}
+	if p.TLSConfig != nil {
+		options = append(options, nats.Secure(p.TLSConfig))
+	}
+	conn, err := nats.Connect(p.URL, options...)
+	if err != nil {
+		return err
+	}
+	if !conn.HeadersSupported() {
+		conn.Close()
+		return errors.New("nats: o servidor não suporta cabeçalhos (NATS 2.2 ou superior é necessário)")
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.js = conn, js
+	return nil
+}
+
+// close encerra a conexão; a próxima publicação abre outra.
+func (p *NATSPublisher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.js = nil, nil
+	}
+}
@@ -0,0 +1,84 @@
+// Package federation implementa o login com provedores OpenID Connect externos (Google,
+// Microsoft ou qualquer provedor compatível), vinculando as contas externas a usuários locais.
+package federation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProviderConfig é a configuração de um provedor OIDC externo.
+type ProviderConfig struct {
+	Name         string // identificador usado nas URLs (ex: google)
+	DisplayName  string // texto exibido no botão de login
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string // URL de callback registrada no provedor
+	AllowSignup  bool   // cria o usuário local no primeiro login (provisionamento just-in-time)
+	TrustEmail   bool   // considera o e-mail verificado mesmo sem a claim email_verified
+}
+
+// LoadConfigsFromEnv lê os provedores listados em OIDC_LOGIN_PROVIDERS (ex: "google,microsoft").
+// Cada provedor é configurado por OIDC_LOGIN_<NOME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET e,
+// opcionalmente, _SCOPES, _DISPLAY_NAME, _ALLOW_SIGNUP e _TRUST_EMAIL. A URL de callback é
+// derivada de OIDC_LOGIN_CALLBACK_BASE_URL.
+func LoadConfigsFromEnv() ([]ProviderConfig, error) {
+	names := strings.Split(os.Getenv("OIDC_LOGIN_PROVIDERS"), ",")
+	baseURL := strings.TrimRight(os.Getenv("OIDC_LOGIN_CALLBACK_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, errors.New("OIDC_LOGIN_CALLBACK_BASE_URL é obrigatória quando OIDC_LOGIN_PROVIDERS está definida")
+	}
+
+	var configs []ProviderConfig
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_LOGIN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := ProviderConfig{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			RedirectURL:  baseURL + "/api/login/oidc/" + name + "/callback",
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("provedor %s: %sISSUER e %sCLIENT_ID são obrigatórias", name, prefix, prefix)
+		}
+		if config.DisplayName == "" {
+			config.DisplayName = name
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = []string{"openid", "email", "profile"}
+		}
+		var err error
+		if config.AllowSignup, err = envBool(prefix+"ALLOW_SIGNUP", true); err != nil {
+			return nil, err
+		}
+		if config.TrustEmail, err = envBool(prefix+"TRUST_EMAIL", false); err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("valor inválido para %s: %q", key, raw)
+	}
+	return v, nil
+}
@@ -0,0 +1,367 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	stateCookie   = "oidc_login_state"
+	stateDuration = 10 * time.Minute
+)
+
+// provider é um provedor configurado. A descoberta (/.well-known/openid-configuration) é
+// feita no primeiro uso e repetida enquanto falhar, para que uma indisponibilidade
+// temporária do provedor não impeça a API de iniciar.
+type provider struct {
+	config ProviderConfig
+
+	mu   sync.Mutex
+	oidc *oidc.Provider
+}
+
+func (p *provider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oidc == nil {
+		discovered, err := oidc.NewProvider(ctx, p.config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		p.oidc = discovered
+	}
+	return p.oidc, nil
+}
+
+func (p *provider) oauth2Config(discovered *oidc.Provider) oauth2.Config {
+	return oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     discovered.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+	}
+}
+
+// Federation expõe os handlers de login federado para os provedores configurados.
+type Federation struct {
+	providers  map[string]*provider
+	order      []string
+	successURL string
+}
+
+// New cria o serviço de login federado. successURL é a página do frontend que recebe o
+// resultado do login no fragmento da URL (#token=... ou #error=...).
+func New(configs []ProviderConfig, successURL string) *Federation {
+	f := &Federation{providers: make(map[string]*provider), successURL: successURL}
+	for _, config := range configs {
+		f.providers[config.Name] = &provider{config: config}
+		f.order = append(f.order, config.Name)
+	}
+	return f
+}
+
+// NewFromEnv cria o serviço a partir de OIDC_LOGIN_PROVIDERS e OIDC_LOGIN_SUCCESS_URL.
+func NewFromEnv() (*Federation, error) {
+	configs, err := LoadConfigsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	successURL := os.Getenv("OIDC_LOGIN_SUCCESS_URL")
+	if successURL == "" {
+		successURL = "/"
+	}
+	return New(configs, successURL), nil
+}
+
+// RegisterRoutes registra os endpoints de login federado no grupo /api.
+func (f *Federation) RegisterRoutes(r gin.IRouter) {
+	r.GET("/login/oidc", f.ProvidersHandler)
+	r.GET("/login/oidc/:provider", f.LoginHandler)
+	r.GET("/login/oidc/:provider/callback", f.CallbackHandler)
+	r.POST("/me/identities/:provider", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.DenyImpersonation(),
+		middleware.RequireRecentAuth(), f.LinkHandler)
+}
+
+// ProvidersHandler lista os provedores disponíveis, para que o frontend exiba os botões de login.
+func (f *Federation) ProvidersHandler(c *gin.Context) {
+	list := make([]gin.H, 0, len(f.order))
+	for _, name := range f.order {
+		list = append(list, gin.H{
+			"name":         name,
+			"display_name": f.providers[name].config.DisplayName,
+			"login_url":    "/api/login/oidc/" + name,
+		})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// LoginHandler inicia o login: gera state, nonce e PKCE e redireciona para o provedor.
+func (f *Federation) LoginHandler(c *gin.Context) {
+	p, ok := f.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provedor de login não encontrado"})
+		return
+	}
+	authURL, err := f.begin(c, p, nil)
+	if errors.Is(err, errProviderUnavailable) {
+		f.fail(c, "provider_unavailable", "provedor de login indisponível")
+		return
+	}
+	if err != nil {
+		f.fail(c, "server_error", "erro ao iniciar o login")
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkHandler inicia o vínculo do provedor à conta do usuário autenticado. Como a requisição
+// é feita pelo frontend (com o token da API), a resposta traz a URL do provedor, para onde o
+// frontend deve navegar; o callback vincula a identidade e redireciona com #linked=<provedor>.
+func (f *Federation) LinkHandler(c *gin.Context) {
+	p, ok := f.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provedor de login não encontrado"})
+		return
+	}
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Operação disponível apenas com login interativo"})
+		return
+	}
+	authURL, err := f.begin(c, p, &userID)
+	if errors.Is(err, errProviderUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Provedor de login indisponível"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao iniciar o vínculo"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// errProviderUnavailable indica que a descoberta do provedor falhou.
+var errProviderUnavailable = errors.New("provedor de login indisponível")
+
+// begin gera state, nonce e PKCE, grava o estado (com o usuário que pediu o vínculo, se
+// linkUserID não for nil) e retorna a URL de autorização do provedor.
+func (f *Federation) begin(c *gin.Context, p *provider, linkUserID *uuid.UUID) (string, error) {
+	discovered, err := p.discover(c.Request.Context())
+	if err != nil {
+		log.Printf("ERROR: Falha na descoberta do provedor OIDC %s (%s): %v", p.config.Name, p.config.Issuer, err)
+		return "", errProviderUnavailable
+	}
+
+	state := rand.Text()
+	nonce := rand.Text()
+	verifier := oauth2.GenerateVerifier()
+	err = services.CreateFederatedLoginState(state, &models.FederatedLoginState{
+		Provider:     p.config.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(stateDuration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// O cookie amarra o state ao navegador que iniciou o login, evitando que um atacante
+	// faça a vítima concluir um login iniciado por ele (login CSRF).
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, int(stateDuration.Seconds()), "/api/login/oidc", "", strings.HasPrefix(p.config.RedirectURL, "https://"), true)
+
+	config := p.oauth2Config(discovered)
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// CallbackHandler conclui o login: troca o código, valida o ID token, resolve o usuário
+// local e devolve ao frontend um token da API.
+func (f *Federation) CallbackHandler(c *gin.Context) {
+	p, ok := f.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provedor de login não encontrado"})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, "", -1, "/api/login/oidc", "", strings.HasPrefix(p.config.RedirectURL, "https://"), true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		log.Printf("WARN: Provedor OIDC %s retornou erro no login: %s (%s)", p.config.Name, providerErr, c.Query("error_description"))
+		f.fail(c, "access_denied", "login cancelado ou negado pelo provedor")
+		return
+	}
+
+	stateParam := c.Query("state")
+	cookieState, _ := c.Cookie(stateCookie)
+	if stateParam == "" || subtle.ConstantTimeCompare([]byte(stateParam), []byte(cookieState)) != 1 {
+		log.Printf("WARN: State inválido no callback OIDC %s (IP: %s)", p.config.Name, c.ClientIP())
+		f.fail(c, "invalid_state", "sessão de login inválida ou expirada")
+		return
+	}
+	state, err := services.ConsumeFederatedLoginState(stateParam)
+	if err != nil || state.Provider != p.config.Name {
+		f.fail(c, "invalid_state", "sessão de login inválida ou expirada")
+		return
+	}
+
+	login, err := p.exchange(c.Request.Context(), c.Query("code"), state)
+	if err != nil {
+		log.Printf("WARN: Falha ao validar o login OIDC %s (IP: %s): %v", p.config.Name, c.ClientIP(), err)
+		f.fail(c, "invalid_token", "não foi possível validar a resposta do provedor")
+		return
+	}
+
+	if state.LinkUserID != nil {
+		f.completeLink(c, *state.LinkUserID, login)
+		return
+	}
+
+	user, _, err := services.LoginFederatedUser(login, p.config.AllowSignup)
+	switch {
+	case errors.Is(err, services.ErrFederatedEmailMissing), errors.Is(err, services.ErrFederatedEmailNotVerified):
+		f.fail(c, "email_not_verified", err.Error())
+		return
+	case errors.Is(err, services.ErrFederatedSignupDisabled):
+		f.fail(c, "signup_disabled", err.Error())
+		return
+	case errors.Is(err, services.ErrFederatedLinkRequired):
+		f.fail(c, "link_required", err.Error())
+		return
+	case err != nil:
+		f.fail(c, "server_error", "erro ao processar login")
+		return
+	}
+	if !user.Active {
+		log.Printf("WARN: Login federado em conta desativada (ID: %s).", user.ID)
+		f.fail(c, "account_disabled", "conta desativada")
+		return
+	}
+
+	token, err := auth.StartSession(user, auth.SessionInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()})
+	if err != nil {
+		f.fail(c, "server_error", err.Error())
+		return
+	}
+	log.Printf("INFO: Usuário ID %s autenticado via provedor OIDC %s.", user.ID, p.config.Name)
+	// No modo cookie, o token fica no cookie HttpOnly e não passa pela URL.
+	if middleware.SessionCookiesEnabled() {
+		if _, err := middleware.IssueSessionCookie(c, token); err != nil {
+			f.fail(c, "server_error", "erro ao iniciar sessão")
+			return
+		}
+		f.redirectToFrontend(c, url.Values{"session": {"cookie"}})
+		return
+	}
+	f.redirectToFrontend(c, url.Values{"token": {token}})
+}
+
+// completeLink conclui o vínculo pedido em LinkHandler. Nenhuma sessão é aberta: o usuário
+// já está autenticado no frontend.
+func (f *Federation) completeLink(c *gin.Context, userID uuid.UUID, login services.FederatedLogin) {
+	actor := middleware.AuditActor(c)
+	actor.Type, actor.UserID = models.AuditActorUser, &userID
+	err := services.LinkFederatedIdentity(actor, userID, login)
+	switch {
+	case errors.Is(err, services.ErrFederatedIdentityInUse):
+		log.Printf("WARN: Vínculo da identidade %s (%s) ao usuário ID %s recusado: já vinculada a outro usuário.", login.Provider, login.Subject, userID)
+		f.fail(c, "identity_in_use", err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		f.fail(c, "account_disabled", "conta desativada")
+	case err != nil:
+		f.fail(c, "server_error", "erro ao vincular o provedor")
+	default:
+		f.redirectToFrontend(c, url.Values{"linked": {login.Provider}})
+	}
+}
+
+// idTokenClaims são as claims do ID token usadas para identificar o usuário.
+type idTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // alguns provedores enviam como string
+	Name          string      `json:"name"`
+}
+
+// exchange troca o código por tokens e valida o ID token (assinatura, emissor, audiência,
+// validade e nonce).
+func (p *provider) exchange(ctx context.Context, code string, state models.FederatedLoginState) (services.FederatedLogin, error) {
+	if code == "" {
+		return services.FederatedLogin{}, errors.New("parâmetro code ausente")
+	}
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return services.FederatedLogin{}, err
+	}
+	config := p.oauth2Config(discovered)
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		return services.FederatedLogin{}, fmt.Errorf("troca do código: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return services.FederatedLogin{}, errors.New("resposta do provedor sem id_token")
+	}
+	idToken, err := discovered.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return services.FederatedLogin{}, fmt.Errorf("ID token inválido: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(state.Nonce)) != 1 {
+		return services.FederatedLogin{}, errors.New("nonce do ID token não corresponde")
+	}
+
+	var claims idTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return services.FederatedLogin{}, fmt.Errorf("claims do ID token: %w", err)
+	}
+	verified := p.config.TrustEmail
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = verified || v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		verified = verified || b
+	}
+
+	return services.FederatedLogin{
+		Provider:      p.config.Name,
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// fail devolve o erro ao frontend, que exibe a mensagem ao usuário.
+func (f *Federation) fail(c *gin.Context, code, description string) {
+	f.redirectToFrontend(c, url.Values{"error": {code}, "error_description": {description}})
+}
+
+// redirectToFrontend envia o resultado no fragmento da URL, que não é enviado a servidores
+// nem registrado em logs de acesso.
+func (f *Federation) redirectToFrontend(c *gin.Context, values url.Values) {
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, f.successURL+"#"+values.Encode())
+}
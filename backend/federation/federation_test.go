@@ -0,0 +1,311 @@
+package federation_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/federation"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	clientID   = "test-client"
+	successURL = "https://app.test/login/callback"
+)
+
+// mockOIDC é um provedor OpenID Connect mínimo: discovery, JWKS e endpoint de token.
+// O ID token emitido carrega as claims definidas em claims e o nonce/PKCE capturados
+// da URL de autorização.
+type mockOIDC struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	claims    jwt.MapClaims
+	nonce     string
+	challenge string
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDC{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "test-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   m.server.URL,
+			"aud":   clientID,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": m.nonce,
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, _ := token.SignedString(key)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func setupFederation(t *testing.T, allowSignup bool) (*gin.Engine, *mockOIDC) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	database.DB = db
+
+	mock := newMockOIDC(t)
+	f := federation.New([]federation.ProviderConfig{{
+		Name:        "mock",
+		DisplayName: "Mock",
+		Issuer:      mock.server.URL,
+		ClientID:    clientID,
+		Scopes:      []string{"openid", "email", "profile"},
+		RedirectURL: "http://api.test/api/login/oidc/mock/callback",
+		AllowSignup: allowSignup,
+	}}, successURL)
+
+	router := gin.New()
+	f.RegisterRoutes(router.Group("/api"))
+	return router, mock
+}
+
+// federatedLogin percorre o fluxo completo e retorna os parâmetros entregues ao frontend.
+func federatedLogin(t *testing.T, router *gin.Engine, mock *mockOIDC, claims jwt.MapClaims) url.Values {
+	mock.claims = claims
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/login/oidc/mock", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	return authorize(t, router, mock, w.Header().Get("Location"), w.Result().Cookies())
+}
+
+// authorize simula a autorização no provedor a partir da URL gerada pela API e entrega o
+// código ao callback, com o cookie de state.
+func authorize(t *testing.T, router *gin.Engine, mock *mockOIDC, location string, cookies []*http.Cookie) url.Values {
+	authURL, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, mock.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+	mock.nonce = authURL.Query().Get("nonce")
+	mock.challenge = authURL.Query().Get("code_challenge")
+	state := authURL.Query().Get("state")
+	require.NotEmpty(t, cookies)
+
+	req := httptest.NewRequest("GET", "/api/login/oidc/mock/callback?code=test-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(cookies[0])
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	redirect, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	result, err := url.ParseQuery(redirect.Fragment)
+	require.NoError(t, err)
+	return result
+}
+
+func TestFederatedLoginProvisionsAndLinks(t *testing.T) {
+	router, mock := setupFederation(t, true)
+	email := "maria." + uuid.NewString() + "@example.com"
+	claims := jwt.MapClaims{"sub": "ext-123", "email": email, "email_verified": true, "name": "Maria Lima"}
+
+	// Primeiro login: usuário criado e identidade vinculada.
+	result := federatedLogin(t, router, mock, claims)
+	require.NotEmpty(t, result.Get("token"), result.Get("error_description"))
+	var user models.User
+	require.NoError(t, database.DB.First(&user, "email = ?", email).Error)
+	assert.Equal(t, "Maria Lima", user.Name)
+	assert.NotNil(t, user.EmailVerifiedAt, "o e-mail da conta criada foi verificado pelo provedor")
+	var identity models.FederatedIdentity
+	require.NoError(t, database.DB.First(&identity, "subject = ?", "ext-123").Error)
+	assert.Equal(t, user.ID, identity.UserID)
+	assert.Equal(t, mock.server.URL, identity.Issuer)
+
+	// Segundo login com o mesmo subject, mesmo que o e-mail tenha mudado no provedor.
+	claims["email"] = "novo." + email
+	result = federatedLogin(t, router, mock, claims)
+	require.NotEmpty(t, result.Get("token"))
+	var count int64
+	database.DB.Model(&models.User{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Conta local cujo e-mail não foi confirmado (ex: cadastro público) não é vinculada
+	// automaticamente: quem controla o e-mail no provedor não recebe a conta.
+	existing := models.User{Name: "João", Email: "joao." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &existing, "senhaSegura123"))
+	claims = jwt.MapClaims{"sub": "ext-456", "email": strings.ToUpper(existing.Email), "email_verified": "true"}
+	result = federatedLogin(t, router, mock, claims)
+	assert.Equal(t, "link_required", result.Get("error"))
+	assert.Empty(t, result.Get("token"))
+
+	// Depois que a posse do e-mail é comprovada (login pelo link mágico), o vínculo é feito.
+	link := models.MagicLink{UserID: &existing.ID, Email: existing.Email, ExpiresAt: time.Now().Add(time.Minute)}
+	require.NoError(t, database.DB.Create(&link).Error)
+	_, err := services.ConsumeMagicLink(services.SystemActor, link.ID)
+	require.NoError(t, err)
+	result = federatedLogin(t, router, mock, claims)
+	require.NotEmpty(t, result.Get("token"), result.Get("error_description"))
+	var linked models.FederatedIdentity
+	require.NoError(t, database.DB.First(&linked, "subject = ?", "ext-456").Error)
+	assert.Equal(t, existing.ID, linked.UserID)
+
+	// A troca do e-mail desfaz a confirmação.
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, existing.ID, map[string]interface{}{"email": "outro." + existing.Email}))
+	stored, err := services.GetUserByID(existing.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.EmailVerifiedAt)
+}
+
+func TestFederatedIdentityLinkRequiresSession(t *testing.T) {
+	router, mock := setupFederation(t, false)
+	user := models.User{Name: "Paula", Email: "paula." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "senhaSegura123"))
+	other := models.User{Name: "Rui", Email: "rui." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &other, "senhaSegura123"))
+	startLink := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/me/identities/mock", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, startLink("").Code)
+
+	// Com a conta aberta, o usuário vincula uma identidade de e-mail diferente, mesmo não
+	// verificado pelo provedor.
+	token, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	w := startLink(token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	mock.claims = jwt.MapClaims{"sub": "ext-link", "email": "pessoal@example.net", "email_verified": false}
+	result := authorize(t, router, mock, response.AuthorizationURL, w.Result().Cookies())
+	assert.Equal(t, "mock", result.Get("linked"), result.Get("error_description"))
+	assert.Empty(t, result.Get("token"))
+	var identity models.FederatedIdentity
+	require.NoError(t, database.DB.First(&identity, "subject = ?", "ext-link").Error)
+	assert.Equal(t, user.ID, identity.UserID)
+	var entry models.AuditLog
+	require.NoError(t, database.DB.Where("action = ?", models.AuditActionIdentityLink).First(&entry).Error)
+	assert.Equal(t, user.ID, *entry.ActorID)
+	assert.Equal(t, user.ID.String(), entry.TargetID)
+
+	// O login seguinte pela identidade vinculada entra na conta.
+	result = federatedLogin(t, router, mock, mock.claims)
+	require.NotEmpty(t, result.Get("token"), result.Get("error_description"))
+
+	// A mesma identidade não pode ser vinculada a outro usuário.
+	otherToken, err := auth.StartSession(other, auth.SessionInfo{})
+	require.NoError(t, err)
+	w = startLink(otherToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	result = authorize(t, router, mock, response.AuthorizationURL, w.Result().Cookies())
+	assert.Equal(t, "identity_in_use", result.Get("error"))
+}
+
+func TestFederatedLoginRejections(t *testing.T) {
+	router, mock := setupFederation(t, false)
+
+	existing := models.User{Name: "Ana", Email: "ana." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &existing, "senhaSegura123"))
+
+	// E-mail não verificado nunca é usado para vincular contas.
+	result := federatedLogin(t, router, mock, jwt.MapClaims{"sub": "ext-1", "email": existing.Email, "email_verified": false})
+	assert.Equal(t, "email_not_verified", result.Get("error"))
+	assert.Empty(t, result.Get("token"))
+
+	// Sem cadastro automático, um e-mail desconhecido é recusado.
+	result = federatedLogin(t, router, mock, jwt.MapClaims{"sub": "ext-2", "email": "novo@example.com", "email_verified": true})
+	assert.Equal(t, "signup_disabled", result.Get("error"))
+
+	// Callback sem o cookie de state é recusado.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/login/oidc/mock/callback?code=test-code&state=forjado", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	fragment, _ := url.ParseQuery(location.Fragment)
+	assert.Equal(t, "invalid_state", fragment.Get("error"))
+}
+
+func TestFederatedLoginRejectsWrongNonce(t *testing.T) {
+	router, mock := setupFederation(t, true)
+
+	mock.claims = jwt.MapClaims{"sub": "ext-9", "email": "x@example.com", "email_verified": true}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/login/oidc/mock", nil))
+	authURL, _ := url.Parse(w.Header().Get("Location"))
+	mock.nonce = "outro-nonce"
+	mock.challenge = authURL.Query().Get("code_challenge")
+
+	req := httptest.NewRequest("GET", "/api/login/oidc/mock/callback?code=test-code&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	fragment, _ := url.ParseQuery(location.Fragment)
+	assert.Equal(t, "invalid_token", fragment.Get("error"))
+}
+
+func TestProvidersHandler(t *testing.T) {
+	router, _ := setupFederation(t, true)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/login/oidc", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"name":"mock","display_name":"Mock","login_url":"/api/login/oidc/mock"}]`, w.Body.String())
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// defaultAPITokenDays é a validade de um token criado sem expires_in_days.
+const defaultAPITokenDays = 90
+
+// apiTokenResponse é a representação de um token de acesso pessoal na API. O campo token
+// só é preenchido na resposta de criação.
+type apiTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func newAPITokenResponse(token models.APIToken) apiTokenResponse {
+	return apiTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.ScopeList(),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// currentUserID retorna o ID do usuário autenticado, definido pelo AuthMiddleware.
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// CreateAPITokenHandler cria um token de acesso pessoal para o usuário autenticado.
+// O valor do token é retornado apenas nesta resposta.
+func CreateAPITokenHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req models.APITokenCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPITokenDays
+	}
+	slices.Sort(req.Scopes)
+
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Scopes:    strings.Join(slices.Compact(req.Scopes), " "),
+		ExpiresAt: time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour),
+	}
+	plain, err := services.CreateAPIToken(middleware.AuditActor(c), &token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar token de API"})
+		return
+	}
+	response := newAPITokenResponse(token)
+	response.Token = plain
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPITokensHandler lista os tokens de acesso pessoal do usuário autenticado.
+func ListAPITokensHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	tokens, err := services.ListAPITokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tokens de API"})
+		return
+	}
+	response := make([]apiTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, newAPITokenResponse(token))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPITokenHandler revoga um token de acesso pessoal do usuário autenticado.
+func RevokeAPITokenHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de token inválido"})
+		return
+	}
+	if err := services.RevokeAPIToken(middleware.AuditActor(c), userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao revogar token de API"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revogado com sucesso"})
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTokenRouter(t *testing.T) (*gin.Engine, models.User, string) {
+	router := setupRouterAndTestDB(t)
+	protected := router.Group("/api/protected", middleware.AuthMiddleware())
+	protected.GET("/users", middleware.RequireScope(models.ScopeUsersRead), handlers.GetUsersHandler)
+	protected.DELETE("/users/:id", middleware.RequireScope(models.ScopeUsersWrite), handlers.DeleteUserHandler)
+	me := router.Group("/api/me", middleware.AuthMiddleware(), middleware.RequireSession())
+	me.GET("/tokens", handlers.ListAPITokensHandler)
+	me.POST("/tokens", handlers.CreateAPITokenHandler)
+	me.DELETE("/tokens/:id", handlers.RevokeAPITokenHandler)
+
+	user := models.User{Name: "CI Bot Owner", Email: "owner." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	jwt, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	return router, user, jwt
+}
+
+func performAuthRequest(router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reqBody).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.RemoteAddr = "203.0.113.7:41000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokenLifecycle(t *testing.T) {
+	router, user, session := setupTokenRouter(t)
+
+	// Criação: o valor do token só aparece nesta resposta.
+	w := performAuthRequest(router, "POST", "/api/me/tokens", session, gin.H{"name": "ci", "scopes": []string{"users:read"}, "expires_in_days": 30})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		ID        string    `json:"id"`
+		Prefix    string    `json:"prefix"`
+		Token     string    `json:"token"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "pat_"+created.Prefix+"_", created.Token[:len(created.Prefix)+5])
+	assert.Equal(t, []string{"users:read"}, created.Scopes)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), created.ExpiresAt, time.Minute)
+	var stored models.APIToken
+	require.NoError(t, database.DB.First(&stored, "id = ?", created.ID).Error)
+	assert.NotContains(t, created.Token, stored.SecretHash, "Only the hash is stored")
+
+	// Leitura permitida pelo escopo; escrita recusada.
+	w = performAuthRequest(router, "GET", "/api/protected/users", created.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+user.ID.String(), created.Token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "users:write")
+
+	// Um token de API não pode gerenciar tokens.
+	w = performAuthRequest(router, "POST", "/api/me/tokens", created.Token, gin.H{"name": "x", "scopes": []string{"users:write"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A listagem mostra o último uso, mas nunca o valor do token.
+	w = performAuthRequest(router, "GET", "/api/me/tokens", session, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Token)
+	var list []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.NotNil(t, list[0]["last_used_at"])
+	assert.Equal(t, "203.0.113.7", list[0]["last_used_ip"])
+
+	// Revogado, o token deixa de funcionar.
+	w = performAuthRequest(router, "DELETE", "/api/me/tokens/"+created.ID, session, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/protected/users", created.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "DELETE", "/api/me/tokens/"+created.ID, session, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPITokenRejections(t *testing.T) {
+	router, user, session := setupTokenRouter(t)
+
+	w := performAuthRequest(router, "POST", "/api/me/tokens", session, gin.H{"name": "x", "scopes": []string{"admin"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	token := models.APIToken{UserID: user.ID, Name: "old", Scopes: models.ScopeUsersRead, ExpiresAt: time.Now().Add(-time.Hour)}
+	expired, err := services.CreateAPIToken(services.SystemActor, &token)
+	require.NoError(t, err)
+	w = performAuthRequest(router, "GET", "/api/protected/users", expired, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Segredo adulterado com prefixo válido.
+	token = models.APIToken{UserID: user.ID, Name: "ok", Scopes: models.ScopeUsersRead, ExpiresAt: time.Now().Add(time.Hour)}
+	valid, err := services.CreateAPIToken(services.SystemActor, &token)
+	require.NoError(t, err)
+	w = performAuthRequest(router, "GET", "/api/protected/users", valid[:len(valid)-1]+"X", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Conta desativada invalida os tokens.
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"active": false}))
+	w = performAuthRequest(router, "GET", "/api/protected/users", valid, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
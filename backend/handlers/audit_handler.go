@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// Paginação de GET /api/audit.
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// auditLogResponse é a representação de um registro de auditoria, com o diff como JSON.
+type auditLogResponse struct {
+	models.AuditLog
+	Changes json.RawMessage `json:"changes,omitempty"`
+}
+
+// parseAuditFilter lê os filtros da query string. Em caso de erro, responde 400 e retorna false.
+func parseAuditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		TargetID:  c.Query("target_id"),
+		Action:    c.Query("action"),
+		RequestID: c.Query("request_id"),
+	}
+	for param, dest := range map[string]**uuid.UUID{"actor_id": &filter.ActorID, "impersonator_id": &filter.ImpersonatorID} {
+		if raw := c.Query(param); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " deve ser um UUID"})
+				return filter, false
+			}
+			*dest = &id
+		}
+	}
+	for param, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(param); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " deve estar no formato RFC 3339 (ex: 2024-01-31T00:00:00Z)"})
+				return filter, false
+			}
+			*dest = t
+		}
+	}
+	return filter, true
+}
+
+// ListAuditLogsHandler lista o log de auditoria, do mais recente para o mais antigo.
+// Filtros: actor_id, impersonator_id, target_id, action, request_id, from e to.
+// Paginação: page (a partir de 1) e page_size. Com ?format=csv, exporta todos os
+// registros filtrados (veja exportAuditLogs).
+func ListAuditLogsHandler(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+	case "csv":
+		exportAuditLogs(c, filter)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato não suportado. Use json ou csv."})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page deve ser um inteiro maior que zero"})
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultAuditPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxAuditPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("page_size deve ser um inteiro entre 1 e %d", maxAuditPageSize)})
+		return
+	}
+
+	entries, total, err := services.ListAuditLogs(filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar registros de auditoria"})
+		return
+	}
+	items := make([]auditLogResponse, len(entries))
+	for i, entry := range entries {
+		items[i] = auditLogResponse{AuditLog: entry}
+		if entry.Changes != "" {
+			items[i].Changes = json.RawMessage(entry.Changes)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// exportAuditLogs escreve os registros filtrados como CSV, em ordem cronológica, à medida
+// que são lidos do banco.
+func exportAuditLogs(c *gin.Context, filter services.AuditFilter) {
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor_type", "actor_id", "impersonator_id", "client_id", "action",
+		"target_type", "target_id", "changes", "ip", "user_agent", "request_id", "prev_hash", "hash"})
+	count := 0
+	err := services.ExportAuditLogs(filter, func(e models.AuditLog) error {
+		record := []string{
+			strconv.FormatUint(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339Nano), e.ActorType,
+			optionalUUID(e.ActorID), optionalUUID(e.ImpersonatorID), e.ClientID, e.Action,
+			e.TargetType, e.TargetID, e.Changes, e.IP, e.UserAgent, e.RequestID, e.PrevHash, e.Hash,
+		}
+		for i := range record {
+			record[i] = csvSafe(record[i])
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	w.Flush()
+	c.Writer.Flush()
+	if err != nil {
+		// O status 200 já foi enviado; só resta registrar a falha e encerrar a resposta.
+		log.Printf("ERROR: Exportação do log de auditoria interrompida após %d registros (IP: %s): %v", count, c.ClientIP(), err)
+	}
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// csvSafe evita que valores controlados pelo cliente (ex: user agent) sejam interpretados
+// como fórmulas ao abrir o CSV em planilhas.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
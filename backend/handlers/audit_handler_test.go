@@ -0,0 +1,145 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAuditRouter(t *testing.T) *gin.Engine {
+	router := setupImpersonationRouter(t)
+	users := router.Group("/api/protected/users", middleware.RequestID(), middleware.AuthMiddleware())
+	users.PUT("/:id", handlers.UpdateUserHandler)
+	users.DELETE("/:id", handlers.DeleteUserHandler)
+	router.GET("/api/audit", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin), handlers.ListAuditLogsHandler)
+	return router
+}
+
+type auditPage struct {
+	Items []struct {
+		ID             uint64                                `json:"id"`
+		ActorType      string                                `json:"actor_type"`
+		ActorID        string                                `json:"actor_id"`
+		ImpersonatorID string                                `json:"impersonator_id"`
+		Action         string                                `json:"action"`
+		TargetID       string                                `json:"target_id"`
+		Changes        map[string]map[string]json.RawMessage `json:"changes"`
+		IP             string                                `json:"ip"`
+		RequestID      string                                `json:"request_id"`
+	} `json:"items"`
+	Total int `json:"total"`
+}
+
+func getAuditPage(t *testing.T, router *gin.Engine, token, query string) auditPage {
+	w := performAuthRequest(router, "GET", "/api/audit?"+query, token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page auditPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func TestAuditLog(t *testing.T) {
+	router := setupAuditRouter(t)
+	admin := createSessionUser(t, models.RoleAdmin)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	user := createSessionUser(t, models.RoleUser)
+
+	// A criação (feita pelo sistema nos testes) é registrada com o estado inicial.
+	page := getAuditPage(t, router, adminToken, "target_id="+user.ID.String())
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.AuditActionUserCreate, page.Items[0].Action)
+	assert.Equal(t, models.AuditActorSystem, page.Items[0].ActorType)
+	assert.JSONEq(t, `"`+user.Email+`"`, string(page.Items[0].Changes["email"]["after"]))
+	assert.JSONEq(t, `"[REDACTED]"`, string(page.Items[0].Changes["password"]["after"]))
+
+	// Alteração: autor, diff apenas dos campos alterados e identificador da requisição.
+	body, _ := json.Marshal(gin.H{"name": "Nome Auditado"})
+	req, _ := http.NewRequest("PUT", "/api/protected/users/"+user.ID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("X-Request-ID", "req-audit-1")
+	req.RemoteAddr = "203.0.113.9:5000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "req-audit-1", w.Header().Get("X-Request-ID"))
+
+	page = getAuditPage(t, router, adminToken, "request_id=req-audit-1")
+	require.Len(t, page.Items, 1)
+	entry := page.Items[0]
+	assert.Equal(t, models.AuditActionUserUpdate, entry.Action)
+	assert.Equal(t, models.AuditActorUser, entry.ActorType)
+	assert.Equal(t, admin.ID.String(), entry.ActorID)
+	assert.Equal(t, "203.0.113.9", entry.IP)
+	assert.Equal(t, map[string]map[string]json.RawMessage{"name": {"before": json.RawMessage(`"Sessões"`), "after": json.RawMessage(`"Nome Auditado"`)}}, entry.Changes)
+
+	// Ações durante a personificação registram o administrador (claim act).
+	w = performAuthRequest(router, "POST", "/api/admin/users/"+user.ID.String()+"/impersonate", adminToken, gin.H{"reason": "chamado 77"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var impersonation struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &impersonation))
+	w = performAuthRequest(router, "PUT", "/api/protected/users/"+user.ID.String(), impersonation.Token, gin.H{"name": "Pelo Suporte"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	page = getAuditPage(t, router, adminToken, "impersonator_id="+admin.ID.String())
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, user.ID.String(), page.Items[0].ActorID)
+	page = getAuditPage(t, router, adminToken, "action=user.impersonate&actor_id="+admin.ID.String())
+	require.Len(t, page.Items, 1)
+	assert.JSONEq(t, `"chamado 77"`, string(page.Items[0].Changes["reason"]["after"]))
+
+	// Remoção: o registro sobrevive ao usuário. Paginação do mais recente ao mais antigo.
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+user.ID.String(), adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+user.ID.String(), adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	page = getAuditPage(t, router, adminToken, "target_id="+user.ID.String()+"&page_size=2&page=1")
+	assert.Equal(t, 5, page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, models.AuditActionUserDelete, page.Items[0].Action)
+	assert.Greater(t, page.Items[0].ID, page.Items[1].ID)
+	page = getAuditPage(t, router, adminToken, "target_id="+user.ID.String()+"&page_size=2&page=3")
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.AuditActionUserCreate, page.Items[0].Action)
+
+	// Exportação CSV, em ordem cronológica.
+	w = performAuthRequest(router, "GET", "/api/audit?format=csv&target_id="+user.ID.String(), adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.Equal(t, "action", records[0][6])
+	assert.Equal(t, models.AuditActionUserCreate, records[1][6])
+	assert.Equal(t, models.AuditActionUserDelete, records[5][6])
+
+	// Filtros inválidos e acesso de não administradores.
+	w = performAuthRequest(router, "GET", "/api/audit?from=ontem", adminToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	other := createSessionUser(t, models.RoleUser)
+	otherToken, err := auth.StartSession(other, auth.SessionInfo{})
+	require.NoError(t, err)
+	w = performAuthRequest(router, "GET", "/api/audit", otherToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// O log só aceita inserções.
+	var stored models.AuditLog
+	require.NoError(t, database.DB.First(&stored, "target_id = ?", user.ID.String()).Error)
+	assert.ErrorIs(t, database.DB.Delete(&stored).Error, models.ErrAuditLogImmutable)
+	assert.ErrorIs(t, database.DB.Model(&stored).Update("action", "x").Error, models.ErrAuditLogImmutable)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/imaging"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// DefaultAvatarMaxBytes é o tamanho máximo padrão da imagem enviada como avatar (5 MiB).
+const DefaultAvatarMaxBytes = 5 << 20
+
+// avatarMIMETypes são os formatos aceitos, identificados pelo conteúdo do arquivo (e não pelo
+// Content-Type informado pelo cliente).
+var avatarMIMETypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+var avatarMaxBytes int64 = DefaultAvatarMaxBytes
+
+// UseAvatarMaxBytes define o tamanho máximo da imagem enviada como avatar.
+func UseAvatarMaxBytes(maxBytes int64) {
+	avatarMaxBytes = maxBytes
+}
+
+// UpdateMyAvatarHandler substitui o avatar do usuário autenticado pela imagem enviada no campo
+// avatar de um formulário multipart/form-data, e responde com o usuário atualizado.
+func UpdateMyAvatarHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Folga para os cabeçalhos e delimitadores do formulário.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, avatarMaxBytes+64<<10)
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Imagem muito grande"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Envie a imagem no campo avatar (multipart/form-data)"})
+		}
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falha ao ler a imagem enviada"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, avatarMaxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falha ao ler a imagem enviada"})
+		return
+	}
+	if int64(len(data)) > avatarMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Imagem muito grande"})
+		return
+	}
+	if detected := mimetype.Detect(data); !mimetype.EqualsAny(detected.String(), avatarMIMETypes...) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Formato de imagem não suportado: " + detected.String(), "supported": avatarMIMETypes})
+		return
+	}
+
+	user, err := services.SetUserAvatar(c.Request.Context(), middleware.AuditActor(c), userID, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedImage), errors.Is(err, imaging.ErrImageTooLarge):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAvatarStorageDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Envio de avatares desabilitado"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar o avatar"})
+		}
+		return
+	}
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteMyAvatarHandler remove o avatar do usuário autenticado.
+func DeleteMyAvatarHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	user, err := services.DeleteUserAvatar(middleware.AuditActor(c), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover o avatar"})
+		return
+	}
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, user)
+}
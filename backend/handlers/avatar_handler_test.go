@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/blobstore"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func performAvatarUpload(router *gin.Engine, field string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile(field, "foto.png")
+	part.Write(data)
+	form.Close()
+	req, _ := http.NewRequest("PUT", "/api/me/avatar", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateMyAvatarHandler(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	user := models.User{Name: "Avatar", Email: "avatar." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	me := router.Group("/api/me", func(c *gin.Context) { c.Set("userID", user.ID.String()) })
+	me.PUT("/avatar", handlers.UpdateMyAvatarHandler)
+	me.DELETE("/avatar", handlers.DeleteMyAvatarHandler)
+
+	store := &blobstore.LocalStore{Dir: t.TempDir(), BaseURL: "/uploads"}
+	services.UseAvatarStore(store, []int{128, 32})
+	t.Cleanup(func() {
+		services.UseAvatarStore(nil, services.DefaultAvatarSizes)
+		handlers.UseAvatarMaxBytes(handlers.DefaultAvatarMaxBytes)
+	})
+	files := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(store.Dir, "avatars", user.ID.String(), "*"))
+		return matches
+	}
+
+	var photo bytes.Buffer
+	require.NoError(t, png.Encode(&photo, image.NewGray(image.Rect(0, 0, 300, 200))))
+
+	upload := func() models.User {
+		w := performAvatarUpload(router, "avatar", photo.Bytes())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var updated models.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		return updated
+	}
+	first := upload()
+	assert.True(t, strings.HasPrefix(first.AvatarURL, "/uploads/avatars/"+user.ID.String()+"/"), first.AvatarURL)
+	assert.True(t, strings.HasSuffix(first.AvatarURL, "-128.jpg"), "avatar_url aponta para a maior miniatura")
+	require.Len(t, files(), 2)
+	_, err := os.Stat(filepath.Join(store.Dir, strings.TrimPrefix(first.AvatarURL, "/uploads/")))
+	require.NoError(t, err)
+
+	// Um novo envio troca as chaves e remove as miniaturas anteriores.
+	second := upload()
+	assert.NotEqual(t, first.AvatarURL, second.AvatarURL)
+	assert.Len(t, files(), 2)
+	assert.Equal(t, first.Version+1, second.Version)
+
+	w := performAvatarUpload(router, "avatar", []byte("texto simples, não uma imagem"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	w = performAvatarUpload(router, "foto", photo.Bytes())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performAvatarUpload(router, "avatar", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	handlers.UseAvatarMaxBytes(int64(photo.Len() - 1))
+	w = performAvatarUpload(router, "avatar", photo.Bytes())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	req, _ := http.NewRequest("DELETE", "/api/me/avatar", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "avatar_url")
+	assert.Empty(t, files())
+}
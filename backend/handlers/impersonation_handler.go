@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// impersonateRequest é o corpo opcional de POST /api/users/:id/impersonate.
+type impersonateRequest struct {
+	Reason          string `json:"reason" binding:"omitempty,max=500"`
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1,max=60"`
+}
+
+// ImpersonateUserHandler emite um token de personificação do usuário :id para o
+// administrador autenticado. Outros administradores não podem ser personificados.
+func ImpersonateUserHandler(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return
+	}
+	var req impersonateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if targetID == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível personificar a si mesmo"})
+		return
+	}
+
+	actor, err := services.GetUserByID(actorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		return
+	}
+	target, err := services.GetUserByID(targetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		}
+		return
+	}
+	if target.Role == models.RoleAdmin {
+		log.Printf("WARN: Administrador ID %s tentou personificar o administrador ID %s (IP: %s).", actorID, targetID, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administradores não podem ser personificados"})
+		return
+	}
+	if !target.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Usuário desativado"})
+		return
+	}
+
+	token, session, err := auth.StartImpersonation(actor, target, auth.SessionInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}, time.Duration(req.DurationMinutes)*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Reason != "" {
+		log.Printf("INFO: [act=%s] Motivo da personificação do usuário ID %s: %q", actorID, targetID, req.Reason)
+	}
+	// Sem o registro de auditoria, a personificação não é concedida.
+	err = services.RecordAudit(middleware.AuditActor(c), models.AuditActionUserImpersonate, "user", targetID.String(),
+		map[string]services.AuditChange{
+			"session_id": {After: session.ID},
+			"reason":     {After: req.Reason},
+			"expires_at": {After: session.ExpiresAt},
+		})
+	if err != nil {
+		services.AbortSession(session.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar personificação"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"session_id": session.ID,
+		"expires_at": session.ExpiresAt,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupImpersonationRouter(t *testing.T) *gin.Engine {
+	router := setupSessionRouter(t)
+	router.POST("/api/admin/users/:id/impersonate", middleware.AuthMiddleware(), middleware.RequireSession(),
+		middleware.DenyImpersonation(), middleware.RequireRole(models.RoleAdmin), handlers.ImpersonateUserHandler)
+	router.POST("/api/me/tokens", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.DenyImpersonation(), handlers.CreateAPITokenHandler)
+	return router
+}
+
+func TestImpersonation(t *testing.T) {
+	router := setupImpersonationRouter(t)
+	admin := createSessionUser(t, models.RoleAdmin)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	user := createSessionUser(t, models.RoleUser)
+
+	w := performAuthRequest(router, "POST", "/api/admin/users/"+user.ID.String()+"/impersonate", adminToken, gin.H{"reason": "chamado 4521", "duration_minutes": 15})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Token     string `json:"token"`
+		SessionID string `json:"session_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	claims, err := auth.ParseToken(created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.UserID)
+	require.NotNil(t, claims.Act)
+	assert.Equal(t, admin.ID.String(), claims.Act.Subject)
+
+	// O token age como o usuário, e a sessão aparece para ele identificada.
+	w = performAuthRequest(router, "GET", "/api/me/sessions", created.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"impersonator_id":"`+admin.ID.String()+`"`)
+
+	// Durante a personificação não é possível criar tokens de API nem personificar outros.
+	w = performAuthRequest(router, "POST", "/api/me/tokens", created.Token, gin.H{"name": "x", "scopes": []string{"users:read"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Revogação: o administrador encerra a sessão de personificação.
+	w = performAuthRequest(router, "DELETE", "/api/admin/users/"+user.ID.String()+"/sessions/"+created.SessionID, adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", created.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Se o administrador perde o papel, os tokens de personificação deixam de valer.
+	w = performAuthRequest(router, "POST", "/api/admin/users/"+user.ID.String()+"/impersonate", adminToken, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, admin.ID, map[string]interface{}{"role": models.RoleUser}))
+	w = performAuthRequest(router, "GET", "/api/me/sessions", created.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestImpersonationRestrictions(t *testing.T) {
+	router := setupImpersonationRouter(t)
+	admin := createSessionUser(t, models.RoleAdmin)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	otherAdmin := createSessionUser(t, models.RoleAdmin)
+	user := createSessionUser(t, models.RoleUser)
+	userToken, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+
+	w := performAuthRequest(router, "POST", "/api/admin/users/"+otherAdmin.ID.String()+"/impersonate", adminToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performAuthRequest(router, "POST", "/api/admin/users/"+admin.ID.String()+"/impersonate", adminToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performAuthRequest(router, "POST", "/api/admin/users/"+otherAdmin.ID.String()+"/impersonate", userToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performAuthRequest(router, "POST", "/api/admin/users/"+user.ID.String()+"/impersonate", adminToken, gin.H{"duration_minutes": 600})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A claim act precisa corresponder à sessão: um token com act para uma sessão comum é
+	// recusado, assim como uma sessão de personificação usada sem act.
+	legit, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	claims, err := auth.ParseToken(legit)
+	require.NoError(t, err)
+	claims.Act = &auth.Actor{Subject: admin.ID.String()}
+	w = performAuthRequest(router, "GET", "/api/me/sessions", signTestToken(t, claims), nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	impersonation, _, err := auth.StartImpersonation(admin, user, auth.SessionInfo{}, 0)
+	require.NoError(t, err)
+	claims, err = auth.ParseToken(impersonation)
+	require.NoError(t, err)
+	claims.Act = nil
+	w = performAuthRequest(router, "GET", "/api/me/sessions", signTestToken(t, claims), nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", impersonation, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// signTestToken assina claims arbitrárias com a chave da aplicação.
+func signTestToken(t *testing.T, claims *auth.Claims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET_KEY")))
+	require.NoError(t, err)
+	return token
+}
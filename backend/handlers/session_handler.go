@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// sessionResponse é a representação de uma sessão de login na API. Current indica a sessão
+// usada na própria requisição.
+type sessionResponse struct {
+	ID          uuid.UUID `json:"id"`
+	DeviceLabel string    `json:"device_label"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Current     bool      `json:"current"`
+	// ImpersonatorID identifica o administrador, em sessões de personificação.
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"`
+}
+
+func listSessions(c *gin.Context, userID uuid.UUID) {
+	sessions, err := services.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar sessões"})
+		return
+	}
+	current := c.GetString("sessionID")
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, sessionResponse{
+			ID:             s.ID,
+			DeviceLabel:    s.DeviceLabel,
+			UserAgent:      s.UserAgent,
+			IP:             s.IP,
+			CreatedAt:      s.CreatedAt,
+			LastSeenAt:     s.LastSeenAt,
+			ExpiresAt:      s.ExpiresAt,
+			Current:        s.ID.String() == current,
+			ImpersonatorID: s.ImpersonatorID,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func revokeSession(c *gin.Context, userID uuid.UUID, param string) {
+	id, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sessão inválido"})
+		return
+	}
+	if err := services.RevokeSession(middleware.AuditActor(c), userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sessão não encontrada"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessão"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessão encerrada com sucesso"})
+}
+
+func revokeSessions(c *gin.Context, userID, keep uuid.UUID) {
+	count, err := services.RevokeOtherSessions(middleware.AuditActor(c), userID, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessões"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessões encerradas com sucesso", "revoked": count})
+}
+
+// targetUserID lê o usuário alvo das rotas administrativas e confere que ele existe.
+func targetUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return uuid.Nil, false
+	}
+	if _, err := services.GetUserByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		}
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ListMySessionsHandler lista as sessões ativas do usuário autenticado.
+func ListMySessionsHandler(c *gin.Context) {
+	if userID, ok := currentUserID(c); ok {
+		listSessions(c, userID)
+	}
+}
+
+// RevokeMySessionHandler encerra uma sessão do usuário autenticado. Encerrar a sessão atual
+// equivale a um logout.
+func RevokeMySessionHandler(c *gin.Context) {
+	if userID, ok := currentUserID(c); ok {
+		revokeSession(c, userID, "id")
+	}
+}
+
+// RevokeOtherSessionsHandler encerra todas as sessões do usuário autenticado, exceto a atual
+// ("sair de todos os outros dispositivos").
+func RevokeOtherSessionsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	current, err := uuid.Parse(c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sessão não identificada"})
+		return
+	}
+	revokeSessions(c, userID, current)
+}
+
+// WriteLoginResponse responde a um login bem-sucedido com o token no corpo ou, se useCookie,
+// no cookie de sessão. No modo cookie, o token não é exposto ao JavaScript: o corpo traz
+// apenas o token CSRF.
+func WriteLoginResponse(c *gin.Context, token string, useCookie bool) {
+	if !useCookie {
+		c.JSON(http.StatusOK, gin.H{"token": token})
+		return
+	}
+	csrfToken, err := middleware.IssueSessionCookie(c, token)
+	if err != nil {
+		log.Printf("ERROR: Falha ao emitir cookie de sessão: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao iniciar sessão"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"csrf_token": csrfToken})
+}
+
+// reauthenticateRequest é o corpo de POST /api/me/reauthenticate.
+type reauthenticateRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ReauthenticateHandler confirma a senha do usuário e emite um novo token da sessão atual
+// com auth_time atualizado, liberando as operações que exigem autenticação recente. No
+// modo cookie, o cookie de sessão é substituído.
+func ReauthenticateHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req reauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	value, _ := c.Get("session")
+	session, ok := value.(models.Session)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sessão não identificada"})
+		return
+	}
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		return
+	}
+	token, err := auth.Reauthenticate(user, session, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha incorreta"})
+		return
+	}
+	WriteLoginResponse(c, token, c.GetString("authTransport") == middleware.TransportCookie)
+}
+
+// LogoutHandler encerra a sessão atual e remove o cookie de sessão, se houver.
+func LogoutHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	sessionID, err := uuid.Parse(c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sessão não identificada"})
+		return
+	}
+	if err := services.RevokeSession(middleware.AuditActor(c), userID, sessionID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessão"})
+		return
+	}
+	middleware.ClearSessionCookie(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logout realizado com sucesso"})
+}
+
+// CSRFTokenHandler retorna o token CSRF da sessão atual, para o frontend recuperá-lo após
+// recarregar a página. Requisições com bearer token não usam token CSRF (retorna null).
+func CSRFTokenHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	if token := middleware.CurrentCSRFToken(c); token != "" {
+		c.JSON(http.StatusOK, gin.H{"csrf_token": token})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"csrf_token": nil})
+}
+
+// ListUserSessionsHandler lista as sessões de qualquer usuário (administradores).
+func ListUserSessionsHandler(c *gin.Context) {
+	if userID, ok := targetUserID(c); ok {
+		listSessions(c, userID)
+	}
+}
+
+// RevokeUserSessionHandler encerra uma sessão de qualquer usuário (administradores).
+func RevokeUserSessionHandler(c *gin.Context) {
+	if userID, ok := targetUserID(c); ok {
+		revokeSession(c, userID, "sessionId")
+	}
+}
+
+// RevokeUserSessionsHandler encerra todas as sessões de um usuário (administradores). Se o
+// alvo for o próprio administrador, a sessão atual é mantida.
+func RevokeUserSessionsHandler(c *gin.Context) {
+	userID, ok := targetUserID(c)
+	if !ok {
+		return
+	}
+	keep := uuid.Nil
+	if userID.String() == c.GetString("userID") {
+		keep, _ = uuid.Parse(c.GetString("sessionID"))
+	}
+	revokeSessions(c, userID, keep)
+}
@@ -0,0 +1,395 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/jsonpatch"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSessionRouter(t *testing.T) *gin.Engine {
+	router := setupRouterAndTestDB(t)
+	me := router.Group("/api/me", middleware.AuthMiddleware(), middleware.RequireSession())
+	me.GET("/sessions", handlers.ListMySessionsHandler)
+	me.DELETE("/sessions", handlers.RevokeOtherSessionsHandler)
+	me.DELETE("/sessions/:id", handlers.RevokeMySessionHandler)
+	admin := router.Group("/api/admin/users/:id/sessions", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin))
+	admin.GET("", handlers.ListUserSessionsHandler)
+	admin.DELETE("", handlers.RevokeUserSessionsHandler)
+	admin.DELETE("/:sessionId", handlers.RevokeUserSessionHandler)
+	return router
+}
+
+func createSessionUser(t *testing.T, role string) models.User {
+	user := models.User{Name: "Sessões", Email: "sessions." + uuid.NewString() + "@example.com", Role: role}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	return user
+}
+
+func TestMySessions(t *testing.T) {
+	router := setupSessionRouter(t)
+	user := createSessionUser(t, models.RoleUser)
+	laptop, err := auth.StartSession(user, auth.SessionInfo{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36", IP: "198.51.100.1"})
+	require.NoError(t, err)
+	phone, err := auth.StartSession(user, auth.SessionInfo{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Safari/604.1"})
+	require.NoError(t, err)
+	tablet, err := auth.StartSession(user, auth.SessionInfo{DeviceLabel: "Tablet da sala"})
+	require.NoError(t, err)
+
+	w := performAuthRequest(router, "GET", "/api/me/sessions", laptop, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var sessions []struct {
+		ID          string `json:"id"`
+		DeviceLabel string `json:"device_label"`
+		Current     bool   `json:"current"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 3)
+	labels := map[string]bool{}
+	var currentID, otherID string
+	for _, s := range sessions {
+		labels[s.DeviceLabel] = s.Current
+		if s.Current {
+			currentID = s.ID
+		} else if s.DeviceLabel == "Safari no iPhone" {
+			otherID = s.ID
+		}
+	}
+	assert.Equal(t, map[string]bool{"Chrome no Windows": true, "Safari no iPhone": false, "Tablet da sala": false}, labels)
+
+	// Encerrar uma sessão invalida o token correspondente imediatamente.
+	w = performAuthRequest(router, "DELETE", "/api/me/sessions/"+otherID, laptop, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", phone, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Sessão encerrada")
+
+	// "Sair de todos os outros dispositivos" mantém apenas a sessão atual.
+	w = performAuthRequest(router, "DELETE", "/api/me/sessions", laptop, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"revoked":1`)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", tablet, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", laptop, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Sessões de outros usuários não podem ser encerradas por aqui.
+	other := createSessionUser(t, models.RoleUser)
+	_, err = auth.StartSession(other, auth.SessionInfo{})
+	require.NoError(t, err)
+	otherSessions, err := services.ListSessions(other.ID)
+	require.NoError(t, err)
+	w = performAuthRequest(router, "DELETE", "/api/me/sessions/"+otherSessions[0].ID.String(), laptop, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Logout: encerrar a própria sessão.
+	w = performAuthRequest(router, "DELETE", "/api/me/sessions/"+currentID, laptop, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", laptop, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAdminSessions(t *testing.T) {
+	router := setupSessionRouter(t)
+	admin := createSessionUser(t, models.RoleAdmin)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	user := createSessionUser(t, models.RoleUser)
+	userToken, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	_, err = auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+
+	// Usuários comuns não acessam as rotas administrativas.
+	w := performAuthRequest(router, "GET", "/api/admin/users/"+admin.ID.String()+"/sessions", userToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	path := "/api/admin/users/" + user.ID.String() + "/sessions"
+	w = performAuthRequest(router, "GET", path, adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sessions []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	assert.Len(t, sessions, 2)
+
+	w = performAuthRequest(router, "DELETE", path+"/"+sessions[0]["id"].(string), adminToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "DELETE", path, adminToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", userToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Encerrar todas as próprias sessões pela rota administrativa mantém a atual.
+	w = performAuthRequest(router, "DELETE", "/api/admin/users/"+admin.ID.String()+"/sessions", adminToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "GET", "/api/admin/users/"+uuid.NewString()+"/sessions", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeactivatedUserSessions(t *testing.T) {
+	router := setupSessionRouter(t)
+	user := createSessionUser(t, models.RoleUser)
+	token, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	w := performAuthRequest(router, "GET", "/api/me/sessions", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Mesmo que uma sessão sobreviva à desativação, o token de um usuário inativo é recusado.
+	require.NoError(t, database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("active", false).Error)
+	w = performAuthRequest(router, "GET", "/api/me/sessions", token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Usuário inativo")
+
+	// A desativação pelos serviços encerra as sessões do usuário.
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"active": true}))
+	_, err = auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"active": false}))
+	sessions, err := services.ListSessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+// loginWithCookie emite o cookie de sessão como o LoginHandler faz com use_cookie=true e
+// retorna os cookies gravados e o token CSRF.
+func loginWithCookie(t *testing.T, user models.User) ([]*http.Cookie, string) {
+	token, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/login", nil)
+	csrfToken, err := middleware.IssueSessionCookie(c, token)
+	require.NoError(t, err)
+	require.NotEmpty(t, csrfToken)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.HttpOnly {
+			assert.Equal(t, token, cookie.Value)
+		} else {
+			assert.NotEqual(t, token, cookie.Value, "O JWT não deve ir para um cookie legível")
+		}
+	}
+	return w.Result().Cookies(), csrfToken
+}
+
+func performCookieRequest(router *gin.Engine, method, path string, cookies []*http.Cookie, csrfToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if csrfToken != "" {
+		req.Header.Set(middleware.CSRFHeader, csrfToken)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCookieSessionCSRF(t *testing.T) {
+	for _, mode := range []string{middleware.CSRFDoubleSubmit, middleware.CSRFSynchronizer} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv("AUTH_COOKIE_MODE", mode)
+			config, err := middleware.LoadCookieConfigFromEnv()
+			require.NoError(t, err)
+			middleware.UseSessionCookies(config)
+			t.Cleanup(func() { middleware.UseSessionCookies(nil) })
+
+			router := setupSessionRouter(t)
+			router.GET("/api/me/csrf", middleware.AuthMiddleware(), handlers.CSRFTokenHandler)
+			router.POST("/api/logout", middleware.AuthMiddleware(), middleware.RequireSession(), handlers.LogoutHandler)
+			user := createSessionUser(t, models.RoleUser)
+			cookies, csrfToken := loginWithCookie(t, user)
+			session := cookies[len(cookies)-1]
+			assert.Equal(t, "__Host-session", session.Name)
+			assert.True(t, session.HttpOnly)
+			assert.True(t, session.Secure)
+			assert.Equal(t, http.SameSiteLaxMode, session.SameSite)
+			if mode == middleware.CSRFDoubleSubmit {
+				require.Len(t, cookies, 2)
+				assert.False(t, cookies[0].HttpOnly, "O cookie CSRF precisa ser legível pelo frontend")
+				assert.Equal(t, csrfToken, cookies[0].Value)
+			} else {
+				assert.Len(t, cookies, 1)
+			}
+
+			// Leituras não exigem o token CSRF.
+			w := performCookieRequest(router, "GET", "/api/me/sessions", cookies, "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			w = performCookieRequest(router, "GET", "/api/me/csrf", cookies, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), csrfToken)
+
+			// Requisições que alteram estado sem o token, ou com outro token, são recusadas.
+			w = performCookieRequest(router, "DELETE", "/api/me/sessions", cookies, "")
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			w = performCookieRequest(router, "DELETE", "/api/me/sessions", cookies, "outro-token")
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			w = performCookieRequest(router, "DELETE", "/api/me/sessions", cookies, csrfToken)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			// Clientes com bearer token não precisam do token CSRF.
+			bearer, err := auth.StartSession(user, auth.SessionInfo{})
+			require.NoError(t, err)
+			w = performAuthRequest(router, "DELETE", "/api/me/sessions/"+uuid.NewString(), bearer, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			w = performAuthRequest(router, "GET", "/api/me/csrf", bearer, nil)
+			assert.JSONEq(t, `{"csrf_token": null}`, w.Body.String())
+
+			// O logout encerra a sessão e remove o cookie.
+			w = performCookieRequest(router, "POST", "/api/logout", cookies, csrfToken)
+			require.Equal(t, http.StatusOK, w.Code)
+			for _, cookie := range w.Result().Cookies() {
+				assert.Empty(t, cookie.Value)
+			}
+			w = performCookieRequest(router, "GET", "/api/me/sessions", cookies, "")
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+func TestCookieConfigValidation(t *testing.T) {
+	t.Setenv("AUTH_COOKIE_MODE", "")
+	config, err := middleware.LoadCookieConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	t.Setenv("AUTH_COOKIE_MODE", "cookie")
+	_, err = middleware.LoadCookieConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("AUTH_COOKIE_MODE", middleware.CSRFDoubleSubmit)
+	t.Setenv("AUTH_COOKIE_SECURE", "false")
+	t.Setenv("AUTH_COOKIE_SAMESITE", "none")
+	_, err = middleware.LoadCookieConfigFromEnv()
+	assert.Error(t, err)
+
+	// Sem Secure, o prefixo __Host- não é aceito pelos navegadores.
+	t.Setenv("AUTH_COOKIE_SAMESITE", "strict")
+	config, err = middleware.LoadCookieConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "session", config.Name)
+	assert.Equal(t, http.SameSiteStrictMode, config.SameSite)
+}
+
+// TestEmailChangeAuthorization confere que um usuário não pode alterar o e-mail (o login) da
+// conta de outro usuário, o que permitiria tomar a conta pelo link de login sem senha.
+func TestEmailChangeAuthorization(t *testing.T) {
+	router := setupSessionRouter(t)
+	protected := router.Group("/api/protected/users", middleware.AuthMiddleware())
+	protected.PUT("/:id", handlers.UpdateUserHandler)
+	protected.PATCH("/:id", handlers.PatchUserHandler)
+
+	attacker := createSessionUser(t, models.RoleUser)
+	victim := createSessionUser(t, models.RoleUser)
+	admin := createSessionUser(t, models.RoleAdmin)
+	attackerToken, err := auth.StartSession(attacker, auth.SessionInfo{})
+	require.NoError(t, err)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	path := "/api/protected/users/" + victim.ID.String()
+
+	w := performAuthRequest(router, "PUT", path, attackerToken, gin.H{"email": "atacante." + uuid.NewString() + "@example.com"})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	req, _ := http.NewRequest("PATCH", path, strings.NewReader(`{"email":"atacante.`+uuid.NewString()+`@example.com"}`))
+	req.Header.Set("Content-Type", jsonpatch.MergePatchContentType)
+	req.Header.Set("Authorization", "Bearer "+attackerToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	stored, err := services.GetUserByID(victim.ID)
+	require.NoError(t, err)
+	assert.Equal(t, victim.Email, stored.Email)
+
+	// Os demais campos continuam editáveis, e o próprio usuário e os administradores podem
+	// trocar o e-mail.
+	w = performAuthRequest(router, "PUT", path, attackerToken, gin.H{"name": "Outro Nome"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = performAuthRequest(router, "PUT", "/api/protected/users/"+attacker.ID.String(), attackerToken, gin.H{"email": "proprio." + uuid.NewString() + "@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = performAuthRequest(router, "PUT", path, adminToken, gin.H{"email": "admin." + uuid.NewString() + "@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestStepUpAuthentication(t *testing.T) {
+	router := setupSessionRouter(t)
+	protected := router.Group("/api/protected/users", middleware.AuthMiddleware())
+	protected.PUT("/:id", handlers.UpdateUserHandler)
+	protected.DELETE("/:id", middleware.RequireRecentAuth(), handlers.DeleteUserHandler)
+	router.POST("/api/me/reauthenticate", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.DenyImpersonation(), handlers.ReauthenticateHandler)
+
+	user := createSessionUser(t, models.RoleUser)
+	victim := createSessionUser(t, models.RoleUser)
+	fresh, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	claims, err := auth.ParseToken(fresh)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), claims.AuthTime.Time, time.Minute)
+
+	// Token de 1 hora atrás: operações comuns continuam permitidas; as sensíveis, não.
+	claims.AuthTime = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	stale := signTestToken(t, claims)
+	w := performAuthRequest(router, "PUT", "/api/protected/users/"+user.ID.String(), stale, gin.H{"name": "Novo Nome"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performAuthRequest(router, "PUT", "/api/protected/users/"+user.ID.String(), stale, gin.H{"email": "novo." + uuid.NewString() + "@example.com"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+victim.ID.String(), stale, nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"reauthentication_required"`)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_user_authentication"`)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "max_age=600")
+
+	// A reautenticação emite um token da mesma sessão com auth_time atualizado.
+	w = performAuthRequest(router, "POST", "/api/me/reauthenticate", stale, gin.H{"password": "senhaErrada"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "POST", "/api/me/reauthenticate", stale, gin.H{"password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	renewed, err := auth.ParseToken(response.Token)
+	require.NoError(t, err)
+	assert.Equal(t, claims.SessionID, renewed.SessionID)
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+victim.ID.String(), response.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Tokens de personificação não têm auth_time e não podem ser reautenticados.
+	admin := createSessionUser(t, models.RoleAdmin)
+	impersonation, _, err := auth.StartImpersonation(admin, user, auth.SessionInfo{}, 0)
+	require.NoError(t, err)
+	w = performAuthRequest(router, "DELETE", "/api/protected/users/"+admin.ID.String(), impersonation, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = performAuthRequest(router, "POST", "/api/me/reauthenticate", impersonation, gin.H{"password": "password123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Tokens de acesso pessoal e de clientes de serviço não comprovam autenticação recente.
+	apiToken := models.APIToken{UserID: user.ID, Name: "CI", Scopes: "users:read users:write", ExpiresAt: time.Now().Add(time.Hour)}
+	pat, err := services.CreateAPIToken(services.SystemActor, &apiToken)
+	require.NoError(t, err)
+	serviceToken, err := auth.GenerateServiceToken("svc-"+uuid.NewString(), "users:read users:write")
+	require.NoError(t, err)
+	for _, token := range []string{pat, serviceToken} {
+		w = performAuthRequest(router, "DELETE", "/api/protected/users/"+admin.ID.String(), token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"code":"reauthentication_required"`)
+	}
+	w = performAuthRequest(router, "PUT", "/api/protected/users/"+user.ID.String(), pat, gin.H{"email": "novo." + uuid.NewString() + "@example.com"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performAuthRequest(router, "PUT", "/api/protected/users/"+user.ID.String(), pat, gin.H{"name": "Nome via token"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = services.GetUserByID(admin.ID)
+	assert.NoError(t, err, "o administrador não deve ter sido removido")
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// schemaContentType é o tipo de mídia dos documentos JSON Schema.
+const schemaContentType = "application/schema+json"
+
+// maxAttributeSchemaBytes limita o tamanho do JSON Schema dos atributos (256 KiB).
+const maxAttributeSchemaBytes = 256 << 10
+
+// GetUserAttributeSchemaHandler retorna o JSON Schema dos atributos personalizados de usuários.
+func GetUserAttributeSchemaHandler(c *gin.Context) {
+	record, err := services.GetUserAttributeSchema()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar o schema de atributos"})
+		return
+	}
+	c.Data(http.StatusOK, schemaContentType, []byte(record.Schema))
+}
+
+// UpdateUserAttributeSchemaHandler substitui o JSON Schema dos atributos personalizados. O corpo
+// da requisição é o próprio schema.
+func UpdateUserAttributeSchemaHandler(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxAttributeSchemaBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Schema muito grande"})
+		return
+	}
+
+	record, err := services.SetUserAttributeSchema(middleware.AuditActor(c), body)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUserAttributeSchema) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gravar o schema de atributos"})
+		}
+		return
+	}
+	c.Data(http.StatusOK, schemaContentType, []byte(record.Schema))
+}
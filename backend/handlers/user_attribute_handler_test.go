@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/jsonpatch"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAttributes(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	router.GET("/api/users", handlers.GetUsersHandler)
+	router.PATCH("/api/users/:id", handlers.PatchUserHandler)
+	router.GET("/api/users/attributes/schema", handlers.GetUserAttributeSchemaHandler)
+	router.PUT("/api/users/attributes/schema", handlers.UpdateUserAttributeSchemaHandler)
+
+	// Sem schema configurado, nenhum atributo é aceito.
+	w := performRequest(router, "GET", "/api/users/attributes/schema", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, models.DefaultUserAttributeSchema, w.Body.String())
+	create := func(email string, attributes map[string]interface{}) *models.User {
+		w := performRequest(router, "POST", "/api/users", gin.H{"name": "Attr", "email": email, "password": "password123", "attributes": attributes})
+		if w.Code != http.StatusCreated {
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			return nil
+		}
+		var user models.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+		return &user
+	}
+	assert.Nil(t, create("attr.none."+uuid.NewString()+"@example.com", map[string]interface{}{"department": "TI"}))
+
+	schema := `{
+		"type": "object",
+		"properties": {
+			"department": {"type": "string"},
+			"employee_id": {"type": "integer", "minimum": 1},
+			"contractor": {"type": "boolean"}
+		},
+		"required": ["department"],
+		"additionalProperties": false
+	}`
+	w = performRawRequest(router, "PUT", "/api/users/attributes/schema", "application/schema+json", `{"type":"array"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRawRequest(router, "PUT", "/api/users/attributes/schema", "application/schema+json", `{"type":"object","properties":{"a":{"type":"inexistente"}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRawRequest(router, "PUT", "/api/users/attributes/schema", "application/schema+json", schema)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, schema, w.Body.String())
+
+	engineer := create("attr.eng."+uuid.NewString()+"@example.com", map[string]interface{}{"department": "Engenharia", "employee_id": 42, "contractor": true})
+	require.NotNil(t, engineer)
+	assert.Equal(t, "Engenharia", engineer.Attributes["department"])
+	require.NotNil(t, create("attr.sales."+uuid.NewString()+"@example.com", map[string]interface{}{"department": "Vendas", "contractor": false}))
+	w = performRequest(router, "POST", "/api/users", gin.H{"name": "Attr", "email": "attr.bad." + uuid.NewString() + "@example.com", "password": "password123", "attributes": gin.H{"department": "TI", "employee_id": "x"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "/employee_id")
+	w = performRequest(router, "POST", "/api/users", gin.H{"name": "Attr", "email": "attr.missing." + uuid.NewString() + "@example.com", "password": "password123"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	list := func(query string) []models.User {
+		w := performRequest(router, "GET", "/api/users?"+query, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var users []models.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+		return users
+	}
+	for query, expected := range map[string]int{
+		"attributes.department=Engenharia":                        1,
+		"attributes.employee_id=42":                               1,
+		"attributes.contractor=false":                             1,
+		"attributes.contractor=true&attributes.department=Vendas": 0,
+		"attributes.department=RH":                                0,
+	} {
+		assert.Len(t, list(query), expected, query)
+	}
+	w = performRequest(router, "GET", "/api/users?attributes.dep%27t=x", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Alterar os atributos os valida com o schema atual.
+	path := "/api/users/" + engineer.ID.String()
+	w = performRawRequest(router, "PATCH", path, jsonpatch.MergePatchContentType, `{"attributes":{"employee_id":0}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = performRawRequest(router, "PATCH", path, jsonpatch.MergePatchContentType, `{"attributes":{"employee_id":7,"contractor":null}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var patched models.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &patched))
+	assert.Equal(t, models.UserAttributes{"department": "Engenharia", "employee_id": float64(7)}, patched.Attributes)
+
+	// Os usuários existentes não são revalidados quando o schema muda.
+	w = performRawRequest(router, "PUT", "/api/users/attributes/schema", "application/schema+json", `{"type":"object","required":["cost_center"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = performRawRequest(router, "PATCH", path, jsonpatch.MergePatchContentType, `{"name":"Renomeado"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/events"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+const (
+	// userEventsHeartbeat é o intervalo dos comentários de keep-alive, quando também é
+	// revalidado o acesso de quem está conectado.
+	userEventsHeartbeat = 25 * time.Second
+	// userEventsMaxDuration encerra streams longos: o cliente reconecta (retomando pelo
+	// Last-Event-ID) e passa de novo pela autenticação completa.
+	userEventsMaxDuration = time.Hour
+	// userEventsBuffer é quantos eventos podem aguardar um cliente lento antes de o stream
+	// ser encerrado.
+	userEventsBuffer = 256
+)
+
+// errStreamAccess indica que a credencial de um stream deixou de ser válida.
+var errStreamAccess = errors.New("acesso ao stream revogado")
+
+var userEventHub *events.Hub
+
+// UseUserEventHub define a origem dos eventos de GET /api/users/events.
+func UseUserEventHub(hub *events.Hub) {
+	userEventHub = hub
+}
+
+// UserEventsHandler envia as criações, alterações e remoções de usuários como Server-Sent
+// Events (event: user.created, user.updated, user.suspended, user.deleted; id: ID do evento).
+// Com o cabeçalho Last-Event-ID (ou ?last_event_id=), reenvia os eventos posteriores ainda no
+// buffer; se não for possível retomar, envia um evento reset, e o cliente deve recarregar a
+// lista. Apenas administradores recebem o diff (changes) de cada alteração.
+func UserEventsHandler(c *gin.Context) {
+	if userEventHub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stream de eventos indisponível"})
+		return
+	}
+	admin, err := streamAccess(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credencial inválida ou revogada"})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	replay, resumed, stream, cancel := userEventHub.Subscribe(lastEventID, userEventsBuffer)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // desativa o buffer do nginx
+	c.Status(http.StatusOK)
+	c.Writer.WriteString("retry: 3000\n\n")
+	if !resumed {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{}})
+	}
+	for _, event := range replay {
+		writeUserEvent(c, event, admin)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(userEventsHeartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(userEventsMaxDuration)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case event, ok := <-stream:
+			if !ok {
+				// Cliente lento: o buffer estourou. Ao reconectar, ele retoma pelo Last-Event-ID.
+				return
+			}
+			writeUserEvent(c, event, admin)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if admin, err = streamAccess(c); err != nil {
+				return
+			}
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeUserEvent escreve um evento do outbox no stream, sem o diff para quem não é administrador.
+func writeUserEvent(c *gin.Context, event models.OutboxEvent, admin bool) {
+	data := json.RawMessage(event.Payload)
+	if !admin {
+		var payload services.UserEvent
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return
+		}
+		payload.Data.Changes = nil
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		data = encoded
+	}
+	c.Render(-1, sse.Event{Id: event.ID.String(), Event: event.EventType, Data: data})
+}
+
+// streamAccess revalida a credencial de quem abriu o stream (a sessão, o token de API e a
+// conta podem ter sido encerrados depois da conexão) e informa se é um administrador.
+func streamAccess(c *gin.Context) (bool, error) {
+	if raw := c.GetString("apiTokenID"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return false, errStreamAccess
+		}
+		if err := services.CheckAPIToken(id); err != nil {
+			return false, err
+		}
+	}
+	if c.GetString("authMethod") == middleware.AuthMethodSession {
+		id, err := uuid.Parse(c.GetString("sessionID"))
+		if err != nil {
+			return false, errStreamAccess
+		}
+		if _, err := services.GetSession(id); err != nil {
+			return false, err
+		}
+	}
+	raw := c.GetString("userID")
+	if raw == "" {
+		return false, nil // cliente de serviço, autorizado pelo escopo
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return false, errStreamAccess
+	}
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		return false, err
+	}
+	if !user.Active {
+		return false, errStreamAccess
+	}
+	return user.Role == models.RoleAdmin, nil
+}
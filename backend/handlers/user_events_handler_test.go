@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/events"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent é um evento lido do stream (campos event, id e data).
+type sseEvent map[string]string
+
+// openUserEvents conecta ao stream e retorna uma função que lê o próximo evento.
+func openUserEvents(t *testing.T, server *httptest.Server, token, lastEventID string) (*http.Response, func() sseEvent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/users/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	reader := bufio.NewReader(resp.Body)
+	return resp, func() sseEvent {
+		event := sseEvent{}
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			if line == "" {
+				if _, ok := event["event"]; ok {
+					return event
+				}
+				continue // bloco retry ou comentário
+			}
+			if field, value, ok := strings.Cut(line, ":"); ok && field != "" {
+				event[field] = strings.TrimPrefix(value, " ")
+			}
+		}
+	}
+}
+
+func TestUserEventsStream(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // o banco em memória do SQLite existe por conexão
+	router.GET("/api/users/events", middleware.AuthMiddleware(), middleware.RequireScope(models.ScopeUsersRead), handlers.UserEventsHandler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // executado depois de encerrar as conexões abertas pelo teste
+
+	admin := createSessionUser(t, models.RoleAdmin)
+	adminToken, err := auth.StartSession(admin, auth.SessionInfo{})
+	require.NoError(t, err)
+	user := createSessionUser(t, models.RoleUser)
+	userToken, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+
+	hub := events.NewHub(2, time.Hour)
+	hub.Poll()
+	handlers.UseUserEventHub(hub)
+	defer handlers.UseUserEventHub(nil)
+
+	resp, nextAdmin := openUserEvents(t, server, adminToken, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	_, nextUser := openUserEvents(t, server, userToken, "")
+
+	target := models.User{Name: "Ao Vivo", Email: "live." + uuid.NewString() + "@example.com", Active: true}
+	require.NoError(t, services.CreateUser(services.SystemActor, &target, "password123"))
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, target.ID, map[string]interface{}{"name": "Renomeado"}))
+	hub.Poll()
+
+	created := nextAdmin()
+	assert.Equal(t, models.EventUserCreated, created["event"])
+	updated := nextAdmin()
+	assert.Equal(t, models.EventUserUpdated, updated["event"])
+	var payload services.UserEvent
+	require.NoError(t, json.Unmarshal([]byte(updated["data"]), &payload))
+	assert.Equal(t, updated["id"], payload.ID.String())
+	assert.Equal(t, "Renomeado", payload.Data.User.Name)
+	assert.Contains(t, payload.Data.Changes, "name")
+
+	// Quem não é administrador recebe o usuário, mas não o diff.
+	nextUser()
+	forUser := nextUser()
+	assert.Equal(t, updated["id"], forUser["id"])
+	assert.NotContains(t, forUser["data"], "changes")
+
+	// Retomada pelo Last-Event-ID: reenvia os eventos posteriores ainda no buffer.
+	_, resumed := openUserEvents(t, server, adminToken, created["id"])
+	assert.Equal(t, updated["id"], resumed()["id"])
+
+	// Evento fora do buffer (limitado a 2 eventos): o cliente precisa recarregar a lista.
+	require.NoError(t, services.DeleteUser(services.SystemActor, target.ID))
+	hub.Poll()
+	assert.Equal(t, models.EventUserDeleted, nextAdmin()["event"])
+	_, stale := openUserEvents(t, server, adminToken, created["id"])
+	assert.Equal(t, "reset", stale()["event"])
+
+	// Sessão encerrada: a conexão é recusada.
+	claims, err := auth.ParseToken(userToken)
+	require.NoError(t, err)
+	require.NoError(t, services.RevokeSession(services.SystemActor, user.ID, uuid.MustParse(claims.SessionID)))
+	resp, _ = openUserEvents(t, server, userToken, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
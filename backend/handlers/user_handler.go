@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/middleware"
 	"github.com/monteirobsb/user-management/backend/models"
 	"github.com/monteirobsb/user-management/backend/services"
 	"gorm.io/gorm"
@@ -21,13 +25,22 @@ func CreateUserHandler(c *gin.Context) {
 	}
 
 	user := models.User{
-		Name:  req.Name,
-		Email: req.Email,
+		Name:       req.Name,
+		Email:      req.Email,
+		Attributes: req.Attributes,
+	}
+	// Atributos omitidos são validados como um objeto vazio (o schema pode exigir algum).
+	if user.Attributes == nil {
+		user.Attributes = models.UserAttributes{}
 	}
 
 	// A senha é passada separadamente para o serviço CreateUser.
 	// A validação de senha (ex: min length) é feita via tags em UserCreateRequest.
-	if err := services.CreateUser(&user, req.Password); err != nil {
+	if err := services.CreateUser(middleware.AuditActor(c), &user, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidUserAttributes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar usuário"})
 		return
 	}
@@ -35,9 +48,30 @@ func CreateUserHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
-// GetUsersHandler lida com a listagem de todos os usuários.
+// GetUsersHandler lida com a listagem de todos os usuários. Parâmetros attributes.<nome>=<valor>
+// filtram pelos atributos personalizados (todos devem corresponder).
 func GetUsersHandler(c *gin.Context) {
-	users, err := services.GetAllUsers()
+	filters := make(map[string]string)
+	for param, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(param, "attributes."); ok {
+			filters[name] = values[0]
+		}
+	}
+
+	var (
+		users []models.User
+		err   error
+	)
+	if len(filters) == 0 {
+		users, err = services.GetAllUsers()
+	} else {
+		scope, filterErr := services.UserAttributeFilter(filters)
+		if filterErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": filterErr.Error()})
+			return
+		}
+		users, _, err = services.ListUsers(scope, "", 0, -1)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuários"})
 		return
@@ -58,6 +92,7 @@ func GetUserHandler(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
 		return
 	}
+	c.Header("ETag", userETag(user))
 	c.JSON(http.StatusOK, user)
 }
 
@@ -89,23 +124,88 @@ func UpdateUserHandler(c *gin.Context) {
 		}
 		return
 	}
+	if !checkIfMatch(c, userToUpdate) {
+		return
+	}
 
 	// Aplicar atualizações se os campos foram fornecidos e validados
+	doc := models.NewUserPatchDocument(userToUpdate)
 	if req.Name != nil {
-		userToUpdate.Name = *req.Name
+		doc.Name = *req.Name
 	}
 	if req.Email != nil {
-		userToUpdate.Email = *req.Email
+		doc.Email = *req.Email
+	}
+	if req.Attributes != nil {
+		doc.Attributes = req.Attributes
+	}
+	saveUserProfile(c, userToUpdate, doc)
+}
+
+// saveUserProfile grava os campos editáveis de doc no usuário lido pelo handler (PUT ou PATCH)
+// e responde com o usuário atualizado e o novo ETag.
+func saveUserProfile(c *gin.Context, user models.User, doc models.UserPatchDocument) {
+	// Alterar o e-mail muda o login da conta (inclusive o do link de login sem senha): só o
+	// próprio usuário ou um administrador podem fazê-lo, e com autenticação recente.
+	if doc.Email != user.Email {
+		allowed, err := canChangeEmail(c, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+			return
+		}
+		if !allowed {
+			log.Printf("WARN: Alteração do e-mail do usuário ID %s recusada para %q (IP: %s).", user.ID, c.GetString("userID"), c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o próprio usuário ou um administrador pode alterar o e-mail"})
+			return
+		}
+		if !middleware.CheckRecentAuth(c) {
+			return
+		}
+	}
+	attributes := user.Attributes
+	doc.ApplyTo(&user)
+	// Atributos inalterados não são regravados nem revalidados: o schema pode ter mudado
+	// depois que foram salvos.
+	if reflect.DeepEqual(map[string]interface{}(attributes), map[string]interface{}(user.Attributes)) {
+		user.Attributes = nil
 	}
 
 	// Chamar o serviço para atualizar o usuário.
-	// A senha não é atualizada por este handler.
-	if err := services.UpdateUser(&userToUpdate, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+	// A senha não é atualizada por estes handlers. A atualização é condicionada à versão lida
+	// pelo handler: uma alteração concorrente entre a leitura e a escrita resulta em 412.
+	if err := services.UpdateUser(middleware.AuditActor(c), &user, user.ID); err != nil {
+		if errors.Is(err, services.ErrUserVersionMismatch) {
+			respondVersionMismatch(c)
+		} else if errors.Is(err, services.ErrInvalidUserAttributes) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+		}
 		return
 	}
 	// Retornar o usuário atualizado (PasswordHash tem json:"-")
-	c.JSON(http.StatusOK, userToUpdate)
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, user)
+}
+
+// canChangeEmail informa se o usuário autenticado pode alterar o e-mail de user: o próprio
+// usuário ou um administrador ativo. Clientes de serviço não têm usuário e são recusados.
+func canChangeEmail(c *gin.Context, user models.User) (bool, error) {
+	callerID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		return false, nil
+	}
+	if callerID == user.ID {
+		return true, nil
+	}
+	caller, err := services.GetUserByID(callerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return caller.Active && caller.Role == models.RoleAdmin, nil
 }
 
 // DeleteUserHandler lida com a remoção de um usuário.
@@ -118,9 +218,69 @@ func DeleteUserHandler(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteUser(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover usuário"})
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+		}
+		return
+	}
+	if !checkIfMatch(c, user) {
+		return
+	}
+
+	if err := services.DeleteUserAtVersion(middleware.AuditActor(c), id, user.Version); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		} else if errors.Is(err, services.ErrUserVersionMismatch) {
+			respondVersionMismatch(c)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover usuário"})
+		}
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Usuário removido com sucesso"})
 }
+
+// ifMatchRequired define se PUT e DELETE em /api/users/:id exigem o cabeçalho If-Match.
+var ifMatchRequired bool
+
+// RequireIfMatch define se as alterações de usuários exigem o cabeçalho If-Match (428 sem ele).
+// Quando opcional, o cabeçalho ainda é verificado se enviado.
+func RequireIfMatch(required bool) {
+	ifMatchRequired = required
+}
+
+// userETag retorna o ETag (forte) da representação de um usuário, derivado da sua versão.
+func userETag(user models.User) string {
+	return fmt.Sprintf(`"%d"`, user.Version)
+}
+
+// checkIfMatch compara o cabeçalho If-Match com o ETag atual do usuário, respondendo 428 se
+// ele é exigido e está ausente, ou 412 se nenhum dos ETags informados corresponde. Aceita
+// "*" e listas separadas por vírgula; ETags fracos (W/) nunca correspondem (RFC 9110).
+func checkIfMatch(c *gin.Context, user models.User) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if ifMatchRequired {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "Envie o cabeçalho If-Match com o ETag do usuário"})
+			return false
+		}
+		return true
+	}
+	current := userETag(user)
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+	respondVersionMismatch(c)
+	return false
+}
+
+// respondVersionMismatch responde 412 quando o usuário foi alterado depois da leitura do cliente.
+func respondVersionMismatch(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "O usuário foi alterado por outra requisição; recarregue-o e tente novamente"})
+}
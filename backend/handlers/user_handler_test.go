@@ -6,13 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/monteirobsb/user-management/backend/database"
 	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/middleware"
 	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -24,7 +28,7 @@ func setupRouterAndTestDB(t *testing.T) *gin.Engine {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	err = db.AutoMigrate(&models.User{})
+	err = database.Migrate(db)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database schema: %v", err)
 	}
@@ -34,7 +38,13 @@ func setupRouterAndTestDB(t *testing.T) *gin.Engine {
 	userRoutes := router.Group("/api/users")
 	{
 		userRoutes.POST("", handlers.CreateUserHandler)
-		userRoutes.PUT("/:id", handlers.UpdateUserHandler)
+		// Simula o AuthMiddleware com um login recente do próprio usuário, exigido para a troca
+		// de e-mail.
+		userRoutes.PUT("/:id", func(c *gin.Context) {
+			c.Set("userID", c.Param("id"))
+			c.Set("authMethod", middleware.AuthMethodSession)
+			c.Set("authTime", time.Now())
+		}, handlers.UpdateUserHandler)
 	}
 	return router
 }
@@ -196,3 +206,125 @@ func TestUpdateUserHandler_ValidationAndSuccess(t *testing.T) {
 		})
 	}
 }
+
+func TestUserOptimisticConcurrency(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	router.GET("/api/users/:id", handlers.GetUserHandler)
+	router.DELETE("/api/users/:id", handlers.DeleteUserHandler)
+	handlers.RequireIfMatch(true)
+	defer handlers.RequireIfMatch(false)
+
+	user := models.User{Name: "Versionado", Email: "version." + uuid.NewString() + "@example.com", Active: true}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	path := "/api/users/" + user.ID.String()
+	withIfMatch := func(method, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := withIfMatch("GET", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	// Sem If-Match, a alteração é recusada quando o cabeçalho é exigido.
+	w = withIfMatch("PUT", "", gin.H{"name": "Sem Versão"})
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	// Dois clientes editam a partir da mesma versão: apenas o primeiro é aplicado.
+	w = withIfMatch("PUT", etag, gin.H{"name": "Primeiro"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"version":2`)
+	w = withIfMatch("PUT", etag, gin.H{"name": "Segundo"})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	stored, err := services.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Primeiro", stored.Name)
+
+	// A verificação no serviço é atômica, mesmo que a leitura do handler já esteja desatualizada.
+	stale := stored
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"active": false}))
+	stale.Name = "Atrasado"
+	assert.ErrorIs(t, services.UpdateUser(services.SystemActor, &stale, user.ID), services.ErrUserVersionMismatch)
+
+	w = withIfMatch("DELETE", `"2"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = withIfMatch("DELETE", `W/"3", "3"`, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestCreateUserIdempotency(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	router.POST("/api/idempotent/users", middleware.Idempotency(), handlers.CreateUserHandler)
+	createFrom := func(remoteAddr, key string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/idempotent/users", bytes.NewReader(payload))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	create := func(key string, body interface{}) *httptest.ResponseRecorder {
+		return createFrom("198.51.100.10:40000", key, body)
+	}
+
+	key := uuid.NewString()
+	request := models.UserCreateRequest{Name: "Rede Instável", Email: "retry." + uuid.NewString() + "@example.com", Password: "password123"}
+	first := create(key, request)
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// A nova tentativa recebe a mesma resposta, sem criar outro usuário nem falhar pelo e-mail duplicado.
+	retry := create(key, request)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ?", request.Email).Count(&count)
+	assert.EqualValues(t, 1, count)
+
+	// Sem autenticação, as chaves são separadas pelo IP: outro cliente com a mesma chave e o
+	// mesmo corpo não recebe a resposta guardada (o cadastro é executado e falha pelo e-mail).
+	other := createFrom("203.0.113.20:40000", key, request)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	assert.NotContains(t, other.Body.String(), request.Email)
+
+	// Cabeçalhos guardados corrompidos resultam em erro, e não em uma resposta incompleta.
+	require.NoError(t, database.DB.Model(&models.IdempotencyKey{}).Where("key = ?", key).Update("header", "{").Error)
+	corrupted := create(key, request)
+	assert.Equal(t, http.StatusInternalServerError, corrupted.Code)
+	assert.Empty(t, corrupted.Header().Get("Idempotent-Replayed"))
+
+	// A mesma chave com outro corpo é recusada.
+	request.Name = "Outro Nome"
+	assert.Equal(t, http.StatusUnprocessableEntity, create(key, request).Code)
+
+	// Respostas de erro do cliente também são guardadas; erros internos liberam a chave.
+	invalidKey := uuid.NewString()
+	assert.Equal(t, http.StatusBadRequest, create(invalidKey, gin.H{"name": "Sem E-mail"}).Code)
+	assert.Equal(t, "true", create(invalidKey, gin.H{"name": "Sem E-mail"}).Header().Get("Idempotent-Replayed"))
+	failedKey := uuid.NewString()
+	duplicate := models.UserCreateRequest{Name: "Duplicado", Email: request.Email, Password: "password123"}
+	assert.Equal(t, http.StatusInternalServerError, create(failedKey, duplicate).Code)
+	var stored int64
+	database.DB.Model(&models.IdempotencyKey{}).Where("key = ?", failedKey).Count(&stored)
+	assert.Zero(t, stored)
+
+	// Uma repetição enquanto a original ainda está em andamento recebe 409.
+	pending, started, err := services.BeginIdempotentRequest("anonymous", uuid.NewString(), "impressao", time.Hour)
+	require.NoError(t, err)
+	require.True(t, started)
+	_, _, err = services.BeginIdempotentRequest("anonymous", pending.Key, "impressao", time.Hour)
+	assert.ErrorIs(t, err, services.ErrIdempotencyKeyInUse)
+}
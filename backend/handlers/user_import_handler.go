@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// maxImportBodyBytes limita o tamanho do arquivo aceito por ImportUsersHandler (50 MiB).
+const maxImportBodyBytes = 50 << 20
+
+// exportFlushEvery define a cada quantas linhas a exportação envia os dados ao cliente.
+const exportFlushEvery = 500
+
+// ImportUsersHandler lida com a importação em massa de usuários a partir de CSV ou NDJSON.
+// O formato é deduzido do Content-Type (text/csv ou application/x-ndjson) ou do parâmetro
+// ?format=csv|ndjson. Parâmetros opcionais: dry_run, upsert e batch_size.
+func ImportUsersHandler(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/jsonl", "application/json-lines":
+			format = "ndjson"
+		}
+	}
+
+	opts := services.UserImportOptions{
+		DryRun: c.Query("dry_run") == "true",
+		Upsert: c.Query("upsert") == "true",
+		Actor:  middleware.AuditActor(c),
+	}
+	if raw := c.Query("batch_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size <= 0 || size > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "batch_size deve ser um inteiro entre 1 e 1000"})
+			return
+		}
+		opts.BatchSize = size
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodyBytes)
+
+	var source services.UserImportSource
+	switch format {
+	case "csv":
+		var err error
+		source, err = services.NewCSVUserImportSource(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case "ndjson":
+		source = services.NewNDJSONUserImportSource(body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Formato não suportado. Use text/csv ou application/x-ndjson."})
+		return
+	}
+
+	summary, err := services.ImportUsers(source, opts)
+	if err != nil {
+		log.Printf("WARN: Importação de usuários interrompida (IP: %s): %v", c.ClientIP(), err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falha ao ler o arquivo de importação", "details": err.Error(), "summary": summary})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// ExportUsersHandler exporta todos os usuários como CSV ou NDJSON (?format=csv|ndjson),
+// escrevendo as linhas à medida que são lidas do banco.
+func ExportUsersHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	filename := fmt.Sprintf("users-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+
+	var (
+		write func(models.User) error
+		flush func()
+	)
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "name", "email", "created_at", "updated_at", "attributes"})
+		write = func(u models.User) error {
+			// Os atributos personalizados vão em uma única coluna, como objeto JSON.
+			attributes, err := json.Marshal(u.Attributes)
+			if err != nil {
+				return err
+			}
+			return w.Write([]string{u.ID.String(), u.Name, u.Email, u.CreatedAt.UTC().Format(time.RFC3339), u.UpdatedAt.UTC().Format(time.RFC3339), string(attributes)})
+		}
+		flush = w.Flush
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(u models.User) error { return enc.Encode(u) }
+		flush = func() {}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato não suportado. Use csv ou ndjson."})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	count := 0
+	err := services.ExportUsers(func(u models.User) error {
+		if err := write(u); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			flush()
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	flush()
+	c.Writer.Flush()
+	if err != nil {
+		// O status 200 já foi enviado; só resta registrar a falha e encerrar a resposta.
+		log.Printf("ERROR: Exportação de usuários interrompida após %d registros (IP: %s): %v", count, c.ClientIP(), err)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupImportRouter(t *testing.T) *gin.Engine {
+	router := setupRouterAndTestDB(t)
+	router.POST("/api/users/import", handlers.ImportUsersHandler)
+	router.GET("/api/users/export", handlers.ExportUsersHandler)
+	return router
+}
+
+func performRawRequest(router *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportUsersHandler_CSV(t *testing.T) {
+	assert := assert.New(t)
+	router := setupImportRouter(t)
+
+	existing := models.User{Name: "Existing", Email: "existing." + uuid.NewString() + "@example.com", PasswordHash: "dummyhash"}
+	assert.NoError(database.DB.Create(&existing).Error)
+
+	newEmail := "bulk." + uuid.NewString() + "@example.com"
+	csvBody := "name,email,password\n" +
+		"Bulk User," + newEmail + ",password123\n" +
+		"Renamed," + existing.Email + ",password123\n" +
+		",missing.name@example.com,password123\n" +
+		"Short,short@example.com,pass\n"
+
+	// Dry run: validation only, nothing written.
+	w := performRawRequest(router, "POST", "/api/users/import?dry_run=true", "text/csv", csvBody)
+	assert.Equal(http.StatusOK, w.Code)
+	var dry services.UserImportSummary
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &dry))
+	assert.True(dry.DryRun)
+	assert.Equal(4, dry.Total)
+	assert.Equal(1, dry.Created)
+	assert.Equal(3, dry.Failed, "Conflict on existing email and two invalid rows")
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ?", newEmail).Count(&count)
+	assert.Zero(count, "Dry run must not create users")
+
+	// Upsert: creates the new user and updates the existing one.
+	w = performRawRequest(router, "POST", "/api/users/import?upsert=true", "text/csv", csvBody)
+	assert.Equal(http.StatusOK, w.Code)
+	var summary services.UserImportSummary
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(1, summary.Created)
+	assert.Equal(1, summary.Updated)
+	assert.Equal(2, summary.Failed)
+
+	byLine := map[int]services.UserImportRowResult{}
+	for _, r := range summary.Rows {
+		byLine[r.Line] = r
+	}
+	assert.Equal(services.ImportStatusInvalid, byLine[4].Status)
+	assert.Contains(byLine[4].Errors, "Name")
+	assert.Contains(byLine[5].Errors, "Password")
+
+	var renamed models.User
+	assert.NoError(database.DB.First(&renamed, "id = ?", existing.ID).Error)
+	assert.Equal("Renamed", renamed.Name)
+	assert.Equal("dummyhash", renamed.PasswordHash, "Upsert must never replace the password of an existing account")
+}
+
+func TestImportUsersHandler_NDJSONAndExport(t *testing.T) {
+	assert := assert.New(t)
+	router := setupImportRouter(t)
+
+	email := "ndjson." + uuid.NewString() + "@example.com"
+	body := `{"name":"NDJSON User","email":"` + email + `","password":"password123"}` + "\n" +
+		"\n" +
+		`{not json}` + "\n"
+	w := performRawRequest(router, "POST", "/api/users/import", "application/x-ndjson", body)
+	assert.Equal(http.StatusOK, w.Code)
+	var summary services.UserImportSummary
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(1, summary.Created)
+	assert.Equal(1, summary.Failed)
+	assert.Equal(3, summary.Rows[1].Line, "Line numbers should account for blank lines")
+
+	w = performRawRequest(router, "POST", "/api/users/import", "application/xml", "<users/>")
+	assert.Equal(http.StatusUnsupportedMediaType, w.Code)
+
+	w = performRawRequest(router, "GET", "/api/users/export?format=ndjson", "", "")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Header().Get("Content-Disposition"), "attachment")
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var u models.User
+		assert.NoError(json.Unmarshal(scanner.Bytes(), &u))
+		if u.Email == email {
+			found = true
+		}
+	}
+	assert.True(found, "Imported user should appear in the export")
+	assert.NotContains(w.Body.String(), "password", "Export must not leak password hashes")
+
+	w = performRawRequest(router, "GET", "/api/users/export?format=csv", "", "")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.True(strings.HasPrefix(w.Body.String(), "id,name,email,created_at,updated_at,attributes\n"))
+	assert.Contains(w.Body.String(), email)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/jsonpatch"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// maxPatchBodyBytes limita o tamanho do patch aceito por PatchUserHandler (64 KiB).
+const maxPatchBodyBytes = 64 << 10
+
+// acceptPatch lista os formatos aceitos por PATCH /api/users/:id (cabeçalho Accept-Patch).
+var acceptPatch = strings.Join([]string{jsonpatch.MergePatchContentType, jsonpatch.JSONPatchContentType}, ", ")
+
+// PatchUserHandler aplica um JSON Merge Patch (application/merge-patch+json) ou um JSON Patch
+// (application/json-patch+json, incluindo operações test) à representação JSON do usuário.
+// Apenas os campos de models.UserPatchDocument podem ser alterados, e o documento resultante
+// passa pelas mesmas validações da atualização com PUT. Como o PUT, respeita If-Match.
+func PatchUserHandler(c *gin.Context) {
+	userIDParam := c.Param("id")
+	id, err := uuid.Parse(userIDParam)
+	if err != nil {
+		log.Printf("WARN: Tentativa de atualizar usuário com ID inválido: %s, erro: %v. IP: %s", userIDParam, err, c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != jsonpatch.MergePatchContentType && contentType != jsonpatch.JSONPatchContentType {
+		c.Header("Accept-Patch", acceptPatch)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Formato não suportado. Use " + acceptPatch + "."})
+		return
+	}
+	patch, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Patch muito grande"})
+		return
+	}
+
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+		}
+		return
+	}
+	if !checkIfMatch(c, user) {
+		return
+	}
+
+	original, err := json.Marshal(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+		return
+	}
+	var patched []byte
+	if contentType == jsonpatch.MergePatchContentType {
+		patched, err = jsonpatch.MergePatch(original, patch)
+	} else {
+		patched, err = jsonpatch.Apply(original, patch)
+	}
+	switch {
+	case errors.Is(err, jsonpatch.ErrInvalidPatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, err := readUserPatchDocument(original, patched)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	saveUserProfile(c, user, doc)
+}
+
+// readUserPatchDocument lê os campos editáveis do documento resultante do patch, recusando
+// alterações nos demais campos da representação (id, role, version etc.) e validando o
+// resultado com as regras de models.UserPatchDocument.
+func readUserPatchDocument(original, patched []byte) (models.UserPatchDocument, error) {
+	var doc models.UserPatchDocument
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil || after == nil {
+		return doc, errors.New("o resultado do patch deve ser um objeto JSON")
+	}
+
+	editable := userPatchFields()
+	for _, values := range []map[string]interface{}{before, after} {
+		for field := range values {
+			if !editable[field] && !reflect.DeepEqual(before[field], after[field]) {
+				return doc, fmt.Errorf("o campo %s não pode ser alterado", field)
+			}
+		}
+	}
+
+	if err := json.Unmarshal(patched, &doc); err != nil {
+		return doc, fmt.Errorf("valor inválido: %v", err)
+	}
+	if err := binding.Validator.ValidateStruct(&doc); err != nil {
+		return doc, err
+	}
+	return doc, nil
+}
+
+// userPatchFields retorna os membros JSON de models.UserPatchDocument (todos serializados,
+// inclusive os vazios), ou seja, os campos que um patch pode alterar.
+func userPatchFields() map[string]bool {
+	var fields map[string]interface{}
+	data, _ := json.Marshal(models.UserPatchDocument{})
+	json.Unmarshal(data, &fields)
+	editable := make(map[string]bool, len(fields))
+	for field := range fields {
+		editable[field] = true
+	}
+	return editable
+}
@@ -0,0 +1,55 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/jsonpatch"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchUserHandler(t *testing.T) {
+	router := setupRouterAndTestDB(t)
+	router.PATCH("/api/users/:id", handlers.PatchUserHandler)
+
+	user := models.User{Name: "Original", Email: "patch." + uuid.NewString() + "@example.com", Active: true}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	path := "/api/users/" + user.ID.String()
+
+	testCases := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedCode int
+		bodyContains string
+	}{
+		{"Merge Patch", jsonpatch.MergePatchContentType, `{"name":"Mesclado"}`, http.StatusOK, `"name":"Mesclado"`},
+		{"JSON Patch com test", jsonpatch.JSONPatchContentType, `[{"op":"test","path":"/name","value":"Mesclado"},{"op":"replace","path":"/name","value":"Substituído"}]`, http.StatusOK, `"name":"Substituído"`},
+		{"test falha", jsonpatch.JSONPatchContentType, `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/name","value":"Outro"}]`, http.StatusConflict, "test"},
+		{"Campo obrigatório removido", jsonpatch.MergePatchContentType, `{"name":null}`, http.StatusUnprocessableEntity, "Name"},
+		{"E-mail inválido", jsonpatch.JSONPatchContentType, `[{"op":"replace","path":"/email","value":"invalido"}]`, http.StatusUnprocessableEntity, "Email"},
+		{"Campo somente leitura", jsonpatch.MergePatchContentType, `{"role":"admin"}`, http.StatusUnprocessableEntity, "role"},
+		{"Tipo inválido", jsonpatch.MergePatchContentType, `{"name":42}`, http.StatusUnprocessableEntity, "inválido"},
+		{"Caminho inexistente", jsonpatch.JSONPatchContentType, `[{"op":"remove","path":"/nickname"}]`, http.StatusUnprocessableEntity, "caminho"},
+		{"Patch malformado", jsonpatch.JSONPatchContentType, `{"op":"add"}`, http.StatusBadRequest, "patch"},
+		{"Content-Type não suportado", "application/json", `{"name":"Json"}`, http.StatusUnsupportedMediaType, jsonpatch.MergePatchContentType},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := performRawRequest(router, "PATCH", path, tc.contentType, tc.body)
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tc.bodyContains)
+		})
+	}
+
+	stored, err := services.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Substituído", stored.Name)
+	assert.Equal(t, models.RoleUser, stored.Role)
+	assert.EqualValues(t, 3, stored.Version)
+}
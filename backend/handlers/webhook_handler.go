@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// Paginação de GET /api/webhooks/:id/deliveries.
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 500
+)
+
+// webhookResponse é a representação de uma assinatura na API. O segredo só é preenchido
+// na resposta de criação.
+type webhookResponse struct {
+	models.WebhookSubscription
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func newWebhookResponse(subscription models.WebhookSubscription) webhookResponse {
+	return webhookResponse{WebhookSubscription: subscription, Events: subscription.EventList()}
+}
+
+// joinEvents normaliza a lista de eventos para o formato armazenado (ordenada, sem repetições).
+func joinEvents(events []string) string {
+	slices.Sort(events)
+	return strings.Join(slices.Compact(events), " ")
+}
+
+// webhookID lê o ID da assinatura da rota e confirma que ela existe. Em caso de erro,
+// responde e retorna false.
+func webhookID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de webhook inválido"})
+		return uuid.Nil, false
+	}
+	if _, err := services.GetWebhook(id); err != nil {
+		respondWebhookError(c, err, "Erro ao buscar webhook")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondWebhookError(c *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook não encontrado"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// CreateWebhookHandler cria uma assinatura de webhook. O segredo usado na assinatura
+// HMAC-SHA256 das entregas é retornado apenas nesta resposta.
+func CreateWebhookHandler(c *gin.Context) {
+	var req models.WebhookCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	subscription := models.WebhookSubscription{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      joinEvents(req.Events),
+	}
+	secret, err := services.CreateWebhook(middleware.AuditActor(c), &subscription)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar webhook"})
+		return
+	}
+	response := newWebhookResponse(subscription)
+	response.Secret = secret
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListWebhooksHandler lista as assinaturas de webhook.
+func ListWebhooksHandler(c *gin.Context) {
+	subscriptions, err := services.ListWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar webhooks"})
+		return
+	}
+	response := make([]webhookResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		response = append(response, newWebhookResponse(subscription))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWebhookHandler retorna uma assinatura de webhook.
+func GetWebhookHandler(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de webhook inválido"})
+		return
+	}
+	subscription, err := services.GetWebhook(id)
+	if err != nil {
+		respondWebhookError(c, err, "Erro ao buscar webhook")
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(subscription))
+}
+
+// UpdateWebhookHandler altera a URL, a descrição, os eventos ou o estado de uma assinatura.
+// Reativar uma assinatura desativada por falhas zera a contagem de falhas; as entregas
+// pendentes voltam a ser enviadas.
+func UpdateWebhookHandler(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de webhook inválido"})
+		return
+	}
+	var req models.WebhookUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	columns := make(map[string]interface{})
+	if req.URL != nil {
+		columns["url"] = *req.URL
+	}
+	if req.Description != nil {
+		columns["description"] = *req.Description
+	}
+	if req.Events != nil {
+		columns["events"] = joinEvents(req.Events)
+	}
+	if req.Active != nil {
+		columns["active"] = *req.Active
+	}
+	if len(columns) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nenhum campo para atualizar"})
+		return
+	}
+	subscription, err := services.UpdateWebhook(middleware.AuditActor(c), id, columns)
+	if err != nil {
+		respondWebhookError(c, err, "Erro ao atualizar webhook")
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(subscription))
+}
+
+// DeleteWebhookHandler remove uma assinatura de webhook e o seu log de entregas.
+func DeleteWebhookHandler(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de webhook inválido"})
+		return
+	}
+	if err := services.DeleteWebhook(middleware.AuditActor(c), id); err != nil {
+		respondWebhookError(c, err, "Erro ao remover webhook")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook removido com sucesso"})
+}
+
+// ListWebhookDeliveriesHandler lista o log de entregas de uma assinatura, da mais recente
+// para a mais antiga. Filtro: status (pending, succeeded ou failed). Paginação: page e
+// page_size, como em GET /api/audit.
+func ListWebhookDeliveriesHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status deve ser pending, succeeded ou failed"})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page deve ser um inteiro maior que zero"})
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultDeliveryPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxDeliveryPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("page_size deve ser um inteiro entre 1 e %d", maxDeliveryPageSize)})
+		return
+	}
+
+	deliveries, total, err := services.ListWebhookDeliveries(id, status, (page-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar entregas do webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":     deliveries,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RedeliverWebhookHandler agenda o reenvio imediato do evento de uma entrega (com o mesmo
+// ID de evento). A nova entrega é retornada com status 202.
+func RedeliverWebhookHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de entrega inválido"})
+		return
+	}
+	delivery, err := services.RedeliverWebhook(id, deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entrega não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao agendar reentrega"})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,157 @@
+// Package imaging gera as miniaturas das imagens enviadas pelos usuários (avatares).
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registra os decodificadores aceitos por Thumbnails
+	"image/jpeg"
+	_ "image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels limita a resolução das imagens decodificadas (20 megapixels), para que um
+// arquivo pequeno e muito comprimido não consuma memória demais.
+const MaxPixels = 20_000_000
+
+// jpegQuality é a qualidade das miniaturas geradas.
+const jpegQuality = 85
+
+var (
+	// ErrUnsupportedImage indica um arquivo que não pôde ser decodificado como JPEG, PNG, GIF
+	// ou WebP.
+	ErrUnsupportedImage = errors.New("imagem inválida ou em formato não suportado")
+	// ErrImageTooLarge indica uma imagem com mais de MaxPixels.
+	ErrImageTooLarge = errors.New("resolução da imagem muito grande")
+)
+
+// Thumbnails gera, para cada tamanho em sizes, uma miniatura quadrada em JPEG com o recorte
+// central da imagem. A orientação EXIF das fotos JPEG é aplicada aos pixels; nenhum
+// metadado (EXIF, XMP, perfis) da imagem original é copiado para as miniaturas. Áreas
+// transparentes ficam brancas, e de GIFs animados só o primeiro quadro é usado.
+func Thumbnails(data []byte, sizes []int) (map[int][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	// O recorte central quadrado é o mesmo antes e depois de aplicar a orientação, então
+	// basta girar as miniaturas, que são bem menores que a imagem original.
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	orientation := jpegOrientation(data)
+
+	thumbnails := make(map[int][]byte, len(sizes))
+	for _, size := range sizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, xdraw.Over, nil)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orient(dst, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		thumbnails[size] = buf.Bytes()
+	}
+	return thumbnails, nil
+}
+
+// orient aplica a uma imagem quadrada a transformação indicada pela tag Orientation do EXIF
+// (1 a 8), para que ela seja exibida na posição em que a foto foi tirada.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	n := img.Bounds().Dx() - 1
+	dst := image.NewRGBA(img.Bounds())
+	for y := 0; y <= n; y++ {
+		for x := 0; x <= n; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // espelhada na horizontal
+				sx, sy = n-x, y
+			case 3: // girada 180°
+				sx, sy = n-x, n-y
+			case 4: // espelhada na vertical
+				sx, sy = x, n-y
+			case 5: // transposta
+				sx, sy = y, x
+			case 6: // girar 90° no sentido horário
+				sx, sy = y, n-x
+			case 7: // transversa
+				sx, sy = n-y, n-x
+			case 8: // girar 90° no sentido anti-horário
+				sx, sy = n-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation lê a tag Orientation (0x0112) do bloco EXIF de um JPEG. Retorna 1
+// (normal) para outros formatos ou se a tag não existir.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			break // início dos dados da imagem ou segmento corrompido
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation procura a tag Orientation no primeiro IFD de um bloco TIFF.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,98 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withOrientation insere logo após o SOI um bloco EXIF com a tag Orientation.
+func withOrientation(t *testing.T, jpegData []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = append(tiff, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	require.Equal(t, []byte{0xFF, 0xD8}, jpegData[:2])
+	return append(append([]byte{0xFF, 0xD8}, segment...), jpegData[2:]...)
+}
+
+func TestThumbnails(t *testing.T) {
+	// 300x100: vermelho à esquerda do centro e azul à direita; as bordas ficam fora do recorte.
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 150 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, src, &jpeg.Options{Quality: 95}))
+	photo := withOrientation(t, buf.Bytes(), 6)
+	assert.Equal(t, 6, jpegOrientation(photo))
+
+	thumbnails, err := Thumbnails(photo, []int{32, 64})
+	require.NoError(t, err)
+	require.Len(t, thumbnails, 2)
+	for size, data := range thumbnails {
+		assert.NotContains(t, string(data), "Exif", "os metadados não devem ser copiados")
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, size, size), img.Bounds())
+
+		// Orientação 6: a foto é girada 90° no sentido horário, levando o vermelho para cima.
+		top, _, _, _ := img.At(size/2, 2).RGBA()
+		_, _, bottom, _ := img.At(size/2, size-3).RGBA()
+		assert.Greater(t, top, uint32(0xC000), "vermelho em cima")
+		assert.Greater(t, bottom, uint32(0xC000), "azul embaixo")
+	}
+
+	// Transparência vira branco.
+	transparent := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	buf.Reset()
+	require.NoError(t, png.Encode(&buf, transparent))
+	thumbnails, err = Thumbnails(buf.Bytes(), []int{16})
+	require.NoError(t, err)
+	img, err := jpeg.Decode(bytes.NewReader(thumbnails[16]))
+	require.NoError(t, err)
+	r, g, b, _ := img.At(8, 8).RGBA()
+	assert.Greater(t, min(r, g, b), uint32(0xF000))
+
+	_, err = Thumbnails([]byte("não é uma imagem"), []int{16})
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+
+	// Cabeçalho PNG declarando 10000x10000 pixels, sem os dados da imagem.
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), 10000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 10000)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	huge := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), ihdr...)
+	huge = binary.BigEndian.AppendUint32(huge, crc32.ChecksumIEEE(ihdr))
+	_, err = Thumbnails(huge, []int{16})
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestOrient(t *testing.T) {
+	// Quadrado 2x2 com um pixel marcado no canto superior esquerdo.
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 255, 255, 255})
+	corners := map[int]image.Point{1: {0, 0}, 2: {1, 0}, 3: {1, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 1}, 8: {0, 1}}
+	for orientation, expected := range corners {
+		assert.Equal(t, uint8(255), orient(img, orientation).RGBAAt(expected.X, expected.Y).R, "orientação %d", orientation)
+	}
+}
@@ -0,0 +1,324 @@
+// Package jsonpatch aplica JSON Merge Patch (RFC 7396) e JSON Patch (RFC 6902) a documentos
+// JSON. Os patches são aplicados sobre uma cópia decodificada do documento: se qualquer
+// operação falhar, nenhuma alteração é aplicada.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Content-Types dos dois formatos de patch.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch indica um patch malformado (JSON inválido, operação desconhecida,
+	// membro obrigatório ausente ou ponteiro inválido).
+	ErrInvalidPatch = errors.New("patch inválido")
+	// ErrTestFailed indica que uma operação test não encontrou o valor esperado.
+	ErrTestFailed = errors.New("operação test falhou")
+	// ErrPathNotFound indica que o destino de uma operação não existe no documento.
+	ErrPathNotFound = errors.New("caminho inexistente no documento")
+)
+
+// MergePatch aplica um JSON Merge Patch (RFC 7396) a doc: membros com valor null são
+// removidos, objetos são mesclados recursivamente e os demais valores substituem os atuais.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var changes interface{}
+	if err := decode(patch, &changes); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(target, changes))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = make(map[string]interface{})
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+		} else {
+			object[key] = mergeValue(object[key], value)
+		}
+	}
+	return object
+}
+
+// Operation é uma operação de JSON Patch. Value é nil quando o membro value está ausente
+// (um null explícito é json.RawMessage("null")).
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply aplica um JSON Patch (RFC 6902) a doc: as operações add, remove, replace, move, copy e
+// test são executadas em ordem, e a primeira que falhar interrompe o patch inteiro.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := decode(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if root, err = op.apply(root); err != nil {
+			return nil, fmt.Errorf("operação %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func (op Operation) apply(root interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: a operação %s exige value", ErrInvalidPatch, op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("%w: não é possível mover um valor para dentro dele mesmo", ErrInvalidPatch)
+		}
+		if value, err = get(root, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if root, err = remove(root, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = clone(value)
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("%w: operação desconhecida %q", ErrInvalidPatch, op.Op)
+	}
+
+	switch op.Op {
+	case "remove":
+		return remove(root, path)
+	case "replace":
+		if _, err := get(root, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if root, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "test":
+		current, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	}
+	return add(root, path, value)
+}
+
+// parsePointer divide um JSON Pointer (RFC 6901) em seus tokens, já sem os escapes ~0 e ~1.
+// O ponteiro vazio ("") referencia o documento inteiro.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: ponteiro %q deve começar com /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex converte um token em índice de array com length elementos. Com end, aceita
+// também "-" e length (a posição após o último elemento, usada por add).
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if end && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%w: índice de array inválido %q", ErrInvalidPatch, token)
+	}
+	if i > length || (i == length && !end) {
+		return 0, ErrPathNotFound
+	}
+	return i, nil
+}
+
+// get retorna o valor em path.
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch current := node.(type) {
+		case map[string]interface{}:
+			value, ok := current[token]
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+			node = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(current), false)
+			if err != nil {
+				return nil, err
+			}
+			node = current[i]
+		default:
+			return nil, ErrPathNotFound
+		}
+	}
+	return node, nil
+}
+
+// update aplica fn ao contêiner que contém o último token de path, retornando node com a
+// alteração (arrays podem ser realocados, por isso cada nível é regravado no pai).
+func update(node interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	switch current := node.(type) {
+	case map[string]interface{}:
+		child, ok := current[path[0]]
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		current[path[0]] = updated
+		return current, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(current), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(current[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		current[i] = updated
+		return current, nil
+	}
+	return nil, ErrPathNotFound
+}
+
+// add insere value em path: em objetos, cria ou substitui o membro; em arrays, insere na
+// posição indicada, deslocando os elementos seguintes.
+func add(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(parent interface{}, token string) (interface{}, error) {
+		switch current := parent.(type) {
+		case map[string]interface{}:
+			current[token] = value
+			return current, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(current), true)
+			if err != nil {
+				return nil, err
+			}
+			current = append(current, nil)
+			copy(current[i+1:], current[i:])
+			current[i] = value
+			return current, nil
+		}
+		return nil, ErrPathNotFound
+	})
+}
+
+// remove remove o valor em path, que precisa existir.
+func remove(root interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: não é possível remover o documento inteiro", ErrInvalidPatch)
+	}
+	return update(root, path, func(parent interface{}, token string) (interface{}, error) {
+		switch current := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := current[token]; !ok {
+				return nil, ErrPathNotFound
+			}
+			delete(current, token)
+			return current, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(current), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(current[:i], current[i+1:]...), nil
+		}
+		return nil, ErrPathNotFound
+	})
+}
+
+// clone copia um valor decodificado, para que copy não compartilhe objetos e arrays.
+func clone(value interface{}) interface{} {
+	switch current := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(current))
+		for key, item := range current {
+			copied[key] = clone(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(current))
+		for i, item := range current {
+			copied[i] = clone(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// decode decodifica um patch, rejeitando conteúdo após o valor JSON.
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("conteúdo após o JSON")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package ldapauth autentica usuários corporativos em um servidor LDAP ou Active Directory
+// (busca seguida de bind), mantendo contas sombra locais em models.User.
+package ldapauth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// Valores padrão da configuração.
+const (
+	DefaultUserFilter     = "(|(mail={login})(userPrincipalName={login}))"
+	DefaultEmailAttribute = "mail"
+	DefaultNameAttribute  = "displayName"
+	DefaultGroupAttribute = "memberOf"
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Config é a configuração da conexão com o diretório.
+type Config struct {
+	URL                string // ldap://host:389 ou ldaps://host:636
+	StartTLS           bool   // executa StartTLS após conectar em ldap://
+	InsecureSkipVerify bool   // não valida o certificado do servidor (apenas para testes)
+	CACertFile         string // certificado da CA do servidor, em PEM
+
+	BindDN       string // conta de serviço usada na busca; vazio faz a busca anônima
+	BindPassword string
+	BaseDN       string
+	UserFilter   string // {login} é substituído pelo login informado, já escapado
+
+	EmailAttribute string
+	NameAttribute  string
+	UIDAttribute   string // atributo que identifica o usuário de forma estável; vazio usa o DN
+	GroupAttribute string
+
+	// GroupRoleMap associa o DN de um grupo do diretório a um papel local. Se estiver
+	// vazio, o papel das contas sombra não é alterado pelo diretório.
+	GroupRoleMap map[string]string
+	AllowSignup  bool // cria a conta sombra no primeiro login
+	Mode         string
+	Timeout      time.Duration
+}
+
+// LoadConfigFromEnv lê a configuração das variáveis LDAP_*.
+func LoadConfigFromEnv() (Config, error) {
+	config := Config{
+		URL:            os.Getenv("LDAP_URL"),
+		CACertFile:     os.Getenv("LDAP_CA_CERT_FILE"),
+		BindDN:         os.Getenv("LDAP_BIND_DN"),
+		BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:         os.Getenv("LDAP_BASE_DN"),
+		UserFilter:     os.Getenv("LDAP_USER_FILTER"),
+		EmailAttribute: os.Getenv("LDAP_EMAIL_ATTRIBUTE"),
+		NameAttribute:  os.Getenv("LDAP_NAME_ATTRIBUTE"),
+		UIDAttribute:   os.Getenv("LDAP_UID_ATTRIBUTE"),
+		GroupAttribute: os.Getenv("LDAP_GROUP_ATTRIBUTE"),
+		Mode:           os.Getenv("LDAP_MODE"),
+	}
+
+	var err error
+	if config.StartTLS, err = envBool("LDAP_START_TLS", false); err != nil {
+		return Config{}, err
+	}
+	if config.InsecureSkipVerify, err = envBool("LDAP_TLS_INSECURE_SKIP_VERIFY", false); err != nil {
+		return Config{}, err
+	}
+	if config.AllowSignup, err = envBool("LDAP_ALLOW_SIGNUP", true); err != nil {
+		return Config{}, err
+	}
+	if raw := os.Getenv("LDAP_TIMEOUT"); raw != "" {
+		if config.Timeout, err = time.ParseDuration(raw); err != nil {
+			return Config{}, fmt.Errorf("valor inválido para LDAP_TIMEOUT: %q", raw)
+		}
+	}
+	if config.GroupRoleMap, err = ParseGroupRoleMap(os.Getenv("LDAP_GROUP_ROLE_MAP")); err != nil {
+		return Config{}, err
+	}
+
+	config.applyDefaults()
+	return config, config.validate()
+}
+
+// ParseGroupRoleMap interpreta o mapeamento "DN do grupo=papel;DN do grupo=papel". Como o
+// DN também contém "=", o papel é o texto após o último "=".
+func ParseGroupRoleMap(raw string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(raw, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("LDAP_GROUP_ROLE_MAP: entrada inválida %q", pair)
+		}
+		group, role := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if role != models.RoleUser && role != models.RoleAdmin {
+			return nil, fmt.Errorf("LDAP_GROUP_ROLE_MAP: papel desconhecido %q para o grupo %s", role, group)
+		}
+		mapping[strings.ToLower(group)] = role
+	}
+	return mapping, nil
+}
+
+func (config *Config) applyDefaults() {
+	if config.UserFilter == "" {
+		config.UserFilter = DefaultUserFilter
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = DefaultEmailAttribute
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = DefaultNameAttribute
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = DefaultGroupAttribute
+	}
+	if config.Mode == "" {
+		config.Mode = auth.ExternalFirst
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+}
+
+func (config Config) validate() error {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return fmt.Errorf("LDAP_URL inválida: %q (use ldap://host:389 ou ldaps://host:636)", config.URL)
+	}
+	if config.StartTLS && u.Scheme == "ldaps" {
+		return errors.New("LDAP_START_TLS não se aplica a conexões ldaps://")
+	}
+	if config.BaseDN == "" {
+		return errors.New("LDAP_BASE_DN é obrigatória quando LDAP_URL está definida")
+	}
+	if !strings.Contains(config.UserFilter, "{login}") {
+		return errors.New("LDAP_USER_FILTER deve conter {login}")
+	}
+	if config.Mode != auth.ExternalFirst && config.Mode != auth.ExternalOnly {
+		return fmt.Errorf("LDAP_MODE inválido: %q (use first ou only)", config.Mode)
+	}
+	return nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("valor inválido para %s: %q", key, raw)
+	}
+	return v, nil
+}
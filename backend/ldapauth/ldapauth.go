@@ -0,0 +1,238 @@
+package ldapauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// ProviderName identifica as contas sombra do LDAP em federated_identities.
+const ProviderName = "ldap"
+
+// ErrInvalidCredentials indica que o usuário existe no diretório, mas a senha não confere.
+var ErrInvalidCredentials = errors.New("credenciais LDAP inválidas")
+
+// Conn é o subconjunto de ldap.Client usado na autenticação. Permite trocar o servidor
+// real pelo diretório em memória de ldaptest nos testes.
+type Conn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// Dialer abre uma conexão com o diretório, já protegida por TLS quando configurado.
+type Dialer func(config Config) (Conn, error)
+
+// Authenticator implementa auth.ExternalAuthenticator sobre um diretório LDAP.
+type Authenticator struct {
+	config Config
+	dial   Dialer
+}
+
+// New cria o autenticador. Se dial for nil, conecta ao servidor em config.URL.
+func New(config Config, dial Dialer) *Authenticator {
+	config.applyDefaults()
+	if dial == nil {
+		dial = DialServer
+	}
+	return &Authenticator{config: config, dial: dial}
+}
+
+// NewFromEnv cria o autenticador a partir das variáveis LDAP_*.
+func NewFromEnv() (*Authenticator, error) {
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if config.CACertFile != "" {
+		// Valida o certificado na inicialização, e não no primeiro login.
+		if _, err := config.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return New(config, nil), nil
+}
+
+// Mode retorna o modo configurado (auth.ExternalFirst ou auth.ExternalOnly).
+func (a *Authenticator) Mode() string {
+	return a.config.Mode
+}
+
+// Authenticate busca o usuário com a conta de serviço, faz o bind com o DN encontrado e a
+// senha informada e retorna a conta sombra local, criada ou atualizada neste login.
+func (a *Authenticator) Authenticate(login, plainPassword string) (models.User, error) {
+	// Um bind com senha vazia é um "unauthenticated bind", que muitos servidores aceitam.
+	if plainPassword == "" {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial(a.config)
+	if err != nil {
+		log.Printf("ERROR: Falha ao conectar ao servidor LDAP %s: %v", a.config.URL, err)
+		return models.User{}, fmt.Errorf("%w: %v", auth.ErrExternalUnavailable, err)
+	}
+	defer conn.Close()
+
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			log.Printf("ERROR: Falha no bind da conta de serviço LDAP %s: %v", a.config.BindDN, err)
+			return models.User{}, fmt.Errorf("%w: %v", auth.ErrExternalUnavailable, err)
+		}
+	}
+
+	entry, err := a.findUser(conn, login)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := conn.Bind(entry.DN, plainPassword); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			log.Printf("WARN: Senha LDAP incorreta para %s.", entry.DN)
+			return models.User{}, ErrInvalidCredentials
+		}
+		log.Printf("ERROR: Falha no bind LDAP de %s: %v", entry.DN, err)
+		return models.User{}, fmt.Errorf("%w: %v", auth.ErrExternalUnavailable, err)
+	}
+
+	return a.syncShadowAccount(entry)
+}
+
+// findUser busca a entrada do usuário. Exatamente uma entrada deve corresponder ao filtro.
+func (a *Authenticator) findUser(conn Conn, login string) (*ldap.Entry, error) {
+	attributes := []string{a.config.EmailAttribute, a.config.NameAttribute, a.config.GroupAttribute}
+	if a.config.UIDAttribute != "" {
+		attributes = append(attributes, a.config.UIDAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.config.Timeout.Seconds()), false,
+		strings.ReplaceAll(a.config.UserFilter, "{login}", ldap.EscapeFilter(login)),
+		attributes, nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		log.Printf("WARN: Login LDAP %s corresponde a mais de uma entrada; verifique LDAP_USER_FILTER.", login)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha na busca LDAP por %s: %v", login, err)
+		return nil, fmt.Errorf("%w: %v", auth.ErrExternalUnavailable, err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, auth.ErrExternalUserNotFound
+	case 1:
+		return result.Entries[0], nil
+	default:
+		// Ambíguo: recusar é mais seguro do que escolher uma das entradas.
+		log.Printf("WARN: Login LDAP %s corresponde a mais de uma entrada; verifique LDAP_USER_FILTER.", login)
+		return nil, ErrInvalidCredentials
+	}
+}
+
+// syncShadowAccount cria ou atualiza a conta sombra do usuário do diretório e aplica o
+// papel derivado dos grupos.
+func (a *Authenticator) syncShadowAccount(entry *ldap.Entry) (models.User, error) {
+	subject := entry.DN
+	if a.config.UIDAttribute != "" {
+		if uid := entry.GetEqualFoldAttributeValue(a.config.UIDAttribute); uid != "" {
+			subject = uid
+			// objectGUID do Active Directory é binário.
+			if !utf8.ValidString(uid) {
+				subject = hex.EncodeToString([]byte(uid))
+			}
+		}
+	}
+
+	user, _, err := services.LoginFederatedUser(services.FederatedLogin{
+		Provider:      ProviderName,
+		Issuer:        a.config.URL,
+		Subject:       subject,
+		Email:         strings.TrimSpace(entry.GetEqualFoldAttributeValue(a.config.EmailAttribute)),
+		EmailVerified: true, // o diretório corporativo é a fonte do e-mail
+		Name:          entry.GetEqualFoldAttributeValue(a.config.NameAttribute),
+	}, a.config.AllowSignup)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if len(a.config.GroupRoleMap) > 0 {
+		role := a.roleFor(entry.GetEqualFoldAttributeValues(a.config.GroupAttribute))
+		if role != user.Role {
+			if err := services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"role": role}); err != nil {
+				return models.User{}, err
+			}
+			log.Printf("INFO: Papel do usuário ID %s alterado de %q para %q pelos grupos LDAP.", user.ID, user.Role, role)
+			user.Role = role
+		}
+	}
+	return user, nil
+}
+
+// roleFor retorna o papel de maior privilégio entre os grupos mapeados do usuário, ou
+// models.RoleUser se nenhum grupo estiver mapeado.
+func (a *Authenticator) roleFor(groups []string) string {
+	for _, group := range groups {
+		if a.config.GroupRoleMap[strings.ToLower(group)] == models.RoleAdmin {
+			return models.RoleAdmin
+		}
+	}
+	return models.RoleUser
+}
+
+// DialServer conecta ao servidor em config.URL, usando LDAPS ou StartTLS conforme configurado.
+func DialServer(config Config) (Conn, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ldap.DialURL(config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: config.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(config.Timeout)
+	if config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (config Config) tlsConfig() (*tls.Config, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler LDAP_CA_CERT_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("LDAP_CA_CERT_FILE não contém certificados PEM válidos")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
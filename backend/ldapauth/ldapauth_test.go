@@ -0,0 +1,159 @@
+package ldapauth_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/ldapauth"
+	"github.com/monteirobsb/user-management/backend/ldapauth/ldaptest"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	baseDN     = "dc=corp,dc=example,dc=com"
+	serviceDN  = "cn=svc-login,ou=service," + baseDN
+	adminGroup = "CN=App Admins,OU=Groups,DC=corp,DC=example,DC=com"
+)
+
+func setupDirectory(t *testing.T, mode string) *ldaptest.Directory {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	database.DB = db
+
+	dir := &ldaptest.Directory{}
+	dir.Add(serviceDN, "svc-secret", map[string][]string{"cn": {"svc-login"}})
+	dir.Add("cn=Carla Dias,ou=people,"+baseDN, "ad-senha-1", map[string][]string{
+		"mail":        {"carla@corp.example.com"},
+		"displayName": {"Carla Dias"},
+		"objectGUID":  {"guid-carla"},
+		"memberOf":    {adminGroup},
+	})
+	dir.Add("cn=Bruno Reis,ou=people,"+baseDN, "ad-senha-2", map[string][]string{
+		"userPrincipalName": {"bruno@corp.example.com"},
+		"mail":              {"bruno.reis@corp.example.com"},
+		"displayName":       {"Bruno Reis"},
+		"objectGUID":        {"guid-bruno"},
+	})
+
+	groupRoles, err := ldapauth.ParseGroupRoleMap(adminGroup + "=admin")
+	require.NoError(t, err)
+	authenticator := ldapauth.New(ldapauth.Config{
+		URL:          "ldap://dc.corp.example.com",
+		BindDN:       serviceDN,
+		BindPassword: "svc-secret",
+		BaseDN:       baseDN,
+		UIDAttribute: "objectGUID",
+		GroupRoleMap: groupRoles,
+		AllowSignup:  true,
+		Mode:         mode,
+	}, dir.Dial)
+	auth.UseExternalAuthenticator(authenticator, authenticator.Mode())
+	t.Cleanup(func() { auth.UseExternalAuthenticator(nil, "") })
+	return dir
+}
+
+func TestLDAPLoginCreatesShadowAccount(t *testing.T) {
+	dir := setupDirectory(t, auth.ExternalFirst)
+
+	user, err := auth.AuthenticateUser("carla@corp.example.com", "ad-senha-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Carla Dias", user.Name)
+	assert.Equal(t, models.RoleAdmin, user.Role)
+	assert.Equal(t, []string{serviceDN, "cn=Carla Dias,ou=people," + baseDN}, dir.Binds())
+
+	var identity models.FederatedIdentity
+	require.NoError(t, database.DB.First(&identity, "provider = ? AND subject = ?", ldapauth.ProviderName, "guid-carla").Error)
+	assert.Equal(t, user.ID, identity.UserID)
+
+	// Login pelo userPrincipalName; a conta sombra usa o atributo mail.
+	user, err = auth.AuthenticateUser("bruno@corp.example.com", "ad-senha-2")
+	require.NoError(t, err)
+	assert.Equal(t, "bruno.reis@corp.example.com", user.Email)
+	assert.Equal(t, models.RoleUser, user.Role)
+
+	// O segundo login reutiliza a conta sombra e o token é emitido normalmente.
+	token, err := auth.LoginUser("carla@corp.example.com", "ad-senha-1", auth.SessionInfo{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	var count int64
+	database.DB.Model(&models.User{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestLDAPGroupRoleIsSyncedOnLogin(t *testing.T) {
+	dir := setupDirectory(t, auth.ExternalFirst)
+	dir.Add("cn=Davi Melo,ou=people,"+baseDN, "ad-senha-3", map[string][]string{
+		"mail": {"davi@corp.example.com"}, "objectGUID": {"guid-davi"},
+	})
+
+	// Conta local promovida manualmente perde o papel quando o diretório não o confirma.
+	user, err := auth.AuthenticateUser("davi@corp.example.com", "ad-senha-3")
+	require.NoError(t, err)
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"role": models.RoleAdmin}))
+	user, err = auth.AuthenticateUser("davi@corp.example.com", "ad-senha-3")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, user.Role)
+	stored, err := services.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, stored.Role)
+}
+
+func TestLDAPWrongPasswordDoesNotFallBack(t *testing.T) {
+	setupDirectory(t, auth.ExternalFirst)
+
+	// Uma conta local com o mesmo e-mail e outra senha não pode ser usada para contornar o diretório.
+	require.NoError(t, services.CreateUser(services.SystemActor, &models.User{Name: "Carla", Email: "carla@corp.example.com"}, "senhaLocal123"))
+	_, err := auth.AuthenticateUser("carla@corp.example.com", "senhaLocal123")
+	assert.Error(t, err)
+	_, err = auth.AuthenticateUser("carla@corp.example.com", "")
+	assert.Error(t, err)
+
+	// Caracteres especiais do filtro são escapados.
+	_, err = auth.AuthenticateUser("*", "ad-senha-1")
+	assert.Error(t, err)
+}
+
+func TestLDAPFirstModeFallsBackToLocal(t *testing.T) {
+	dir := setupDirectory(t, auth.ExternalFirst)
+	email := "local." + uuid.NewString() + "@example.com"
+	require.NoError(t, services.CreateUser(services.SystemActor, &models.User{Name: "Local", Email: email}, "senhaLocal123"))
+
+	// Usuário fora do diretório usa a senha local.
+	_, err := auth.AuthenticateUser(email, "senhaLocal123")
+	assert.NoError(t, err)
+
+	// Com o diretório fora do ar, contas locais continuam funcionando.
+	dir.SetDown(true)
+	_, err = auth.AuthenticateUser(email, "senhaLocal123")
+	assert.NoError(t, err)
+	_, err = auth.AuthenticateUser("carla@corp.example.com", "ad-senha-1")
+	assert.Error(t, err)
+}
+
+func TestLDAPOnlyModeRejectsLocalAccounts(t *testing.T) {
+	setupDirectory(t, auth.ExternalOnly)
+	email := "local." + uuid.NewString() + "@example.com"
+	require.NoError(t, services.CreateUser(services.SystemActor, &models.User{Name: "Local", Email: email}, "senhaLocal123"))
+
+	_, err := auth.AuthenticateUser(email, "senhaLocal123")
+	assert.Error(t, err)
+	_, err = auth.AuthenticateUser("carla@corp.example.com", "ad-senha-1")
+	assert.NoError(t, err)
+}
+
+func TestParseGroupRoleMap(t *testing.T) {
+	mapping, err := ldapauth.ParseGroupRoleMap("CN=Admins,OU=Groups,DC=corp=admin; cn=staff,dc=corp=user")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cn=admins,ou=groups,dc=corp": "admin", "cn=staff,dc=corp": "user"}, mapping)
+
+	_, err = ldapauth.ParseGroupRoleMap("cn=x,dc=corp=superuser")
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+// Package ldaptest fornece um diretório LDAP em memória, usado nos testes no lugar de um
+// servidor real. Ele implementa bind simples e busca com filtros and, or, not, igualdade,
+// presença e substrings, o suficiente para o fluxo de busca seguida de bind.
+package ldaptest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/monteirobsb/user-management/backend/ldapauth"
+)
+
+// ErrUnavailable é retornado por Dial enquanto o diretório está fora do ar.
+var ErrUnavailable = errors.New("ldaptest: diretório indisponível")
+
+type entry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// Directory é um diretório em memória. O valor zero está pronto para uso.
+type Directory struct {
+	mu      sync.Mutex
+	entries []entry
+	down    bool
+	binds   []string
+}
+
+// Add inclui uma entrada com a senha usada no bind (vazia impede o bind).
+func (d *Directory) Add(dn, password string, attributes map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, entry{dn: dn, password: password, attributes: attributes})
+}
+
+// SetDown simula a indisponibilidade do servidor.
+func (d *Directory) SetDown(down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down = down
+}
+
+// Binds retorna os DNs de todos os binds bem-sucedidos, em ordem.
+func (d *Directory) Binds() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.binds...)
+}
+
+// Dial implementa ldapauth.Dialer.
+func (d *Directory) Dial(ldapauth.Config) (ldapauth.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down {
+		return nil, ErrUnavailable
+	}
+	return &conn{dir: d}, nil
+}
+
+type conn struct {
+	dir    *Directory
+	closed bool
+}
+
+func (c *conn) Bind(username, password string) error {
+	if c.closed {
+		return ldap.NewError(ldap.ErrorNetwork, errors.New("ldaptest: conexão fechada"))
+	}
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	for _, e := range c.dir.entries {
+		if strings.EqualFold(e.dn, username) && e.password != "" && e.password == password {
+			c.dir.binds = append(c.dir.binds, e.dn)
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("ldaptest: invalid credentials"))
+}
+
+func (c *conn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.closed {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("ldaptest: conexão fechada"))
+	}
+	filter, err := ldap.CompileFilter(request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	result := &ldap.SearchResult{}
+	base := strings.ToLower(request.BaseDN)
+	for _, e := range c.dir.entries {
+		dn := strings.ToLower(e.dn)
+		if dn != base && !strings.HasSuffix(dn, ","+base) {
+			continue
+		}
+		ok, err := match(filter, e.attributes)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if request.SizeLimit > 0 && len(result.Entries) == request.SizeLimit {
+			return result, ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("ldaptest: size limit exceeded"))
+		}
+		result.Entries = append(result.Entries, ldap.NewEntry(e.dn, selectAttributes(e.attributes, request.Attributes)))
+	}
+	return result, nil
+}
+
+func (c *conn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func selectAttributes(attributes map[string][]string, names []string) map[string][]string {
+	if len(names) == 0 {
+		return attributes
+	}
+	selected := make(map[string][]string)
+	for _, name := range names {
+		if values := lookup(attributes, name); values != nil {
+			selected[name] = values
+		}
+	}
+	return selected
+}
+
+// lookup busca um atributo sem diferenciar maiúsculas, como no LDAP.
+func lookup(attributes map[string][]string, name string) []string {
+	for k, v := range attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// match avalia um filtro compilado por ldap.CompileFilter contra os atributos de uma entrada.
+func match(filter *ber.Packet, attributes map[string][]string) (bool, error) {
+	switch filter.Tag {
+	case ldap.FilterAnd, ldap.FilterOr:
+		for _, child := range filter.Children {
+			ok, err := match(child, attributes)
+			if err != nil {
+				return false, err
+			}
+			if ok == (filter.Tag == ldap.FilterOr) {
+				return ok, nil
+			}
+		}
+		return filter.Tag == ldap.FilterAnd, nil
+	case ldap.FilterNot:
+		ok, err := match(filter.Children[0], attributes)
+		return !ok, err
+	case ldap.FilterPresent:
+		return len(lookup(attributes, filter.Value.(string))) > 0, nil
+	case ldap.FilterEqualityMatch:
+		want := filter.Children[1].Value.(string)
+		for _, v := range lookup(attributes, filter.Children[0].Value.(string)) {
+			if strings.EqualFold(v, want) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		for _, v := range lookup(attributes, filter.Children[0].Value.(string)) {
+			if matchSubstrings(strings.ToLower(v), filter.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("ldaptest: filtro não suportado (%s)", ldap.FilterMap[uint64(filter.Tag)])
+	}
+}
+
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		sub := strings.ToLower(part.Value.(string))
+		switch part.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, sub) {
+				return false
+			}
+			value = value[len(sub):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, sub) {
+				return false
+			}
+			value = ""
+		default:
+			i := strings.Index(value, sub)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(sub):]
+		}
+	}
+	return true
+}
@@ -0,0 +1,301 @@
+// Package magiclink implementa o login sem senha: um link assinado e de uso único, com um
+// código de 6 dígitos como alternativa, enviados por e-mail.
+package magiclink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/mailer"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// Valores padrão da configuração.
+const (
+	DefaultTTL         = 15 * time.Minute
+	DefaultMaxRequests = 3
+	DefaultWindow      = 15 * time.Minute
+	// maxCodeAttempts é o número de códigos incorretos que invalida os pedidos do endereço.
+	maxCodeAttempts = 5
+)
+
+// Respostas genéricas: não revelam se o e-mail está cadastrado.
+const (
+	requestedMessage = "Se o e-mail estiver cadastrado, você receberá um link de acesso e um código."
+	invalidMessage   = "Link ou código inválido ou expirado"
+)
+
+// Config é a configuração do login sem senha.
+type Config struct {
+	LinkURL     string        // página do frontend que recebe o link (#token=...)
+	TTL         time.Duration // validade do link e do código
+	MaxRequests int           // pedidos por endereço dentro de Window
+	Window      time.Duration
+}
+
+// LoadConfigFromEnv lê a configuração das variáveis MAGIC_LINK_*.
+func LoadConfigFromEnv() (Config, error) {
+	config := Config{LinkURL: os.Getenv("MAGIC_LINK_URL"), TTL: DefaultTTL, MaxRequests: DefaultMaxRequests, Window: DefaultWindow}
+	if _, err := url.Parse(config.LinkURL); err != nil || config.LinkURL == "" {
+		return Config{}, fmt.Errorf("MAGIC_LINK_URL inválida: %q", config.LinkURL)
+	}
+	for key, target := range map[string]*time.Duration{"MAGIC_LINK_TTL": &config.TTL, "MAGIC_LINK_RATE_WINDOW": &config.Window} {
+		if raw := os.Getenv(key); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("valor inválido para %s: %q", key, raw)
+			}
+			*target = d
+		}
+	}
+	if raw := os.Getenv("MAGIC_LINK_MAX_REQUESTS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("valor inválido para MAGIC_LINK_MAX_REQUESTS: %q", raw)
+		}
+		config.MaxRequests = n
+	}
+	return config, nil
+}
+
+// MagicLinks expõe os handlers do login sem senha.
+type MagicLinks struct {
+	config Config
+	sender mailer.Sender
+	key    []byte
+}
+
+// New cria o serviço. secret é a chave da aplicação (JWT_SECRET_KEY); a chave que assina os
+// links e os códigos é derivada dela.
+func New(config Config, sender mailer.Sender, secret []byte) *MagicLinks {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("magic-link"))
+	return &MagicLinks{config: config, sender: sender, key: mac.Sum(nil)}
+}
+
+// NewFromEnv cria o serviço a partir das variáveis MAGIC_LINK_* e SMTP_*.
+func NewFromEnv() (*MagicLinks, error) {
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	sender, err := mailer.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(config, sender, []byte(os.Getenv("JWT_SECRET_KEY"))), nil
+}
+
+// RegisterRoutes registra os endpoints do login sem senha no grupo /api.
+func (m *MagicLinks) RegisterRoutes(r gin.IRouter) {
+	r.POST("/login/magic-link", m.RequestHandler)
+	r.POST("/login/magic-link/verify", m.VerifyHandler)
+}
+
+type requestPayload struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+}
+
+// RequestHandler envia o link e o código para o e-mail informado. A resposta é a mesma
+// para e-mails cadastrados ou não; o envio é feito em segundo plano para que o tempo de
+// resposta também não os diferencie.
+func (m *MagicLinks) RequestHandler(c *gin.Context) {
+	var payload requestPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload inválido ou dados ausentes", "details": err.Error()})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+
+	since := time.Now().Add(-m.config.Window)
+	count, err := services.CountMagicLinks(email, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar pedido"})
+		return
+	}
+	if count >= int64(m.config.MaxRequests) {
+		log.Printf("WARN: Limite de pedidos de login sem senha atingido para %s (IP: %s).", email, c.ClientIP())
+		c.Header("Retry-After", strconv.Itoa(int(m.config.Window.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Muitos pedidos para este endereço. Tente novamente mais tarde."})
+		return
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar pedido"})
+		return
+	}
+	link := models.MagicLink{
+		Email:     email,
+		CodeHash:  m.codeHash(email, code),
+		RequestIP: c.ClientIP(),
+		ExpiresAt: time.Now().Add(m.config.TTL),
+	}
+	// E-mails sem conta (ou de contas desativadas) também geram um registro, que nunca pode
+	// ser usado, mas conta no limite de pedidos.
+	user, err := services.FindUserByEmail(email)
+	if err == nil && user.Active {
+		link.UserID = &user.ID
+	}
+	if err := services.CreateMagicLink(&link, since); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar pedido"})
+		return
+	}
+	if link.UserID != nil {
+		log.Printf("INFO: Link de login sem senha enviado ao usuário ID %s (IP: %s).", user.ID, c.ClientIP())
+		go m.deliver(mailer.Message{
+			To:      user.Email,
+			Subject: "Seu link de acesso",
+			Body:    m.messageBody(user, m.signLink(link), code),
+		})
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": requestedMessage})
+}
+
+type verifyPayload struct {
+	Token      string `json:"token"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	Code       string `json:"code" binding:"omitempty,len=6,numeric"`
+	DeviceName string `json:"device_name" binding:"omitempty,max=255"`
+	UseCookie  bool   `json:"use_cookie"`
+}
+
+// VerifyHandler conclui o login com o token do link ou com e-mail e código, e emite o
+// mesmo token de sessão do login com senha.
+func (m *MagicLinks) VerifyHandler(c *gin.Context) {
+	var payload verifyPayload
+	if err := c.ShouldBindJSON(&payload); err != nil || (payload.Token == "" && (payload.Email == "" || payload.Code == "")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o token do link ou o e-mail e o código"})
+		return
+	}
+	if payload.UseCookie && !middleware.SessionCookiesEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Modo de sessão por cookie não está habilitado"})
+		return
+	}
+
+	var id uuid.UUID
+	var ok bool
+	if payload.Token != "" {
+		id, ok = m.verifyLink(payload.Token)
+	} else {
+		id, ok = m.verifyCode(strings.ToLower(strings.TrimSpace(payload.Email)), payload.Code)
+	}
+	if !ok {
+		log.Printf("WARN: Login sem senha recusado (IP: %s).", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": invalidMessage})
+		return
+	}
+	link, err := services.ConsumeMagicLink(middleware.AuditActor(c), id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": invalidMessage})
+		return
+	}
+	user, err := services.GetUserByID(*link.UserID)
+	if err != nil || !user.Active {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": invalidMessage})
+		return
+	}
+
+	token, err := auth.StartSession(user, auth.SessionInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP(), DeviceLabel: payload.DeviceName})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("INFO: Usuário ID %s autenticado por login sem senha.", user.ID)
+	handlers.WriteLoginResponse(c, token, payload.UseCookie)
+}
+
+// signLink gera o token do link: "<id>.<expiração>.<assinatura>".
+func (m *MagicLinks) signLink(link models.MagicLink) string {
+	payload := link.ID.String() + "." + strconv.FormatInt(link.ExpiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(m.sign(payload))
+}
+
+// verifyLink confere a assinatura e a validade do token do link e retorna o ID do pedido.
+func (m *MagicLinks) verifyLink(token string) (uuid.UUID, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return uuid.Nil, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(signature, m.sign(token[:i])) {
+		return uuid.Nil, false
+	}
+	rawID, rawExpiry, _ := strings.Cut(token[:i], ".")
+	expiry, err := strconv.ParseInt(rawExpiry, 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(rawID)
+	return id, err == nil
+}
+
+// verifyCode busca o pedido ativo do e-mail com o código informado. Códigos incorretos são
+// contados e, após maxCodeAttempts, os pedidos do endereço deixam de aceitar códigos.
+func (m *MagicLinks) verifyCode(email, code string) (uuid.UUID, bool) {
+	links, err := services.ActiveMagicLinks(email, maxCodeAttempts)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	hash := m.codeHash(email, code)
+	for _, link := range links {
+		if subtle.ConstantTimeCompare([]byte(link.CodeHash), []byte(hash)) == 1 {
+			return link.ID, true
+		}
+	}
+	services.RecordMagicCodeFailure(email)
+	return uuid.Nil, false
+}
+
+func (m *MagicLinks) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// codeHash vincula o código ao endereço: um código só vale para o e-mail a que foi enviado.
+func (m *MagicLinks) codeHash(email, code string) string {
+	return hex.EncodeToString(m.sign("code:" + email + ":" + code))
+}
+
+func (m *MagicLinks) messageBody(user models.User, token, code string) string {
+	link := m.config.LinkURL + "#" + url.Values{"token": {token}}.Encode()
+	minutes := int(m.config.TTL.Minutes())
+	return fmt.Sprintf("Olá, %s.\n\nUse o link abaixo para entrar (válido por %d minutos e apenas uma vez):\n%s\n\nOu informe o código: %s\n\nSe você não pediu este acesso, ignore este e-mail.\n",
+		user.Name, minutes, link, code)
+}
+
+func (m *MagicLinks) deliver(msg mailer.Message) {
+	if err := m.sender.Send(msg); err != nil {
+		log.Printf("ERROR: Falha ao enviar link de login sem senha: %v", err)
+	}
+}
+
+// randomCode gera um código numérico de 6 dígitos com distribuição uniforme.
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", errors.New("falha ao gerar código")
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,194 @@
+package magiclink_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/magiclink"
+	"github.com/monteirobsb/user-management/backend/mailer"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// outbox registra os e-mails enviados em segundo plano.
+type outbox chan mailer.Message
+
+func (o outbox) Send(msg mailer.Message) error {
+	o <- msg
+	return nil
+}
+
+func (o outbox) next(t *testing.T) mailer.Message {
+	select {
+	case msg := <-o:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("Nenhum e-mail enviado")
+		return mailer.Message{}
+	}
+}
+
+func setupMagicLinks(t *testing.T) (*gin.Engine, outbox, models.User) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	database.DB = db
+
+	sent := make(outbox, 10)
+	m := magiclink.New(magiclink.Config{
+		LinkURL:     "https://app.example.com/login/link",
+		TTL:         10 * time.Minute,
+		MaxRequests: 3,
+		Window:      15 * time.Minute,
+	}, sent, []byte("test-secret"))
+	router := gin.New()
+	m.RegisterRoutes(router.Group("/api"))
+
+	user := models.User{Name: "Eventual", Email: "eventual." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "senhaEsquecida1"))
+	return router, sent, user
+}
+
+func post(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(payload)))
+	return w
+}
+
+// parseMessage extrai o token do link e o código do e-mail.
+func parseMessage(t *testing.T, msg mailer.Message) (string, string) {
+	link := regexp.MustCompile(`https://\S+`).FindString(msg.Body)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	fragment, err := url.ParseQuery(u.Fragment)
+	require.NoError(t, err)
+	code := regexp.MustCompile(`código: (\d{6})`).FindStringSubmatch(msg.Body)
+	require.Len(t, code, 2)
+	return fragment.Get("token"), code[1]
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	router, sent, user := setupMagicLinks(t)
+
+	w := post(router, "/api/login/magic-link", gin.H{"email": strings.ToUpper(user.Email)})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	msg := sent.next(t)
+	assert.Equal(t, user.Email, msg.To)
+	token, _ := parseMessage(t, msg)
+
+	w = post(router, "/api/login/magic-link/verify", gin.H{"token": token})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	claims, err := auth.ParseToken(response.Token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.UserID)
+	assert.NotEmpty(t, claims.SessionID)
+
+	// O link é de uso único.
+	w = post(router, "/api/login/magic-link/verify", gin.H{"token": token})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Assinatura adulterada.
+	w = post(router, "/api/login/magic-link", gin.H{"email": user.Email})
+	require.Equal(t, http.StatusAccepted, w.Code)
+	token, _ = parseMessage(t, sent.next(t))
+	w = post(router, "/api/login/magic-link/verify", gin.H{"token": token[:len(token)-2] + "AA"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMagicCodeLogin(t *testing.T) {
+	router, sent, user := setupMagicLinks(t)
+
+	require.Equal(t, http.StatusAccepted, post(router, "/api/login/magic-link", gin.H{"email": user.Email}).Code)
+	token, code := parseMessage(t, sent.next(t))
+
+	// O código só vale para o endereço a que foi enviado.
+	w := post(router, "/api/login/magic-link/verify", gin.H{"email": "outro@example.com", "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = post(router, "/api/login/magic-link/verify", gin.H{"email": user.Email, "code": code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "token")
+
+	// Usado o código, o link do mesmo e-mail também deixa de valer.
+	w = post(router, "/api/login/magic-link/verify", gin.H{"token": token})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMagicCodeAttemptsAreLimited(t *testing.T) {
+	router, sent, user := setupMagicLinks(t)
+
+	require.Equal(t, http.StatusAccepted, post(router, "/api/login/magic-link", gin.H{"email": user.Email}).Code)
+	_, code := parseMessage(t, sent.next(t))
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < 5; i++ {
+		w := post(router, "/api/login/magic-link/verify", gin.H{"email": user.Email, "code": wrong})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	// Após 5 códigos incorretos, nem o código correto é aceito.
+	w := post(router, "/api/login/magic-link/verify", gin.H{"email": user.Email, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMagicLinkDoesNotEnumerateAccounts(t *testing.T) {
+	router, sent, user := setupMagicLinks(t)
+	unknown := "ninguem." + uuid.NewString() + "@example.com"
+
+	known := post(router, "/api/login/magic-link", gin.H{"email": user.Email})
+	missing := post(router, "/api/login/magic-link", gin.H{"email": unknown})
+	assert.Equal(t, known.Code, missing.Code)
+	assert.Equal(t, known.Body.String(), missing.Body.String())
+	sent.next(t)
+	select {
+	case msg := <-sent:
+		t.Fatalf("E-mail inesperado para %s", msg.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// O limite por endereço se aplica da mesma forma a e-mails cadastrados ou não.
+	for _, email := range []string{user.Email, unknown} {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusAccepted, post(router, "/api/login/magic-link", gin.H{"email": email}).Code)
+			if email == user.Email {
+				sent.next(t)
+			}
+		}
+		w := post(router, "/api/login/magic-link", gin.H{"email": email})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "900", w.Header().Get("Retry-After"))
+	}
+
+	// Contas desativadas não recebem link.
+	disabled := models.User{Name: "Desativado", Email: "off." + uuid.NewString() + "@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &disabled, "password123"))
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, disabled.ID, map[string]interface{}{"active": false}))
+	assert.Equal(t, http.StatusAccepted, post(router, "/api/login/magic-link", gin.H{"email": disabled.Email}).Code)
+	select {
+	case msg := <-sent:
+		t.Fatalf("E-mail inesperado para %s", msg.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,84 @@
+// Package mailer envia os e-mails transacionais da aplicação (ex: links de login) por SMTP.
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message é um e-mail em texto simples.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender envia mensagens. Implementações devem ser seguras para uso concorrente.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender envia mensagens por um servidor SMTP. A conexão usa STARTTLS quando o servidor
+// oferece; a autenticação (se configurada) exige TLS, exceto em localhost.
+type SMTPSender struct {
+	Addr     string // host:porta
+	Username string
+	Password string
+	From     string
+}
+
+// Send implementa Sender.
+func (s *SMTPSender) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("cabeçalho de e-mail inválido")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	header := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n",
+		s.From, msg.To, mime.QEncoding.Encode("UTF-8", msg.Subject), time.Now().Format(time.RFC1123Z))
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	return smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, []byte(header+body))
+}
+
+// LogSender apenas registra as mensagens no log. Serve para desenvolvimento, sem servidor
+// SMTP: o conteúdo (inclusive links de login) fica visível nos logs.
+type LogSender struct{}
+
+// Send implementa Sender.
+func (LogSender) Send(msg Message) error {
+	log.Printf("INFO: E-mail (não enviado, SMTP_HOST não definida) para %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// NewFromEnv cria o Sender a partir das variáveis SMTP_*. Sem SMTP_HOST, retorna um LogSender.
+func NewFromEnv() (Sender, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Print("WARN: SMTP_HOST não definida; e-mails serão apenas registrados no log (use somente em desenvolvimento).")
+		return LogSender{}, nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, errors.New("SMTP_FROM é obrigatória quando SMTP_HOST está definida")
+	}
+	return &SMTPSender{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}, nil
+}
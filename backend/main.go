@@ -1,21 +1,39 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/blobstore"
 	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/events"
+	"github.com/monteirobsb/user-management/backend/federation"
 	"github.com/monteirobsb/user-management/backend/handlers"
+	"github.com/monteirobsb/user-management/backend/ldapauth"
+	"github.com/monteirobsb/user-management/backend/magiclink"
 	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/oidc"
+	"github.com/monteirobsb/user-management/backend/password"
+	"github.com/monteirobsb/user-management/backend/scim"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/monteirobsb/user-management/backend/webhooks"
 )
 
 // LoginPayload define a estrutura esperada para o corpo da requisição de login.
 type LoginPayload struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name" binding:"omitempty,max=255"` // rótulo da sessão (ex: "Notebook do trabalho")
+	UseCookie  bool   `json:"use_cookie"`                              // entrega o token em cookie HttpOnly (modo cookie)
 }
 
 // LoginHandler processa as requisições de login.
@@ -29,7 +47,16 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := auth.LoginUser(payload.Email, payload.Password)
+	if payload.UseCookie && !middleware.SessionCookiesEnabled() {
+		c.JSON(400, gin.H{"error": "Modo de sessão por cookie não está habilitado"})
+		return
+	}
+
+	token, err := auth.LoginUser(payload.Email, payload.Password, auth.SessionInfo{
+		UserAgent:   c.Request.UserAgent(),
+		IP:          c.ClientIP(),
+		DeviceLabel: payload.DeviceName,
+	})
 	if err != nil {
 		// auth.LoginUser já loga os erros internos.
 		// Retorna uma mensagem genérica para o cliente.
@@ -37,7 +64,7 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{"token": token})
+	handlers.WriteLoginResponse(c, token, payload.UseCookie)
 }
 
 func main() {
@@ -49,10 +76,94 @@ func main() {
 		log.Print("INFO: Arquivo .env carregado com sucesso.")
 	}
 
+	// HMAC dos registros de auditoria: vale também para os subcomandos que alteram usuários.
+	if key := os.Getenv("AUDIT_HMAC_KEY"); key != "" {
+		if len(key) < 32 {
+			log.Fatal("CRITICAL: AUDIT_HMAC_KEY deve ter pelo menos 32 caracteres.")
+		}
+		services.UseAuditHMACKey([]byte(key))
+	}
+	if raw := os.Getenv("AUDIT_HMAC_SINCE_ID"); raw != "" {
+		since, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			log.Fatalf("CRITICAL: Valor inválido para AUDIT_HMAC_SINCE_ID: %q", raw)
+		}
+		services.UseAuditHMACSince(since)
+	}
+
+	// Parâmetros de hash de senha que a verificação recusaria impedem a inicialização: as
+	// senhas gravadas com eles não poderiam mais ser verificadas.
+	passwords, err := password.NewManagerFromEnv()
+	if err != nil {
+		log.Fatalf("CRITICAL: %v", err)
+	}
+	password.SetDefault(passwords)
+
+	// Subcomandos administrativos (ex: import-users) são executados no lugar do servidor.
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// Inicializa o banco de dados.
 	// InitDatabase agora usa log.Fatal em caso de erro, então não precisamos checar erro aqui.
 	database.InitDatabase()
 
+	// Checkpoints assinados periódicos do log de auditoria.
+	if signingKey, err := loadAuditSigningKey(); err != nil {
+		log.Fatalf("CRITICAL: Chave de assinatura do log de auditoria inválida: %v", err)
+	} else if signingKey != nil {
+		interval := time.Hour
+		if raw := os.Getenv("AUDIT_CHECKPOINT_INTERVAL"); raw != "" {
+			if interval, err = time.ParseDuration(raw); err != nil || interval <= 0 {
+				log.Fatalf("CRITICAL: Valor inválido para AUDIT_CHECKPOINT_INTERVAL: %q", raw)
+			}
+		}
+		go services.RunAuditCheckpoints(signingKey, interval)
+		log.Printf("INFO: Checkpoints do log de auditoria habilitados (a cada %s, chave %s).", interval, services.AuditKeyID(signingKey.Public().(ed25519.PublicKey)))
+	} else {
+		log.Print("INFO: AUDIT_SIGNING_KEY não definida, checkpoints do log de auditoria desabilitados.")
+	}
+
+	// Publicação dos eventos de usuários gravados no outbox (webhooks, NATS, Kafka).
+	relay, err := events.NewFromEnv()
+	if err != nil {
+		log.Fatalf("CRITICAL: Configuração inválida de publicação de eventos: %v", err)
+	}
+	go relay.Run(context.Background())
+	log.Print("INFO: Relay do outbox de eventos habilitado.")
+
+	// Stream de eventos de usuários (SSE) para a interface de administração.
+	hubSize := 1000
+	if raw := os.Getenv("USER_EVENTS_BUFFER_SIZE"); raw != "" {
+		if hubSize, err = strconv.Atoi(raw); err != nil || hubSize < 1 {
+			log.Fatalf("CRITICAL: Valor inválido para USER_EVENTS_BUFFER_SIZE: %q", raw)
+		}
+	}
+	hub := events.NewHub(hubSize, time.Second)
+	go hub.Run(context.Background())
+	handlers.UseUserEventHub(hub)
+
+	// Envio dos eventos de usuários aos webhooks assinados, com novas tentativas.
+	dispatcher, err := webhooks.NewFromEnv()
+	if err != nil {
+		log.Fatalf("CRITICAL: Configuração inválida de webhooks: %v", err)
+	}
+	go dispatcher.Run(context.Background())
+	log.Printf("INFO: Envio de webhooks habilitado (até %d tentativas por entrega).", dispatcher.MaxAttempts)
+
+	// Autenticação no LDAP/Active Directory corporativo, tentada antes (ou no lugar) da
+	// verificação da senha local.
+	if os.Getenv("LDAP_URL") != "" {
+		directory, err := ldapauth.NewFromEnv()
+		if err != nil {
+			log.Fatalf("CRITICAL: Configuração inválida de LDAP: %v", err)
+		}
+		auth.UseExternalAuthenticator(directory, directory.Mode())
+		log.Printf("INFO: Autenticação LDAP habilitada (%s, modo %s).", os.Getenv("LDAP_URL"), directory.Mode())
+	} else {
+		log.Print("INFO: LDAP_URL não definida, autenticação LDAP desabilitada.")
+	}
+
 	// Inicia o roteador Gin
 	// gin.Default() já vem com os middlewares Logger e Recovery.
 	router := gin.Default()
@@ -62,24 +173,196 @@ func main() {
 	// Para capturar erros de rota/handler, esta posição é boa.
 	router.Use(middleware.ErrorHandler())
 
+	// Identificador de cada requisição (X-Request-ID), registrado no log de auditoria.
+	router.Use(middleware.RequestID())
+
+	// Modo de sessão por cookie HttpOnly para o frontend, com proteção CSRF. Clientes que
+	// enviam o bearer token continuam funcionando.
+	cookieConfig, err := middleware.LoadCookieConfigFromEnv()
+	if err != nil {
+		log.Fatalf("CRITICAL: Configuração inválida do modo cookie: %v", err)
+	}
+	if cookieConfig != nil {
+		middleware.UseSessionCookies(cookieConfig)
+		log.Printf("INFO: Sessão por cookie habilitada (CSRF: %s).", cookieConfig.CSRFMode)
+	} else {
+		log.Print("INFO: AUTH_COOKIE_MODE não definida, sessão por cookie desabilitada.")
+	}
+
+	// Operações sensíveis (ex: remover usuário) exigem login ou reautenticação recente.
+	if raw := os.Getenv("STEP_UP_MAX_AGE"); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 {
+			log.Fatalf("CRITICAL: Valor inválido para STEP_UP_MAX_AGE: %q", raw)
+		}
+		middleware.UseStepUpMaxAge(maxAge)
+	}
+
+	// Controle de concorrência otimista: PUT e DELETE de usuários exigem If-Match por padrão.
+	requireIfMatch := true
+	if raw := os.Getenv("USER_IF_MATCH_REQUIRED"); raw != "" {
+		if requireIfMatch, err = strconv.ParseBool(raw); err != nil {
+			log.Fatalf("CRITICAL: Valor inválido para USER_IF_MATCH_REQUIRED: %q", raw)
+		}
+	}
+	handlers.RequireIfMatch(requireIfMatch)
+
+	// Respostas guardadas para as novas tentativas com o mesmo Idempotency-Key.
+	if raw := os.Getenv("IDEMPOTENCY_KEY_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			log.Fatalf("CRITICAL: Valor inválido para IDEMPOTENCY_KEY_TTL: %q", raw)
+		}
+		middleware.UseIdempotencyTTL(ttl)
+	}
+
+	// Avatares: miniaturas gravadas em disco local ou em um bucket S3 (BLOB_STORE).
+	blobs, err := blobstore.NewFromEnv()
+	if err != nil {
+		log.Fatalf("CRITICAL: Configuração inválida de armazenamento de arquivos: %v", err)
+	}
+	avatarSizes := services.DefaultAvatarSizes
+	if raw := os.Getenv("AVATAR_SIZES"); raw != "" {
+		avatarSizes = nil
+		for _, field := range strings.Split(raw, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || size < 16 || size > 1024 {
+				log.Fatalf("CRITICAL: Valor inválido para AVATAR_SIZES: %q", raw)
+			}
+			avatarSizes = append(avatarSizes, size)
+		}
+	}
+	services.UseAvatarStore(blobs, avatarSizes)
+	if raw := os.Getenv("AVATAR_MAX_BYTES"); raw != "" {
+		maxBytes, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			log.Fatalf("CRITICAL: Valor inválido para AVATAR_MAX_BYTES: %q", raw)
+		}
+		handlers.UseAvatarMaxBytes(maxBytes)
+	}
+	if local, ok := blobs.(*blobstore.LocalStore); ok && local.Path() != "" {
+		router.Static(local.Path(), local.Dir)
+		log.Printf("INFO: Arquivos enviados servidos em %s (diretório %s).", local.Path(), local.Dir)
+	}
+
 	// Agrupa as rotas da API sob o prefixo /api
 	api := router.Group("/api")
 	{
 		// Rotas públicas
 		api.POST("/login", LoginHandler)
+		api.POST("/logout", middleware.AuthMiddleware(), middleware.RequireSession(), handlers.LogoutHandler)
 		// A rota de criação de usuário deve ser pública para permitir o registro de novos usuários.
-		api.POST("/users", handlers.CreateUserHandler)
+		// Clientes podem repetir a criação com segurança enviando Idempotency-Key.
+		api.POST("/users", middleware.Idempotency(), handlers.CreateUserHandler)
+
+		// Login com provedores OpenID Connect externos (Google, Microsoft, etc).
+		if os.Getenv("OIDC_LOGIN_PROVIDERS") != "" {
+			federated, err := federation.NewFromEnv()
+			if err != nil {
+				log.Fatalf("CRITICAL: Configuração inválida de login federado: %v", err)
+			}
+			federated.RegisterRoutes(api)
+			log.Print("INFO: Login com provedores OpenID Connect externos habilitado.")
+		}
+
+		// Login sem senha: link e código enviados por e-mail.
+		if os.Getenv("MAGIC_LINK_URL") != "" {
+			magicLinks, err := magiclink.NewFromEnv()
+			if err != nil {
+				log.Fatalf("CRITICAL: Configuração inválida de login sem senha: %v", err)
+			}
+			magicLinks.RegisterRoutes(api)
+			log.Print("INFO: Login sem senha (link mágico) habilitado.")
+		} else {
+			log.Print("INFO: MAGIC_LINK_URL não definida, login sem senha desabilitado.")
+		}
 
 		// Rotas protegidas
 		// O middleware AuthMiddleware() será aplicado a este grupo.
 		protected := api.Group("/users")
 		protected.Use(middleware.AuthMiddleware())
 		{
-			protected.GET("", handlers.GetUsersHandler)
-			protected.GET("/:id", handlers.GetUserHandler)
-			protected.PUT("/:id", handlers.UpdateUserHandler)
-			protected.DELETE("/:id", handlers.DeleteUserHandler)
+			// Tokens de API só acessam as rotas cobertas pelos escopos concedidos.
+			read := middleware.RequireScope(models.ScopeUsersRead)
+			write := middleware.RequireScope(models.ScopeUsersWrite)
+			protected.GET("", read, handlers.GetUsersHandler)
+			protected.POST("/import", write, middleware.Idempotency(), handlers.ImportUsersHandler)
+			protected.GET("/export", read, handlers.ExportUsersHandler)
+			protected.GET("/events", read, handlers.UserEventsHandler)
+			// JSON Schema dos atributos personalizados; só administradores podem alterá-lo.
+			protected.GET("/attributes/schema", read, handlers.GetUserAttributeSchemaHandler)
+			protected.PUT("/attributes/schema", middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin),
+				handlers.UpdateUserAttributeSchemaHandler)
+			protected.GET("/:id", read, handlers.GetUserHandler)
+			protected.PUT("/:id", write, handlers.UpdateUserHandler)
+			protected.PATCH("/:id", write, handlers.PatchUserHandler)
+			protected.DELETE("/:id", write, middleware.RequireRecentAuth(), handlers.DeleteUserHandler)
+
+			// Personificação para suporte: o token carrega o usuário e o administrador (claim act).
+			protected.POST("/:id/impersonate", middleware.RequireSession(), middleware.DenyImpersonation(),
+				middleware.RequireRole(models.RoleAdmin), middleware.RequireRecentAuth(), handlers.ImpersonateUserHandler)
+
+			// Gestão das sessões de qualquer usuário, restrita a administradores com login interativo.
+			admin := protected.Group("/:id/sessions", middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin))
+			admin.GET("", handlers.ListUserSessionsHandler)
+			admin.DELETE("", handlers.RevokeUserSessionsHandler)
+			admin.DELETE("/:sessionId", handlers.RevokeUserSessionHandler)
 		}
+
+		// Log de auditoria das alterações de usuários, restrito a administradores.
+		audit := api.Group("/audit", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin))
+		audit.GET("", handlers.ListAuditLogsHandler)
+
+		// Assinaturas de webhooks para os eventos de usuários, restritas a administradores.
+		hooks := api.Group("/webhooks", middleware.AuthMiddleware(), middleware.RequireSession(), middleware.RequireRole(models.RoleAdmin))
+		hooks.POST("", handlers.CreateWebhookHandler)
+		hooks.GET("", handlers.ListWebhooksHandler)
+		hooks.GET("/:id", handlers.GetWebhookHandler)
+		hooks.PUT("/:id", handlers.UpdateWebhookHandler)
+		hooks.DELETE("/:id", handlers.DeleteWebhookHandler)
+		hooks.GET("/:id/deliveries", handlers.ListWebhookDeliveriesHandler)
+		hooks.POST("/:id/deliveries/:deliveryId/redeliver", middleware.Idempotency(), handlers.RedeliverWebhookHandler)
+
+		// Tokens de acesso pessoal do usuário autenticado. Criar e revogar tokens exige
+		// login interativo: um token de API não pode gerar outros tokens.
+		me := api.Group("/me")
+		me.Use(middleware.AuthMiddleware(), middleware.RequireSession())
+		{
+			me.GET("/tokens", handlers.ListAPITokensHandler)
+			me.POST("/tokens", middleware.DenyImpersonation(), middleware.RequireRecentAuth(), handlers.CreateAPITokenHandler)
+			me.POST("/reauthenticate", middleware.DenyImpersonation(), handlers.ReauthenticateHandler)
+			me.DELETE("/tokens/:id", handlers.RevokeAPITokenHandler)
+			me.GET("/csrf", handlers.CSRFTokenHandler)
+			me.PUT("/avatar", handlers.UpdateMyAvatarHandler)
+			me.DELETE("/avatar", handlers.DeleteMyAvatarHandler)
+			me.GET("/sessions", handlers.ListMySessionsHandler)
+			me.DELETE("/sessions", handlers.RevokeOtherSessionsHandler)
+			me.DELETE("/sessions/:id", handlers.RevokeMySessionHandler)
+		}
+	}
+
+	// Provisionamento SCIM 2.0 (Okta, Azure AD). Só é habilitado quando o token do
+	// cliente de provisionamento está configurado.
+	if scimToken := os.Getenv("SCIM_BEARER_TOKEN"); scimToken != "" {
+		scimGroup := router.Group("/scim/v2")
+		scimGroup.Use(middleware.SCIMAuthMiddleware(scimToken))
+		scim.RegisterRoutes(scimGroup)
+		log.Print("INFO: Endpoints SCIM habilitados em /scim/v2.")
+	} else {
+		log.Print("INFO: SCIM_BEARER_TOKEN não definida, endpoints SCIM desabilitados.")
+	}
+
+	// Provedor OpenID Connect para outras aplicações internas. Só é habilitado quando o
+	// emissor (URL pública deste serviço) está configurado.
+	if os.Getenv("OIDC_ISSUER") != "" {
+		provider, err := oidc.NewProviderFromEnv()
+		if err != nil {
+			log.Fatalf("CRITICAL: Falha ao configurar o provedor OpenID Connect: %v", err)
+		}
+		provider.RegisterRoutes(router)
+		log.Printf("INFO: Provedor OpenID Connect habilitado (issuer: %s).", provider.Issuer)
+	} else {
+		log.Print("INFO: OIDC_ISSUER não definida, modo provedor OpenID Connect desabilitado.")
 	}
 
 	// Inicia o servidor na porta definida
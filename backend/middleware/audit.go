@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// RequestIDHeader é o cabeçalho com o identificador da requisição, aceito do cliente (ou do
+// proxy reverso) e devolvido na resposta.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limita o identificador recebido, que é gravado no log de auditoria.
+const maxRequestIDLength = 128
+
+// RequestID associa um identificador a cada requisição ("requestID" no contexto). Um valor
+// recebido em X-Request-ID é reaproveitado se for curto e imprimível; caso contrário, é gerado
+// um UUID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AuditActor descreve, para o log de auditoria, quem fez a requisição: o usuário autenticado
+// pelo AuthMiddleware (com o administrador da personificação, se houver), o cliente de serviço
+// ou o cliente SCIM, além de IP, user agent e identificador da requisição.
+func AuditActor(c *gin.Context) services.AuditActor {
+	actor := services.AuditActor{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("requestID"),
+		ClientID:  c.GetString("clientID"),
+	}
+	switch c.GetString("authMethod") {
+	case AuthMethodSession:
+		actor.Type = models.AuditActorUser
+	case AuthMethodAPIToken:
+		actor.Type = models.AuditActorAPIToken
+	case AuthMethodClient:
+		actor.Type = models.AuditActorClient
+	case AuthMethodSCIM:
+		actor.Type = models.AuditActorSCIM
+	default:
+		actor.Type = models.AuditActorAnonymous
+	}
+	if id, err := uuid.Parse(c.GetString("userID")); err == nil {
+		actor.UserID = &id
+	}
+	if id, err := uuid.Parse(c.GetString("actorID")); err == nil {
+		actor.ImpersonatorID = &id
+	}
+	return actor
+}
@@ -1,33 +1,68 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/services"
 )
 
-// AuthMiddleware verifica o token JWT na requisição.
+// Formas de autenticação registradas em "authMethod" no contexto.
+const (
+	AuthMethodSession  = "session"   // JWT emitido no login
+	AuthMethodAPIToken = "api_token" // token de acesso pessoal
+	AuthMethodClient   = "client"    // cliente de serviço (grant client_credentials)
+	AuthMethodSCIM     = "scim"      // cliente de provisionamento SCIM (SCIMAuthMiddleware)
+)
+
+// AuthMiddleware verifica o token JWT ou o token de acesso pessoal na requisição. Com o modo
+// cookie habilitado (UseSessionCookies), o JWT da sessão também é aceito no cookie HttpOnly.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		cookieToken, fromCookie := sessionCookie(c)
+		if authHeader != "" {
+			fromCookie = false // o cabeçalho tem precedência sobre o cookie
+		}
+		if authHeader == "" && !fromCookie {
 			log.Printf("WARN: Tentativa de acesso não autorizado à rota %s (IP: %s): Cabeçalho de autorização não encontrado.", c.FullPath(), c.ClientIP())
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Cabeçalho de autorização não encontrado"})
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader { // Significa que o prefixo "Bearer " não estava lá
+		if fromCookie {
+			tokenString = cookieToken
+		} else if tokenString == authHeader { // Significa que o prefixo "Bearer " não estava lá
 			log.Printf("WARN: Tentativa de acesso não autorizado à rota %s (IP: %s): Formato de token inválido (sem prefixo 'Bearer ').", c.FullPath(), c.ClientIP())
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Formato de token inválido"})
 			return
 		}
 
+		// Tokens de acesso pessoal (pat_...) são validados no banco, não como JWT.
+		if !fromCookie && strings.HasPrefix(tokenString, services.APITokenPrefix) {
+			apiToken, user, err := services.AuthenticateAPIToken(tokenString, c.ClientIP())
+			if err != nil {
+				log.Printf("WARN: Tentativa de acesso não autorizado à rota %s (IP: %s): Token de API recusado. Erro: %v", c.FullPath(), c.ClientIP(), err)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido ou expirado"})
+				return
+			}
+			c.Set("userID", user.ID.String())
+			c.Set("authMethod", AuthMethodAPIToken)
+			c.Set("apiTokenID", apiToken.ID.String())
+			c.Set("tokenScopes", apiToken.ScopeList())
+			c.Next()
+			return
+		}
+
 		// JWT_SECRET_KEY é verificado no init() do pacote auth. Se estiver vazio, o app não inicia.
 		// Portanto, os.Getenv("JWT_SECRET_KEY") aqui deve ser seguro.
 		jwtKey := []byte(os.Getenv("JWT_SECRET_KEY"))
@@ -63,7 +98,131 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims.IsService() && !fromCookie {
+			// Tokens com jti podem ser revogados antes de expirar (POST /oauth/revoke).
+			revoked, err := services.IsTokenRevoked(claims.ID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Erro ao validar token"})
+				return
+			}
+			if revoked {
+				log.Printf("WARN: Tentativa de acesso à rota %s com token revogado (IP: %s).", c.FullPath(), c.ClientIP())
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revogado"})
+				return
+			}
+			// Cliente de serviço: não há usuário associado e o acesso é limitado aos escopos.
+			c.Set("clientID", claims.ClientID)
+			c.Set("authMethod", AuthMethodClient)
+			c.Set("tokenScopes", strings.Fields(claims.Scope))
+			c.Next()
+			return
+		}
+		// A sessão pode ter sido encerrada pelo usuário ou por um administrador, e o usuário
+		// pode ter sido desativado (as mesmas regras valem para a introspecção de tokens).
+		session, _, err := auth.ValidateUserToken(claims, c.ClientIP())
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrTokenRevoked):
+				log.Printf("WARN: Tentativa de acesso à rota %s com token revogado (IP: %s).", c.FullPath(), c.ClientIP())
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revogado"})
+			case errors.Is(err, services.ErrSessionInvalid):
+				log.Printf("WARN: Tentativa de acesso à rota %s com sessão encerrada %q (IP: %s).", c.FullPath(), claims.SessionID, c.ClientIP())
+				ClearSessionCookie(c)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sessão encerrada ou expirada"})
+			case errors.Is(err, auth.ErrUserInactive):
+				log.Printf("WARN: Tentativa de acesso à rota %s com token de usuário inexistente ou inativo %q (IP: %s).", c.FullPath(), claims.UserID, c.ClientIP())
+				ClearSessionCookie(c)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Usuário inativo ou inexistente"})
+			case errors.Is(err, auth.ErrImpersonationInvalid):
+				// Personificação: a sessão precisa ter sido aberta pelo mesmo administrador, que
+				// deve continuar ativo e com o papel de administrador.
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Personificação encerrada ou inválida"})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Erro ao validar sessão"})
+			}
+			return
+		}
+		// O navegador envia o cookie em qualquer requisição ao domínio, inclusive as forjadas
+		// por outros sites: as que alteram estado precisam também do token CSRF.
+		if fromCookie {
+			if !safeMethod(c.Request.Method) && !validCSRF(c, session) {
+				logCSRFFailure(c, session.ID)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token CSRF ausente ou inválido"})
+				return
+			}
+			c.Set("authTransport", TransportCookie)
+		}
+		c.Set("session", session)
+		if claims.Act != nil {
+			c.Set("actorID", claims.Act.Subject)
+			log.Printf("INFO: [act=%s] %s %s como usuário ID %s (sessão %s, IP: %s).", claims.Act.Subject, c.Request.Method, c.Request.URL.Path, claims.UserID, session.ID, c.ClientIP())
+		}
+
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		if claims.AuthTime != nil {
+			c.Set("authTime", claims.AuthTime.Time)
+		}
+		c.Set("authMethod", AuthMethodSession)
+		c.Next()
+	}
+}
+
+// RequireScope exige que um token com escopos (token de API ou de cliente de serviço) tenha
+// recebido o escopo informado. Tokens de login (sessão do usuário) têm acesso completo.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, limited := c.Get("tokenScopes")
+		if limited && !slices.Contains(scopes.([]string), scope) {
+			log.Printf("WARN: Acesso negado à rota %s (IP: %s): Token sem o escopo %s.", c.FullPath(), c.ClientIP(), scope)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token sem permissão para esta operação", "required_scope": scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession recusa tokens de API, para operações que só o próprio usuário, com login
+// interativo, pode realizar (ex: criar novos tokens).
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodSession {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Operação disponível apenas com login interativo"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole exige que o usuário autenticado tenha o papel informado. Clientes de serviço
+// não têm usuário e são recusados.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("userID"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Operação restrita a administradores"})
+			return
+		}
+		user, err := services.GetUserByID(userID)
+		if err != nil || user.Role != role {
+			log.Printf("WARN: Acesso negado à rota %s (IP: %s): Usuário ID %s sem o papel %s.", c.FullPath(), c.ClientIP(), userID, role)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Operação restrita a administradores"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// DenyImpersonation recusa requisições feitas com um token de personificação, para
+// operações que o administrador não deve realizar em nome do usuário (ex: criar tokens de
+// API, que sobreviveriam ao fim da personificação).
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := c.GetString("actorID"); actor != "" {
+			log.Printf("WARN: [act=%s] Operação %s %s recusada durante personificação (IP: %s).", actor, c.Request.Method, c.FullPath(), c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Operação não permitida durante personificação"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// DefaultIdempotencyTTL é o tempo padrão durante o qual a resposta de uma requisição com
+// Idempotency-Key é reenviada às novas tentativas.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength limita o tamanho do cabeçalho Idempotency-Key.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodyBytes limita o corpo lido para calcular a impressão digital da requisição
+// (50 MiB, o mesmo limite da importação de usuários).
+const maxIdempotentBodyBytes = 50 << 20
+
+// idempotentHeaders são os cabeçalhos da resposta original reenviados junto com o corpo.
+var idempotentHeaders = []string{"Content-Type", "Location", "ETag"}
+
+var idempotencyTTL = DefaultIdempotencyTTL
+
+// UseIdempotencyTTL define por quanto tempo as respostas das requisições com Idempotency-Key
+// são guardadas.
+func UseIdempotencyTTL(ttl time.Duration) {
+	idempotencyTTL = ttl
+}
+
+// responseRecorder repassa a resposta ao cliente e guarda uma cópia do corpo.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency torna a rota segura para novas tentativas do cliente: a primeira requisição com
+// um cabeçalho Idempotency-Key é executada e sua resposta é guardada (por UseIdempotencyTTL);
+// as repetições com a mesma chave e a mesma requisição (método, URL e corpo) recebem a
+// resposta guardada, com o cabeçalho Idempotent-Replayed: true, sem executar o handler de
+// novo. Reusar a chave com outra requisição resulta em 422, e repeti-la enquanto a original
+// está em andamento, em 409. Respostas 5xx não são guardadas, para que a requisição possa ser
+// repetida. As chaves são separadas por usuário ou cliente autenticado (e, sem autenticação,
+// pelo IP do cliente); por isso, o middleware deve ser registrado depois de AuthMiddleware nas
+// rotas protegidas. Requisições sem o cabeçalho não são afetadas.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key deve ter no máximo 255 caracteres"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Corpo da requisição muito grande"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		record, started, err := services.BeginIdempotentRequest(idempotencyScope(c), key, fingerprint, idempotencyTTL)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+			log.Printf("WARN: Idempotency-Key reutilizada com outra requisição em %s %s (IP: %s).", c.Request.Method, c.FullPath(), c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Esta Idempotency-Key já foi usada com outra requisição"})
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyInUse):
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A requisição com esta Idempotency-Key ainda está em andamento"})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+			return
+		}
+
+		if !started {
+			var header map[string]string
+			if err := json.Unmarshal([]byte(record.Header), &header); err != nil {
+				log.Printf("ERROR: Cabeçalhos guardados inválidos para a Idempotency-Key do escopo %s em %s %s: %v", record.Scope, c.Request.Method, c.FullPath(), err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar sua solicitação"})
+				return
+			}
+			for name, value := range header {
+				c.Header(name, value)
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(record.StatusCode)
+			c.Writer.Write(record.Body)
+			c.Abort()
+			return
+		}
+
+		// Se o handler não terminar (ex: panic), a chave é liberada para uma nova tentativa.
+		completed := false
+		defer func() {
+			if !completed {
+				services.ReleaseIdempotencyKey(record)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if status := recorder.Status(); status < http.StatusInternalServerError {
+			header := make(map[string]string)
+			for _, name := range idempotentHeaders {
+				if value := recorder.Header().Get(name); value != "" {
+					header[name] = value
+				}
+			}
+			encoded, _ := json.Marshal(header)
+			completed = services.CompleteIdempotentRequest(record, status, string(encoded), recorder.body.Bytes()) == nil
+		}
+	}
+}
+
+// idempotencyScope identifica quem enviou a chave, para que clientes diferentes não vejam as
+// respostas uns dos outros. Sem autenticação (ex: o cadastro público), o escopo é o IP do
+// cliente: outro cliente que conheça a chave não recebe a resposta guardada (com os dados do
+// usuário criado) nem bloqueia a chave.
+func idempotencyScope(c *gin.Context) string {
+	if userID := c.GetString("userID"); userID != "" {
+		return "user:" + userID
+	}
+	if clientID := c.GetString("clientID"); clientID != "" {
+		return "client:" + clientID
+	}
+	return "anonymous:" + c.ClientIP()
+}
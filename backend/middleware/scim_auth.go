@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuthMiddleware autentica o cliente de provisionamento SCIM (Okta, Azure AD)
+// comparando o bearer token recebido com o token configurado.
+// As respostas de erro seguem o formato de erro do SCIM (RFC 7644, seção 3.12).
+func SCIMAuthMiddleware(expectedToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" || token == authHeader ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(expectedToken)) != 1 {
+			log.Printf("WARN: Tentativa de acesso SCIM não autorizado à rota %s (IP: %s).", c.FullPath(), c.ClientIP())
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.Header("Content-Type", "application/scim+json")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+				"status":  "401",
+				"detail":  "Token de provisionamento inválido ou ausente",
+			})
+			return
+		}
+		c.Set("authMethod", AuthMethodSCIM)
+		c.Next()
+	}
+}
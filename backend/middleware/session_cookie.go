@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// Estratégias de proteção CSRF do modo cookie.
+const (
+	// CSRFDoubleSubmit grava o token CSRF em um cookie legível pelo JavaScript; o cliente o
+	// repete no cabeçalho X-CSRF-Token e o servidor compara os dois valores.
+	CSRFDoubleSubmit = "double-submit"
+	// CSRFSynchronizer guarda o token CSRF na sessão, no banco; o cliente o recebe no login
+	// (ou em GET /api/me/csrf) e o envia no cabeçalho X-CSRF-Token.
+	CSRFSynchronizer = "synchronizer"
+)
+
+// TransportCookie é registrado em "authTransport" quando a requisição foi autenticada pelo
+// cookie de sessão, e não pelo cabeçalho Authorization.
+const TransportCookie = "cookie"
+
+// CSRFHeader é o cabeçalho com o token CSRF nas requisições que alteram estado.
+const CSRFHeader = "X-CSRF-Token"
+
+// CookieConfig configura o modo de sessão por cookie, usado pelo frontend no lugar do
+// bearer token guardado no navegador.
+type CookieConfig struct {
+	Name           string // cookie HttpOnly com o JWT da sessão
+	CSRFCookieName string // cookie legível com o token CSRF (apenas double-submit)
+	Domain         string
+	Secure         bool
+	SameSite       http.SameSite
+	CSRFMode       string
+}
+
+// sessionCookies é a configuração ativa; nil mantém apenas a autenticação por bearer token.
+var sessionCookies *CookieConfig
+
+// UseSessionCookies habilita (ou, com nil, desabilita) o modo cookie no AuthMiddleware.
+func UseSessionCookies(config *CookieConfig) {
+	sessionCookies = config
+}
+
+// SessionCookiesEnabled indica se o modo cookie está habilitado.
+func SessionCookiesEnabled() bool {
+	return sessionCookies != nil
+}
+
+// LoadCookieConfigFromEnv lê a configuração das variáveis AUTH_COOKIE_*. Retorna nil se
+// AUTH_COOKIE_MODE estiver vazia.
+func LoadCookieConfigFromEnv() (*CookieConfig, error) {
+	mode := os.Getenv("AUTH_COOKIE_MODE")
+	if mode == "" {
+		return nil, nil
+	}
+	if mode != CSRFDoubleSubmit && mode != CSRFSynchronizer {
+		return nil, fmt.Errorf("AUTH_COOKIE_MODE inválido: %q (use %s ou %s)", mode, CSRFDoubleSubmit, CSRFSynchronizer)
+	}
+	config := &CookieConfig{CSRFMode: mode, Domain: os.Getenv("AUTH_COOKIE_DOMAIN"), Secure: true}
+	if raw := os.Getenv("AUTH_COOKIE_SECURE"); raw != "" {
+		secure, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("valor inválido para AUTH_COOKIE_SECURE: %q", raw)
+		}
+		config.Secure = secure
+	}
+	switch strings.ToLower(os.Getenv("AUTH_COOKIE_SAMESITE")) {
+	case "", "lax":
+		config.SameSite = http.SameSiteLaxMode
+	case "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !config.Secure {
+			return nil, errors.New("AUTH_COOKIE_SAMESITE=none exige AUTH_COOKIE_SECURE=true")
+		}
+		config.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("AUTH_COOKIE_SAMESITE inválido: %q (use lax, strict ou none)", os.Getenv("AUTH_COOKIE_SAMESITE"))
+	}
+
+	// O prefixo __Host- impede que um subdomínio sobrescreva o cookie, mas só é aceito pelos
+	// navegadores em cookies Secure, sem Domain e com Path=/.
+	prefix := ""
+	if config.Secure && config.Domain == "" {
+		prefix = "__Host-"
+	}
+	config.Name = prefix + "session"
+	config.CSRFCookieName = prefix + "csrf_token"
+	return config, nil
+}
+
+// IssueSessionCookie grava o JWT da sessão no cookie HttpOnly e retorna o token CSRF que o
+// cliente deve enviar em X-CSRF-Token nas requisições que alteram estado.
+func IssueSessionCookie(c *gin.Context, token string) (string, error) {
+	config := sessionCookies
+	if config == nil {
+		return "", errors.New("modo cookie desabilitado")
+	}
+	claims, err := auth.ParseToken(token)
+	if err != nil {
+		return "", err
+	}
+	expires := claims.ExpiresAt.Time
+
+	var csrfToken string
+	if config.CSRFMode == CSRFSynchronizer {
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return "", err
+		}
+		session, err := services.GetSession(sessionID)
+		if err != nil {
+			return "", err
+		}
+		csrfToken = session.CSRFToken
+	} else {
+		csrfToken = rand.Text()
+		config.set(c, config.CSRFCookieName, csrfToken, expires, false)
+	}
+	config.set(c, config.Name, token, expires, true)
+	return csrfToken, nil
+}
+
+// ClearSessionCookie remove os cookies de sessão e CSRF do navegador.
+func ClearSessionCookie(c *gin.Context) {
+	if config := sessionCookies; config != nil {
+		config.set(c, config.Name, "", time.Unix(0, 0), true)
+		if config.CSRFMode == CSRFDoubleSubmit {
+			config.set(c, config.CSRFCookieName, "", time.Unix(0, 0), false)
+		}
+	}
+}
+
+func (config *CookieConfig) set(c *gin.Context, name, value string, expires time.Time, httpOnly bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   config.Domain,
+		Expires:  expires,
+		Secure:   config.Secure,
+		HttpOnly: httpOnly,
+		SameSite: config.SameSite,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// sessionCookie retorna o JWT do cookie de sessão, se o modo cookie estiver habilitado.
+func sessionCookie(c *gin.Context) (string, bool) {
+	if sessionCookies == nil {
+		return "", false
+	}
+	token, err := c.Cookie(sessionCookies.Name)
+	return token, err == nil && token != ""
+}
+
+// safeMethod indica os métodos que não alteram estado e dispensam o token CSRF.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRF confere o token CSRF de uma requisição autenticada pelo cookie de sessão.
+func validCSRF(c *gin.Context, session models.Session) bool {
+	header := c.GetHeader(CSRFHeader)
+	if header == "" {
+		return false
+	}
+	expected := session.CSRFToken
+	if sessionCookies.CSRFMode == CSRFDoubleSubmit {
+		cookie, err := c.Cookie(sessionCookies.CSRFCookieName)
+		if err != nil {
+			return false
+		}
+		expected = cookie
+	}
+	return expected != "" && subtle.ConstantTimeCompare([]byte(header), []byte(expected)) == 1
+}
+
+// CurrentCSRFToken retorna o token CSRF da sessão autenticada pelo cookie, ou "" para
+// requisições com bearer token, que não precisam dele.
+func CurrentCSRFToken(c *gin.Context) string {
+	if c.GetString("authTransport") != TransportCookie {
+		return ""
+	}
+	if sessionCookies.CSRFMode == CSRFDoubleSubmit {
+		token, _ := c.Cookie(sessionCookies.CSRFCookieName)
+		return token
+	}
+	session, _ := c.Get("session")
+	return session.(models.Session).CSRFToken
+}
+
+// logCSRFFailure registra uma requisição recusada pela verificação CSRF.
+func logCSRFFailure(c *gin.Context, sessionID uuid.UUID) {
+	log.Printf("WARN: Requisição %s %s recusada: token CSRF ausente ou inválido (sessão %s, IP: %s, Origin: %q).", c.Request.Method, c.FullPath(), sessionID, c.ClientIP(), c.GetHeader("Origin"))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultStepUpMaxAge é a idade máxima padrão da autenticação exigida por RequireRecentAuth.
+const DefaultStepUpMaxAge = 10 * time.Minute
+
+// ReauthenticationRequired é o código de erro retornado quando a autenticação não é recente,
+// para que o cliente peça a senha novamente (POST /api/me/reauthenticate).
+const ReauthenticationRequired = "reauthentication_required"
+
+var stepUpMaxAge = DefaultStepUpMaxAge
+
+// UseStepUpMaxAge define a idade máxima da autenticação para operações sensíveis.
+func UseStepUpMaxAge(maxAge time.Duration) {
+	stepUpMaxAge = maxAge
+}
+
+// RequireRecentAuth exige que o usuário tenha se autenticado (login ou reautenticação) há
+// no máximo o tempo configurado em UseStepUpMaxAge. Tokens de API e de clientes de serviço
+// não comprovam uma autenticação recente e são sempre recusados.
+func RequireRecentAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CheckRecentAuth(c) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckRecentAuth é a verificação de RequireRecentAuth para uso dentro de handlers, quando
+// só parte da operação é sensível (ex: alteração de e-mail). Se a autenticação não for
+// recente, responde 401 (ou 403, para tokens que não podem ser reautenticados) e retorna false.
+func CheckRecentAuth(c *gin.Context) bool {
+	if c.GetString("authMethod") != AuthMethodSession {
+		log.Printf("WARN: Operação sensível %s %s recusada para token sem login interativo (%s, IP: %s).", c.Request.Method, c.FullPath(), c.GetString("authMethod"), c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Esta operação exige autenticação recente com login interativo e não pode ser feita com tokens de API ou de clientes de serviço.",
+			"code":  ReauthenticationRequired,
+		})
+		return false
+	}
+	authTime := c.GetTime("authTime")
+	if !authTime.IsZero() && time.Since(authTime) <= stepUpMaxAge {
+		return true
+	}
+	maxAge := int(stepUpMaxAge.Seconds())
+	log.Printf("INFO: Reautenticação exigida para %s %s (usuário ID %s, IP: %s).", c.Request.Method, c.FullPath(), c.GetString("userID"), c.ClientIP())
+	// Desafio de step-up da RFC 9470.
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="Reautenticação necessária", max_age=%d`, maxAge))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":   "Esta operação exige autenticação recente. Confirme sua senha.",
+		"code":    ReauthenticationRequired,
+		"max_age": maxAge,
+	})
+	return false
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Escopos que podem ser concedidos a tokens de acesso pessoal e a clientes de serviço.
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+)
+
+// APIScopes lista os escopos aceitos pela API.
+var APIScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+// APIToken é um token de acesso pessoal (chave de API) usado por scripts e jobs de CI.
+// O valor do token só é exibido na criação; o banco guarda o prefixo, usado na busca, e o
+// hash SHA-256 do segredo.
+type APIToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	Name       string     `gorm:"size:255;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null;uniqueIndex" json:"prefix"`
+	SecretHash string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"-"` // separados por espaço
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do token antes da criação.
+func (token *APIToken) BeforeCreate(tx *gorm.DB) (err error) {
+	token.ID = uuid.New()
+	return
+}
+
+// ScopeList retorna os escopos concedidos ao token.
+func (token APIToken) ScopeList() []string {
+	return strings.Fields(token.Scopes)
+}
+
+// APITokenCreateRequest define a estrutura para criar um token de acesso pessoal.
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" binding:"required,max=255"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=users:read users:write"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // padrão: 90 dias
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditCheckpoint é um ponto de controle assinado (Ed25519) do log de auditoria: atesta o
+// hash do último registro e a quantidade de registros até ele. Exportados para fora do banco,
+// os checkpoints permitem detectar a reescrita da cadeia inteira ou a remoção dos registros
+// mais recentes.
+type AuditCheckpoint struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	LastEntryID uint64    `gorm:"not null;index" json:"last_entry_id"`
+	EntryHash   string    `gorm:"size:64;not null" json:"entry_hash"`
+	EntryCount  int64     `gorm:"not null" json:"entry_count"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	KeyID       string    `gorm:"size:32;not null" json:"key_id"` // derivado da chave pública
+	Signature   string    `gorm:"type:text;not null" json:"signature"`
+}
+
+// BeforeUpdate impede a alteração de checkpoints.
+func (*AuditCheckpoint) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete impede a remoção de checkpoints.
+func (*AuditCheckpoint) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipos de autor registrados no log de auditoria.
+const (
+	AuditActorUser      = "user"      // usuário com login interativo
+	AuditActorAPIToken  = "api_token" // token de acesso pessoal
+	AuditActorClient    = "client"    // cliente de serviço (client_credentials)
+	AuditActorSCIM      = "scim"      // cliente de provisionamento SCIM
+	AuditActorSystem    = "system"    // processos internos (CLI, sincronização LDAP)
+	AuditActorAnonymous = "anonymous" // requisição sem autenticação (ex: cadastro público)
+)
+
+// Ações registradas no log de auditoria.
+const (
+	AuditActionUserCreate      = "user.create"
+	AuditActionUserUpdate      = "user.update"
+	AuditActionUserDelete      = "user.delete"
+	AuditActionUserImpersonate = "user.impersonate"
+	AuditActionUserDeactivate  = "user.deactivate" // alteração que desativa o usuário (ex: SCIM active=false)
+	AuditActionWebhookCreate   = "webhook.create"
+	AuditActionWebhookUpdate   = "webhook.update"
+	AuditActionWebhookDelete   = "webhook.delete"
+	AuditActionSessionRevoke   = "session.revoke"     // uma sessão (inclusive logout)
+	AuditActionSessionsRevoke  = "session.revoke_all" // várias sessões de um usuário
+	AuditActionAPITokenCreate  = "api_token.create"
+	AuditActionAPITokenRevoke  = "api_token.revoke"
+	AuditActionMagicLinkLogin  = "magic_link.login"
+	AuditActionIdentityLink    = "federated_identity.link" // vínculo de um provedor externo pedido pelo próprio usuário
+
+	AuditActionUserAttributeSchemaUpdate = "user_attribute_schema.update"
+)
+
+// ErrAuditLogImmutable é retornado ao tentar alterar ou remover um registro de auditoria.
+var ErrAuditLogImmutable = errors.New("registros de auditoria não podem ser alterados ou removidos")
+
+// Algoritmos do hash que encadeia os registros de auditoria.
+const (
+	AuditHashSHA256     = "sha256"
+	AuditHashHMACSHA256 = "hmac-sha256"
+)
+
+// AuditLog é um registro do log de auditoria, gravado na mesma transação da alteração.
+// A tabela só recebe inserções: os hooks do GORM recusam atualizações e remoções. Cada
+// registro guarda o hash do anterior (PrevHash) e o próprio hash, calculado sobre o seu
+// conteúdo e PrevHash: alterar, remover ou reordenar registros quebra a cadeia.
+type AuditLog struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt time.Time  `gorm:"not null;index" json:"created_at"`
+	ActorType string     `gorm:"size:32;not null" json:"actor_type"`
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actor_id,omitempty"` // usuário autenticado (userID)
+	// ImpersonatorID é o administrador que agia em nome de ActorID (claim act).
+	ImpersonatorID *uuid.UUID `gorm:"type:uuid;index" json:"impersonator_id,omitempty"`
+	ClientID       string     `gorm:"size:64" json:"client_id,omitempty"`
+	Action         string     `gorm:"size:64;not null;index" json:"action"`
+	TargetType     string     `gorm:"size:32;not null" json:"target_type"`
+	TargetID       string     `gorm:"size:64;not null;index" json:"target_id"`
+	Changes        string     `gorm:"type:text" json:"-"` // JSON com {"campo": {"before": ..., "after": ...}}
+	IP             string     `gorm:"size:64" json:"ip"`
+	UserAgent      string     `gorm:"type:text" json:"user_agent"`
+	RequestID      string     `gorm:"size:128;index" json:"request_id"`
+	PrevHash       string     `gorm:"size:64" json:"prev_hash"`
+	Hash           string     `gorm:"size:64" json:"hash"` // vazio nos registros anteriores ao encadeamento
+	HashAlg        string     `gorm:"size:16" json:"hash_alg,omitempty"`
+}
+
+// BeforeUpdate impede a alteração de registros de auditoria.
+func (*AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete impede a remoção de registros de auditoria.
+func (*AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FederatedIdentity vincula um usuário local a uma conta em um provedor OpenID Connect externo
+// (Google, Microsoft, etc). A conta externa é identificada pelo par emissor + subject.
+type FederatedIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:64;not null" json:"provider"` // nome do provedor na configuração (ex: google)
+	Issuer      string     `gorm:"size:255;not null;uniqueIndex:idx_federated_identities_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_federated_identities_issuer_subject" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"` // e-mail informado pelo provedor no último login
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do vínculo antes da criação.
+func (identity *FederatedIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	identity.ID = uuid.New()
+	return
+}
+
+// FederatedLoginState guarda os dados de uma tentativa de login federado em andamento,
+// entre o redirecionamento para o provedor e o retorno no callback.
+type FederatedLoginState struct {
+	StateHash    string     `gorm:"size:64;primary_key"`
+	Provider     string     `gorm:"size:64;not null"`
+	Nonce        string     `gorm:"size:64;not null"`
+	CodeVerifier string     `gorm:"size:128;not null"`
+	LinkUserID   *uuid.UUID `gorm:"type:uuid"` // usuário autenticado que pediu o vínculo (POST /api/me/identities/:provider); nulo no login
+	ExpiresAt    time.Time  `gorm:"not null;index"`
+	CreatedAt    time.Time  `gorm:"not null"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Group representa um grupo de usuários, provisionado pelo provedor de identidade via SCIM.
+type Group struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	DisplayName string    `gorm:"size:255;not null;unique" json:"display_name"`
+	ExternalID  *string   `gorm:"size:255;index" json:"external_id,omitempty"`
+	Members     []User    `gorm:"many2many:group_members;" json:"members,omitempty"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do grupo antes da criação.
+func (group *Group) BeforeCreate(tx *gorm.DB) (err error) {
+	group.ID = uuid.New()
+	return
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey guarda o resultado de uma requisição enviada com o cabeçalho
+// Idempotency-Key, para que novas tentativas com a mesma chave recebam a mesma resposta em
+// vez de repetir a operação. Enquanto a requisição original está em andamento, CompletedAt
+// é nulo e a chave funciona como uma trava.
+type IdempotencyKey struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	Scope       string     `gorm:"size:128;not null;uniqueIndex:idx_idempotency_scope_key"` // quem enviou a chave (ex: user:<id>, client:<id> ou anonymous:<ip>)
+	Key         string     `gorm:"size:255;not null;uniqueIndex:idx_idempotency_scope_key"`
+	Fingerprint string     `gorm:"size:64;not null"` // SHA-256 do método, da URL e do corpo da requisição
+	StatusCode  int        `gorm:"not null;default:0"`
+	Header      string     `gorm:"type:text"` // JSON com os cabeçalhos reenviados (Content-Type, Location, ETag)
+	Body        []byte     // corpo da resposta
+	CompletedAt *time.Time // nulo enquanto a requisição original está em andamento
+	ExpiresAt   time.Time  `gorm:"not null;index"`
+	CreatedAt   time.Time  `gorm:"not null"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do registro antes da criação.
+func (key *IdempotencyKey) BeforeCreate(tx *gorm.DB) (err error) {
+	key.ID = uuid.New()
+	return
+}
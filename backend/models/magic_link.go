@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MagicLink é um pedido de login sem senha. O link enviado por e-mail é assinado e contém
+// o ID do registro; o código de 6 dígitos é guardado apenas como HMAC. Pedidos para e-mails
+// sem conta também são registrados (UserID nulo), para que o limite de pedidos por endereço
+// se comporte da mesma forma e não revele quais e-mails estão cadastrados.
+type MagicLink struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	Email     string     `gorm:"size:255;not null;index"` // em minúsculas
+	CodeHash  string     `gorm:"size:64;not null"`
+	Attempts  int        `gorm:"not null;default:0"` // códigos incorretos informados
+	RequestIP string     `gorm:"size:64"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	CreatedAt time.Time  `gorm:"not null"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do pedido antes da criação.
+func (link *MagicLink) BeforeCreate(tx *gorm.DB) (err error) {
+	link.ID = uuid.New()
+	return
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthClient é uma aplicação registrada que usa este serviço como provedor OpenID Connect
+// ou, se Service for verdadeiro, um serviço que acessa a API em nome próprio.
+type OAuthClient struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	ClientID         string    `gorm:"size:64;not null;uniqueIndex" json:"client_id"`
+	ClientSecretHash string    `gorm:"type:text" json:"-"` // vazio para clientes públicos (SPA, apps nativos)
+	Name             string    `gorm:"size:255;not null" json:"name"`
+	RedirectURIs     string    `gorm:"type:text;not null" json:"redirect_uris"` // URIs separadas por espaço
+	Scopes           string    `gorm:"type:text;not null" json:"scopes"`        // escopos permitidos, separados por espaço
+	Public           bool      `gorm:"not null;default:false" json:"public"`
+	SkipConsent      bool      `gorm:"not null;default:false" json:"skip_consent"` // aplicações internas confiáveis
+	Service          bool      `gorm:"not null;default:false" json:"service"`      // cliente de máquina: usa apenas o grant client_credentials
+	CreatedAt        time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do cliente antes da criação.
+func (client *OAuthClient) BeforeCreate(tx *gorm.DB) (err error) {
+	client.ID = uuid.New()
+	return
+}
+
+// RedirectURIList retorna as URIs de redirecionamento registradas.
+func (client *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(client.RedirectURIs)
+}
+
+// ScopeList retorna os escopos que o cliente pode solicitar.
+func (client *OAuthClient) ScopeList() []string {
+	return strings.Fields(client.Scopes)
+}
+
+// OAuthAuthorizationCode é um código de autorização emitido por /oauth/authorize.
+// Apenas o hash SHA-256 do código é armazenado; o registro é removido ao ser trocado por tokens.
+type OAuthAuthorizationCode struct {
+	CodeHash      string    `gorm:"size:64;primary_key" json:"-"`
+	ClientID      string    `gorm:"size:64;not null;index" json:"client_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	RedirectURI   string    `gorm:"type:text;not null" json:"redirect_uri"`
+	Scope         string    `gorm:"type:text;not null" json:"scope"`
+	Nonce         string    `gorm:"type:text" json:"nonce"`
+	CodeChallenge string    `gorm:"size:128;not null" json:"-"`
+	AuthTime      time.Time `gorm:"not null" json:"auth_time"`
+	ExpiresAt     time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt     time.Time `gorm:"not null" json:"created_at"`
+}
+
+// OAuthConsent registra os escopos que um usuário já autorizou para um cliente.
+type OAuthConsent struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	ClientID  string    `gorm:"size:64;primary_key" json:"client_id"`
+	Scope     string    `gorm:"type:text;not null" json:"scope"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
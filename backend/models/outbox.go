@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxEvent é um evento gravado na mesma transação da alteração que o originou
+// (transactional outbox) e publicado depois pelo relay. Se o processo cair entre o commit e
+// a publicação, o evento continua na tabela e é publicado na próxima execução.
+type OutboxEvent struct {
+	// ID identifica o evento para deduplicação: é repetido em toda nova publicação.
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	AggregateType string     `gorm:"size:32;not null" json:"aggregate_type"` // ex: user
+	AggregateID   string     `gorm:"size:64;not null;index" json:"aggregate_id"`
+	EventType     string     `gorm:"size:64;not null" json:"event_type"` // ex: user.created
+	Payload       string     `gorm:"type:text;not null" json:"-"`        // JSON publicado
+	CreatedAt     time.Time  `gorm:"not null;index" json:"created_at"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at,omitempty"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID do evento, se ainda não definido.
+func (event *OutboxEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	return
+}
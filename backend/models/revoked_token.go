@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RevokedToken registra o identificador (jti) de um token revogado antes de expirar. O
+// registro só precisa existir até a expiração do token, quando é removido.
+type RevokedToken struct {
+	JTI       string    `gorm:"size:64;primary_key"`
+	ClientID  string    `gorm:"size:64;not null"` // cliente ao qual o token foi emitido
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
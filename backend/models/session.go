@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session é um login ativo de um usuário. O token emitido no login carrega o ID da sessão
+// (claim sid); removida a sessão, o token deixa de ser aceito.
+type Session struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	UserAgent   string    `gorm:"type:text" json:"user_agent"`
+	IP          string    `gorm:"size:64" json:"ip"`
+	DeviceLabel string    `gorm:"size:255" json:"device_label"` // informado no login ou derivado do user agent
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	LastSeenAt  time.Time `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	CSRFToken   string    `gorm:"size:64" json:"-"` // token CSRF do modo cookie (estratégia synchronizer)
+	// ImpersonatorID é o administrador que abriu a sessão em nome do usuário (personificação).
+	ImpersonatorID *uuid.UUID `gorm:"type:uuid;index" json:"impersonator_id,omitempty"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID da sessão antes da criação.
+func (session *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	session.ID = uuid.New()
+	return
+}
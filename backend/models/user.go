@@ -7,20 +7,40 @@ import (
 	"gorm.io/gorm"
 )
 
+// Papéis de autorização dos usuários.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User representa a estrutura de um usuário no banco de dados
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Name         string    `gorm:"size:255;not null" json:"name" binding:"required"`
-	Email        string    `gorm:"size:255;not null;unique" json:"email" binding:"required,email"`
-	Password     string    `gorm:"-" json:"password,omitempty" binding:"omitempty,min=8"` // omitempty para edição, min=8 para criação
-	PasswordHash string    `gorm:"not null" json:"-"`
-	CreatedAt    time.Time `gorm:"not null" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"not null" json:"updated_at"`
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;" json:"id"`
+	Name            string         `gorm:"size:255;not null" json:"name" binding:"required"`
+	Email           string         `gorm:"size:255;not null;unique" json:"email" binding:"required,email"`
+	Password        string         `gorm:"-" json:"password,omitempty" binding:"omitempty,min=8"` // omitempty para edição, min=8 para criação
+	PasswordHash    string         `gorm:"not null" json:"-"`
+	Active          bool           `gorm:"not null;default:true" json:"active"`         // false quando a conta foi desativada (ex: via SCIM)
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`                 // posse do e-mail comprovada localmente (link mágico ou cadastro pelo provedor); nulo após a troca do e-mail
+	ExternalID      *string        `gorm:"size:255;index" json:"external_id,omitempty"` // identificador no provedor de identidade que provisionou a conta
+	Role            string         `gorm:"size:32;not null;default:user" json:"role"`   // papel de autorização (user ou admin)
+	Version         int64          `gorm:"not null;default:1" json:"version"`           // incrementada a cada alteração; base do ETag
+	Attributes      UserAttributes `gorm:"not null;default:'{}'" json:"attributes"`     // atributos personalizados, validados pelo UserAttributeSchema
+	AvatarKey       string         `gorm:"size:255" json:"-"`                           // prefixo das miniaturas do avatar no BlobStore
+	AvatarURL       string         `gorm:"size:1024" json:"avatar_url,omitempty"`       // URL pública da maior miniatura do avatar
+	CreatedAt       time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt       time.Time      `gorm:"not null" json:"updated_at"`
 }
 
 // BeforeCreate é um hook do GORM que será chamado antes de um usuário ser criado.
 func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
 	// Gera um novo UUID e o atribui ao ID do usuário
 	user.ID = uuid.New()
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	if user.Version == 0 {
+		user.Version = 1
+	}
 	return
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultUserAttributeSchema é o JSON Schema usado enquanto nenhum foi configurado: nenhum
+// atributo personalizado é aceito.
+const DefaultUserAttributeSchema = `{"type":"object","additionalProperties":false}`
+
+// UserAttributes são os atributos personalizados do perfil de um usuário (ex: department,
+// phone, employee_id), definidos pelo JSON Schema configurado pelos administradores. São
+// gravados como JSONB no PostgreSQL e como texto JSON no SQLite.
+type UserAttributes map[string]interface{}
+
+// Value grava os atributos como JSON (um objeto vazio quando nil).
+func (attrs UserAttributes) Value() (driver.Value, error) {
+	if attrs == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan lê os atributos gravados como JSON.
+func (attrs *UserAttributes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*attrs = UserAttributes{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("tipo não suportado para atributos do usuário: %T", value)
+	}
+	result := UserAttributes{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*attrs = result
+	return nil
+}
+
+// GormDBDataType define o tipo da coluna: jsonb no PostgreSQL, texto nos demais bancos.
+func (UserAttributes) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+// UserAttributeSchema guarda o JSON Schema dos atributos personalizados dos usuários. Há um
+// único registro (ID 1), alterado pelos administradores.
+type UserAttributeSchema struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	Schema    string     `gorm:"type:text;not null" json:"-"`
+	UpdatedBy *uuid.UUID `gorm:"type:uuid" json:"updated_by,omitempty"` // administrador que fez a última alteração
+	UpdatedAt time.Time  `json:"updated_at"`
+}
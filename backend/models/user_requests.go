@@ -3,9 +3,10 @@ package models
 // UserCreateRequest defines the structure for creating a new user.
 // It includes the plaintext password which will be hashed by the service.
 type UserCreateRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Name       string         `json:"name" binding:"required"`
+	Email      string         `json:"email" binding:"required,email"`
+	Password   string         `json:"password" binding:"required,min=8"`
+	Attributes UserAttributes `json:"attributes,omitempty"` // validated against the configured JSON Schema
 }
 
 // UserUpdateRequest defines the structure for updating an existing user.
@@ -15,4 +16,33 @@ type UserCreateRequest struct {
 type UserUpdateRequest struct {
 	Name  *string `json:"name,omitempty" binding:"omitempty,min=1"` // If Name is provided, it must not be empty
 	Email *string `json:"email,omitempty" binding:"omitempty,email"` // If Email is provided, it must be a valid email
+	// If Attributes is provided, it replaces all custom attributes and must satisfy the
+	// configured JSON Schema.
+	Attributes UserAttributes `json:"attributes,omitempty"`
+}
+
+// UserPatchDocument holds the editable profile fields of a user. PATCH /api/users/:id applies
+// the patch to the user's JSON representation and then reads these fields back, so the patched
+// document must still satisfy the rules below. To make a new profile field editable, add it
+// here (and to NewUserPatchDocument and ApplyTo); use a pointer for optional fields, so that
+// removing the member (or setting it to null) clears the value.
+type UserPatchDocument struct {
+	Name       string         `json:"name" binding:"required"`
+	Email      string         `json:"email" binding:"required,email"`
+	Attributes UserAttributes `json:"attributes"` // checked against the JSON Schema by the service
+}
+
+// NewUserPatchDocument returns the editable fields of user.
+func NewUserPatchDocument(user User) UserPatchDocument {
+	return UserPatchDocument{Name: user.Name, Email: user.Email, Attributes: user.Attributes}
+}
+
+// ApplyTo copies the document's fields onto user. Removing the attributes member clears them.
+func (doc UserPatchDocument) ApplyTo(user *User) {
+	user.Name = doc.Name
+	user.Email = doc.Email
+	user.Attributes = doc.Attributes
+	if user.Attributes == nil {
+		user.Attributes = UserAttributes{}
+	}
 }
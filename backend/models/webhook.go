@@ -0,0 +1,107 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Eventos do ciclo de vida dos usuários enviados aos webhooks.
+const (
+	EventUserCreated   = "user.created"
+	EventUserUpdated   = "user.updated"
+	EventUserSuspended = "user.suspended" // conta desativada (active = false)
+	EventUserDeleted   = "user.deleted"
+)
+
+// WebhookEventTypes lista os eventos aos quais uma assinatura pode se inscrever.
+var WebhookEventTypes = []string{EventUserCreated, EventUserUpdated, EventUserSuspended, EventUserDeleted}
+
+// Status de uma entrega de webhook.
+const (
+	WebhookDeliveryPending   = "pending"   // aguardando a primeira tentativa ou uma nova tentativa
+	WebhookDeliverySucceeded = "succeeded" // o endpoint respondeu 2xx
+	WebhookDeliveryFailed    = "failed"    // tentativas esgotadas
+)
+
+// WebhookSubscription é um endpoint externo (ex: billing, CRM) que recebe os eventos
+// assinados. O segredo é usado no HMAC-SHA256 das entregas e só é exibido na criação.
+type WebhookSubscription struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	URL         string    `gorm:"type:text;not null" json:"url"`
+	Description string    `gorm:"size:255" json:"description"`
+	Events      string    `gorm:"size:255;not null" json:"-"` // separados por espaço
+	Secret      string    `gorm:"size:64;not null" json:"-"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	// ConsecutiveFailures conta as tentativas com falha desde a última entrega bem-sucedida;
+	// ao atingir o limite configurado, a assinatura é desativada automaticamente.
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `gorm:"size:255" json:"disabled_reason,omitempty"`
+	CreatedBy           *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt           time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID da assinatura antes da criação.
+func (subscription *WebhookSubscription) BeforeCreate(tx *gorm.DB) (err error) {
+	subscription.ID = uuid.New()
+	return
+}
+
+// EventList retorna os eventos assinados.
+func (subscription WebhookSubscription) EventList() []string {
+	return strings.Fields(subscription.Events)
+}
+
+// Subscribes informa se a assinatura recebe o evento.
+func (subscription WebhookSubscription) Subscribes(eventType string) bool {
+	return slices.Contains(subscription.EventList(), eventType)
+}
+
+// WebhookDelivery é o envio de um evento a uma assinatura, com o resultado da última
+// tentativa. Forma o log de entregas consultado pela API.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"` // repetido nas reentregas
+	EventType      string     `gorm:"size:64;not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"-"`
+	Status         string     `gorm:"size:16;not null;index" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body,omitempty"` // truncado
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	DurationMs     int64      `json:"duration_ms,omitempty"`
+	// RedeliveryOf aponta a entrega original quando esta foi criada por reentrega manual.
+	RedeliveryOf *uuid.UUID `gorm:"type:uuid" json:"redelivery_of,omitempty"`
+	CreatedAt    time.Time  `gorm:"not null;index" json:"created_at"`
+}
+
+// BeforeCreate é um hook do GORM que gera o UUID da entrega antes da criação.
+func (delivery *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	delivery.ID = uuid.New()
+	return
+}
+
+// WebhookCreateRequest define a estrutura para criar uma assinatura de webhook. O endereço
+// de destino é verificado a cada envio: faixas internas são recusadas (veja webhooks.NewClient).
+type WebhookCreateRequest struct {
+	URL         string   `json:"url" binding:"required,http_url,max=2048"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required,min=1,dive,oneof=user.created user.updated user.suspended user.deleted"`
+}
+
+// WebhookUpdateRequest define os campos que podem ser alterados em uma assinatura.
+// Reativar (active = true) zera a contagem de falhas.
+type WebhookUpdateRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,http_url,max=2048"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"omitempty,min=1,dive,oneof=user.created user.updated user.suspended user.deleted"`
+	Active      *bool    `json:"active"`
+}
@@ -0,0 +1,258 @@
+package oidc
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// AuthorizeRequest contém os parâmetros da requisição de autorização (RFC 6749, seção 4.1.1,
+// e RFC 7636). Os mesmos campos circulam como campos ocultos no formulário de login.
+type AuthorizeRequest struct {
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	ResponseType        string `form:"response_type" json:"response_type"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state,omitempty"`
+	Nonce               string `form:"nonce" json:"nonce,omitempty"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Prompt              string `form:"prompt" json:"prompt,omitempty"`
+}
+
+// authorizeError é um erro da requisição de autorização. Quando redirect é falso, o
+// client_id ou a redirect_uri não são confiáveis e o erro é exibido ao usuário em vez de
+// ser enviado para a aplicação (RFC 6749, seção 4.1.2.1).
+type authorizeError struct {
+	code        string
+	description string
+	redirect    bool
+}
+
+// AuthorizeHandler valida a requisição de autorização e exibe a tela de login.
+func (p *Provider) AuthorizeHandler(c *gin.Context) {
+	var req AuthorizeRequest
+	_ = c.ShouldBindQuery(&req)
+
+	client, authErr := p.validateAuthorizeRequest(req)
+	if authErr != nil {
+		p.respondAuthorizeError(c, req, authErr)
+		return
+	}
+	if slices.Contains(strings.Fields(req.Prompt), "none") {
+		// Não há sessão no provedor: toda autorização exige login interativo.
+		p.respondAuthorizeError(c, req, &authorizeError{code: "login_required", redirect: true})
+		return
+	}
+	p.renderLogin(c, http.StatusOK, client, req, "")
+}
+
+// LoginHandler recebe o formulário de login, autentica o usuário com auth.AuthenticateUser e
+// segue para o consentimento ou diretamente para a emissão do código.
+func (p *Provider) LoginHandler(c *gin.Context) {
+	var req AuthorizeRequest
+	_ = c.ShouldBind(&req)
+
+	client, authErr := p.validateAuthorizeRequest(req)
+	if authErr != nil {
+		p.respondAuthorizeError(c, req, authErr)
+		return
+	}
+
+	user, err := auth.AuthenticateUser(c.PostForm("email"), c.PostForm("password"))
+	if err != nil {
+		log.Printf("WARN: Falha de login OIDC para o cliente %s (IP: %s).", client.ClientID, c.ClientIP())
+		p.renderLogin(c, http.StatusUnauthorized, client, req, err.Error())
+		return
+	}
+	authTime := time.Now()
+
+	scopes := strings.Fields(req.Scope)
+	needsConsent := !client.SkipConsent
+	if needsConsent && !slices.Contains(strings.Fields(req.Prompt), "consent") {
+		granted, err := services.HasOAuthConsent(user.ID, client.ClientID, scopes)
+		if err != nil {
+			p.respondAuthorizeError(c, req, &authorizeError{code: "server_error", redirect: true})
+			return
+		}
+		needsConsent = !granted
+	}
+	if !needsConsent {
+		p.issueCode(c, req, user.ID, authTime)
+		return
+	}
+
+	consentToken, err := p.sign(&consentClaims{
+		Request:  req,
+		AuthTime: authTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   user.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(authTime.Add(consentTokenDuration)),
+		},
+	}, typeConsentToken)
+	if err != nil {
+		log.Printf("ERROR: Falha ao assinar token de consentimento para usuário ID %s: %v", user.ID, err)
+		p.respondAuthorizeError(c, req, &authorizeError{code: "server_error", redirect: true})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	p.ConsentPage(c, ConsentPageData{
+		Action:       p.Issuer + "/oauth/authorize/consent",
+		ClientName:   client.Name,
+		UserEmail:    user.Email,
+		Scopes:       describeScopes(scopes),
+		ConsentToken: consentToken,
+	})
+}
+
+// ConsentHandler recebe a decisão do usuário na tela de consentimento.
+func (p *Provider) ConsentHandler(c *gin.Context) {
+	var claims consentClaims
+	if err := p.parse(c.PostForm("consent_token"), &claims, typeConsentToken); err != nil {
+		log.Printf("WARN: Token de consentimento inválido (IP: %s): %v", c.ClientIP(), err)
+		p.renderError(c, http.StatusBadRequest, "A solicitação de autorização expirou. Volte à aplicação e tente novamente.")
+		return
+	}
+	req := claims.Request
+
+	client, authErr := p.validateAuthorizeRequest(req)
+	if authErr != nil {
+		p.respondAuthorizeError(c, req, authErr)
+		return
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		p.renderError(c, http.StatusBadRequest, "Solicitação de autorização inválida.")
+		return
+	}
+
+	if c.PostForm("decision") != "approve" {
+		log.Printf("INFO: Usuário ID %s negou acesso ao cliente OAuth %s.", userID, client.ClientID)
+		p.respondAuthorizeError(c, req, &authorizeError{code: "access_denied", description: "o usuário negou o acesso", redirect: true})
+		return
+	}
+
+	if err := services.SaveOAuthConsent(userID, client.ClientID, strings.Fields(req.Scope)); err != nil {
+		p.respondAuthorizeError(c, req, &authorizeError{code: "server_error", redirect: true})
+		return
+	}
+	p.issueCode(c, req, userID, time.Unix(claims.AuthTime, 0))
+}
+
+// validateAuthorizeRequest confere cliente, redirect_uri, response_type, escopos e PKCE.
+func (p *Provider) validateAuthorizeRequest(req AuthorizeRequest) (models.OAuthClient, *authorizeError) {
+	if req.ClientID == "" {
+		return models.OAuthClient{}, &authorizeError{code: "invalid_request", description: "client_id é obrigatório"}
+	}
+	client, err := services.GetOAuthClient(req.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return client, &authorizeError{code: "invalid_client", description: "cliente não registrado"}
+		}
+		return client, &authorizeError{code: "server_error", description: "erro ao buscar o cliente"}
+	}
+	// A redirect_uri deve ser idêntica a uma das registradas (sem correspondência parcial).
+	if !slices.Contains(client.RedirectURIList(), req.RedirectURI) {
+		return client, &authorizeError{code: "invalid_request", description: "redirect_uri não registrada para o cliente"}
+	}
+
+	if req.ResponseType != "code" {
+		return client, &authorizeError{code: "unsupported_response_type", description: "apenas response_type=code é suportado", redirect: true}
+	}
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		return client, &authorizeError{code: "invalid_scope", description: "scope é obrigatório", redirect: true}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) || !slices.Contains(client.ScopeList(), scope) {
+			return client, &authorizeError{code: "invalid_scope", description: "escopo não permitido: " + scope, redirect: true}
+		}
+	}
+	// PKCE é obrigatório para todos os clientes, inclusive os confidenciais.
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return client, &authorizeError{code: "invalid_request", description: "code_challenge com code_challenge_method=S256 é obrigatório", redirect: true}
+	}
+	if len(req.CodeChallenge) != 43 {
+		return client, &authorizeError{code: "invalid_request", description: "code_challenge inválido", redirect: true}
+	}
+	return client, nil
+}
+
+// issueCode grava o código de autorização e redireciona o navegador de volta ao cliente.
+func (p *Provider) issueCode(c *gin.Context, req AuthorizeRequest, userID uuid.UUID, authTime time.Time) {
+	code, err := services.CreateAuthorizationCode(&models.OAuthAuthorizationCode{
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(codeDuration),
+	})
+	if err != nil {
+		p.respondAuthorizeError(c, req, &authorizeError{code: "server_error", redirect: true})
+		return
+	}
+	log.Printf("INFO: Código de autorização emitido para usuário ID %s (cliente %s).", userID, req.ClientID)
+	p.redirect(c, req, url.Values{"code": {code}})
+}
+
+// respondAuthorizeError envia o erro para a redirect_uri do cliente ou, quando ela não é
+// confiável, exibe uma página de erro.
+func (p *Provider) respondAuthorizeError(c *gin.Context, req AuthorizeRequest, authErr *authorizeError) {
+	if !authErr.redirect {
+		p.renderError(c, http.StatusBadRequest, "Requisição de autorização inválida: "+authErr.description)
+		return
+	}
+	params := url.Values{"error": {authErr.code}}
+	if authErr.description != "" {
+		params.Set("error_description", authErr.description)
+	}
+	p.redirect(c, req, params)
+}
+
+// redirect volta para a redirect_uri com os parâmetros, o state e o emissor (RFC 9207).
+func (p *Provider) redirect(c *gin.Context, req AuthorizeRequest, params url.Values) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		p.renderError(c, http.StatusBadRequest, "redirect_uri inválida")
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	query.Set("iss", p.Issuer)
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+func (p *Provider) renderLogin(c *gin.Context, status int, client models.OAuthClient, req AuthorizeRequest, errorMessage string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	p.LoginPage(c, status, LoginPageData{
+		Action:     p.Issuer + "/oauth/authorize",
+		ClientName: client.Name,
+		Request:    req,
+		Error:      errorMessage,
+	})
+}
@@ -0,0 +1,178 @@
+package oidc
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// tokenInfo descreve um token emitido para um cliente: um access token do fluxo
+// authorization code ou um token de cliente de serviço (client_credentials).
+type tokenInfo struct {
+	ClientID  string
+	Subject   string
+	Scope     string
+	Issuer    string
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// inspectToken valida um token emitido para um cliente. Tokens de login de usuários e
+// tokens de acesso pessoal não são emitidos para clientes: a introspecção os valida com
+// inspectUserToken, e a revogação (RFC 7009) os ignora.
+func (p *Provider) inspectToken(tokenString string) (tokenInfo, bool) {
+	var access AccessTokenClaims
+	if err := p.parse(tokenString, &access, typeAccessToken); err == nil && access.ID != "" {
+		info := tokenInfo{ClientID: access.ClientID, Subject: access.Subject, Scope: access.Scope, Issuer: access.Issuer, JTI: access.ID, ExpiresAt: access.ExpiresAt.Time}
+		if access.IssuedAt != nil {
+			info.IssuedAt = access.IssuedAt.Time
+		}
+		return info, true
+	}
+
+	claims, err := auth.ParseToken(tokenString)
+	if err != nil || !claims.IsService() || claims.ID == "" {
+		return tokenInfo{}, false
+	}
+	info := tokenInfo{ClientID: claims.ClientID, Subject: claims.Subject, Scope: claims.Scope, JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	return info, true
+}
+
+// inspectUserToken valida um token de login de usuário (JWT HS256) ou um token de acesso
+// pessoal (pat_...) com as mesmas regras do middleware.AuthMiddleware: sessão aberta, jti não
+// revogado, token não expirado e usuário ativo. O uso não é registrado na sessão nem no token.
+func inspectUserToken(tokenString string) (tokenInfo, bool) {
+	if strings.HasPrefix(tokenString, services.APITokenPrefix) {
+		token, user, err := services.LookupAPIToken(tokenString)
+		if err != nil {
+			return tokenInfo{}, false
+		}
+		return tokenInfo{Subject: user.ID.String(), Scope: token.Scopes, IssuedAt: token.CreatedAt, ExpiresAt: token.ExpiresAt}, true
+	}
+
+	claims, err := auth.ParseToken(tokenString)
+	if err != nil || claims.IsService() {
+		return tokenInfo{}, false
+	}
+	if _, _, err := auth.ValidateUserToken(claims, ""); err != nil {
+		return tokenInfo{}, false
+	}
+	info := tokenInfo{Subject: claims.UserID, JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	return info, true
+}
+
+// IntrospectHandler informa se um token está ativo e quais são suas claims (RFC 7662).
+// Apenas clientes confidenciais (ex: servidores de recursos) podem consultar tokens.
+func (p *Provider) IntrospectHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	client, ok := p.authenticateClient(c)
+	if !ok {
+		return
+	}
+	if client.Public {
+		p.rejectClient(c, false)
+		return
+	}
+	tokenString := c.PostForm("token")
+	if tokenString == "" {
+		writeTokenError(c, http.StatusBadRequest, "invalid_request", "token é obrigatório")
+		return
+	}
+
+	info, ok := p.inspectToken(tokenString)
+	if ok {
+		ok = tokenActive(info)
+	} else {
+		info, ok = inspectUserToken(tokenString)
+	}
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	response := gin.H{
+		"active":     true,
+		"token_type": "Bearer",
+		"sub":        info.Subject,
+		"exp":        info.ExpiresAt.Unix(),
+	}
+	// Tokens de login não têm cliente nem escopos (o acesso é completo), e tokens de acesso
+	// pessoal não têm jti.
+	for claim, value := range map[string]string{"client_id": info.ClientID, "scope": info.Scope, "jti": info.JTI} {
+		if value != "" {
+			response[claim] = value
+		}
+	}
+	if !info.IssuedAt.IsZero() {
+		response["iat"] = info.IssuedAt.Unix()
+	}
+	if info.Issuer != "" {
+		response["iss"] = info.Issuer
+		response["aud"] = info.ClientID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// tokenActive confere se o token não foi revogado e, para tokens de usuário, se a conta
+// ainda existe e está ativa.
+func tokenActive(info tokenInfo) bool {
+	revoked, err := services.IsTokenRevoked(info.JTI)
+	if err != nil || revoked {
+		return false
+	}
+	if info.Issuer == "" {
+		return true // token de cliente de serviço: não há usuário
+	}
+	userID, err := uuid.Parse(info.Subject)
+	if err != nil {
+		return false
+	}
+	user, err := services.GetUserByID(userID)
+	return err == nil && user.Active
+}
+
+// RevocationHandler revoga um token emitido para o cliente autenticado (RFC 7009). Tokens
+// inválidos, já expirados ou de outros clientes são ignorados, e a resposta é sempre 200,
+// para não revelar se o token existia.
+func (p *Provider) RevocationHandler(c *gin.Context) {
+	client, ok := p.authenticateClient(c)
+	if !ok {
+		return
+	}
+	tokenString := c.PostForm("token")
+	if tokenString == "" {
+		writeTokenError(c, http.StatusBadRequest, "invalid_request", "token é obrigatório")
+		return
+	}
+
+	info, ok := p.inspectToken(tokenString)
+	if ok && info.ClientID == client.ClientID {
+		if err := services.RevokeTokenID(info.JTI, client.ClientID, info.ExpiresAt); err != nil {
+			// A RFC 7009 prevê 503 para que o cliente tente novamente.
+			writeTokenError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+			return
+		}
+	} else if ok {
+		log.Printf("WARN: Cliente OAuth %s tentou revogar token do cliente %s (IP: %s)", client.ClientID, info.ClientID, c.ClientIP())
+	}
+	c.Status(http.StatusOK)
+}
+
+// revokedAccessToken indica se o access token usado em /userinfo foi revogado.
+func revokedAccessToken(claims AccessTokenClaims) bool {
+	revoked, err := services.IsTokenRevoked(claims.ID)
+	return err != nil || revoked
+}
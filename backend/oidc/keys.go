@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// SigningKey é a chave RSA usada para assinar ID tokens e access tokens (RS256).
+type SigningKey struct {
+	ID         string // kid publicado no JWKS
+	PrivateKey *rsa.PrivateKey
+}
+
+// NewSigningKey cria uma SigningKey, derivando o kid do hash da chave pública.
+func NewSigningKey(key *rsa.PrivateKey) (*SigningKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &SigningKey{ID: base64.RawURLEncoding.EncodeToString(sum[:12]), PrivateKey: key}, nil
+}
+
+// LoadSigningKey lê uma chave RSA privada em PEM (PKCS#1 ou PKCS#8).
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("arquivo não contém um bloco PEM")
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed interface{}
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if key, ok = parsed.(*rsa.PrivateKey); !ok {
+				return nil, errors.New("a chave PKCS#8 não é RSA")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("tipo de bloco PEM não suportado: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key)
+}
+
+// GenerateSigningKey gera uma chave RSA de 2048 bits. Usada quando nenhuma chave é
+// configurada: os tokens emitidos deixam de ser válidos quando o processo reinicia.
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key)
+}
+
+// JSONWebKey é a representação JSON (RFC 7517) de uma chave pública RSA.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// PublicJWK retorna a chave pública no formato publicado em jwks_uri.
+func (k *SigningKey) PublicJWK() JSONWebKey {
+	pub := k.PrivateKey.PublicKey
+	return JSONWebKey{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: k.ID,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
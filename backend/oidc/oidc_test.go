@@ -0,0 +1,437 @@
+package oidc_test
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/oidc"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	issuer      = "http://idp.test"
+	redirectURI = "https://app.test/callback"
+	verifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type testEnv struct {
+	router   *gin.Engine
+	client   models.OAuthClient
+	secret   string
+	email    string
+	password string
+}
+
+func setupProvider(t *testing.T, skipConsent bool) testEnv {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	database.DB = db
+
+	key, err := oidc.GenerateSigningKey()
+	require.NoError(t, err)
+	router := gin.New()
+	oidc.NewProvider(issuer, key).RegisterRoutes(router)
+
+	env := testEnv{router: router, email: "ana." + uuid.NewString() + "@example.com", password: "senhaSegura123"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &models.User{Name: "Ana Souza", Email: env.email}, env.password))
+
+	env.client = models.OAuthClient{Name: "Wiki", RedirectURIs: redirectURI, Scopes: "openid profile email", SkipConsent: skipConsent}
+	env.secret, err = services.CreateOAuthClient(&env.client)
+	require.NoError(t, err)
+	return env
+}
+
+func challenge(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (env testEnv) authorizeParams() url.Values {
+	return url.Values{
+		"client_id":             {env.client.ClientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func (env testEnv) post(path string, form url.Values, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func (env testEnv) get(path string, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+// login envia o formulário de login e, se necessário, aprova o consentimento.
+// Retorna o código de autorização recebido na redirect_uri.
+func (env testEnv) login(t *testing.T) string {
+	form := env.authorizeParams()
+	form.Set("email", env.email)
+	form.Set("password", env.password)
+	w := env.post("/oauth/authorize", form, nil)
+
+	if w.Code == http.StatusOK {
+		match := regexp.MustCompile(`name="consent_token" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+		require.NotNil(t, match, "Consent page should contain the consent token")
+		w = env.post("/oauth/authorize/consent", url.Values{"consent_token": {match[1]}, "decision": {"approve"}}, nil)
+	}
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	assert.Equal(t, issuer, location.Query().Get("iss"))
+	return location.Query().Get("code")
+}
+
+func (env testEnv) exchange(code, codeVerifier string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := env.post("/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}, map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(env.client.ClientID+":"+env.secret))})
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	env := setupProvider(t, false)
+
+	w := env.get("/oauth/authorize?"+env.authorizeParams().Encode(), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Wiki")
+
+	code := env.login(t)
+	require.NotEmpty(t, code)
+
+	w, body := env.exchange(code, verifier)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Bearer", body["token_type"])
+
+	// O ID token deve ser verificável com a chave publicada no JWKS.
+	w = env.get("/oauth/jwks", nil)
+	var jwks struct {
+		Keys []oidc.JSONWebKey `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	var idClaims oidc.IDTokenClaims
+	token, err := jwt.ParseWithClaims(body["id_token"].(string), &idClaims, func(*jwt.Token) (interface{}, error) { return publicKey, nil },
+		jwt.WithIssuer(issuer), jwt.WithAudience(env.client.ClientID))
+	require.NoError(t, err)
+	assert.Equal(t, jwks.Keys[0].Kid, token.Header["kid"])
+	assert.Equal(t, "n-0S6_WzA2Mj", idClaims.Nonce)
+	assert.Equal(t, env.email, idClaims.Email)
+	assert.Equal(t, "Ana Souza", idClaims.Name)
+	assert.NotNil(t, idClaims.AuthTime)
+
+	// O access token dá acesso a /userinfo.
+	w = env.get("/userinfo", map[string]string{"Authorization": "Bearer " + body["access_token"].(string)})
+	require.Equal(t, http.StatusOK, w.Code)
+	var info map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &info)
+	assert.Equal(t, idClaims.Subject, info["sub"])
+	assert.Equal(t, env.email, info["email"])
+
+	// O ID token não é aceito como access token.
+	w = env.get("/userinfo", map[string]string{"Authorization": "Bearer " + body["id_token"].(string)})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// O código só pode ser usado uma vez.
+	w, body = env.exchange(code, verifier)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	// Com o consentimento já registrado, o próximo login não exibe a tela novamente.
+	form := env.authorizeParams()
+	form.Set("email", env.email)
+	form.Set("password", env.password)
+	w = env.post("/oauth/authorize", form, nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestTokenRejectsWrongVerifierAndClient(t *testing.T) {
+	env := setupProvider(t, true)
+
+	code := env.login(t)
+	w, body := env.exchange(code, strings.Repeat("a", 43))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	code = env.login(t)
+	env.secret = "segredo-errado"
+	w, body = env.exchange(code, verifier)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "invalid_client", body["error"])
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	env := setupProvider(t, false)
+
+	// redirect_uri não registrada: o erro é exibido, nunca redirecionado.
+	params := env.authorizeParams()
+	params.Set("redirect_uri", "https://evil.test/callback")
+	w := env.get("/oauth/authorize?"+params.Encode(), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// Sem PKCE: erro enviado ao cliente.
+	params = env.authorizeParams()
+	params.Del("code_challenge")
+	w = env.get("/oauth/authorize?"+params.Encode(), nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+
+	// Senha incorreta: a tela de login é exibida novamente.
+	form := env.authorizeParams()
+	form.Set("email", env.email)
+	form.Set("password", "errada")
+	w = env.post("/oauth/authorize", form, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Negar o consentimento devolve access_denied.
+	form.Set("password", env.password)
+	w = env.post("/oauth/authorize", form, nil)
+	match := regexp.MustCompile(`name="consent_token" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	require.NotNil(t, match)
+	w = env.post("/oauth/authorize/consent", url.Values{"consent_token": {match[1]}, "decision": {"deny"}}, nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, _ = url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, "access_denied", location.Query().Get("error"))
+}
+
+func TestDiscovery(t *testing.T) {
+	env := setupProvider(t, false)
+
+	w := env.get("/.well-known/openid-configuration", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var config map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &config)
+	assert.Equal(t, issuer, config["issuer"])
+	assert.Equal(t, issuer+"/oauth/token", config["token_endpoint"])
+	assert.Equal(t, []interface{}{"S256"}, config["code_challenge_methods_supported"])
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	env := setupProvider(t, false)
+	protected := env.router.Group("/api/users", middleware.AuthMiddleware())
+	protected.GET("", middleware.RequireScope(models.ScopeUsersRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"client": c.GetString("clientID"), "user": c.GetString("userID")})
+	})
+	protected.DELETE("/:id", middleware.RequireScope(models.ScopeUsersWrite), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	service := models.OAuthClient{Name: "Billing", Scopes: "users:read", Service: true}
+	secret, err := services.CreateOAuthClient(&service)
+	require.NoError(t, err)
+	basic := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(service.ClientID+":"+secret))}
+
+	w := env.post("/oauth/token", url.Values{"grant_type": {"client_credentials"}}, basic)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "users:read", body["scope"])
+	assert.Equal(t, float64(3600), body["expires_in"])
+	bearer := map[string]string{"Authorization": "Bearer " + body["access_token"].(string)}
+
+	// O token identifica o cliente, não um usuário, e só permite leitura.
+	w = env.get("/api/users", bearer)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"client":"`+service.ClientID+`","user":""}`, w.Body.String())
+	req, _ := http.NewRequest("DELETE", "/api/users/"+uuid.NewString(), nil)
+	req.Header.Set("Authorization", bearer["Authorization"])
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Escopo não concedido ao cliente.
+	w = env.post("/oauth/token", url.Values{"grant_type": {"client_credentials"}, "scope": {"users:write"}}, basic)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_scope")
+
+	// Clientes de login (não de serviço) não usam client_credentials.
+	w = env.post("/oauth/token", url.Values{"grant_type": {"client_credentials"}}, map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(env.client.ClientID+":"+env.secret))})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unauthorized_client")
+
+	// Segredo errado.
+	w = env.post("/oauth/token", url.Values{"grant_type": {"client_credentials"}, "client_id": {service.ClientID}, "client_secret": {"errado"}}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestIntrospectionAndRevocation(t *testing.T) {
+	env := setupProvider(t, true)
+	env.router.GET("/api/users", middleware.AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	clientAuth := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(env.client.ClientID+":"+env.secret))}
+
+	service := models.OAuthClient{Name: "Resource Server", Scopes: "users:read", Service: true}
+	serviceSecret, err := services.CreateOAuthClient(&service)
+	require.NoError(t, err)
+	serviceAuth := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(service.ClientID+":"+serviceSecret))}
+
+	_, body := env.exchange(env.login(t), verifier)
+	accessToken := body["access_token"].(string)
+
+	introspect := func(token string, header map[string]string) map[string]interface{} {
+		w := env.post("/oauth/introspect", url.Values{"token": {token}}, header)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var info map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		return info
+	}
+
+	// Um servidor de recursos consulta o access token de outro cliente.
+	info := introspect(accessToken, serviceAuth)
+	assert.Equal(t, true, info["active"])
+	assert.Equal(t, env.client.ClientID, info["client_id"])
+	assert.Equal(t, "openid profile email", info["scope"])
+	assert.Equal(t, issuer, info["iss"])
+	assert.NotEmpty(t, info["sub"])
+	assert.Equal(t, false, introspect("nao-e-um-token", serviceAuth)["active"])
+
+	// Revogação por outro cliente é ignorada, sem revelar o motivo.
+	w := env.post("/oauth/revoke", url.Values{"token": {accessToken}}, serviceAuth)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, introspect(accessToken, serviceAuth)["active"])
+
+	// Revogado pelo próprio cliente, o token deixa de valer em /userinfo.
+	w = env.post("/oauth/revoke", url.Values{"token": {accessToken}, "token_type_hint": {"access_token"}}, clientAuth)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, introspect(accessToken, serviceAuth)["active"])
+	w = env.get("/userinfo", map[string]string{"Authorization": "Bearer " + accessToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = env.post("/oauth/revoke", url.Values{"token": {accessToken}}, clientAuth)
+	assert.Equal(t, http.StatusOK, w.Code, "Revoking twice is not an error")
+
+	// Tokens de cliente de serviço também podem ser consultados e revogados.
+	w = env.post("/oauth/token", url.Values{"grant_type": {"client_credentials"}}, serviceAuth)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	serviceToken := body["access_token"].(string)
+	info = introspect(serviceToken, clientAuth)
+	assert.Equal(t, true, info["active"])
+	assert.Equal(t, service.ClientID, info["client_id"])
+	assert.Equal(t, "users:read", info["scope"])
+	assert.Equal(t, http.StatusOK, env.get("/api/users", map[string]string{"Authorization": "Bearer " + serviceToken}).Code)
+
+	w = env.post("/oauth/revoke", url.Values{"token": {serviceToken}}, serviceAuth)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, introspect(serviceToken, clientAuth)["active"])
+	assert.Equal(t, http.StatusUnauthorized, env.get("/api/users", map[string]string{"Authorization": "Bearer " + serviceToken}).Code)
+
+	// Sem autenticação do cliente, a consulta é recusada.
+	w = env.post("/oauth/introspect", url.Values{"token": {serviceToken}}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestIntrospectionUserTokens(t *testing.T) {
+	env := setupProvider(t, true)
+	env.router.GET("/api/users", middleware.AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	clientAuth := map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(env.client.ClientID+":"+env.secret))}
+	introspect := func(token string) map[string]interface{} {
+		w := env.post("/oauth/introspect", url.Values{"token": {token}}, clientAuth)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var info map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		return info
+	}
+	// A introspecção deve concordar com o AuthMiddleware.
+	consistent := func(token string, active bool) {
+		t.Helper()
+		assert.Equal(t, active, introspect(token)["active"])
+		expected := http.StatusUnauthorized
+		if active {
+			expected = http.StatusOK
+		}
+		assert.Equal(t, expected, env.get("/api/users", map[string]string{"Authorization": "Bearer " + token}).Code)
+	}
+
+	user, err := services.FindUserByEmail(env.email)
+	require.NoError(t, err)
+	loginToken, err := auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	apiToken := models.APIToken{UserID: user.ID, Name: "CI", Scopes: "users:read", ExpiresAt: time.Now().Add(time.Hour)}
+	pat, err := services.CreateAPIToken(services.SystemActor, &apiToken)
+	require.NoError(t, err)
+
+	info := introspect(loginToken)
+	assert.Equal(t, true, info["active"])
+	assert.Equal(t, user.ID.String(), info["sub"])
+	assert.NotContains(t, info, "client_id")
+	consistent(loginToken, true)
+
+	info = introspect(pat)
+	assert.Equal(t, true, info["active"])
+	assert.Equal(t, user.ID.String(), info["sub"])
+	assert.Equal(t, "users:read", info["scope"])
+	consistent(pat, true)
+	consistent(pat+"x", false)
+
+	// Sessão encerrada: o token de login deixa de valer, o token de acesso pessoal não.
+	sessions, err := services.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.NoError(t, services.RevokeSession(services.SystemActor, user.ID, sessions[0].ID))
+	consistent(loginToken, false)
+	consistent(pat, true)
+
+	// Usuário desativado: nenhum dos tokens vale.
+	loginToken, err = auth.StartSession(user, auth.SessionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("active", false).Error)
+	consistent(loginToken, false)
+	consistent(pat, false)
+
+	// Token de acesso pessoal expirado.
+	require.NoError(t, database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("active", true).Error)
+	require.NoError(t, database.DB.Model(&apiToken).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	consistent(pat, false)
+}
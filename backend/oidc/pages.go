@@ -0,0 +1,125 @@
+package oidc
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginPageData são os dados passados para a página de login.
+type LoginPageData struct {
+	Action     string // URL para a qual o formulário deve ser enviado
+	ClientName string
+	Request    AuthorizeRequest
+	Error      string
+}
+
+// ConsentPageData são os dados passados para a página de consentimento. O formulário deve
+// enviar consent_token e decision ("approve" ou "deny") para Action.
+type ConsentPageData struct {
+	Action       string
+	ClientName   string
+	UserEmail    string
+	Scopes       []ScopeDescription
+	ConsentToken string
+}
+
+// ScopeDescription é um escopo solicitado, com a descrição exibida ao usuário.
+type ScopeDescription struct {
+	Name        string
+	Description string
+}
+
+var scopeDescriptions = map[string]string{
+	"openid":  "Confirmar a sua identidade",
+	"profile": "Ver o seu nome",
+	"email":   "Ver o seu endereço de e-mail",
+}
+
+func describeScopes(scopes []string) []ScopeDescription {
+	described := make([]ScopeDescription, 0, len(scopes))
+	for _, scope := range scopes {
+		described = append(described, ScopeDescription{Name: scope, Description: scopeDescriptions[scope]})
+	}
+	return described
+}
+
+const pageLayout = `{{define "head"}}<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>
+body { font-family: sans-serif; background: #f4f4f5; display: flex; justify-content: center; padding-top: 10vh; }
+main { background: #fff; padding: 2rem; border-radius: 8px; width: 320px; box-shadow: 0 1px 4px rgba(0,0,0,.1); }
+input[type=email], input[type=password] { width: 100%; box-sizing: border-box; margin: .25rem 0 1rem; padding: .5rem; }
+button { padding: .5rem 1rem; margin-right: .5rem; }
+.error { color: #b91c1c; }
+</style>
+</head>
+<body><main>{{end}}
+{{define "foot"}}</main></body></html>{{end}}`
+
+var loginTemplate = template.Must(template.New("login").Parse(pageLayout + `
+{{template "head" "Entrar"}}
+<h1>Entrar</h1>
+<p>Para continuar em <strong>{{.ClientName}}</strong>.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="prompt" value="{{.Request.Prompt}}">
+<label>E-mail <input type="email" name="email" required autofocus></label>
+<label>Senha <input type="password" name="password" required></label>
+<button type="submit">Entrar</button>
+</form>
+{{template "foot"}}`))
+
+var consentTemplate = template.Must(template.New("consent").Parse(pageLayout + `
+{{template "head" "Autorizar acesso"}}
+<h1>Autorizar acesso</h1>
+<p><strong>{{.ClientName}}</strong> quer acessar a sua conta {{.UserEmail}} para:</p>
+<ul>{{range .Scopes}}<li>{{.Description}}</li>{{end}}</ul>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="consent_token" value="{{.ConsentToken}}">
+<button type="submit" name="decision" value="approve">Permitir</button>
+<button type="submit" name="decision" value="deny">Negar</button>
+</form>
+{{template "foot"}}`))
+
+var errorTemplate = template.Must(template.New("error").Parse(pageLayout + `
+{{template "head" "Erro"}}
+<h1>Não foi possível continuar</h1>
+<p class="error">{{.}}</p>
+{{template "foot"}}`))
+
+// DefaultLoginPage renderiza a página de login embutida.
+func DefaultLoginPage(c *gin.Context, status int, data LoginPageData) {
+	renderHTML(c, status, loginTemplate, data)
+}
+
+// DefaultConsentPage renderiza a página de consentimento embutida.
+func DefaultConsentPage(c *gin.Context, data ConsentPageData) {
+	renderHTML(c, http.StatusOK, consentTemplate, data)
+}
+
+func (p *Provider) renderError(c *gin.Context, status int, message string) {
+	renderHTML(c, status, errorTemplate, message)
+}
+
+func renderHTML(c *gin.Context, status int, tmpl *template.Template, data interface{}) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := tmpl.Execute(c.Writer, data); err != nil {
+		log.Printf("ERROR: Falha ao renderizar página %s: %v", tmpl.Name(), err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package oidc implementa o modo provedor OpenID Connect: outras aplicações internas
+// autenticam seus usuários por este serviço usando o fluxo authorization code com PKCE.
+package oidc
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// supportedScopes lista os escopos que este provedor entende.
+var supportedScopes = []string{"openid", "profile", "email"}
+
+// Provider reúne a configuração do provedor OpenID Connect e expõe seus handlers.
+type Provider struct {
+	Issuer string
+	Key    *SigningKey
+
+	// LoginPage e ConsentPage renderizam as telas do fluxo de autorização. Podem ser
+	// substituídas para usar páginas próprias, desde que enviem os mesmos campos de
+	// formulário para POST /oauth/authorize e POST /oauth/authorize/consent.
+	LoginPage   func(c *gin.Context, status int, data LoginPageData)
+	ConsentPage func(c *gin.Context, data ConsentPageData)
+}
+
+// NewProvider cria um provedor com as páginas de login e consentimento padrão.
+func NewProvider(issuer string, key *SigningKey) *Provider {
+	return &Provider{
+		Issuer:      strings.TrimRight(issuer, "/"),
+		Key:         key,
+		LoginPage:   DefaultLoginPage,
+		ConsentPage: DefaultConsentPage,
+	}
+}
+
+// NewProviderFromEnv cria o provedor a partir de OIDC_ISSUER e OIDC_SIGNING_KEY_FILE.
+// Sem chave configurada, uma chave temporária é gerada a cada inicialização.
+func NewProviderFromEnv() (*Provider, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, errors.New("OIDC_ISSUER não definida")
+	}
+
+	var (
+		key *SigningKey
+		err error
+	)
+	if path := os.Getenv("OIDC_SIGNING_KEY_FILE"); path != "" {
+		key, err = LoadSigningKey(path)
+	} else {
+		log.Print("WARN: OIDC_SIGNING_KEY_FILE não definida. Usando chave de assinatura temporária; tokens emitidos deixarão de ser válidos quando o servidor reiniciar.")
+		key, err = GenerateSigningKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(issuer, key), nil
+}
+
+// RegisterRoutes registra os endpoints OpenID Connect na raiz do roteador, de forma que
+// os caminhos correspondam aos anunciados em /.well-known/openid-configuration.
+func (p *Provider) RegisterRoutes(r gin.IRouter) {
+	r.GET("/.well-known/openid-configuration", p.DiscoveryHandler)
+	r.GET("/oauth/jwks", p.JWKSHandler)
+	r.GET("/oauth/authorize", p.AuthorizeHandler)
+	r.POST("/oauth/authorize", p.LoginHandler)
+	r.POST("/oauth/authorize/consent", p.ConsentHandler)
+	r.POST("/oauth/token", p.TokenHandler)
+	r.POST("/oauth/introspect", p.IntrospectHandler)
+	r.POST("/oauth/revoke", p.RevocationHandler)
+	r.GET("/userinfo", p.UserInfoHandler)
+	r.POST("/userinfo", p.UserInfoHandler)
+}
+
+// DiscoveryHandler publica os metadados do provedor (OpenID Connect Discovery 1.0).
+func (p *Provider) DiscoveryHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                         p.Issuer,
+		"authorization_endpoint":                         p.Issuer + "/oauth/authorize",
+		"token_endpoint":                                 p.Issuer + "/oauth/token",
+		"userinfo_endpoint":                              p.Issuer + "/userinfo",
+		"jwks_uri":                                       p.Issuer + "/oauth/jwks",
+		"introspection_endpoint":                         p.Issuer + "/oauth/introspect",
+		"revocation_endpoint":                            p.Issuer + "/oauth/revoke",
+		"scopes_supported":                               supportedScopes,
+		"response_types_supported":                       []string{"code"},
+		"response_modes_supported":                       []string{"query"},
+		"grant_types_supported":                          []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":                        []string{"public"},
+		"id_token_signing_alg_values_supported":          []string{"RS256"},
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"introspection_endpoint_auth_methods_supported":  []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint_auth_methods_supported":     []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"claims_supported":                               []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email", "email_verified", "updated_at"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// JWKSHandler publica a chave pública usada para verificar os tokens.
+func (p *Provider) JWKSHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": []JSONWebKey{p.Key.PublicJWK()}})
+}
+
+// userClaims monta as claims do usuário liberadas pelos escopos (usadas em /userinfo e no ID token).
+func userClaims(user models.User, scope string) gin.H {
+	scopes := strings.Fields(scope)
+	claims := gin.H{"sub": user.ID.String()}
+	if slices.Contains(scopes, "profile") {
+		claims["name"] = user.Name
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, "email") {
+		claims["email"] = user.Email
+		// O serviço ainda não confirma a posse dos endereços de e-mail.
+		claims["email_verified"] = false
+	}
+	return claims
+}
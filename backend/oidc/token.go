@@ -0,0 +1,260 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/auth"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// writeTokenError responde com um erro no formato da RFC 6749, seção 5.2.
+func writeTokenError(c *gin.Context, status int, code, description string) {
+	body := gin.H{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// TokenHandler troca um código de autorização por access token e ID token ou, para clientes
+// de serviço, emite um token de acesso à API (grant client_credentials).
+func (p *Provider) TokenHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := p.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	switch grantType := c.PostForm("grant_type"); grantType {
+	case "authorization_code":
+		if client.Service {
+			writeTokenError(c, http.StatusBadRequest, "unauthorized_client", "clientes de serviço usam apenas o grant client_credentials")
+			return
+		}
+		p.exchangeAuthorizationCode(c, client)
+	case "client_credentials":
+		p.clientCredentials(c, client)
+	case "":
+		writeTokenError(c, http.StatusBadRequest, "invalid_request", "grant_type é obrigatório")
+	default:
+		writeTokenError(c, http.StatusBadRequest, "unsupported_grant_type", "grant_type não suportado: "+grantType)
+	}
+}
+
+// authenticateClient identifica o cliente por client_secret_basic, client_secret_post ou,
+// para clientes públicos, apenas pelo client_id (a prova de posse vem do PKCE).
+func (p *Provider) authenticateClient(c *gin.Context) (models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// Na autenticação Basic, id e segredo são codificados como form-urlencoded (RFC 6749, seção 2.3.1).
+		var errID, errSecret error
+		clientID, errID = url.QueryUnescape(clientID)
+		secret, errSecret = url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			p.rejectClient(c, basic)
+			return models.OAuthClient{}, false
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		p.rejectClient(c, basic)
+		return models.OAuthClient{}, false
+	}
+
+	client, err := services.GetOAuthClient(clientID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			writeTokenError(c, http.StatusInternalServerError, "server_error", "")
+			return client, false
+		}
+		log.Printf("WARN: Cliente OAuth desconhecido no endpoint de token: %s (IP: %s)", clientID, c.ClientIP())
+		p.rejectClient(c, basic)
+		return client, false
+	}
+
+	if client.Public {
+		if secret != "" {
+			p.rejectClient(c, basic)
+			return client, false
+		}
+		return client, true
+	}
+	if !services.VerifyOAuthClientSecret(client, secret) {
+		log.Printf("WARN: Segredo inválido para o cliente OAuth %s (IP: %s)", clientID, c.ClientIP())
+		p.rejectClient(c, basic)
+		return client, false
+	}
+	return client, true
+}
+
+func (p *Provider) rejectClient(c *gin.Context, basic bool) {
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	writeTokenError(c, http.StatusUnauthorized, "invalid_client", "autenticação do cliente falhou")
+}
+
+func (p *Provider) exchangeAuthorizationCode(c *gin.Context, client models.OAuthClient) {
+	plainCode := c.PostForm("code")
+	verifier := c.PostForm("code_verifier")
+	if plainCode == "" || verifier == "" {
+		writeTokenError(c, http.StatusBadRequest, "invalid_request", "code e code_verifier são obrigatórios")
+		return
+	}
+
+	code, err := services.ConsumeAuthorizationCode(plainCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, services.ErrAuthorizationCodeExpired) {
+			writeTokenError(c, http.StatusBadRequest, "invalid_grant", "código de autorização inválido, expirado ou já utilizado")
+			return
+		}
+		writeTokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if code.ClientID != client.ClientID {
+		log.Printf("WARN: Cliente OAuth %s tentou usar código emitido para %s (IP: %s)", client.ClientID, code.ClientID, c.ClientIP())
+		writeTokenError(c, http.StatusBadRequest, "invalid_grant", "código de autorização emitido para outro cliente")
+		return
+	}
+	if c.PostForm("redirect_uri") != code.RedirectURI {
+		writeTokenError(c, http.StatusBadRequest, "invalid_grant", "redirect_uri difere da usada na autorização")
+		return
+	}
+	if !verifyPKCE(verifier, code.CodeChallenge) {
+		writeTokenError(c, http.StatusBadRequest, "invalid_grant", "code_verifier inválido")
+		return
+	}
+
+	user, err := services.GetUserByID(code.UserID)
+	if err != nil || !user.Active {
+		writeTokenError(c, http.StatusBadRequest, "invalid_grant", "usuário indisponível")
+		return
+	}
+
+	issuedAt := time.Now()
+	accessToken, err := p.issueAccessToken(user.ID.String(), client.ClientID, code.Scope, issuedAt)
+	if err != nil {
+		log.Printf("ERROR: Falha ao assinar access token para usuário ID %s: %v", user.ID, err)
+		writeTokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	response := gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(accessTokenDuration.Seconds()),
+		"scope":        code.Scope,
+	}
+	if strings.Contains(" "+code.Scope+" ", " openid ") {
+		idToken, err := p.issueIDToken(user, code, accessToken, issuedAt)
+		if err != nil {
+			log.Printf("ERROR: Falha ao assinar ID token para usuário ID %s: %v", user.ID, err)
+			writeTokenError(c, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		response["id_token"] = idToken
+	}
+
+	log.Printf("INFO: Tokens OIDC emitidos para usuário ID %s (cliente %s).", user.ID, client.ClientID)
+	c.JSON(http.StatusOK, response)
+}
+
+// clientCredentials emite um token para um cliente de serviço (RFC 6749, seção 4.4). O token
+// identifica o cliente, e não um usuário, e só dá acesso aos escopos concedidos.
+func (p *Provider) clientCredentials(c *gin.Context, client models.OAuthClient) {
+	if !client.Service || client.Public {
+		writeTokenError(c, http.StatusBadRequest, "unauthorized_client", "cliente não autorizado a usar client_credentials")
+		return
+	}
+
+	// Sem o parâmetro scope, todos os escopos do cliente são concedidos.
+	scopes := strings.Fields(c.PostForm("scope"))
+	if len(scopes) == 0 {
+		scopes = client.ScopeList()
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIScopes, scope) || !slices.Contains(client.ScopeList(), scope) {
+			writeTokenError(c, http.StatusBadRequest, "invalid_scope", "escopo não permitido: "+scope)
+			return
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	accessToken, err := auth.GenerateServiceToken(client.ClientID, scope)
+	if err != nil {
+		writeTokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	log.Printf("INFO: Token emitido para o cliente de serviço %s (escopos: %s, IP: %s).", client.ClientID, scope, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(auth.ServiceTokenDuration.Seconds()),
+		"scope":        scope,
+	})
+}
+
+// verifyPKCE confere o code_verifier contra o code_challenge S256 (RFC 7636, seção 4.6).
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// UserInfoHandler retorna as claims do usuário autorizadas pelo access token.
+func (p *Provider) UserInfoHandler(c *gin.Context) {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+		c.Header("WWW-Authenticate", `Bearer realm="userinfo"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_request", "error_description": "access token ausente"})
+		return
+	}
+
+	var claims AccessTokenClaims
+	if err := p.parse(tokenString, &claims, typeAccessToken); err != nil {
+		log.Printf("WARN: Access token inválido em /userinfo (IP: %s): %v", c.ClientIP(), err)
+		p.rejectBearer(c)
+		return
+	}
+	if revokedAccessToken(claims) {
+		p.rejectBearer(c)
+		return
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		p.rejectBearer(c)
+		return
+	}
+	user, err := services.GetUserByID(userID)
+	if err != nil || !user.Active {
+		p.rejectBearer(c)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userClaims(user, claims.Scope))
+}
+
+func (p *Provider) rejectBearer(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "access token inválido ou expirado"})
+}
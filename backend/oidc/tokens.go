@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// Durações dos artefatos emitidos pelo provedor.
+const (
+	accessTokenDuration  = time.Hour
+	idTokenDuration      = time.Hour
+	codeDuration         = 5 * time.Minute
+	consentTokenDuration = 10 * time.Minute
+)
+
+// Valores do cabeçalho "typ" que impedem o uso de um tipo de token no lugar de outro.
+const (
+	typeAccessToken  = "at+jwt" // RFC 9068
+	typeIDToken      = "JWT"
+	typeConsentToken = "consent+jwt"
+)
+
+// AccessTokenClaims são as claims dos access tokens emitidos por /oauth/token (RFC 9068).
+type AccessTokenClaims struct {
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenClaims são as claims do ID token (OpenID Connect Core, seção 2).
+type IDTokenClaims struct {
+	AuthTime      *jwt.NumericDate `json:"auth_time,omitempty"`
+	Nonce         string           `json:"nonce,omitempty"`
+	AccessHash    string           `json:"at_hash,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	Email         string           `json:"email,omitempty"`
+	EmailVerified *bool            `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// consentClaims transportam a requisição de autorização, já validada, do login até a
+// tela de consentimento. Assim a decisão do usuário não depende de campos editáveis do formulário.
+type consentClaims struct {
+	Request  AuthorizeRequest `json:"req"`
+	AuthTime int64            `json:"auth_time"`
+	jwt.RegisteredClaims
+}
+
+// sign assina as claims com a chave do provedor, usando o typ informado no cabeçalho.
+func (p *Provider) sign(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.Key.ID
+	token.Header["typ"] = typ
+	return token.SignedString(p.Key.PrivateKey)
+}
+
+// parse valida assinatura, typ, emissor e validade de um token emitido por este provedor.
+func (p *Provider) parse(tokenString string, claims jwt.Claims, typ string) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != typ {
+			return nil, fmt.Errorf("tipo de token inesperado: %v", token.Header["typ"])
+		}
+		return &p.Key.PrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(p.Issuer), jwt.WithExpirationRequired())
+	return err
+}
+
+// issueAccessToken emite o access token usado em /userinfo.
+func (p *Provider) issueAccessToken(subject, clientID, scope string, now time.Time) (string, error) {
+	return p.sign(&AccessTokenClaims{
+		ClientID: clientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenDuration)),
+			ID:        uuid.NewString(),
+		},
+	}, typeAccessToken)
+}
+
+// issueIDToken emite o ID token com as claims permitidas pelos escopos concedidos.
+func (p *Provider) issueIDToken(user models.User, code models.OAuthAuthorizationCode, accessToken string, now time.Time) (string, error) {
+	claims := &IDTokenClaims{
+		AuthTime:   jwt.NewNumericDate(code.AuthTime),
+		Nonce:      code.Nonce,
+		AccessHash: accessTokenHash(accessToken),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{code.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(idTokenDuration)),
+		},
+	}
+	profile := userClaims(user, code.Scope)
+	if name, ok := profile["name"].(string); ok {
+		claims.Name = name
+	}
+	if email, ok := profile["email"].(string); ok {
+		claims.Email = email
+		verified := false
+		claims.EmailVerified = &verified
+	}
+	return p.sign(claims, typeIDToken)
+}
+
+// accessTokenHash calcula a claim at_hash: metade esquerda do SHA-256, em base64url.
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
@@ -0,0 +1,149 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params são os parâmetros de custo do argon2id.
+type Argon2Params struct {
+	Memory      uint32 // em KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params segue a recomendação mínima da OWASP para argon2id
+// (19 MiB de memória, 2 iterações, paralelismo 1).
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Limites dos parâmetros aceitos em hashes argon2id armazenados. Os hashes podem vir de
+// importações e de outros sistemas: sem limites, um hash forjado (ex: m enorme ou t=0)
+// esgotaria a memória ou derrubaria o processo a cada tentativa de login.
+const (
+	maxArgon2Memory      = 1 << 20 // KiB (1 GiB)
+	maxArgon2Iterations  = 64
+	maxArgon2Parallelism = 64
+	maxArgon2KeyLength   = 1024
+)
+
+var errInvalidArgon2Hash = errors.New("hash argon2id mal formatado")
+
+// Argon2idHasher gera hashes argon2id no formato PHC:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt base64>$<hash base64>
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+// NewArgon2idHasher cria um Argon2idHasher com os parâmetros informados.
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return &Argon2idHasher{Params: params}
+}
+
+func (h *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) Hash(plain string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := h.Params
+	key := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(encoded, plain string) (bool, error) {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	computed := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+// NeedsRehash retorna true quando o hash foi gerado com parâmetros diferentes dos atuais.
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.Memory != h.Params.Memory ||
+		p.Iterations != h.Params.Iterations ||
+		p.Parallelism != h.Params.Parallelism ||
+		uint32(len(salt)) != h.Params.SaltLength ||
+		uint32(len(key)) != h.Params.KeyLength
+}
+
+// validate confere os limites de custo aceitos na verificação: hashes com parâmetros fora
+// deles são recusados, e por isso também não podem ser gerados.
+func (p Argon2Params) validate() error {
+	if p.Iterations < 1 || p.Iterations > maxArgon2Iterations ||
+		p.Parallelism < 1 || p.Parallelism > maxArgon2Parallelism ||
+		p.Memory < 8*uint32(p.Parallelism) || p.Memory > maxArgon2Memory {
+		return fmt.Errorf("parâmetros fora dos limites (m=%d, t=%d, p=%d)", p.Memory, p.Iterations, p.Parallelism)
+	}
+	return nil
+}
+
+// decodeArgon2id extrai parâmetros, salt e hash de um hash argon2id em formato PHC.
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(encoded, "$")
+	// ["", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash]
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("versão de argon2 incompatível: %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	if err := p.validate(); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", errInvalidArgon2Hash, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > maxArgon2KeyLength {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,59 @@
+package password
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost é o custo usado quando nenhum outro é configurado.
+const DefaultBcryptCost = bcrypt.DefaultCost
+
+// BcryptHasher gera hashes bcrypt no formato modular crypt ($2a$10$...).
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher cria um BcryptHasher, ajustando custos fora do intervalo aceito pelo bcrypt.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		log.Printf("WARN: Custo de bcrypt %d fora do intervalo [%d, %d], usando %d.", cost, bcrypt.MinCost, bcrypt.MaxCost, DefaultBcryptCost)
+		cost = DefaultBcryptCost
+	}
+	return &BcryptHasher{Cost: cost}
+}
+
+// Recognizes aceita os prefixos $2a$, $2b$ e $2y$.
+func (h *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) Hash(plain string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(encoded, plain string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return false, err
+}
+
+// NeedsRehash retorna true quando o custo armazenado é diferente do configurado.
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+	return cost != h.Cost
+}
@@ -0,0 +1,164 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"errors"
+	"strings"
+)
+
+// itoa64 é o alfabeto usado pelo crypt(3) e pelo phpass para codificar hashes.
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// maxPHPassCountLog2 limita o custo dos hashes phpass (2^16 rodadas de MD5). O phpass aceita
+// até 2^30, o que permitiria a um hash importado ocupar a CPU a cada tentativa de login; o
+// WordPress usa 2^8 e o Drupal 7, 2^15.
+const maxPHPassCountLog2 = 16
+
+var errInvalidMD5Hash = errors.New("hash baseado em MD5 mal formatado")
+
+// PHPassVerifier verifica hashes "portáveis" do phpass ($P$ do WordPress, $H$ do phpBB).
+// Estes hashes só são aceitos para verificação; no primeiro login bem-sucedido
+// eles são substituídos por um hash do algoritmo atual.
+type PHPassVerifier struct{}
+
+func (PHPassVerifier) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$P$") || strings.HasPrefix(encoded, "$H$")
+}
+
+func (PHPassVerifier) Verify(encoded, plain string) (bool, error) {
+	if len(encoded) != 34 {
+		return false, errInvalidMD5Hash
+	}
+	countLog2 := strings.IndexByte(itoa64, encoded[3])
+	if countLog2 < 7 || countLog2 > maxPHPassCountLog2 {
+		return false, errInvalidMD5Hash
+	}
+	salt := encoded[4:12]
+
+	sum := md5.Sum([]byte(salt + plain))
+	for count := 1 << countLog2; count > 0; count-- {
+		sum = md5.Sum(append(sum[:], plain...))
+	}
+
+	computed := encoded[:12] + phpassEncode64(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(encoded)) == 1, nil
+}
+
+// phpassEncode64 reproduz a função encode64 do phpass.
+func phpassEncode64(input []byte) string {
+	var out strings.Builder
+	count := len(input)
+	i := 0
+	for i < count {
+		value := int(input[i])
+		i++
+		out.WriteByte(itoa64[value&0x3f])
+		if i < count {
+			value |= int(input[i]) << 8
+		}
+		out.WriteByte(itoa64[(value>>6)&0x3f])
+		if i >= count {
+			break
+		}
+		i++
+		if i < count {
+			value |= int(input[i]) << 16
+		}
+		out.WriteByte(itoa64[(value>>12)&0x3f])
+		if i >= count {
+			break
+		}
+		i++
+		out.WriteByte(itoa64[(value>>18)&0x3f])
+	}
+	return out.String()
+}
+
+// MD5CryptVerifier verifica hashes MD5-crypt ($1$salt$hash), usados por
+// instalações PHP antigas via crypt().
+type MD5CryptVerifier struct{}
+
+func (MD5CryptVerifier) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$1$")
+}
+
+func (MD5CryptVerifier) Verify(encoded, plain string) (bool, error) {
+	rest := strings.TrimPrefix(encoded, "$1$")
+	sep := strings.IndexByte(rest, '$')
+	if sep < 0 {
+		return false, errInvalidMD5Hash
+	}
+	salt := rest[:sep]
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	computed := md5Crypt([]byte(plain), []byte(salt))
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(encoded)) == 1, nil
+}
+
+// md5Crypt implementa o algoritmo MD5-crypt de Poul-Henning Kamp.
+func md5Crypt(pw, salt []byte) string {
+	const magic = "$1$"
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write(salt)
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte(magic))
+	ctx.Write(salt)
+	for pl := len(pw); pl > 0; pl -= 16 {
+		ctx.Write(altSum[:min(pl, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(magic)
+	out.Write(salt)
+	out.WriteByte('$')
+	to64 := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			out.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	to64(uint32(final[0])<<16|uint32(final[6])<<8|uint32(final[12]), 4)
+	to64(uint32(final[1])<<16|uint32(final[7])<<8|uint32(final[13]), 4)
+	to64(uint32(final[2])<<16|uint32(final[8])<<8|uint32(final[14]), 4)
+	to64(uint32(final[3])<<16|uint32(final[9])<<8|uint32(final[15]), 4)
+	to64(uint32(final[4])<<16|uint32(final[10])<<8|uint32(final[5]), 4)
+	to64(uint32(final[11]), 2)
+	return out.String()
+}
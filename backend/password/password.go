@@ -0,0 +1,213 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Algoritmos suportados para geração de novos hashes.
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrUnknownFormat é retornado quando nenhum verificador reconhece o hash armazenado.
+var ErrUnknownFormat = errors.New("formato de hash de senha desconhecido")
+
+// Verifier verifica senhas contra hashes de um formato específico.
+type Verifier interface {
+	// Recognizes informa se o hash codificado pertence ao formato deste verificador.
+	Recognizes(encoded string) bool
+	// Verify compara a senha em texto plano com o hash codificado.
+	// Retorna false (sem erro) quando a senha simplesmente não confere.
+	Verify(encoded, plain string) (bool, error)
+}
+
+// Hasher é um Verifier capaz também de gerar novos hashes com parâmetros configurados.
+type Hasher interface {
+	Verifier
+	// Hash gera o hash codificado da senha em texto plano.
+	Hash(plain string) (string, error)
+	// NeedsRehash informa se um hash reconhecido por este Hasher usa parâmetros
+	// diferentes (tipicamente mais fracos) dos configurados atualmente.
+	NeedsRehash(encoded string) bool
+}
+
+// Manager combina o Hasher usado para novas senhas com os verificadores
+// aceitos para hashes já armazenados.
+type Manager struct {
+	current   Hasher
+	verifiers []Verifier
+}
+
+// NewManager cria um Manager que gera hashes com current e verifica hashes
+// de current e de qualquer um dos verificadores adicionais.
+func NewManager(current Hasher, verifiers ...Verifier) *Manager {
+	return &Manager{current: current, verifiers: verifiers}
+}
+
+// Hash gera o hash da senha usando o algoritmo atual.
+func (m *Manager) Hash(plain string) (string, error) {
+	return m.current.Hash(plain)
+}
+
+// Verify compara a senha com o hash armazenado.
+// rehash é true quando a senha confere mas o hash deve ser regerado com o algoritmo
+// ou os parâmetros atuais.
+func (m *Manager) Verify(encoded, plain string) (ok bool, rehash bool, err error) {
+	if m.current.Recognizes(encoded) {
+		ok, err = m.current.Verify(encoded, plain)
+		return ok, ok && m.current.NeedsRehash(encoded), err
+	}
+	for _, v := range m.verifiers {
+		if v.Recognizes(encoded) {
+			ok, err = v.Verify(encoded, plain)
+			return ok, ok, err
+		}
+	}
+	return false, false, ErrUnknownFormat
+}
+
+// Recognizes informa se algum dos formatos aceitos pelo Manager reconhece o hash.
+// Usado para validar hashes importados de outros sistemas antes de armazená-los.
+func (m *Manager) Recognizes(encoded string) bool {
+	if m.current.Recognizes(encoded) {
+		return true
+	}
+	for _, v := range m.verifiers {
+		if v.Recognizes(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	defaultMu      sync.Mutex
+	defaultManager *Manager
+)
+
+// Default retorna o Manager padrão, configurado a partir das variáveis de ambiente
+// no primeiro uso.
+func Default() *Manager {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultManager == nil {
+		m, err := NewManagerFromEnv()
+		if err != nil {
+			log.Fatalf("CRITICAL: %v", err)
+		}
+		defaultManager = m
+	}
+	return defaultManager
+}
+
+// SetDefault substitui o Manager padrão. Útil em testes e em inicializações customizadas.
+func SetDefault(m *Manager) {
+	defaultMu.Lock()
+	defaultManager = m
+	defaultMu.Unlock()
+}
+
+// Hash gera o hash da senha com o Manager padrão.
+func Hash(plain string) (string, error) {
+	return Default().Hash(plain)
+}
+
+// Verify verifica a senha com o Manager padrão.
+func Verify(encoded, plain string) (ok bool, rehash bool, err error) {
+	return Default().Verify(encoded, plain)
+}
+
+// NewManagerFromEnv monta o Manager a partir das variáveis de ambiente:
+//
+//	PASSWORD_HASH_ALGORITHM     bcrypt | argon2id (padrão: argon2id)
+//	PASSWORD_BCRYPT_COST        custo do bcrypt (padrão: 10)
+//	PASSWORD_ARGON2_MEMORY_KIB  memória do argon2id em KiB (padrão: 19456)
+//	PASSWORD_ARGON2_ITERATIONS  iterações do argon2id (padrão: 2)
+//	PASSWORD_ARGON2_PARALLELISM paralelismo do argon2id (padrão: 1)
+//
+// Ambos os algoritmos continuam sendo aceitos na verificação, de modo que trocar
+// o algoritmo não invalida senhas já armazenadas. Os formatos legados (veja
+// LegacyVerifiers) também são sempre aceitos e são convertidos no próximo login.
+//
+// Parâmetros do argon2id fora dos limites aceitos na verificação (memória até 1 GiB e de
+// pelo menos 8 KiB por via, até 64 iterações e 64 vias) resultam em erro: os hashes gerados
+// com eles nunca poderiam ser verificados.
+func NewManagerFromEnv() (*Manager, error) {
+	bc := NewBcryptHasher(envInt("PASSWORD_BCRYPT_COST", DefaultBcryptCost))
+
+	argonParams := DefaultArgon2Params
+	memory, err := envUint("PASSWORD_ARGON2_MEMORY_KIB", uint64(argonParams.Memory), 32)
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := envUint("PASSWORD_ARGON2_ITERATIONS", uint64(argonParams.Iterations), 32)
+	if err != nil {
+		return nil, err
+	}
+	parallelism, err := envUint("PASSWORD_ARGON2_PARALLELISM", uint64(argonParams.Parallelism), 8)
+	if err != nil {
+		return nil, err
+	}
+	argonParams.Memory, argonParams.Iterations, argonParams.Parallelism = uint32(memory), uint32(iterations), uint8(parallelism)
+	if err := argonParams.validate(); err != nil {
+		return nil, fmt.Errorf("configuração inválida em PASSWORD_ARGON2_*: %w", err)
+	}
+	ar := NewArgon2idHasher(argonParams)
+
+	algorithm := strings.ToLower(strings.TrimSpace(os.Getenv("PASSWORD_HASH_ALGORITHM")))
+	switch algorithm {
+	case AlgorithmBcrypt:
+		return NewManager(bc, append([]Verifier{ar}, LegacyVerifiers()...)...), nil
+	case "", AlgorithmArgon2id:
+		return NewManager(ar, append([]Verifier{bc}, LegacyVerifiers()...)...), nil
+	default:
+		log.Printf("WARN: PASSWORD_HASH_ALGORITHM '%s' não suportado, usando '%s'.", algorithm, AlgorithmArgon2id)
+		return NewManager(ar, append([]Verifier{bc}, LegacyVerifiers()...)...), nil
+	}
+}
+
+// LegacyVerifiers retorna os verificadores de formatos de outros sistemas aceitos
+// para contas importadas: phpass, MD5-crypt, PBKDF2 do Django e PBKDF2 do Keycloak.
+func LegacyVerifiers() []Verifier {
+	return []Verifier{
+		PHPassVerifier{},
+		MD5CryptVerifier{},
+		DjangoPBKDF2Verifier{},
+		PHCPBKDF2Verifier{},
+	}
+}
+
+// envUint lê um inteiro sem sinal de até bits bits de uma variável de ambiente, usando def se
+// ausente. Valores inválidos ou que não cabem no tipo resultam em erro, em vez de truncados.
+func envUint(name string, def uint64, bits int) (uint64, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseUint(raw, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido para %s: %q", name, raw)
+	}
+	return v, nil
+}
+
+// envInt lê um inteiro positivo de uma variável de ambiente, usando def se ausente ou inválido.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		log.Printf("WARN: Valor inválido para %s ('%s'), usando padrão %d.", name, raw, def)
+		return def
+	}
+	return v
+}
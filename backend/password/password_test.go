@@ -0,0 +1,219 @@
+package password
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2Params keeps the tests quick while still exercising the real algorithm.
+var fastArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHasher_HashAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	h := NewArgon2idHasher(fastArgon2Params)
+
+	encoded, err := h.Hash("correct horse battery")
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"), "Hash should use the PHC format")
+	assert.True(h.Recognizes(encoded))
+
+	ok, err := h.Verify(encoded, "correct horse battery")
+	assert.NoError(err)
+	assert.True(ok, "Correct password should verify")
+
+	ok, err = h.Verify(encoded, "wrong password")
+	assert.NoError(err)
+	assert.False(ok, "Wrong password should not verify")
+
+	_, err = h.Verify("$argon2id$v=19$garbage", "x")
+	assert.Error(err, "Malformed hash should return an error")
+
+	// Stored parameters come from imports: out-of-range costs must be rejected, not computed.
+	tail := "$c2FsdHNhbHRzYWx0c2FsdA$" + base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	for _, params := range []string{"m=1024,t=0,p=1", "m=1024,t=1,p=0", "m=4294967295,t=1,p=1", "m=1024,t=4294967295,p=1", "m=1024,t=1,p=255", "m=1,t=1,p=1"} {
+		ok, err := h.Verify("$argon2id$v=19$"+params+tail, "x")
+		assert.Error(err, params)
+		assert.False(ok, params)
+	}
+}
+
+func TestNewManagerFromEnv_RejectsUnverifiableArgon2Params(t *testing.T) {
+	testCases := []struct {
+		name  string
+		env   map[string]string
+		valid bool
+	}{
+		{name: "defaults", env: map[string]string{}, valid: true},
+		{name: "custom within limits", env: map[string]string{"PASSWORD_ARGON2_MEMORY_KIB": "65536", "PASSWORD_ARGON2_ITERATIONS": "3", "PASSWORD_ARGON2_PARALLELISM": "4"}, valid: true},
+		{name: "memory above 1 GiB", env: map[string]string{"PASSWORD_ARGON2_MEMORY_KIB": "2097152"}},
+		{name: "memory below 8 KiB per lane", env: map[string]string{"PASSWORD_ARGON2_MEMORY_KIB": "64", "PASSWORD_ARGON2_PARALLELISM": "16"}},
+		{name: "too many iterations", env: map[string]string{"PASSWORD_ARGON2_ITERATIONS": "65"}},
+		{name: "too many lanes", env: map[string]string{"PASSWORD_ARGON2_PARALLELISM": "65"}},
+		{name: "parallelism overflowing uint8", env: map[string]string{"PASSWORD_ARGON2_PARALLELISM": "257"}},
+		{name: "zero iterations", env: map[string]string{"PASSWORD_ARGON2_ITERATIONS": "0"}},
+		{name: "not a number", env: map[string]string{"PASSWORD_ARGON2_MEMORY_KIB": "muito"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"PASSWORD_ARGON2_MEMORY_KIB", "PASSWORD_ARGON2_ITERATIONS", "PASSWORD_ARGON2_PARALLELISM"} {
+				t.Setenv(name, tc.env[name])
+			}
+			m, err := NewManagerFromEnv()
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			// Every hash the manager produces must be accepted by its own verifier.
+			encoded, err := m.Hash("password123")
+			assert.NoError(t, err)
+			ok, _, err := m.Verify(encoded, "password123")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestManager_VerifyFlagsRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	oldBcrypt, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(err)
+
+	argonWeak, err := NewArgon2idHasher(fastArgon2Params).Hash("password123")
+	assert.NoError(err)
+
+	stronger := fastArgon2Params
+	stronger.Iterations = 2
+	m := NewManager(NewArgon2idHasher(stronger), NewBcryptHasher(bcrypt.MinCost))
+
+	testCases := []struct {
+		name         string
+		encoded      string
+		plain        string
+		expectOK     bool
+		expectRehash bool
+		expectErr    bool
+	}{
+		{name: "Legacy bcrypt hash is accepted and upgraded", encoded: string(oldBcrypt), plain: "password123", expectOK: true, expectRehash: true},
+		{name: "Wrong password on legacy hash", encoded: string(oldBcrypt), plain: "nope", expectOK: false, expectRehash: false},
+		{name: "Argon2id with outdated cost", encoded: argonWeak, plain: "password123", expectOK: true, expectRehash: true},
+		{name: "Unknown format", encoded: "plaintext", plain: "plaintext", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, rehash, err := m.Verify(tc.encoded, tc.plain)
+			if tc.expectErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.expectOK, ok)
+			assert.Equal(tc.expectRehash, rehash)
+		})
+	}
+
+	current, err := m.Hash("password123")
+	assert.NoError(err)
+	ok, rehash, err := m.Verify(current, "password123")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.False(rehash, "Hash produced with current parameters should not need rehash")
+}
+
+func TestBcryptHasher_NeedsRehashOnCostChange(t *testing.T) {
+	assert := assert.New(t)
+	low := NewBcryptHasher(bcrypt.MinCost)
+	encoded, err := low.Hash("password123")
+	assert.NoError(err)
+
+	assert.False(low.NeedsRehash(encoded))
+	assert.True(NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(encoded))
+}
+
+func TestLegacyVerifiers(t *testing.T) {
+	assert := assert.New(t)
+	keycloakHash, err := EncodePHCPBKDF2("pbkdf2-sha256", 27500,
+		[]byte("0123456789abcdef"),
+		mustDecodeBase64(t, "Dr8ltg9fE3xi3yjUiRvLYpXKHIs9fM2tPGxFGokOH/g6Z4vL/u0AOm7kwJRwyjX2xstHROVCpJHbWYzP8Z8amg=="))
+	assert.NoError(err)
+
+	testCases := []struct {
+		name     string
+		verifier Verifier
+		encoded  string
+		plain    string
+	}{
+		// Reference vector from the phpass distribution (test.php).
+		{name: "phpass portable", verifier: PHPassVerifier{}, encoded: "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", plain: "test12345"},
+		// Generated with `openssl passwd -1 -salt saltsalt password123`.
+		{name: "MD5-crypt", verifier: MD5CryptVerifier{}, encoded: "$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0", plain: "password123"},
+		// Generated with Python's hashlib.pbkdf2_hmac, as Django does.
+		{name: "Django PBKDF2-SHA256", verifier: DjangoPBKDF2Verifier{}, encoded: "pbkdf2_sha256$1000$abcdefgh$bj6napko4PVD149ME63177JfyaNws8L6th04lutKHI8=", plain: "password123"},
+		{name: "Keycloak PBKDF2-SHA256", verifier: PHCPBKDF2Verifier{}, encoded: keycloakHash, plain: "password123"},
+	}
+
+	m := NewManager(NewArgon2idHasher(fastArgon2Params), LegacyVerifiers()...)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(tc.verifier.Recognizes(tc.encoded))
+
+			ok, err := tc.verifier.Verify(tc.encoded, tc.plain)
+			assert.NoError(err)
+			assert.True(ok, "Correct password should verify")
+
+			ok, err = tc.verifier.Verify(tc.encoded, tc.plain+"x")
+			assert.NoError(err)
+			assert.False(ok, "Wrong password should not verify")
+
+			ok, rehash, err := m.Verify(tc.encoded, tc.plain)
+			assert.NoError(err)
+			assert.True(ok)
+			assert.True(rehash, "Legacy hashes must always be upgraded after a successful login")
+		})
+	}
+}
+
+func TestLegacyVerifiers_RejectExcessiveCost(t *testing.T) {
+	assert := assert.New(t)
+	testCases := []struct {
+		name     string
+		verifier Verifier
+		encoded  string
+	}{
+		{name: "phpass 2^30 rounds", verifier: PHPassVerifier{}, encoded: "$P$SIQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0"},
+		{name: "phpass 2^17 rounds", verifier: PHPassVerifier{}, encoded: "$P$FIQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0"},
+		{name: "Django PBKDF2 above the cap", verifier: DjangoPBKDF2Verifier{}, encoded: "pbkdf2_sha256$2000001$abcdefgh$bj6napko4PVD149ME63177JfyaNws8L6th04lutKHI8="},
+		{name: "PHC PBKDF2 above the cap", verifier: PHCPBKDF2Verifier{}, encoded: "$pbkdf2-sha256$i=2147483647$MDEyMzQ1Njc4OWFiY2RlZg$Dr8ltg9fE3xi3yjUiRvLYpXKHIs9fM2tPGxFGokOH/g"},
+		// The derived key length comes from the stored hash: each extra digest block repeats every iteration.
+		{name: "Django PBKDF2 key above the cap", verifier: DjangoPBKDF2Verifier{}, encoded: "pbkdf2_sha256$1000$abcdefgh$" + base64.StdEncoding.EncodeToString(make([]byte, maxPBKDF2KeyLength+1))},
+		{name: "PHC PBKDF2 key above the cap", verifier: PHCPBKDF2Verifier{}, encoded: "$pbkdf2-sha256$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$" + base64.RawStdEncoding.EncodeToString(make([]byte, 1<<20))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := tc.verifier.Verify(tc.encoded, "password123")
+			assert.Error(err)
+			assert.False(ok)
+		})
+	}
+
+	_, err := EncodePHCPBKDF2("pbkdf2-sha256", maxPBKDF2Iterations+1, []byte("salt"), []byte("key"))
+	assert.Error(err, "Imports must not store hashes above the cap")
+	_, err = EncodePHCPBKDF2("pbkdf2-sha256", 1000, []byte("salt"), make([]byte, maxPBKDF2KeyLength+1))
+	assert.Error(err, "Imports must not store keys above the cap")
+	_, err = EncodePHCPBKDF2("pbkdf2-sha512", 1000, []byte("salt"), make([]byte, maxPBKDF2KeyLength))
+	assert.NoError(err, "A full SHA-512 output is a valid key")
+}
+
+func mustDecodeBase64(t *testing.T, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid base64 fixture: %v", err)
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package password
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// maxPBKDF2Iterations limita o custo dos hashes PBKDF2 importados (o Django 5.2 usa 1.000.000
+// e o Keycloak, 27.500 a 600.000), para que um hash forjado não ocupe a CPU a cada login.
+const maxPBKDF2Iterations = 2_000_000
+
+// maxPBKDF2KeyLength limita o tamanho da chave derivada dos hashes importados (64 bytes, a
+// saída do SHA-512): cada bloco além da saída do digest repete todas as iterações.
+const maxPBKDF2KeyLength = 64
+
+var errInvalidPBKDF2Hash = errors.New("hash pbkdf2 mal formatado")
+
+// pbkdf2Digests mapeia o nome do digest usado nos formatos suportados para sua implementação.
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// DjangoPBKDF2Verifier verifica hashes no formato do Django:
+//
+//	pbkdf2_sha256$<iterações>$<salt>$<hash base64>
+//
+// O salt é usado literalmente (não é codificado em base64).
+type DjangoPBKDF2Verifier struct{}
+
+func (DjangoPBKDF2Verifier) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "pbkdf2_sha256$") || strings.HasPrefix(encoded, "pbkdf2_sha1$")
+}
+
+func (DjangoPBKDF2Verifier) Verify(encoded, plain string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false, errInvalidPBKDF2Hash
+	}
+	digest, ok := pbkdf2Digests[strings.TrimPrefix(parts[0], "pbkdf2_")]
+	if !ok {
+		return false, errInvalidPBKDF2Hash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 || iterations > maxPBKDF2Iterations {
+		return false, errInvalidPBKDF2Hash
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 || len(expected) > maxPBKDF2KeyLength {
+		return false, errInvalidPBKDF2Hash
+	}
+	computed, err := pbkdf2.Key(digest, plain, []byte(parts[2]), iterations, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(computed, expected) == 1, nil
+}
+
+// PHCPBKDF2Verifier verifica hashes PBKDF2 no formato PHC, usado para credenciais
+// importadas do Keycloak (que exporta salt e hash separadamente):
+//
+//	$pbkdf2-sha256$i=27500$<salt base64>$<hash base64>
+type PHCPBKDF2Verifier struct{}
+
+func (PHCPBKDF2Verifier) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$pbkdf2-")
+}
+
+func (PHCPBKDF2Verifier) Verify(encoded, plain string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	// ["", "pbkdf2-sha256", "i=27500", salt, hash]
+	if len(parts) != 5 {
+		return false, errInvalidPBKDF2Hash
+	}
+	digest, ok := pbkdf2Digests[strings.TrimPrefix(parts[1], "pbkdf2-")]
+	if !ok {
+		return false, errInvalidPBKDF2Hash
+	}
+	var iterations int
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &iterations); err != nil || iterations <= 0 || iterations > maxPBKDF2Iterations {
+		return false, errInvalidPBKDF2Hash
+	}
+	salt, err := decodeBase64Lenient(parts[3])
+	if err != nil {
+		return false, errInvalidPBKDF2Hash
+	}
+	expected, err := decodeBase64Lenient(parts[4])
+	if err != nil || len(expected) == 0 || len(expected) > maxPBKDF2KeyLength {
+		return false, errInvalidPBKDF2Hash
+	}
+	computed, err := pbkdf2.Key(digest, plain, salt, iterations, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(computed, expected) == 1, nil
+}
+
+// EncodePHCPBKDF2 monta um hash PBKDF2 em formato PHC a partir dos componentes
+// exportados por outros sistemas (ex: secretData/credentialData do Keycloak).
+// algorithm aceita os nomes do Keycloak: "pbkdf2" (SHA-1), "pbkdf2-sha256" e "pbkdf2-sha512".
+func EncodePHCPBKDF2(algorithm string, iterations int, salt, key []byte) (string, error) {
+	name := strings.ToLower(algorithm)
+	if name == "pbkdf2" {
+		name = "pbkdf2-sha1"
+	}
+	if _, ok := pbkdf2Digests[strings.TrimPrefix(name, "pbkdf2-")]; !ok || !strings.HasPrefix(name, "pbkdf2-") {
+		return "", fmt.Errorf("algoritmo pbkdf2 não suportado: %s", algorithm)
+	}
+	if iterations <= 0 || iterations > maxPBKDF2Iterations || len(salt) == 0 || len(key) == 0 || len(key) > maxPBKDF2KeyLength {
+		return "", errInvalidPBKDF2Hash
+	}
+	return fmt.Sprintf("$%s$i=%d$%s$%s", name, iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// decodeBase64Lenient aceita base64 padrão com ou sem padding.
+func decodeBase64Lenient(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
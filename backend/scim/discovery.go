@@ -0,0 +1,167 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaAttribute descreve um atributo na resposta de /Schemas (RFC 7643, seção 7).
+type schemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []schemaAttribute `json:"subAttributes,omitempty"`
+}
+
+func attr(name, typ string, required bool, mutability, returned, uniqueness string) schemaAttribute {
+	return schemaAttribute{Name: name, Type: typ, Required: required, Mutability: mutability, Returned: returned, Uniqueness: uniqueness}
+}
+
+func multi(a schemaAttribute, sub ...schemaAttribute) schemaAttribute {
+	a.MultiValued = true
+	a.SubAttributes = sub
+	return a
+}
+
+func complexAttr(a schemaAttribute, sub ...schemaAttribute) schemaAttribute {
+	a.SubAttributes = sub
+	return a
+}
+
+// userSchema lista apenas os atributos do schema User suportados por esta implementação.
+var userSchema = gin.H{
+	"schemas":     []string{SchemaSchema},
+	"id":          SchemaUser,
+	"name":        "User",
+	"description": "Conta de usuário",
+	"attributes": []schemaAttribute{
+		attr("userName", "string", true, "readWrite", "default", "server"),
+		complexAttr(attr("name", "complex", false, "readWrite", "default", "none"),
+			attr("formatted", "string", false, "readWrite", "default", "none"),
+			attr("givenName", "string", false, "writeOnly", "never", "none"),
+			attr("familyName", "string", false, "writeOnly", "never", "none"),
+		),
+		attr("displayName", "string", false, "readWrite", "default", "none"),
+		multi(attr("emails", "complex", false, "readWrite", "default", "none"),
+			attr("value", "string", false, "readWrite", "default", "server"),
+			attr("type", "string", false, "readOnly", "default", "none"),
+			attr("primary", "boolean", false, "readOnly", "default", "none"),
+		),
+		attr("active", "boolean", false, "readWrite", "default", "none"),
+		attr("password", "string", false, "writeOnly", "never", "none"),
+		multi(attr("groups", "complex", false, "readOnly", "default", "none"),
+			attr("value", "string", false, "readOnly", "default", "none"),
+			attr("display", "string", false, "readOnly", "default", "none"),
+			attr("$ref", "reference", false, "readOnly", "default", "none"),
+		),
+	},
+	"meta": gin.H{"resourceType": "Schema", "location": "/scim/v2/Schemas/" + SchemaUser},
+}
+
+var groupSchema = gin.H{
+	"schemas":     []string{SchemaSchema},
+	"id":          SchemaGroup,
+	"name":        "Group",
+	"description": "Grupo de usuários",
+	"attributes": []schemaAttribute{
+		attr("displayName", "string", true, "readWrite", "default", "server"),
+		multi(attr("members", "complex", false, "readWrite", "default", "none"),
+			attr("value", "string", false, "immutable", "default", "none"),
+			attr("display", "string", false, "readOnly", "default", "none"),
+			attr("$ref", "reference", false, "immutable", "default", "none"),
+		),
+	},
+	"meta": gin.H{"resourceType": "Schema", "location": "/scim/v2/Schemas/" + SchemaGroup},
+}
+
+var resourceTypes = map[string]gin.H{
+	"User": {
+		"schemas":     []string{SchemaResourceType},
+		"id":          "User",
+		"name":        "User",
+		"endpoint":    "/Users",
+		"description": "Conta de usuário",
+		"schema":      SchemaUser,
+		"meta":        gin.H{"resourceType": "ResourceType", "location": "/scim/v2/ResourceTypes/User"},
+	},
+	"Group": {
+		"schemas":     []string{SchemaResourceType},
+		"id":          "Group",
+		"name":        "Group",
+		"endpoint":    "/Groups",
+		"description": "Grupo de usuários",
+		"schema":      SchemaGroup,
+		"meta":        gin.H{"resourceType": "ResourceType", "location": "/scim/v2/ResourceTypes/Group"},
+	},
+}
+
+// ServiceProviderConfigHandler descreve as funcionalidades SCIM suportadas.
+func ServiceProviderConfigHandler(c *gin.Context) {
+	writeJSON(c, http.StatusOK, gin.H{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "",
+		"patch":            gin.H{"supported": true},
+		"bulk":             gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           gin.H{"supported": true, "maxResults": maxCount},
+		"changePassword":   gin.H{"supported": true},
+		"sort":             gin.H{"supported": true},
+		"etag":             gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Token estático do cliente de provisionamento (SCIM_BEARER_TOKEN)",
+			"primary":     true,
+		}},
+		"meta": gin.H{"resourceType": "ServiceProviderConfig", "location": "/scim/v2/ServiceProviderConfig"},
+	})
+}
+
+// SchemasHandler lista os schemas suportados.
+func SchemasHandler(c *gin.Context) {
+	writeJSON(c, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: 2,
+		StartIndex:   1,
+		ItemsPerPage: 2,
+		Resources:    []gin.H{userSchema, groupSchema},
+	})
+}
+
+// SchemaHandler retorna um schema pelo URN.
+func SchemaHandler(c *gin.Context) {
+	switch c.Param("id") {
+	case SchemaUser:
+		writeJSON(c, http.StatusOK, userSchema)
+	case SchemaGroup:
+		writeJSON(c, http.StatusOK, groupSchema)
+	default:
+		writeError(c, http.StatusNotFound, "", "Schema não encontrado")
+	}
+}
+
+// ResourceTypesHandler lista os tipos de recurso expostos.
+func ResourceTypesHandler(c *gin.Context) {
+	writeJSON(c, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    []gin.H{resourceTypes["User"], resourceTypes["Group"]},
+	})
+}
+
+// ResourceTypeHandler retorna um tipo de recurso pelo nome.
+func ResourceTypeHandler(c *gin.Context) {
+	rt, ok := resourceTypes[c.Param("id")]
+	if !ok {
+		writeError(c, http.StatusNotFound, "", "ResourceType não encontrado")
+		return
+	}
+	writeJSON(c, http.StatusOK, rt)
+}
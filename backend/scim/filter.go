@@ -0,0 +1,330 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expression é um nó da árvore de um filtro SCIM (RFC 7644, seção 3.4.2.2).
+type Expression interface {
+	isExpression()
+}
+
+// AttrExpr compara um atributo com um valor: `userName eq "bjensen"`, `title pr`.
+// Attr é normalizado para minúsculas, pois nomes de atributos SCIM não diferenciam maiúsculas.
+type AttrExpr struct {
+	Attr  string
+	Op    string      // eq, ne, co, sw, ew, gt, ge, lt, le ou pr
+	Value interface{} // string, float64, bool ou nil
+}
+
+// LogicalExpr combina duas expressões com "and" ou "or".
+type LogicalExpr struct {
+	Op          string
+	Left, Right Expression
+}
+
+// NotExpr nega uma expressão: `not (active eq true)`.
+type NotExpr struct {
+	Expr Expression
+}
+
+// ValuePathExpr filtra atributos multivalorados: `emails[type eq "work"]`.
+type ValuePathExpr struct {
+	Attr   string
+	Filter Expression
+}
+
+func (AttrExpr) isExpression()      {}
+func (LogicalExpr) isExpression()   {}
+func (NotExpr) isExpression()       {}
+func (ValuePathExpr) isExpression() {}
+
+var compareOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// schemaPrefixes são removidos dos nomes de atributos totalmente qualificados.
+var schemaPrefixes = []string{
+	strings.ToLower(SchemaUser) + ":",
+	strings.ToLower(SchemaGroup) + ":",
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize quebra o filtro em tokens. Identificadores incluem ':' e '.', pois nomes
+// de atributos podem ser qualificados por URN (urn:...:User:name.givenName).
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		ch := rune(input[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case ch == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case ch == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case ch == '"':
+			start := i
+			i++
+			for i < len(input) && input[i] != '"' {
+				if input[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("string não terminada na posição %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, input[start:i], start})
+		case ch == '-' || unicode.IsDigit(ch):
+			start := i
+			i++
+			for i < len(input) && strings.ContainsRune("0123456789.eE+-", rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, input[start:i], start})
+		case unicode.IsLetter(ch) || ch == '$' || ch == '_':
+			start := i
+			for i < len(input) && (unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i])) || strings.ContainsRune(":._-$", rune(input[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, input[start:i], start})
+		default:
+			return nil, fmt.Errorf("caractere inesperado '%c' na posição %d", ch, i)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(input)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) peekKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+// ParseFilter interpreta um filtro SCIM. A precedência segue a RFC: not > and > or.
+func ParseFilter(input string) (Expression, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("token inesperado '%s' na posição %d", t.text, t.pos)
+	}
+	return expr, nil
+}
+
+func (p *parser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = LogicalExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = LogicalExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expression, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		if p.peek().kind != tokLParen {
+			return nil, fmt.Errorf("esperado '(' após 'not' na posição %d", p.peek().pos)
+		}
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr{Expr: inner}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, fmt.Errorf("esperado ')' na posição %d", t.pos)
+		}
+		return inner, nil
+	}
+	return p.parseAttrExpr()
+}
+
+func (p *parser) parseAttrExpr() (Expression, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, fmt.Errorf("esperado nome de atributo na posição %d", t.pos)
+	}
+	attr := normalizeAttr(t.text)
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRBracket {
+			return nil, fmt.Errorf("esperado ']' na posição %d", t.pos)
+		}
+		return ValuePathExpr{Attr: attr, Filter: inner}, nil
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind != tokIdent || (op != "pr" && !compareOps[op]) {
+		return nil, fmt.Errorf("operador inválido '%s' na posição %d", opTok.text, opTok.pos)
+	}
+	if op == "pr" {
+		return AttrExpr{Attr: attr, Op: op}, nil
+	}
+
+	valTok := p.next()
+	value, err := parseCompValue(valTok)
+	if err != nil {
+		return nil, err
+	}
+	return AttrExpr{Attr: attr, Op: op, Value: value}, nil
+}
+
+// parseCompValue converte o token de valor (string JSON, número, true, false ou null).
+func parseCompValue(t token) (interface{}, error) {
+	switch t.kind {
+	case tokString, tokNumber:
+		var v interface{}
+		if err := json.Unmarshal([]byte(t.text), &v); err != nil {
+			return nil, fmt.Errorf("valor inválido '%s' na posição %d", t.text, t.pos)
+		}
+		return v, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("valor de comparação inválido '%s' na posição %d", t.text, t.pos)
+}
+
+// normalizeAttr remove o prefixo de schema e converte o nome para minúsculas.
+func normalizeAttr(name string) string {
+	lower := strings.ToLower(name)
+	for _, prefix := range schemaPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return strings.TrimPrefix(lower, prefix)
+		}
+	}
+	return lower
+}
+
+// Path é o alvo de uma operação PATCH: `members`, `name.givenName`,
+// `members[value eq "id"]` ou `emails[type eq "work"].value`.
+type Path struct {
+	Attr    string
+	Filter  Expression // nil quando não há filtro entre colchetes
+	SubAttr string
+}
+
+// ParsePath interpreta o atributo "path" de uma operação PATCH (RFC 7644, seção 3.5.2).
+func ParsePath(input string) (Path, error) {
+	input = strings.TrimSpace(input)
+	open := strings.IndexByte(input, '[')
+	if open < 0 {
+		attr := normalizeAttr(input)
+		if attr == "" {
+			return Path{}, fmt.Errorf("path vazio")
+		}
+		return Path{Attr: attr}, nil
+	}
+	close := strings.LastIndexByte(input, ']')
+	if close < open {
+		return Path{}, fmt.Errorf("path inválido: '%s'", input)
+	}
+	filter, err := ParseFilter(input[open+1 : close])
+	if err != nil {
+		return Path{}, err
+	}
+	path := Path{Attr: normalizeAttr(input[:open]), Filter: filter}
+	if rest := input[close+1:]; rest != "" {
+		if !strings.HasPrefix(rest, ".") {
+			return Path{}, fmt.Errorf("path inválido: '%s'", input)
+		}
+		path.SubAttr = strings.ToLower(rest[1:])
+	}
+	return path, nil
+}
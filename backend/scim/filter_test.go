@@ -0,0 +1,76 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	expr, err := ParseFilter(`userName eq "bjensen@example.com"`)
+	assert.NoError(err)
+	assert.Equal(AttrExpr{Attr: "username", Op: "eq", Value: "bjensen@example.com"}, expr)
+
+	// "and" binds tighter than "or".
+	expr, err = ParseFilter(`active eq true or userName sw "a" and externalId pr`)
+	assert.NoError(err)
+	or, ok := expr.(LogicalExpr)
+	assert.True(ok)
+	assert.Equal("or", or.Op)
+	assert.Equal(AttrExpr{Attr: "active", Op: "eq", Value: true}, or.Left)
+	assert.Equal("and", or.Right.(LogicalExpr).Op)
+
+	expr, err = ParseFilter(`not (emails[value co "@example.com"]) AND urn:ietf:params:scim:schemas:core:2.0:User:userName NE null`)
+	assert.NoError(err)
+	and := expr.(LogicalExpr)
+	assert.Equal(NotExpr{Expr: ValuePathExpr{Attr: "emails", Filter: AttrExpr{Attr: "value", Op: "co", Value: "@example.com"}}}, and.Left)
+	assert.Equal(AttrExpr{Attr: "username", Op: "ne", Value: nil}, and.Right)
+
+	for _, invalid := range []string{`userName`, `userName eq`, `userName xx "a"`, `(userName eq "a"`, `userName eq "a`, `not userName eq "a"`} {
+		_, err := ParseFilter(invalid)
+		assert.Error(err, "Filter should be rejected: %s", invalid)
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	expr, _ := ParseFilter(`userName eq "BJensen@Example.com" and (displayName co "50%" or active eq false)`)
+	sql, args, err := toSQL(expr, userAttributes, "")
+	assert.NoError(err)
+	assert.Equal(`(LOWER(email) = ? AND (LOWER(name) LIKE ? ESCAPE '\' OR active = ?))`, sql)
+	assert.Equal([]interface{}{"bjensen@example.com", `%50\%%`, false}, args)
+
+	expr, _ = ParseFilter(`id eq "not-a-uuid"`)
+	sql, _, err = toSQL(expr, userAttributes, "")
+	assert.NoError(err)
+	assert.Equal("1 = 0", sql, "Malformed IDs match nothing")
+
+	expr, _ = ParseFilter(`nickName eq "x"`)
+	_, _, err = toSQL(expr, userAttributes, "")
+	assert.Error(err, "Unknown attributes are rejected")
+
+	expr, _ = ParseFilter(`active gt true`)
+	_, _, err = toSQL(expr, userAttributes, "")
+	assert.Error(err)
+}
+
+func TestParsePath(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := ParsePath(`name.givenName`)
+	assert.NoError(err)
+	assert.Equal(Path{Attr: "name.givenname"}, p)
+
+	p, err = ParsePath(`members[value eq "2819c223"]`)
+	assert.NoError(err)
+	assert.Equal("members", p.Attr)
+	assert.Equal(AttrExpr{Attr: "value", Op: "eq", Value: "2819c223"}, p.Filter)
+
+	p, err = ParsePath(`emails[type eq "work"].value`)
+	assert.NoError(err)
+	assert.Equal("emails", p.Attr)
+	assert.Equal("value", p.SubAttr)
+}
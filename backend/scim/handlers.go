@@ -0,0 +1,564 @@
+package scim
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"github.com/monteirobsb/user-management/backend/services"
+	"gorm.io/gorm"
+)
+
+// Limites de paginação (RFC 7644, seção 3.4.2.4).
+const (
+	defaultCount = 100
+	maxCount     = 200
+)
+
+const contentType = "application/scim+json"
+
+var validate = validator.New()
+
+// RegisterRoutes registra os endpoints SCIM 2.0 sob o grupo informado (tipicamente /scim/v2).
+// A autenticação do cliente de provisionamento deve ser aplicada ao grupo por quem chama.
+func RegisterRoutes(r gin.IRouter) {
+	r.GET("/ServiceProviderConfig", ServiceProviderConfigHandler)
+	r.GET("/Schemas", SchemasHandler)
+	r.GET("/Schemas/:id", SchemaHandler)
+	r.GET("/ResourceTypes", ResourceTypesHandler)
+	r.GET("/ResourceTypes/:id", ResourceTypeHandler)
+
+	r.GET("/Users", ListUsersHandler)
+	r.POST("/Users", CreateUserHandler)
+	r.GET("/Users/:id", GetUserHandler)
+	r.PUT("/Users/:id", ReplaceUserHandler)
+	r.PATCH("/Users/:id", PatchUserHandler)
+	r.DELETE("/Users/:id", DeleteUserHandler)
+
+	r.GET("/Groups", ListGroupsHandler)
+	r.POST("/Groups", CreateGroupHandler)
+	r.GET("/Groups/:id", GetGroupHandler)
+	r.PUT("/Groups/:id", ReplaceGroupHandler)
+	r.PATCH("/Groups/:id", PatchGroupHandler)
+	r.DELETE("/Groups/:id", DeleteGroupHandler)
+}
+
+// writeJSON responde com o Content-Type application/scim+json.
+func writeJSON(c *gin.Context, status int, v interface{}) {
+	c.Header("Content-Type", contentType)
+	c.JSON(status, v)
+}
+
+// writeError responde com uma mensagem de erro SCIM (RFC 7644, seção 3.12).
+func writeError(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{
+		"schemas": []string{SchemaError},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", contentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// baseURL retorna a URL base dos recursos SCIM, usada em meta.location e $ref.
+// Pode ser fixada por SCIM_BASE_URL quando a API está atrás de um proxy.
+func baseURL(c *gin.Context) string {
+	if base := os.Getenv("SCIM_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2"
+}
+
+// pagination lê startIndex (base 1) e count da query string.
+func pagination(c *gin.Context) (startIndex, count int) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err = strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultCount)))
+	if err != nil || count < 0 {
+		count = defaultCount
+	}
+	if count > maxCount {
+		count = maxCount
+	}
+	return startIndex, count
+}
+
+// listScope monta o scope de filtro e a ordenação a partir dos parâmetros da query.
+func listScope(c *gin.Context, attrs map[string]attribute) (func(*gorm.DB) *gorm.DB, string, bool) {
+	var scope func(*gorm.DB) *gorm.DB
+	if filter := c.Query("filter"); filter != "" {
+		expr, err := ParseFilter(filter)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "invalidFilter", "Filtro inválido: "+err.Error())
+			return nil, "", false
+		}
+		scope, err = toScope(expr, attrs)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return nil, "", false
+		}
+	}
+	order, err := orderBy(c.Query("sortBy"), c.Query("sortOrder"), attrs)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return nil, "", false
+	}
+	return scope, order, true
+}
+
+func parseID(c *gin.Context, resource string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusNotFound, "", resource+" não encontrado")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ListUsersHandler lida com GET /Users, com filtro, ordenação e paginação.
+func ListUsersHandler(c *gin.Context) {
+	scope, order, ok := listScope(c, userAttributes)
+	if !ok {
+		return
+	}
+	startIndex, count := pagination(c)
+
+	users, total, err := services.ListUsers(scope, order, startIndex-1, count)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar usuários")
+		return
+	}
+
+	base := baseURL(c)
+	resources := make([]User, 0, len(users))
+	for _, u := range users {
+		groups, err := services.GetUserGroups(u.ID)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, "", "Erro ao buscar grupos do usuário")
+			return
+		}
+		resources = append(resources, newUserResource(u, groups, base))
+	}
+	writeJSON(c, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetUserHandler lida com GET /Users/:id.
+func GetUserHandler(c *gin.Context) {
+	id, ok := parseID(c, "Usuário")
+	if !ok {
+		return
+	}
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondLookupError(c, err, "Usuário")
+		return
+	}
+	writeUser(c, http.StatusOK, user)
+}
+
+// CreateUserHandler lida com POST /Users (provisionamento).
+func CreateUserHandler(c *gin.Context) {
+	var req User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalidSyntax", "Payload SCIM inválido: "+err.Error())
+		return
+	}
+	email := emailOf(req)
+	if err := validate.Var(email, "required,email"); err != nil {
+		writeError(c, http.StatusBadRequest, "invalidValue", "userName (ou emails) deve conter um e-mail válido")
+		return
+	}
+	if taken, err := services.EmailInUse(email, uuid.Nil); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao verificar unicidade do usuário")
+		return
+	} else if taken {
+		writeError(c, http.StatusConflict, "uniqueness", "Já existe um usuário com este userName")
+		return
+	}
+
+	plain := req.Password
+	if plain == "" {
+		// Contas provisionadas sem senha recebem uma senha aleatória descartada:
+		// o login local fica inviável até que uma senha seja definida.
+		plain = randomSecret()
+	}
+	user := models.User{
+		Name:       displayNameOf(req),
+		Email:      email,
+		ExternalID: optionalString(req.ExternalID),
+	}
+	if err := services.CreateUser(middleware.AuditActor(c), &user, plain); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao criar usuário")
+		return
+	}
+	// O GORM ignora o valor zero de campos com default no INSERT, por isso a desativação
+	// é gravada em uma segunda etapa.
+	if req.Active != nil && !*req.Active {
+		if err := services.UpdateUserColumns(middleware.AuditActor(c), user.ID, map[string]interface{}{"active": false}); err != nil {
+			writeError(c, http.StatusInternalServerError, "", "Erro ao desativar usuário")
+			return
+		}
+	}
+
+	created, err := services.GetUserByID(user.ID)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar usuário criado")
+		return
+	}
+	c.Header("Location", baseURL(c)+"/Users/"+created.ID.String())
+	writeUser(c, http.StatusCreated, created)
+}
+
+// ReplaceUserHandler lida com PUT /Users/:id, substituindo os atributos mutáveis.
+func ReplaceUserHandler(c *gin.Context) {
+	id, ok := parseID(c, "Usuário")
+	if !ok {
+		return
+	}
+	if _, err := services.GetUserByID(id); err != nil {
+		respondLookupError(c, err, "Usuário")
+		return
+	}
+
+	var req User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalidSyntax", "Payload SCIM inválido: "+err.Error())
+		return
+	}
+	state := userPatch{
+		Name:       displayNameOf(req),
+		Email:      emailOf(req),
+		ExternalID: optionalString(req.ExternalID),
+		Active:     req.Active == nil || *req.Active,
+		Password:   req.Password,
+	}
+	saveUser(c, id, state)
+}
+
+// PatchUserHandler lida com PATCH /Users/:id (ex: desativação com active=false).
+func PatchUserHandler(c *gin.Context) {
+	id, ok := parseID(c, "Usuário")
+	if !ok {
+		return
+	}
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		respondLookupError(c, err, "Usuário")
+		return
+	}
+
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		writeError(c, http.StatusBadRequest, "invalidSyntax", "Requisição PATCH inválida")
+		return
+	}
+	state := userPatch{Name: user.Name, Email: user.Email, ExternalID: user.ExternalID, Active: user.Active}
+	if err := applyUserPatch(&state, req.Operations); err != nil {
+		respondPatchError(c, err)
+		return
+	}
+	saveUser(c, id, state)
+}
+
+// saveUser valida e grava o novo estado do usuário, respondendo com o recurso atualizado.
+func saveUser(c *gin.Context, id uuid.UUID, state userPatch) {
+	if err := validate.Var(state.Email, "required,email"); err != nil {
+		writeError(c, http.StatusBadRequest, "invalidValue", "userName deve ser um e-mail válido")
+		return
+	}
+	if strings.TrimSpace(state.Name) == "" {
+		state.Name = state.Email
+	}
+	if taken, err := services.EmailInUse(state.Email, id); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao verificar unicidade do usuário")
+		return
+	} else if taken {
+		writeError(c, http.StatusConflict, "uniqueness", "Já existe um usuário com este userName")
+		return
+	}
+
+	columns := map[string]interface{}{
+		"name":        state.Name,
+		"email":       state.Email,
+		"external_id": state.ExternalID,
+		"active":      state.Active,
+	}
+	if state.Password != "" {
+		hashed, err := password.Hash(state.Password)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, "", "Erro ao processar senha")
+			return
+		}
+		columns["password_hash"] = hashed
+	}
+	if err := services.UpdateUserColumns(middleware.AuditActor(c), id, columns); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao atualizar usuário")
+		return
+	}
+
+	user, err := services.GetUserByID(id)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar usuário atualizado")
+		return
+	}
+	writeUser(c, http.StatusOK, user)
+}
+
+// DeleteUserHandler lida com DELETE /Users/:id (desprovisionamento definitivo).
+func DeleteUserHandler(c *gin.Context) {
+	id, ok := parseID(c, "Usuário")
+	if !ok {
+		return
+	}
+	if _, err := services.GetUserByID(id); err != nil {
+		respondLookupError(c, err, "Usuário")
+		return
+	}
+	if err := services.DeleteUser(middleware.AuditActor(c), id); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao remover usuário")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroupsHandler lida com GET /Groups.
+func ListGroupsHandler(c *gin.Context) {
+	scope, order, ok := listScope(c, groupAttributes)
+	if !ok {
+		return
+	}
+	startIndex, count := pagination(c)
+
+	groups, total, err := services.ListGroups(scope, order, startIndex-1, count)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar grupos")
+		return
+	}
+	base := baseURL(c)
+	resources := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		resources = append(resources, newGroupResource(g, base))
+	}
+	writeJSON(c, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetGroupHandler lida com GET /Groups/:id.
+func GetGroupHandler(c *gin.Context) {
+	id, ok := parseID(c, "Grupo")
+	if !ok {
+		return
+	}
+	group, err := services.GetGroupByID(id)
+	if err != nil {
+		respondLookupError(c, err, "Grupo")
+		return
+	}
+	writeJSON(c, http.StatusOK, newGroupResource(group, baseURL(c)))
+}
+
+// CreateGroupHandler lida com POST /Groups.
+func CreateGroupHandler(c *gin.Context) {
+	var req Group
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.DisplayName) == "" {
+		writeError(c, http.StatusBadRequest, "invalidValue", "displayName é obrigatório")
+		return
+	}
+	if taken, err := services.GroupNameInUse(req.DisplayName, uuid.Nil); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao verificar unicidade do grupo")
+		return
+	} else if taken {
+		writeError(c, http.StatusConflict, "uniqueness", "Já existe um grupo com este displayName")
+		return
+	}
+	memberIDs, ok := parseMemberIDs(c, req.Members)
+	if !ok {
+		return
+	}
+
+	group := models.Group{DisplayName: req.DisplayName, ExternalID: optionalString(req.ExternalID)}
+	if err := services.CreateGroup(&group, memberIDs); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao criar grupo")
+		return
+	}
+	created, err := services.GetGroupByID(group.ID)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar grupo criado")
+		return
+	}
+	c.Header("Location", baseURL(c)+"/Groups/"+created.ID.String())
+	writeJSON(c, http.StatusCreated, newGroupResource(created, baseURL(c)))
+}
+
+// ReplaceGroupHandler lida com PUT /Groups/:id.
+func ReplaceGroupHandler(c *gin.Context) {
+	id, ok := parseID(c, "Grupo")
+	if !ok {
+		return
+	}
+	if _, err := services.GetGroupByID(id); err != nil {
+		respondLookupError(c, err, "Grupo")
+		return
+	}
+	var req Group
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalidSyntax", "Payload SCIM inválido")
+		return
+	}
+	state := groupPatch{DisplayName: req.DisplayName, ExternalID: optionalString(req.ExternalID), MemberIDs: []string{}}
+	for _, m := range req.Members {
+		state.MemberIDs = append(state.MemberIDs, m.Value)
+	}
+	saveGroup(c, id, state)
+}
+
+// PatchGroupHandler lida com PATCH /Groups/:id (ex: adicionar/remover membros).
+func PatchGroupHandler(c *gin.Context) {
+	id, ok := parseID(c, "Grupo")
+	if !ok {
+		return
+	}
+	group, err := services.GetGroupByID(id)
+	if err != nil {
+		respondLookupError(c, err, "Grupo")
+		return
+	}
+
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		writeError(c, http.StatusBadRequest, "invalidSyntax", "Requisição PATCH inválida")
+		return
+	}
+	state := groupPatch{DisplayName: group.DisplayName, ExternalID: group.ExternalID, MemberIDs: []string{}}
+	for _, m := range group.Members {
+		state.MemberIDs = append(state.MemberIDs, m.ID.String())
+	}
+	if err := applyGroupPatch(&state, req.Operations); err != nil {
+		respondPatchError(c, err)
+		return
+	}
+	saveGroup(c, id, state)
+}
+
+// saveGroup valida e grava o novo estado do grupo, substituindo a lista de membros.
+func saveGroup(c *gin.Context, id uuid.UUID, state groupPatch) {
+	if strings.TrimSpace(state.DisplayName) == "" {
+		writeError(c, http.StatusBadRequest, "invalidValue", "displayName é obrigatório")
+		return
+	}
+	if taken, err := services.GroupNameInUse(state.DisplayName, id); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao verificar unicidade do grupo")
+		return
+	} else if taken {
+		writeError(c, http.StatusConflict, "uniqueness", "Já existe um grupo com este displayName")
+		return
+	}
+
+	members := make([]MultiValue, 0, len(state.MemberIDs))
+	for _, m := range state.MemberIDs {
+		members = append(members, MultiValue{Value: m})
+	}
+	memberIDs, ok := parseMemberIDs(c, members)
+	if !ok {
+		return
+	}
+
+	group := models.Group{ID: id, DisplayName: state.DisplayName, ExternalID: state.ExternalID}
+	if err := services.UpdateGroup(&group, memberIDs); err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao atualizar grupo")
+		return
+	}
+	updated, err := services.GetGroupByID(id)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar grupo atualizado")
+		return
+	}
+	writeJSON(c, http.StatusOK, newGroupResource(updated, baseURL(c)))
+}
+
+// DeleteGroupHandler lida com DELETE /Groups/:id.
+func DeleteGroupHandler(c *gin.Context) {
+	id, ok := parseID(c, "Grupo")
+	if !ok {
+		return
+	}
+	if err := services.DeleteGroup(id); err != nil {
+		respondLookupError(c, err, "Grupo")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeUser(c *gin.Context, status int, user models.User) {
+	groups, err := services.GetUserGroups(user.ID)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, "", "Erro ao buscar grupos do usuário")
+		return
+	}
+	writeJSON(c, status, newUserResource(user, groups, baseURL(c)))
+}
+
+func respondLookupError(c *gin.Context, err error, resource string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(c, http.StatusNotFound, "", resource+" não encontrado")
+		return
+	}
+	writeError(c, http.StatusInternalServerError, "", "Erro ao processar sua solicitação")
+}
+
+func respondPatchError(c *gin.Context, err error) {
+	var patchErr *PatchError
+	if errors.As(err, &patchErr) {
+		writeError(c, http.StatusBadRequest, patchErr.ScimType, patchErr.Error())
+		return
+	}
+	writeError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+}
+
+// parseMemberIDs converte os valores de members em UUIDs, rejeitando valores inválidos.
+func parseMemberIDs(c *gin.Context, members []MultiValue) ([]uuid.UUID, bool) {
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "invalidValue", "Membro inválido: "+m.Value)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// randomSecret gera uma senha aleatória para contas provisionadas sem senha.
+func randomSecret() string {
+	return rand.Text() + rand.Text()
+}
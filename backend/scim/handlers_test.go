@@ -0,0 +1,202 @@
+package scim_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/middleware"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/scim"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const testToken = "provisioning-token"
+
+func setupSCIMRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate test database schema: %v", err)
+	}
+	database.DB = db
+
+	router := gin.New()
+	group := router.Group("/scim/v2")
+	group.Use(middleware.SCIMAuthMiddleware(testToken))
+	scim.RegisterRoutes(group)
+	return router
+}
+
+func scimRequest(router *gin.Engine, method, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/scim+json")
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var decoded map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &decoded)
+	return w, decoded
+}
+
+func TestSCIMUsersLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	router := setupSCIMRouter(t)
+
+	// Unauthenticated requests are rejected.
+	req, _ := http.NewRequest("GET", "/scim/v2/Users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(http.StatusUnauthorized, w.Code)
+
+	email := "bjensen." + uuid.NewString() + "@example.com"
+	w, body := scimRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{
+		"schemas":    []string{scim.SchemaUser},
+		"userName":   email,
+		"externalId": "okta-123",
+		"name":       map[string]string{"givenName": "Barbara", "familyName": "Jensen"},
+		"active":     true,
+	})
+	assert.Equal(http.StatusCreated, w.Code)
+	assert.Equal("application/scim+json", w.Header().Get("Content-Type"))
+	assert.Equal("Barbara Jensen", body["displayName"])
+	id := body["id"].(string)
+
+	// Duplicate userName (case-insensitive) is a uniqueness conflict.
+	w, body = scimRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "BJENSEN" + email[7:]})
+	assert.Equal(http.StatusConflict, w.Code)
+	assert.Equal("uniqueness", body["scimType"])
+
+	for i := 0; i < 3; i++ {
+		w, _ = scimRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{"userName": fmt.Sprintf("page%d.%s@example.com", i, uuid.NewString())})
+		assert.Equal(http.StatusCreated, w.Code)
+	}
+
+	// Filtering as Okta does before provisioning.
+	w, body = scimRequest(router, "GET", `/scim/v2/Users?filter=userName+eq+"`+email+`"`, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(float64(1), body["totalResults"])
+	assert.Equal("okta-123", body["Resources"].([]interface{})[0].(map[string]interface{})["externalId"])
+
+	// Pagination is 1-based.
+	w, body = scimRequest(router, "GET", "/scim/v2/Users?startIndex=2&count=2", nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(float64(4), body["totalResults"])
+	assert.Equal(float64(2), body["startIndex"])
+	assert.Equal(float64(2), body["itemsPerPage"])
+
+	w, body = scimRequest(router, "GET", `/scim/v2/Users?filter=userName+zz+"x"`, nil)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Equal("invalidFilter", body["scimType"])
+
+	// Okta-style deactivation: replace without path. It also ends the user's sessions.
+	userID := uuid.MustParse(id)
+	database.DB.Create(&models.Session{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)})
+	w, body = scimRequest(router, "PATCH", "/scim/v2/Users/"+id, map[string]interface{}{
+		"schemas":    []string{scim.SchemaPatchOp},
+		"Operations": []map[string]interface{}{{"op": "replace", "value": map[string]interface{}{"active": false}}},
+	})
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(false, body["active"])
+	var stored models.User
+	database.DB.First(&stored, "id = ?", id)
+	assert.False(stored.Active, "Deactivation should be persisted")
+	var sessions int64
+	database.DB.Model(&models.Session{}).Where("user_id = ?", userID).Count(&sessions)
+	assert.Zero(sessions, "Deactivation should revoke the user's sessions")
+
+	// Azure-style PATCH with paths and string booleans.
+	w, body = scimRequest(router, "PATCH", "/scim/v2/Users/"+id, map[string]interface{}{
+		"schemas": []string{scim.SchemaPatchOp},
+		"Operations": []map[string]interface{}{
+			{"op": "Replace", "path": "active", "value": "True"},
+			{"op": "Replace", "path": "displayName", "value": "Babs Jensen"},
+			{"op": "Add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "Ops"},
+		},
+	})
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(true, body["active"])
+	assert.Equal("Babs Jensen", body["displayName"])
+
+	w, _ = scimRequest(router, "DELETE", "/scim/v2/Users/"+id, nil)
+	assert.Equal(http.StatusNoContent, w.Code)
+	w, _ = scimRequest(router, "GET", "/scim/v2/Users/"+id, nil)
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestSCIMGroupsMembership(t *testing.T) {
+	assert := assert.New(t)
+	router := setupSCIMRouter(t)
+
+	var userIDs []string
+	for i := 0; i < 2; i++ {
+		_, body := scimRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{"userName": fmt.Sprintf("member%d.%s@example.com", i, uuid.NewString())})
+		userIDs = append(userIDs, body["id"].(string))
+	}
+
+	w, body := scimRequest(router, "POST", "/scim/v2/Groups", map[string]interface{}{
+		"schemas":     []string{scim.SchemaGroup},
+		"displayName": "Engineering",
+		"members":     []map[string]string{{"value": userIDs[0]}},
+	})
+	assert.Equal(http.StatusCreated, w.Code)
+	groupID := body["id"].(string)
+	assert.Len(body["members"], 1)
+
+	w, body = scimRequest(router, "PATCH", "/scim/v2/Groups/"+groupID, map[string]interface{}{
+		"schemas": []string{scim.SchemaPatchOp},
+		"Operations": []map[string]interface{}{
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": userIDs[1]}}},
+			{"op": "remove", "path": `members[value eq "` + userIDs[0] + `"]`},
+		},
+	})
+	assert.Equal(http.StatusOK, w.Code)
+	members := body["members"].([]interface{})
+	assert.Len(members, 1)
+	assert.Equal(userIDs[1], members[0].(map[string]interface{})["value"])
+
+	w, body = scimRequest(router, "GET", `/scim/v2/Groups?filter=members+eq+"`+userIDs[1]+`"`, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(float64(1), body["totalResults"])
+
+	// The user's groups are reflected on the User resource.
+	_, body = scimRequest(router, "GET", "/scim/v2/Users/"+userIDs[1], nil)
+	assert.Equal("Engineering", body["groups"].([]interface{})[0].(map[string]interface{})["display"])
+
+	w, _ = scimRequest(router, "DELETE", "/scim/v2/Groups/"+groupID, nil)
+	assert.Equal(http.StatusNoContent, w.Code)
+}
+
+func TestSCIMDiscovery(t *testing.T) {
+	assert := assert.New(t)
+	router := setupSCIMRouter(t)
+
+	w, body := scimRequest(router, "GET", "/scim/v2/ServiceProviderConfig", nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(true, body["patch"].(map[string]interface{})["supported"])
+
+	w, body = scimRequest(router, "GET", "/scim/v2/ResourceTypes", nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(float64(2), body["totalResults"])
+
+	w, body = scimRequest(router, "GET", "/scim/v2/Schemas/"+scim.SchemaUser, nil)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("User", body["name"])
+}
@@ -0,0 +1,415 @@
+package scim
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// PatchError é um erro de operação PATCH, com o scimType a ser devolvido ao cliente.
+type PatchError struct {
+	ScimType string
+	msg      string
+}
+
+func (e *PatchError) Error() string { return e.msg }
+
+func patchErrorf(scimType, format string, args ...interface{}) error {
+	return &PatchError{ScimType: scimType, msg: fmt.Sprintf(format, args...)}
+}
+
+// userPatch é o estado mutável de um usuário durante a aplicação de operações PATCH.
+type userPatch struct {
+	Name       string
+	Email      string
+	ExternalID *string
+	Active     bool
+	Password   string
+
+	givenName, familyName *string
+}
+
+// applyUserPatch aplica as operações, em ordem, sobre o estado do usuário.
+func applyUserPatch(state *userPatch, ops []PatchOperation) error {
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return patchErrorf("invalidSyntax", "operação PATCH inválida: %s", op.Op)
+		}
+
+		if op.Path == "" {
+			if opName == "remove" {
+				return patchErrorf("noTarget", "operação remove requer path")
+			}
+			values, ok := op.Value.(map[string]interface{})
+			if !ok {
+				return patchErrorf("invalidValue", "operação sem path requer um objeto como valor")
+			}
+			for key, value := range values {
+				if err := applyUserAttr(state, opName, Path{Attr: normalizeAttr(key)}, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := ParsePath(op.Path)
+		if err != nil {
+			return patchErrorf("invalidPath", "path inválido: %v", err)
+		}
+		if err := applyUserAttr(state, opName, path, op.Value); err != nil {
+			return err
+		}
+	}
+
+	if state.givenName != nil || state.familyName != nil {
+		var given, family string
+		if state.givenName != nil {
+			given = *state.givenName
+		}
+		if state.familyName != nil {
+			family = *state.familyName
+		}
+		if full := strings.TrimSpace(given + " " + family); full != "" {
+			state.Name = full
+		}
+	}
+	return nil
+}
+
+func applyUserAttr(state *userPatch, op string, path Path, value interface{}) error {
+	attr := path.Attr
+	if path.SubAttr != "" {
+		attr += "." + path.SubAttr
+	}
+
+	switch attr {
+	case "username":
+		if op == "remove" {
+			return patchErrorf("mutability", "userName é obrigatório e não pode ser removido")
+		}
+		s, err := stringValue(attr, value)
+		if err != nil {
+			return err
+		}
+		state.Email = s
+	case "displayname", "name.formatted":
+		if op == "remove" {
+			return nil
+		}
+		s, err := stringValue(attr, value)
+		if err != nil {
+			return err
+		}
+		state.Name = s
+	case "name.givenname", "name.familyname":
+		if op == "remove" {
+			return nil
+		}
+		s, err := stringValue(attr, value)
+		if err != nil {
+			return err
+		}
+		if attr == "name.givenname" {
+			state.givenName = &s
+		} else {
+			state.familyName = &s
+		}
+	case "name":
+		if op == "remove" {
+			return nil
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return patchErrorf("invalidValue", "name deve ser um objeto")
+		}
+		for key, v := range m {
+			if err := applyUserAttr(state, op, Path{Attr: "name", SubAttr: strings.ToLower(key)}, v); err != nil {
+				return err
+			}
+		}
+	case "emails", "emails.value":
+		if op == "remove" {
+			return patchErrorf("mutability", "o e-mail é obrigatório e não pode ser removido")
+		}
+		email, err := emailValue(value)
+		if err != nil {
+			return err
+		}
+		state.Email = email
+	case "active":
+		if op == "remove" {
+			return patchErrorf("mutability", "active não pode ser removido")
+		}
+		b, err := boolValue(value)
+		if err != nil {
+			return err
+		}
+		state.Active = b
+	case "externalid":
+		if op == "remove" {
+			state.ExternalID = nil
+			return nil
+		}
+		s, err := stringValue(attr, value)
+		if err != nil {
+			return err
+		}
+		state.ExternalID = optionalString(s)
+	case "password":
+		if op == "remove" {
+			return patchErrorf("mutability", "password não pode ser removido")
+		}
+		s, err := stringValue(attr, value)
+		if err != nil {
+			return err
+		}
+		state.Password = s
+	case "id", "meta", "schemas", "groups":
+		// Atributos somente leitura enviados por alguns provedores junto com o restante do recurso.
+	default:
+		// Atributos sem correspondência em models.User (ex: extensões enterprise) são ignorados
+		// para não interromper o provisionamento do provedor de identidade.
+		log.Printf("WARN: SCIM PATCH ignorou atributo não suportado: %s", attr)
+	}
+	return nil
+}
+
+// groupPatch é o estado mutável de um grupo durante a aplicação de operações PATCH.
+type groupPatch struct {
+	DisplayName string
+	ExternalID  *string
+	MemberIDs   []string
+}
+
+// applyGroupPatch aplica as operações, em ordem, sobre o estado do grupo.
+func applyGroupPatch(state *groupPatch, ops []PatchOperation) error {
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return patchErrorf("invalidSyntax", "operação PATCH inválida: %s", op.Op)
+		}
+
+		if op.Path == "" {
+			if opName == "remove" {
+				return patchErrorf("noTarget", "operação remove requer path")
+			}
+			values, ok := op.Value.(map[string]interface{})
+			if !ok {
+				return patchErrorf("invalidValue", "operação sem path requer um objeto como valor")
+			}
+			for key, value := range values {
+				if err := applyGroupAttr(state, opName, Path{Attr: normalizeAttr(key)}, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := ParsePath(op.Path)
+		if err != nil {
+			return patchErrorf("invalidPath", "path inválido: %v", err)
+		}
+		if err := applyGroupAttr(state, opName, path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyGroupAttr(state *groupPatch, op string, path Path, value interface{}) error {
+	switch path.Attr {
+	case "displayname":
+		if op == "remove" {
+			return patchErrorf("mutability", "displayName é obrigatório e não pode ser removido")
+		}
+		s, err := stringValue("displayName", value)
+		if err != nil {
+			return err
+		}
+		state.DisplayName = s
+	case "externalid":
+		if op == "remove" {
+			state.ExternalID = nil
+			return nil
+		}
+		s, err := stringValue("externalId", value)
+		if err != nil {
+			return err
+		}
+		state.ExternalID = optionalString(s)
+	case "members":
+		return applyMembersOp(state, op, path, value)
+	case "id", "meta", "schemas":
+	default:
+		log.Printf("WARN: SCIM PATCH ignorou atributo de grupo não suportado: %s", path.Attr)
+	}
+	return nil
+}
+
+func applyMembersOp(state *groupPatch, op string, path Path, value interface{}) error {
+	switch op {
+	case "add", "replace":
+		ids, err := memberValues(value)
+		if err != nil {
+			return err
+		}
+		if op == "replace" {
+			state.MemberIDs = nil
+		}
+		for _, id := range ids {
+			if !containsString(state.MemberIDs, id) {
+				state.MemberIDs = append(state.MemberIDs, id)
+			}
+		}
+	case "remove":
+		var remaining []string
+		switch {
+		case path.Filter != nil:
+			for _, id := range state.MemberIDs {
+				match, err := matchesMember(path.Filter, id)
+				if err != nil {
+					return err
+				}
+				if !match {
+					remaining = append(remaining, id)
+				}
+			}
+		case value != nil:
+			// Formato usado pelo Azure AD: path "members" com a lista a remover em value.
+			ids, err := memberValues(value)
+			if err != nil {
+				return err
+			}
+			for _, id := range state.MemberIDs {
+				if !containsString(ids, id) {
+					remaining = append(remaining, id)
+				}
+			}
+		}
+		state.MemberIDs = remaining
+	}
+	return nil
+}
+
+// matchesMember avalia um filtro de valuePath (ex: value eq "id") para um membro.
+func matchesMember(expr Expression, id string) (bool, error) {
+	switch e := expr.(type) {
+	case AttrExpr:
+		if e.Attr != "value" {
+			return false, patchErrorf("invalidFilter", "filtro de members suporta apenas o atributo value")
+		}
+		s, _ := e.Value.(string)
+		switch e.Op {
+		case "eq":
+			return strings.EqualFold(s, id), nil
+		case "ne":
+			return !strings.EqualFold(s, id), nil
+		case "pr":
+			return true, nil
+		}
+		return false, patchErrorf("invalidFilter", "operador não suportado em filtro de members: %s", e.Op)
+	case LogicalExpr:
+		left, err := matchesMember(e.Left, id)
+		if err != nil {
+			return false, err
+		}
+		right, err := matchesMember(e.Right, id)
+		if err != nil {
+			return false, err
+		}
+		if e.Op == "and" {
+			return left && right, nil
+		}
+		return left || right, nil
+	case NotExpr:
+		match, err := matchesMember(e.Expr, id)
+		return !match, err
+	}
+	return false, patchErrorf("invalidFilter", "filtro de members não suportado")
+}
+
+// memberValues extrai os IDs de uma lista [{"value": "..."}] (ou de um único objeto).
+func memberValues(value interface{}) ([]string, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		items = []interface{}{v}
+	default:
+		return nil, patchErrorf("invalidValue", "members deve ser uma lista de objetos com 'value'")
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, patchErrorf("invalidValue", "members deve ser uma lista de objetos com 'value'")
+		}
+		id, ok := m["value"].(string)
+		if !ok || id == "" {
+			return nil, patchErrorf("invalidValue", "membro sem 'value'")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func stringValue(attr string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", patchErrorf("invalidValue", "%s deve ser uma string", attr)
+	}
+	return s, nil
+}
+
+// boolValue aceita booleanos e as strings "true"/"false" (enviadas pelo Azure AD).
+func boolValue(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err == nil {
+			return b, nil
+		}
+	}
+	return false, patchErrorf("invalidValue", "active deve ser booleano")
+}
+
+// emailValue aceita uma string ou uma lista de e-mails (usa o primário, ou o primeiro).
+func emailValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		var first string
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			email, _ := m["value"].(string)
+			if primary, _ := m["primary"].(bool); primary && email != "" {
+				return email, nil
+			}
+			if first == "" {
+				first = email
+			}
+		}
+		if first != "" {
+			return first, nil
+		}
+	}
+	return "", patchErrorf("invalidValue", "emails deve conter ao menos um e-mail")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
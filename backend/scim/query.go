@@ -0,0 +1,229 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type attrKind int
+
+const (
+	kindString attrKind = iota
+	kindBool
+	kindTime
+	kindUUID
+	kindMembers // membros de grupo (tabela group_members)
+)
+
+// attribute descreve como um atributo SCIM é mapeado para uma coluna do banco.
+type attribute struct {
+	column    string
+	kind      attrKind
+	caseExact bool
+}
+
+// userAttributes mapeia os atributos filtráveis/ordenáveis do recurso User.
+var userAttributes = map[string]attribute{
+	"id":                {column: "id", kind: kindUUID},
+	"externalid":        {column: "external_id", kind: kindString, caseExact: true},
+	"username":          {column: "email", kind: kindString},
+	"displayname":       {column: "name", kind: kindString},
+	"name.formatted":    {column: "name", kind: kindString},
+	"emails":            {column: "email", kind: kindString},
+	"emails.value":      {column: "email", kind: kindString},
+	"active":            {column: "active", kind: kindBool},
+	"meta.created":      {column: "created_at", kind: kindTime},
+	"meta.lastmodified": {column: "updated_at", kind: kindTime},
+}
+
+// groupAttributes mapeia os atributos filtráveis/ordenáveis do recurso Group.
+var groupAttributes = map[string]attribute{
+	"id":                {column: "id", kind: kindUUID},
+	"externalid":        {column: "external_id", kind: kindString, caseExact: true},
+	"displayname":       {column: "display_name", kind: kindString},
+	"members":           {column: "id", kind: kindMembers},
+	"members.value":     {column: "id", kind: kindMembers},
+	"meta.created":      {column: "created_at", kind: kindTime},
+	"meta.lastmodified": {column: "updated_at", kind: kindTime},
+}
+
+// FilterError indica um filtro sintaticamente válido mas não suportado (scimType invalidFilter).
+type FilterError struct{ msg string }
+
+func (e *FilterError) Error() string { return e.msg }
+
+func filterErrorf(format string, args ...interface{}) error {
+	return &FilterError{msg: fmt.Sprintf(format, args...)}
+}
+
+// toScope converte a expressão do filtro em um scope do GORM usando o mapa de atributos.
+func toScope(expr Expression, attrs map[string]attribute) (func(*gorm.DB) *gorm.DB, error) {
+	sql, args, err := toSQL(expr, attrs, "")
+	if err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB { return db.Where(sql, args...) }, nil
+}
+
+// toSQL gera a cláusula WHERE (com placeholders) correspondente à expressão.
+// prefix é usado para qualificar os atributos dentro de um valuePath (emails[value eq ...]).
+func toSQL(expr Expression, attrs map[string]attribute, prefix string) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case LogicalExpr:
+		left, largs, err := toSQL(e.Left, attrs, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rargs, err := toSQL(e.Right, attrs, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(e.Op) + " " + right + ")", append(largs, rargs...), nil
+	case NotExpr:
+		inner, args, err := toSQL(e.Expr, attrs, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case ValuePathExpr:
+		return toSQL(e.Filter, attrs, e.Attr+".")
+	case AttrExpr:
+		return attrToSQL(e, attrs, prefix)
+	}
+	return "", nil, filterErrorf("expressão de filtro não suportada")
+}
+
+func attrToSQL(e AttrExpr, attrs map[string]attribute, prefix string) (string, []interface{}, error) {
+	name := prefix + e.Attr
+	attr, ok := attrs[name]
+	if !ok {
+		return "", nil, filterErrorf("atributo não suportado no filtro: %s", name)
+	}
+
+	if e.Op == "pr" {
+		switch attr.kind {
+		case kindString:
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", attr.column, attr.column), nil, nil
+		case kindMembers:
+			return "id IN (SELECT group_id FROM group_members)", nil, nil
+		default:
+			return attr.column + " IS NOT NULL", nil, nil
+		}
+	}
+
+	if e.Value == nil {
+		switch e.Op {
+		case "eq":
+			return attr.column + " IS NULL", nil, nil
+		case "ne":
+			return attr.column + " IS NOT NULL", nil, nil
+		}
+		return "", nil, filterErrorf("operador '%s' não aceita null", e.Op)
+	}
+
+	switch attr.kind {
+	case kindBool:
+		v, ok := e.Value.(bool)
+		if !ok || (e.Op != "eq" && e.Op != "ne") {
+			return "", nil, filterErrorf("atributo %s aceita apenas eq/ne com true ou false", name)
+		}
+		return attr.column + " " + sqlOp(e.Op) + " ?", []interface{}{v}, nil
+
+	case kindUUID, kindMembers:
+		s, ok := e.Value.(string)
+		if !ok || (e.Op != "eq" && e.Op != "ne") {
+			return "", nil, filterErrorf("atributo %s aceita apenas eq/ne com string", name)
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			// Um ID mal formatado simplesmente não corresponde a nenhum recurso.
+			if e.Op == "eq" {
+				return "1 = 0", nil, nil
+			}
+			return "1 = 1", nil, nil
+		}
+		if attr.kind == kindMembers {
+			sub := "id IN (SELECT group_id FROM group_members WHERE user_id = ?)"
+			if e.Op == "ne" {
+				sub = "NOT " + sub
+			}
+			return sub, []interface{}{id}, nil
+		}
+		return attr.column + " " + sqlOp(e.Op) + " ?", []interface{}{id}, nil
+
+	case kindTime:
+		s, ok := e.Value.(string)
+		if !ok {
+			return "", nil, filterErrorf("atributo %s requer data no formato RFC 3339", name)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", nil, filterErrorf("data inválida para %s: %s", name, s)
+		}
+		if e.Op == "co" || e.Op == "sw" || e.Op == "ew" {
+			return "", nil, filterErrorf("operador '%s' não se aplica a datas", e.Op)
+		}
+		return attr.column + " " + sqlOp(e.Op) + " ?", []interface{}{t}, nil
+	}
+
+	// kindString
+	s, ok := e.Value.(string)
+	if !ok {
+		return "", nil, filterErrorf("atributo %s requer valor string", name)
+	}
+	column, value := attr.column, s
+	if !attr.caseExact {
+		column, value = "LOWER("+attr.column+")", strings.ToLower(s)
+	}
+	switch e.Op {
+	case "co":
+		return column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(value) + "%"}, nil
+	case "sw":
+		return column + ` LIKE ? ESCAPE '\'`, []interface{}{escapeLike(value) + "%"}, nil
+	case "ew":
+		return column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(value)}, nil
+	}
+	return column + " " + sqlOp(e.Op) + " ?", []interface{}{value}, nil
+}
+
+func sqlOp(op string) string {
+	switch op {
+	case "eq":
+		return "="
+	case "ne":
+		return "<>"
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	}
+	return "="
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// orderBy converte sortBy/sortOrder em uma cláusula ORDER BY.
+func orderBy(sortBy, sortOrder string, attrs map[string]attribute) (string, error) {
+	if sortBy == "" {
+		return "", nil
+	}
+	attr, ok := attrs[normalizeAttr(sortBy)]
+	if !ok || attr.kind == kindMembers {
+		return "", filterErrorf("sortBy não suportado: %s", sortBy)
+	}
+	direction := "asc"
+	if strings.EqualFold(sortOrder, "descending") {
+		direction = "desc"
+	}
+	return attr.column + " " + direction, nil
+}
@@ -0,0 +1,194 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// URNs de schemas e mensagens definidos pela RFC 7643/7644.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// Meta contém os metadados de um recurso SCIM.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+// Name é o atributo complexo "name" do recurso User.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValue representa itens de atributos multivalorados (emails, groups, members).
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User é a representação SCIM de models.User. userName corresponde ao e-mail.
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Password    string       `json:"password,omitempty"`
+	Groups      []MultiValue `json:"groups,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// Group é a representação SCIM de models.Group.
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// ListResponse é o envelope das respostas de listagem/busca.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// PatchRequest é o corpo de uma requisição PATCH.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation é uma operação add/replace/remove.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// versionOf gera uma versão fraca (ETag) a partir da data de atualização do recurso.
+func versionOf(t time.Time) string {
+	return fmt.Sprintf(`W/"%d"`, t.UnixNano())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// newUserResource converte um models.User na representação SCIM.
+func newUserResource(u models.User, groups []models.Group, baseURL string) User {
+	active := u.Active
+	res := User{
+		Schemas:     []string{SchemaUser},
+		ID:          u.ID.String(),
+		UserName:    u.Email,
+		Name:        &Name{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []MultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      formatTime(u.CreatedAt),
+			LastModified: formatTime(u.UpdatedAt),
+			Location:     baseURL + "/Users/" + u.ID.String(),
+			Version:      versionOf(u.UpdatedAt),
+		},
+	}
+	if u.ExternalID != nil {
+		res.ExternalID = *u.ExternalID
+	}
+	for _, g := range groups {
+		res.Groups = append(res.Groups, MultiValue{Value: g.ID.String(), Display: g.DisplayName, Ref: baseURL + "/Groups/" + g.ID.String()})
+	}
+	return res
+}
+
+// newGroupResource converte um models.Group (com membros carregados) na representação SCIM.
+func newGroupResource(g models.Group, baseURL string) Group {
+	res := Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          g.ID.String(),
+		DisplayName: g.DisplayName,
+		Members:     []MultiValue{},
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      formatTime(g.CreatedAt),
+			LastModified: formatTime(g.UpdatedAt),
+			Location:     baseURL + "/Groups/" + g.ID.String(),
+			Version:      versionOf(g.UpdatedAt),
+		},
+	}
+	if g.ExternalID != nil {
+		res.ExternalID = *g.ExternalID
+	}
+	for _, m := range g.Members {
+		res.Members = append(res.Members, MultiValue{Value: m.ID.String(), Display: m.Name, Ref: baseURL + "/Users/" + m.ID.String()})
+	}
+	return res
+}
+
+// displayNameOf escolhe o nome a ser gravado em models.User a partir dos atributos SCIM,
+// na ordem: displayName, name.formatted, givenName + familyName e, por fim, userName.
+func displayNameOf(u User) string {
+	if strings.TrimSpace(u.DisplayName) != "" {
+		return strings.TrimSpace(u.DisplayName)
+	}
+	if u.Name != nil {
+		if strings.TrimSpace(u.Name.Formatted) != "" {
+			return strings.TrimSpace(u.Name.Formatted)
+		}
+		if full := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	return u.UserName
+}
+
+// emailOf retorna o e-mail do recurso: userName, ou o e-mail primário se userName não for um e-mail.
+func emailOf(u User) string {
+	if strings.Contains(u.UserName, "@") {
+		return u.UserName
+	}
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return u.UserName
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix identifica tokens de acesso pessoal no cabeçalho Authorization, para que
+// não sejam confundidos com JWTs (e para que vazamentos sejam fáceis de detectar).
+const APITokenPrefix = "pat_"
+
+// apiTokenUseInterval limita a frequência com que last_used_at é gravado.
+const apiTokenUseInterval = time.Minute
+
+// Erros de autenticação com token de acesso pessoal.
+var (
+	ErrAPITokenInvalid  = errors.New("token de API inválido")
+	ErrAPITokenExpired  = errors.New("token de API expirado")
+	ErrAPITokenDisabled = errors.New("conta do token de API desativada")
+)
+
+// CreateAPIToken grava um novo token de acesso pessoal, com registro no log de auditoria em
+// nome de actor, e retorna o valor completo, no formato pat_<prefixo>_<segredo>, que só é
+// exibido nesta resposta.
+func CreateAPIToken(actor AuditActor, token *models.APIToken) (string, error) {
+	token.Prefix = strings.ToLower(rand.Text()[:12])
+	secret := rand.Text()
+	token.SecretHash = hashToken(secret)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionAPITokenCreate, "api_token", token.ID.String(), map[string]AuditChange{
+			"user_id":    {After: token.UserID},
+			"name":       {After: token.Name},
+			"prefix":     {After: token.Prefix},
+			"scopes":     {After: token.Scopes},
+			"expires_at": {After: token.ExpiresAt},
+		})
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar token de API para o usuário ID %s: %v", token.UserID, err)
+		return "", err
+	}
+	log.Printf("INFO: Token de API %s (%s) criado para o usuário ID %s.", token.Prefix, token.Name, token.UserID)
+	return APITokenPrefix + token.Prefix + "_" + secret, nil
+}
+
+// ListAPITokens lista os tokens de um usuário, do mais recente para o mais antigo.
+func ListAPITokens(userID uuid.UUID) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar tokens de API do usuário ID %s: %v", userID, err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken remove um token do usuário, com registro no log de auditoria em nome de
+// actor. Retorna gorm.ErrRecordNotFound se o token não existe ou pertence a outro usuário.
+func RevokeAPIToken(actor AuditActor, userID, id uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.APIToken
+		if err := tx.First(&token, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&token).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionAPITokenRevoke, "api_token", id.String(), map[string]AuditChange{
+			"user_id": {Before: userID},
+			"name":    {Before: token.Name},
+			"prefix":  {Before: token.Prefix},
+		})
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao revogar token de API %s: %v", id, err)
+		}
+		return err
+	}
+	log.Printf("INFO: Token de API %s revogado pelo usuário ID %s.", id, userID)
+	return nil
+}
+
+// LookupAPIToken valida um token de acesso pessoal sem registrar seu uso. O token é
+// encontrado pelo prefixo e o segredo é comparado pelo hash, em tempo constante; tokens
+// expirados e de usuários desativados são recusados.
+func LookupAPIToken(plain string) (models.APIToken, models.User, error) {
+	var token models.APIToken
+	var user models.User
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(plain, APITokenPrefix), "_")
+	if !ok || !strings.HasPrefix(plain, APITokenPrefix) || prefix == "" || secret == "" {
+		return token, user, ErrAPITokenInvalid
+	}
+	if err := database.DB.First(&token, "prefix = ?", prefix).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao buscar token de API %s: %v", prefix, err)
+			return token, user, err
+		}
+		return token, user, ErrAPITokenInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(token.SecretHash)) != 1 {
+		return token, user, ErrAPITokenInvalid
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return token, user, ErrAPITokenExpired
+	}
+	if err := database.DB.First(&user, "id = ?", token.UserID).Error; err != nil {
+		return token, user, ErrAPITokenInvalid
+	}
+	if !user.Active {
+		return token, user, ErrAPITokenDisabled
+	}
+	return token, user, nil
+}
+
+// AuthenticateAPIToken valida um token de acesso pessoal (veja LookupAPIToken) e registra
+// seu uso.
+func AuthenticateAPIToken(plain, clientIP string) (models.APIToken, models.User, error) {
+	token, user, err := LookupAPIToken(plain)
+	if err != nil {
+		return token, user, err
+	}
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenUseInterval || token.LastUsedIP != clientIP {
+		err := database.DB.Model(&models.APIToken{}).Where("id = ?", token.ID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": clientIP}).Error
+		if err != nil {
+			// Não impede o acesso: o registro de uso é apenas informativo.
+			log.Printf("WARN: Falha ao registrar uso do token de API %s: %v", token.Prefix, err)
+		}
+		token.LastUsedAt = &now
+		token.LastUsedIP = clientIP
+	}
+	return token, user, nil
+}
+
+// CheckAPIToken confirma que um token já autenticado continua válido (não foi revogado nem
+// expirou), para conexões longas como o stream de eventos de usuários.
+func CheckAPIToken(id uuid.UUID) error {
+	var token models.APIToken
+	if err := database.DB.First(&token, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPITokenInvalid
+		}
+		log.Printf("ERROR: Falha ao buscar token de API %s: %v", id, err)
+		return err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return ErrAPITokenExpired
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// auditChainLock identifica o advisory lock do PostgreSQL que serializa a gravação dos
+// registros de auditoria, para que duas transações não encadeiem ao mesmo registro anterior.
+const auditChainLock = 0x61756469 // "audi"
+
+// auditHMACKey, quando definida, faz o hash de cada registro ser um HMAC-SHA256: sem a
+// chave, quem tem acesso ao banco não consegue recalcular a cadeia após uma alteração.
+var auditHMACKey []byte
+
+// auditHMACSince é o ID do primeiro registro que precisa ter HMAC quando há chave: os
+// anteriores, gravados antes da chave ser configurada, podem ter SHA-256 simples.
+var auditHMACSince uint64
+
+// UseAuditHMACKey passa a assinar os novos registros de auditoria com HMAC-SHA256. Na
+// verificação, todo registro encadeado precisa então ter HMAC (veja UseAuditHMACSince).
+func UseAuditHMACKey(key []byte) {
+	auditHMACKey = key
+}
+
+// UseAuditHMACSince aceita, na verificação, registros com SHA-256 simples e ID menor que id:
+// os gravados antes de AUDIT_HMAC_KEY ser configurada. O valor fica fora do banco, para que
+// quem o altera não consiga rebaixar registros assinados.
+func UseAuditHMACSince(id uint64) {
+	auditHMACSince = id
+}
+
+// auditHashInput é a serialização canônica do conteúdo de um registro usada no hash.
+type auditHashInput struct {
+	ID             uint64 `json:"id"`
+	HashAlg        string `json:"hash_alg"`
+	PrevHash       string `json:"prev_hash"`
+	CreatedAt      string `json:"created_at"`
+	ActorType      string `json:"actor_type"`
+	ActorID        string `json:"actor_id"`
+	ImpersonatorID string `json:"impersonator_id"`
+	ClientID       string `json:"client_id"`
+	Action         string `json:"action"`
+	TargetType     string `json:"target_type"`
+	TargetID       string `json:"target_id"`
+	Changes        string `json:"changes"`
+	IP             string `json:"ip"`
+	UserAgent      string `json:"user_agent"`
+	RequestID      string `json:"request_id"`
+}
+
+// auditEntryHash calcula o hash do registro com o algoritmo indicado. O ID e o algoritmo
+// fazem parte do conteúdo: mudar qualquer um dos dois também invalida o hash.
+func auditEntryHash(entry *models.AuditLog, alg string, key []byte) (string, error) {
+	input := auditHashInput{
+		ID:         entry.ID,
+		HashAlg:    alg,
+		PrevHash:   entry.PrevHash,
+		CreatedAt:  entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ActorType:  entry.ActorType,
+		ClientID:   entry.ClientID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Changes:    entry.Changes,
+		IP:         entry.IP,
+		UserAgent:  entry.UserAgent,
+		RequestID:  entry.RequestID,
+	}
+	if entry.ActorID != nil {
+		input.ActorID = entry.ActorID.String()
+	}
+	if entry.ImpersonatorID != nil {
+		input.ImpersonatorID = entry.ImpersonatorID.String()
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	switch alg {
+	case models.AuditHashSHA256:
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	case models.AuditHashHMACSHA256:
+		if len(key) == 0 {
+			return "", errors.New("AUDIT_HMAC_KEY é necessária para verificar registros assinados com HMAC")
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	default:
+		return "", fmt.Errorf("algoritmo de hash desconhecido: %q", alg)
+	}
+}
+
+// chainAuditEntry encadeia o registro ao último gravado, preenchendo ID, CreatedAt, PrevHash,
+// Hash e HashAlg. Deve ser chamada dentro da transação que insere o registro: o ID é o
+// seguinte ao do último registro, reservado pela mesma trava que serializa o encadeamento.
+func chainAuditEntry(tx *gorm.DB, entry *models.AuditLog) error {
+	if tx.Dialector.Name() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return err
+		}
+	}
+	var last models.AuditLog
+	if err := tx.Select("id", "hash").Order("id desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	// O banco guarda microssegundos: o horário é truncado para que o hash possa ser recalculado.
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.ID = last.ID + 1
+	entry.PrevHash = last.Hash
+	entry.HashAlg = models.AuditHashSHA256
+	if len(auditHMACKey) > 0 {
+		entry.HashAlg = models.AuditHashHMACSHA256
+	}
+	hash, err := auditEntryHash(entry, entry.HashAlg, auditHMACKey)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	return nil
+}
+
+// AuditChainReport é o resultado de VerifyAuditChain.
+type AuditChainReport struct {
+	Entries     int64  // registros verificados
+	Unchained   int64  // registros anteriores ao encadeamento, sem hash
+	Checkpoints int    // checkpoints verificados
+	BrokenAt    uint64 // ID do primeiro registro com problema (0 se o problema é de um checkpoint)
+	Problem     string // vazio se a cadeia está íntegra
+}
+
+// OK informa se nenhum problema foi encontrado.
+func (r AuditChainReport) OK() bool {
+	return r.Problem == ""
+}
+
+// VerifyAuditChain percorre o log de auditoria em ordem e recalcula a cadeia de hashes,
+// parando no primeiro elo quebrado. Em seguida confere os checkpoints do banco e os de
+// external (ex: lidos de um arquivo exportado) com a chave pública informada: cada
+// checkpoint precisa ter assinatura válida e corresponder a um registro ainda presente,
+// com o mesmo hash e a mesma quantidade de registros até ele.
+func VerifyAuditChain(publicKey ed25519.PublicKey, external []models.AuditCheckpoint) (AuditChainReport, error) {
+	var report AuditChainReport
+	rows, err := database.DB.Model(&models.AuditLog{}).Order("id").Rows()
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	prev := ""
+	hashes := make(map[uint64]string)
+	counts := make(map[uint64]int64)
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := database.DB.ScanRows(rows, &entry); err != nil {
+			return report, err
+		}
+		fail := func(problem string) {
+			report.BrokenAt, report.Problem = entry.ID, problem
+		}
+		if entry.Hash == "" {
+			if report.Entries > 0 {
+				fail("registro sem hash após o início do encadeamento")
+				return report, nil
+			}
+			report.Unchained++
+			continue
+		}
+		report.Entries++
+		if entry.PrevHash != prev {
+			fail("prev_hash não corresponde ao registro anterior (registro removido, inserido ou reordenado)")
+			return report, nil
+		}
+		// Com a chave configurada, um registro com SHA-256 simples pode ter sido reescrito
+		// por quem não tem a chave (rebaixamento), a menos que seja anterior a ela.
+		if len(auditHMACKey) > 0 && entry.HashAlg != models.AuditHashHMACSHA256 && entry.ID >= auditHMACSince {
+			fail("registro sem HMAC com AUDIT_HMAC_KEY configurada (registro rebaixado ou reescrito)")
+			return report, nil
+		}
+		expected, err := auditEntryHash(&entry, entry.HashAlg, auditHMACKey)
+		if err != nil {
+			return report, err
+		}
+		if !hmac.Equal([]byte(expected), []byte(entry.Hash)) {
+			fail("hash não corresponde ao conteúdo do registro (registro alterado)")
+			return report, nil
+		}
+		prev = entry.Hash
+		hashes[entry.ID] = entry.Hash
+		counts[entry.ID] = report.Unchained + report.Entries
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	var checkpoints []models.AuditCheckpoint
+	if err := database.DB.Order("id").Find(&checkpoints).Error; err != nil {
+		return report, err
+	}
+	checkpoints = append(checkpoints, external...)
+	if len(checkpoints) > 0 && publicKey == nil {
+		return report, errors.New("chave pública necessária para verificar os checkpoints")
+	}
+	for _, cp := range checkpoints {
+		if !VerifyAuditCheckpoint(publicKey, cp) {
+			report.Problem = fmt.Sprintf("checkpoint %d com assinatura inválida", cp.ID)
+			return report, nil
+		}
+		if hash, ok := hashes[cp.LastEntryID]; !ok || hash != cp.EntryHash || counts[cp.LastEntryID] != cp.EntryCount {
+			report.BrokenAt = cp.LastEntryID
+			report.Problem = fmt.Sprintf("checkpoint %d não corresponde ao registro %d (registros reescritos ou removidos)", cp.ID, cp.LastEntryID)
+			return report, nil
+		}
+		report.Checkpoints++
+	}
+	return report, nil
+}
+
+// auditCheckpointPayload é a mensagem assinada de um checkpoint.
+func auditCheckpointPayload(cp models.AuditCheckpoint) []byte {
+	data, _ := json.Marshal(struct {
+		LastEntryID uint64 `json:"last_entry_id"`
+		EntryHash   string `json:"entry_hash"`
+		EntryCount  int64  `json:"entry_count"`
+		CreatedAt   string `json:"created_at"`
+	}{cp.LastEntryID, cp.EntryHash, cp.EntryCount, cp.CreatedAt.UTC().Format(time.RFC3339Nano)})
+	return data
+}
+
+// VerifyAuditCheckpoint confere a assinatura do checkpoint.
+func VerifyAuditCheckpoint(publicKey ed25519.PublicKey, cp models.AuditCheckpoint) bool {
+	signature, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || cp.KeyID != AuditKeyID(publicKey) {
+		return false
+	}
+	return ed25519.Verify(publicKey, auditCheckpointPayload(cp), signature)
+}
+
+// CreateAuditCheckpoint assina e grava um checkpoint do último registro encadeado. Retorna
+// false (sem erro) se não houver registros novos desde o último checkpoint.
+func CreateAuditCheckpoint(key ed25519.PrivateKey) (models.AuditCheckpoint, bool, error) {
+	var cp models.AuditCheckpoint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var last models.AuditLog
+		if err := tx.Where("hash <> ''").Order("id desc").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		var previous models.AuditCheckpoint
+		if err := tx.Order("id desc").Limit(1).Find(&previous).Error; err != nil {
+			return err
+		}
+		if last.ID == 0 || last.ID == previous.LastEntryID {
+			return nil
+		}
+		var count int64
+		if err := tx.Model(&models.AuditLog{}).Where("id <= ?", last.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		cp = models.AuditCheckpoint{
+			LastEntryID: last.ID,
+			EntryHash:   last.Hash,
+			EntryCount:  count,
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+			KeyID:       AuditKeyID(key.Public().(ed25519.PublicKey)),
+		}
+		cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, auditCheckpointPayload(cp)))
+		return tx.Create(&cp).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar checkpoint do log de auditoria: %v", err)
+		return cp, false, err
+	}
+	return cp, cp.ID != 0, nil
+}
+
+// ListAuditCheckpoints retorna todos os checkpoints, do mais antigo para o mais recente.
+func ListAuditCheckpoints() ([]models.AuditCheckpoint, error) {
+	var checkpoints []models.AuditCheckpoint
+	if err := database.DB.Order("id").Find(&checkpoints).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar checkpoints do log de auditoria: %v", err)
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// RunAuditCheckpoints cria um checkpoint a cada intervalo, enquanto houver registros novos.
+// Deve ser executada em uma goroutine.
+func RunAuditCheckpoints(key ed25519.PrivateKey, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if cp, created, err := CreateAuditCheckpoint(key); err == nil && created {
+			log.Printf("INFO: Checkpoint %d do log de auditoria criado (registro %d).", cp.ID, cp.LastEntryID)
+		}
+	}
+}
+
+// AuditKeyID deriva o identificador da chave de assinatura dos checkpoints.
+func AuditKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// LoadAuditSigningKey lê a chave Ed25519 privada (PEM, PKCS#8) que assina os checkpoints.
+func LoadAuditSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEMBlock(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("a chave PKCS#8 não é Ed25519")
+	}
+	return key, nil
+}
+
+// LoadAuditPublicKey lê a chave Ed25519 pública (PEM, PKIX) usada para verificar checkpoints.
+func LoadAuditPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEMBlock(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("a chave pública não é Ed25519")
+	}
+	return key, nil
+}
+
+func readPEMBlock(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("arquivo não contém um bloco PEM %q", blockType)
+	}
+	return block, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestAuditChain usa um banco próprio, pois adultera registros de auditoria.
+func TestAuditChain(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	originalGlobalDB := database.DB
+	database.DB = db
+	defer func() { database.DB = originalGlobalDB }()
+
+	var users []models.User
+	for i := 0; i < 3; i++ {
+		user := models.User{Name: "Cadeia", Email: "chain." + uuid.NewString() + "@example.com"}
+		require.NoError(t, CreateUser(SystemActor, &user, "password123"))
+		users = append(users, user)
+	}
+	// A partir daqui os registros passam a ser assinados com HMAC.
+	UseAuditHMACKey([]byte("chave-hmac-de-teste-com-32-bytes!"))
+	defer UseAuditHMACKey(nil)
+	require.NoError(t, UpdateUserColumns(SystemActor, users[0].ID, map[string]interface{}{"name": "Renomeado"}))
+	require.NoError(t, DeleteUser(SystemActor, users[1].ID))
+
+	var entries []models.AuditLog
+	require.NoError(t, db.Order("id").Find(&entries).Error)
+	require.Len(t, entries, 5)
+	assert.Empty(t, entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, models.AuditHashSHA256, entries[2].HashAlg)
+	assert.Equal(t, models.AuditHashHMACSHA256, entries[3].HashAlg)
+
+	// Com a chave, todo registro precisa ter HMAC, exceto os anteriores à sua configuração.
+	report, err := VerifyAuditChain(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, entries[0].ID, report.BrokenAt)
+	assert.Contains(t, report.Problem, "sem HMAC")
+	UseAuditHMACSince(entries[3].ID)
+	defer UseAuditHMACSince(0)
+	report, err = VerifyAuditChain(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problem)
+	assert.EqualValues(t, 5, report.Entries)
+
+	// Sem a chave, os registros com HMAC não podem ser verificados.
+	UseAuditHMACKey(nil)
+	_, err = VerifyAuditChain(nil, nil)
+	assert.Error(t, err)
+	UseAuditHMACKey([]byte("chave-hmac-de-teste-com-32-bytes!"))
+
+	// Checkpoint assinado: só é criado quando há registros novos.
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	checkpoint, created, err := CreateAuditCheckpoint(private)
+	require.NoError(t, err)
+	require.True(t, created)
+	assert.Equal(t, entries[4].ID, checkpoint.LastEntryID)
+	assert.EqualValues(t, 5, checkpoint.EntryCount)
+	_, created, err = CreateAuditCheckpoint(private)
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problem)
+	assert.Equal(t, 1, report.Checkpoints)
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	report, err = VerifyAuditChain(otherPublic, nil)
+	require.NoError(t, err)
+	assert.Contains(t, report.Problem, "assinatura inválida")
+
+	// Alteração direta no banco (sem os hooks do GORM): o hash deixa de conferir.
+	require.NoError(t, db.Exec("UPDATE audit_logs SET ip = ? WHERE id = ?", "10.0.0.1", entries[1].ID).Error)
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.Equal(t, entries[1].ID, report.BrokenAt)
+	assert.Contains(t, report.Problem, "registro alterado")
+	require.NoError(t, db.Exec("UPDATE audit_logs SET ip = ? WHERE id = ?", entries[1].IP, entries[1].ID).Error)
+
+	// O ID faz parte do hash: renumerar registros é detectado.
+	require.NoError(t, db.Exec("UPDATE audit_logs SET id = ? WHERE id = ?", entries[4].ID+10, entries[4].ID).Error)
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.Equal(t, entries[4].ID+10, report.BrokenAt)
+	require.NoError(t, db.Exec("UPDATE audit_logs SET id = ? WHERE id = ?", entries[4].ID, entries[4].ID+10).Error)
+
+	// Rebaixar um registro assinado para SHA-256 simples também é detectado.
+	downgraded := entries[4]
+	downgraded.HashAlg = models.AuditHashSHA256
+	downgraded.Hash, err = auditEntryHash(&downgraded, models.AuditHashSHA256, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("UPDATE audit_logs SET hash_alg = ?, hash = ? WHERE id = ?", downgraded.HashAlg, downgraded.Hash, downgraded.ID).Error)
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.Equal(t, entries[4].ID, report.BrokenAt)
+	require.NoError(t, db.Exec("UPDATE audit_logs SET hash_alg = ?, hash = ? WHERE id = ?", entries[4].HashAlg, entries[4].Hash, entries[4].ID).Error)
+
+	// Remover o último registro não quebra a cadeia, mas o checkpoint denuncia a remoção.
+	require.NoError(t, db.Exec("DELETE FROM audit_logs WHERE id = ?", entries[4].ID).Error)
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.Contains(t, report.Problem, "checkpoint")
+
+	// Remover um registro do meio quebra o elo seguinte.
+	require.NoError(t, db.Exec("DELETE FROM audit_logs WHERE id = ?", entries[2].ID).Error)
+	report, err = VerifyAuditChain(public, nil)
+	require.NoError(t, err)
+	assert.Equal(t, entries[3].ID, report.BrokenAt)
+	assert.Contains(t, report.Problem, "prev_hash")
+}
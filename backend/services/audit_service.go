@@ -0,0 +1,228 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// AuditActor identifica o autor de uma alteração e a requisição que a originou.
+type AuditActor struct {
+	Type           string // models.AuditActor*
+	UserID         *uuid.UUID
+	ImpersonatorID *uuid.UUID // administrador em personificação (claim act)
+	ClientID       string
+	IP             string
+	UserAgent      string
+	RequestID      string
+}
+
+// SystemActor é o autor das alterações feitas por processos internos (CLI, sincronização LDAP).
+var SystemActor = AuditActor{Type: models.AuditActorSystem}
+
+// AuditChange é o valor de um campo antes e depois de uma alteração.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditRedacted substitui, no diff, valores que não podem ser expostos (ex: hash de senha).
+const auditRedacted = "[REDACTED]"
+
+// AuditFilter restringe a consulta ao log de auditoria. Campos vazios não filtram.
+type AuditFilter struct {
+	ActorID        *uuid.UUID
+	ImpersonatorID *uuid.UUID
+	TargetID       string
+	Action         string
+	RequestID      string
+	From           time.Time
+	To             time.Time
+}
+
+// scope aplica o filtro à consulta.
+func (f AuditFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.ActorID != nil {
+		db = db.Where("actor_id = ?", *f.ActorID)
+	}
+	if f.ImpersonatorID != nil {
+		db = db.Where("impersonator_id = ?", *f.ImpersonatorID)
+	}
+	if f.TargetID != "" {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if !f.From.IsZero() {
+		db = db.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("created_at < ?", f.To)
+	}
+	return db
+}
+
+// RecordAudit grava um registro de auditoria fora de uma transação de alteração
+// (ex: início de uma personificação).
+func RecordAudit(actor AuditActor, action, targetType, targetID string, changes map[string]AuditChange) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return writeAudit(tx, actor, action, targetType, targetID, changes)
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar registro de auditoria (%s em %s %s): %v", action, targetType, targetID, err)
+		return err
+	}
+	return nil
+}
+
+// writeAudit insere o registro de auditoria, encadeado ao anterior, usando tx (normalmente
+// a transação da alteração).
+func writeAudit(tx *gorm.DB, actor AuditActor, action, targetType, targetID string, changes map[string]AuditChange) error {
+	entry := models.AuditLog{
+		ActorType:      actor.Type,
+		ActorID:        actor.UserID,
+		ImpersonatorID: actor.ImpersonatorID,
+		ClientID:       actor.ClientID,
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		IP:             actor.IP,
+		UserAgent:      actor.UserAgent,
+		RequestID:      actor.RequestID,
+	}
+	if entry.ActorType == "" {
+		entry.ActorType = models.AuditActorSystem
+	}
+	if len(changes) > 0 {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		entry.Changes = string(data)
+	}
+	if err := chainAuditEntry(tx, &entry); err != nil {
+		return err
+	}
+	return tx.Create(&entry).Error
+}
+
+// recordUserChange registra a criação (before nil), remoção (after nil) ou alteração de um
+// usuário no log de auditoria, com o diff dos campos, e grava o evento correspondente no
+// outbox. Alterações sem nenhum campo modificado não são registradas.
+func recordUserChange(tx *gorm.DB, actor AuditActor, action string, before, after *models.User) error {
+	changes := diffUserFields(before, after)
+	if action == models.AuditActionUserUpdate && len(changes) == 0 {
+		return nil
+	}
+	target := after
+	if target == nil {
+		target = before
+	}
+	if err := writeAudit(tx, actor, action, "user", target.ID.String(), changes); err != nil {
+		return err
+	}
+	return writeUserEvent(tx, action, before, after, changes)
+}
+
+// userAuditFields retorna os campos de um usuário comparados no log de auditoria.
+func userAuditFields(user *models.User) map[string]interface{} {
+	if user == nil {
+		return map[string]interface{}{}
+	}
+	var externalID interface{}
+	if user.ExternalID != nil {
+		externalID = *user.ExternalID
+	}
+	attributes := map[string]interface{}(user.Attributes)
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"name":        user.Name,
+		"email":       user.Email,
+		"active":      user.Active,
+		"external_id": externalID,
+		"role":        user.Role,
+		"password":    user.PasswordHash,
+		"attributes":  attributes,
+		"avatar_url":  user.AvatarURL,
+	}
+}
+
+// diffUserFields compara os campos auditados de dois estados de um usuário. A senha aparece
+// no diff apenas como alterada, sem o hash.
+func diffUserFields(before, after *models.User) map[string]AuditChange {
+	old, current := userAuditFields(before), userAuditFields(after)
+	changes := make(map[string]AuditChange)
+	for _, field := range []string{"name", "email", "active", "external_id", "role", "password", "attributes", "avatar_url"} {
+		b, a := old[field], current[field]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		if field == "password" {
+			b, a = redact(b), redact(a)
+		}
+		changes[field] = AuditChange{Before: b, After: a}
+	}
+	return changes
+}
+
+// redact oculta um valor sensível, preservando a informação de que ele existe.
+func redact(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return nil
+	}
+	return auditRedacted
+}
+
+// ListAuditLogs retorna uma página do log de auditoria, do registro mais recente para o mais
+// antigo, e o total de registros que satisfazem o filtro.
+func ListAuditLogs(filter AuditFilter, offset, limit int) ([]models.AuditLog, int64, error) {
+	query := database.DB.Model(&models.AuditLog{}).Scopes(filter.scope)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("ERROR: Falha ao contar registros de auditoria: %v", err)
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id desc").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar registros de auditoria: %v", err)
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ExportAuditLogs percorre, em ordem cronológica, os registros que satisfazem o filtro,
+// chamando fn para cada um sem carregar a tabela inteira em memória.
+func ExportAuditLogs(filter AuditFilter, fn func(models.AuditLog) error) error {
+	rows, err := database.DB.Model(&models.AuditLog{}).Scopes(filter.scope).Order("id").Rows()
+	if err != nil {
+		log.Printf("ERROR: Falha ao iniciar exportação do log de auditoria: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := database.DB.ScanRows(rows, &entry); err != nil {
+			log.Printf("ERROR: Falha ao ler registro de auditoria durante exportação: %v", err)
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestAuditedAccessChanges confere que sessões, tokens de API, login sem senha e desativação
+// geram registros de auditoria.
+func TestAuditedAccessChanges(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	originalGlobalDB := database.DB
+	database.DB = db
+	defer func() { database.DB = originalGlobalDB }()
+
+	user := models.User{Name: "Acessos", Email: "access." + uuid.NewString() + "@example.com"}
+	require.NoError(t, CreateUser(SystemActor, &user, "password123"))
+	actor := AuditActor{Type: models.AuditActorUser, UserID: &user.ID, IP: "198.51.100.7", RequestID: "req-acessos"}
+	lastEntry := func(action string) models.AuditLog {
+		t.Helper()
+		var entry models.AuditLog
+		require.NoError(t, db.Where("action = ?", action).Order("id desc").First(&entry).Error, action)
+		return entry
+	}
+
+	token := models.APIToken{UserID: user.ID, Name: "CI", Scopes: "users:read", ExpiresAt: time.Now().Add(time.Hour)}
+	_, err = CreateAPIToken(actor, &token)
+	require.NoError(t, err)
+	entry := lastEntry(models.AuditActionAPITokenCreate)
+	assert.Equal(t, token.ID.String(), entry.TargetID)
+	assert.Equal(t, "req-acessos", entry.RequestID)
+	assert.Contains(t, entry.Changes, `"users:read"`)
+	require.NoError(t, RevokeAPIToken(actor, user.ID, token.ID))
+	assert.Equal(t, token.ID.String(), lastEntry(models.AuditActionAPITokenRevoke).TargetID)
+
+	sessions := make([]models.Session, 3)
+	for i := range sessions {
+		sessions[i] = models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, CreateSession(&sessions[i]))
+	}
+	require.NoError(t, RevokeSession(actor, user.ID, sessions[0].ID))
+	assert.Equal(t, sessions[0].ID.String(), lastEntry(models.AuditActionSessionRevoke).TargetID)
+	count, err := RevokeOtherSessions(actor, user.ID, sessions[1].ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	entry = lastEntry(models.AuditActionSessionsRevoke)
+	assert.Equal(t, user.ID.String(), entry.TargetID)
+	assert.JSONEq(t, `{"revoked_sessions":{"before":null,"after":1}}`, entry.Changes)
+
+	// Sem sessões para encerrar, nada é registrado.
+	var before int64
+	db.Model(&models.AuditLog{}).Count(&before)
+	_, err = RevokeOtherSessions(actor, user.ID, sessions[1].ID)
+	require.NoError(t, err)
+	var after int64
+	db.Model(&models.AuditLog{}).Count(&after)
+	assert.Equal(t, before, after)
+
+	// O login sem senha é registrado em nome do usuário do pedido.
+	link := models.MagicLink{UserID: &user.ID, Email: user.Email, ExpiresAt: time.Now().Add(time.Minute)}
+	require.NoError(t, db.Create(&link).Error)
+	_, err = ConsumeMagicLink(AuditActor{Type: models.AuditActorAnonymous, IP: "198.51.100.8"}, link.ID)
+	require.NoError(t, err)
+	entry = lastEntry(models.AuditActionMagicLinkLogin)
+	assert.Equal(t, models.AuditActorUser, entry.ActorType)
+	assert.Equal(t, user.ID, *entry.ActorID)
+	assert.Equal(t, "198.51.100.8", entry.IP)
+	_, err = ConsumeMagicLink(actor, link.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// A desativação tem ação própria e encerra as sessões restantes na mesma transação.
+	scim := AuditActor{Type: models.AuditActorSCIM}
+	require.NoError(t, UpdateUserColumns(scim, user.ID, map[string]interface{}{"active": false}))
+	entry = lastEntry(models.AuditActionUserDeactivate)
+	assert.Equal(t, models.AuditActorSCIM, entry.ActorType)
+	assert.Contains(t, entry.Changes, `"active"`)
+	entry = lastEntry(models.AuditActionSessionsRevoke)
+	assert.Equal(t, models.AuditActorSCIM, entry.ActorType)
+	assert.JSONEq(t, `{"revoked_sessions":{"before":null,"after":1}}`, entry.Changes)
+
+	// Pedidos de usuários desativados não podem ser usados.
+	link = models.MagicLink{UserID: &user.ID, Email: user.Email, ExpiresAt: time.Now().Add(time.Minute)}
+	require.NoError(t, db.Create(&link).Error)
+	_, err = ConsumeMagicLink(actor, link.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/blobstore"
+	"github.com/monteirobsb/user-management/backend/imaging"
+	"github.com/monteirobsb/user-management/backend/models"
+)
+
+// DefaultAvatarSizes são os lados, em pixels, das miniaturas geradas para cada avatar.
+var DefaultAvatarSizes = []int{64, 128, 256}
+
+// ErrAvatarStorageDisabled indica que nenhum BlobStore foi configurado para os avatares.
+var ErrAvatarStorageDisabled = errors.New("armazenamento de avatares não configurado")
+
+var (
+	avatarStore blobstore.BlobStore
+	avatarSizes = DefaultAvatarSizes
+)
+
+// UseAvatarStore define onde as miniaturas dos avatares são gravadas e os seus tamanhos.
+func UseAvatarStore(store blobstore.BlobStore, sizes []int) {
+	avatarStore, avatarSizes = store, slices.Sorted(slices.Values(sizes))
+}
+
+// SetUserAvatar gera as miniaturas de image (veja imaging.Thumbnails), grava-as no BlobStore
+// e as associa ao usuário, em avatar_url (a maior miniatura). As miniaturas do avatar
+// anterior são removidas. As chaves mudam a cada envio (avatars/<usuário>/<aleatório>-<lado>.jpg),
+// para que caches e CDNs nunca sirvam a imagem antiga. A alteração é registrada no log de
+// auditoria em nome de actor.
+func SetUserAvatar(ctx context.Context, actor AuditActor, id uuid.UUID, image []byte) (models.User, error) {
+	if avatarStore == nil {
+		return models.User{}, ErrAvatarStorageDisabled
+	}
+	user, err := GetUserByID(id)
+	if err != nil {
+		return user, err
+	}
+	thumbnails, err := imaging.Thumbnails(image, avatarSizes)
+	if err != nil {
+		return user, err
+	}
+
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	prefix := fmt.Sprintf("avatars/%s/%s", id, hex.EncodeToString(suffix))
+	for _, size := range avatarSizes {
+		if err := avatarStore.Put(ctx, avatarFileKey(prefix, size), thumbnails[size], "image/jpeg"); err != nil {
+			log.Printf("ERROR: Falha ao gravar miniatura do avatar do usuário ID %s: %v", id, err)
+			deleteAvatarFiles(prefix)
+			return user, err
+		}
+	}
+
+	url := avatarStore.URL(avatarFileKey(prefix, avatarSizes[len(avatarSizes)-1]))
+	if err := UpdateUserColumns(actor, id, map[string]interface{}{"avatar_key": prefix, "avatar_url": url}); err != nil {
+		deleteAvatarFiles(prefix)
+		return user, err
+	}
+	if user.AvatarKey != "" {
+		deleteAvatarFiles(user.AvatarKey)
+	}
+	log.Printf("INFO: Avatar do usuário ID %s atualizado.", id)
+	return GetUserByID(id)
+}
+
+// DeleteUserAvatar remove o avatar do usuário e as suas miniaturas.
+func DeleteUserAvatar(actor AuditActor, id uuid.UUID) (models.User, error) {
+	user, err := GetUserByID(id)
+	if err != nil || user.AvatarKey == "" {
+		return user, err
+	}
+	if err := UpdateUserColumns(actor, id, map[string]interface{}{"avatar_key": "", "avatar_url": ""}); err != nil {
+		return user, err
+	}
+	deleteAvatarFiles(user.AvatarKey)
+	return GetUserByID(id)
+}
+
+// avatarFileKey retorna a chave da miniatura de um lado do avatar.
+func avatarFileKey(prefix string, size int) string {
+	return fmt.Sprintf("%s-%d.jpg", prefix, size)
+}
+
+// deleteAvatarFiles remove as miniaturas de um avatar. Falhas são apenas registradas: o
+// arquivo órfão não afeta o usuário.
+func deleteAvatarFiles(prefix string) {
+	if avatarStore == nil {
+		return
+	}
+	for _, size := range avatarSizes {
+		if err := avatarStore.Delete(context.Background(), avatarFileKey(prefix, size)); err != nil {
+			log.Printf("WARN: Falha ao remover miniatura de avatar %s: %v", avatarFileKey(prefix, size), err)
+		}
+	}
+}
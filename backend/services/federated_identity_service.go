@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"gorm.io/gorm"
+)
+
+// Erros de login federado que impedem vincular ou criar a conta local.
+var (
+	ErrFederatedEmailMissing     = errors.New("o provedor não informou o e-mail do usuário")
+	ErrFederatedEmailNotVerified = errors.New("o e-mail não foi verificado pelo provedor")
+	ErrFederatedSignupDisabled   = errors.New("não há conta local com este e-mail e o cadastro automático está desabilitado")
+	ErrFederatedLinkRequired     = errors.New("já existe uma conta com este e-mail: entre nela e vincule o provedor nas configurações da conta")
+	ErrFederatedIdentityInUse    = errors.New("esta conta do provedor já está vinculada a outro usuário")
+)
+
+// FederatedLogin são os dados de um usuário autenticado por um provedor OIDC externo,
+// extraídos do ID token já validado.
+type FederatedLogin struct {
+	Provider      string
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// LoginFederatedUser resolve o usuário local de um login federado:
+//  1. se a identidade externa já está vinculada, retorna o usuário vinculado;
+//  2. senão, vincula a um usuário existente com o mesmo e-mail, desde que verificado pelo provedor
+//     e que a posse do e-mail tenha sido comprovada localmente (EmailVerifiedAt): um e-mail
+//     cadastrado sem confirmação (ex: pelo cadastro público) não entrega a conta a quem o controla
+//     no provedor, e o vínculo precisa ser pedido com a conta aberta (LinkFederatedIdentity);
+//  3. senão, se allowSignup for verdadeiro, cria o usuário (provisionamento just-in-time).
+//
+// O retorno created indica se o usuário foi criado neste login.
+func LoginFederatedUser(login FederatedLogin, allowSignup bool) (user models.User, created bool, err error) {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var identity models.FederatedIdentity
+		result := tx.First(&identity, "issuer = ? AND subject = ?", login.Issuer, login.Subject)
+		if result.Error == nil {
+			if err := tx.Model(&identity).Updates(map[string]interface{}{"email": login.Email, "last_login_at": now}).Error; err != nil {
+				return err
+			}
+			return tx.First(&user, "id = ?", identity.UserID).Error
+		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+
+		// Primeiro login com esta identidade: o vínculo é feito pelo e-mail.
+		if login.Email == "" {
+			return ErrFederatedEmailMissing
+		}
+		if !login.EmailVerified {
+			return ErrFederatedEmailNotVerified
+		}
+
+		result = tx.First(&user, "LOWER(email) = LOWER(?)", login.Email)
+		switch {
+		case result.Error == nil:
+			if user.EmailVerifiedAt == nil {
+				log.Printf("WARN: Identidade %s (%s) não vinculada ao usuário ID %s: e-mail não confirmado localmente.", login.Provider, login.Subject, user.ID)
+				return ErrFederatedLinkRequired
+			}
+			log.Printf("INFO: Identidade %s (%s) vinculada ao usuário existente ID %s.", login.Provider, login.Subject, user.ID)
+		case errors.Is(result.Error, gorm.ErrRecordNotFound):
+			if !allowSignup {
+				return ErrFederatedSignupDisabled
+			}
+			// A conta criada não tem senha conhecida: o login é feito apenas pelo provedor
+			// até que o usuário defina uma senha.
+			hash, err := password.Hash(rand.Text())
+			if err != nil {
+				return err
+			}
+			user = models.User{Name: federatedName(login), Email: login.Email, PasswordHash: hash, EmailVerifiedAt: &now}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := recordUserChange(tx, SystemActor, models.AuditActionUserCreate, nil, &user); err != nil {
+				return err
+			}
+			created = true
+			log.Printf("INFO: Usuário ID %s criado no primeiro login via %s.", user.ID, login.Provider)
+		default:
+			return result.Error
+		}
+
+		return tx.Create(&models.FederatedIdentity{
+			UserID:      user.ID,
+			Provider:    login.Provider,
+			Issuer:      login.Issuer,
+			Subject:     login.Subject,
+			Email:       login.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		if !errors.Is(err, ErrFederatedEmailMissing) && !errors.Is(err, ErrFederatedEmailNotVerified) && !errors.Is(err, ErrFederatedSignupDisabled) && !errors.Is(err, ErrFederatedLinkRequired) {
+			log.Printf("ERROR: Falha ao processar login federado (%s, subject %s): %v", login.Provider, login.Subject, err)
+		}
+		return models.User{}, false, err
+	}
+	return user, created, nil
+}
+
+// LinkFederatedIdentity vincula a identidade externa ao usuário userID, a pedido dele próprio
+// (com a conta aberta), sem depender do e-mail. Retorna ErrFederatedIdentityInUse se a
+// identidade já está vinculada a outro usuário.
+func LinkFederatedIdentity(actor AuditActor, userID uuid.UUID, login FederatedLogin) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var user models.User
+		if err := tx.First(&user, "id = ? AND active = ?", userID, true).Error; err != nil {
+			return err
+		}
+		var identity models.FederatedIdentity
+		result := tx.First(&identity, "issuer = ? AND subject = ?", login.Issuer, login.Subject)
+		switch {
+		case result.Error == nil && identity.UserID != userID:
+			return ErrFederatedIdentityInUse
+		case result.Error == nil:
+			return tx.Model(&identity).Updates(map[string]interface{}{"email": login.Email, "last_login_at": now}).Error
+		case !errors.Is(result.Error, gorm.ErrRecordNotFound):
+			return result.Error
+		}
+		if err := tx.Create(&models.FederatedIdentity{
+			UserID:      userID,
+			Provider:    login.Provider,
+			Issuer:      login.Issuer,
+			Subject:     login.Subject,
+			Email:       login.Email,
+			LastLoginAt: &now,
+		}).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionIdentityLink, "user", userID.String(), map[string]AuditChange{
+			"provider": {After: login.Provider},
+			"subject":  {After: login.Subject},
+		})
+	})
+	if err != nil {
+		if !errors.Is(err, ErrFederatedIdentityInUse) && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao vincular identidade %s (subject %s) ao usuário ID %s: %v", login.Provider, login.Subject, userID, err)
+		}
+		return err
+	}
+	log.Printf("INFO: Identidade %s (%s) vinculada pelo usuário ID %s.", login.Provider, login.Subject, userID)
+	return nil
+}
+
+// CreateFederatedLoginState grava o estado de um login federado, identificado pelo valor
+// do parâmetro state. Estados expirados são removidos na mesma operação.
+func CreateFederatedLoginState(plainState string, state *models.FederatedLoginState) error {
+	state.StateHash = hashToken(plainState)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.FederatedLoginState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(state).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar estado de login federado (%s): %v", state.Provider, err)
+	}
+	return err
+}
+
+// ConsumeFederatedLoginState busca e remove o estado de um login federado, que só pode ser
+// usado uma vez. Retorna gorm.ErrRecordNotFound se ele não existe, já foi usado ou expirou.
+func ConsumeFederatedLoginState(plainState string) (models.FederatedLoginState, error) {
+	var state models.FederatedLoginState
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&state, "state_hash = ?", hashToken(plainState)).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.FederatedLoginState{}, "state_hash = ?", state.StateHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao consumir estado de login federado: %v", err)
+		}
+		return state, err
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return state, gorm.ErrRecordNotFound
+	}
+	return state, nil
+}
+
+// federatedName escolhe o nome do usuário criado no primeiro login: o informado pelo
+// provedor ou, na falta dele, a parte local do e-mail.
+func federatedName(login FederatedLogin) string {
+	if name := strings.TrimSpace(login.Name); name != "" {
+		return name
+	}
+	local, _, _ := strings.Cut(login.Email, "@")
+	return local
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// CreateGroup cria um grupo com os membros informados (por ID de usuário).
+func CreateGroup(group *models.Group, memberIDs []uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		members, err := findMembers(tx, memberIDs)
+		if err != nil {
+			return err
+		}
+		group.Members = members
+		return tx.Omit("Members.*").Create(group).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar grupo '%s': %v", group.DisplayName, err)
+		return err
+	}
+	return nil
+}
+
+// ListGroups retorna uma página de grupos (com membros) e o total que satisfaz o filtro.
+func ListGroups(scope func(*gorm.DB) *gorm.DB, order string, offset, limit int) ([]models.Group, int64, error) {
+	query := database.DB.Model(&models.Group{})
+	if scope != nil {
+		query = query.Scopes(scope)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("ERROR: Falha ao contar grupos: %v", err)
+		return nil, 0, err
+	}
+
+	if order == "" {
+		order = "created_at, id"
+	}
+	var groups []models.Group
+	if err := query.Preload("Members").Order(order).Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar grupos: %v", err)
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// GetGroupByID retorna um grupo com seus membros.
+func GetGroupByID(id uuid.UUID) (models.Group, error) {
+	var group models.Group
+	result := database.DB.Preload("Members").First(&group, "id = ?", id)
+	if result.Error != nil {
+		log.Printf("ERROR: Falha ao buscar grupo com ID %s: %v", id, result.Error)
+		return group, result.Error
+	}
+	return group, nil
+}
+
+// GetUserGroups retorna os grupos dos quais o usuário é membro.
+func GetUserGroups(userID uuid.UUID) ([]models.Group, error) {
+	var groups []models.Group
+	err := database.DB.
+		Where("id IN (SELECT group_id FROM group_members WHERE user_id = ?)", userID).
+		Order("display_name").
+		Find(&groups).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar grupos do usuário ID %s: %v", userID, err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+// UpdateGroup grava nome e externalId do grupo. Se memberIDs não for nil,
+// a lista de membros é substituída por ela.
+func UpdateGroup(group *models.Group, memberIDs []uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Group{}).Where("id = ?", group.ID).Updates(map[string]interface{}{
+			"display_name": group.DisplayName,
+			"external_id":  group.ExternalID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if memberIDs == nil {
+			return nil
+		}
+		members, err := findMembers(tx, memberIDs)
+		if err != nil {
+			return err
+		}
+		group.Members = members
+		return tx.Model(group).Omit("Members.*").Association("Members").Replace(members)
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao atualizar grupo ID %s: %v", group.ID, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteGroup remove um grupo e suas associações de membros.
+func DeleteGroup(id uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM group_members WHERE group_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Group{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao deletar grupo ID %s: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GroupNameInUse informa se outro grupo (diferente de exceptID) já usa o nome,
+// sem diferenciar maiúsculas de minúsculas.
+func GroupNameInUse(name string, exceptID uuid.UUID) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Group{}).
+		Where("LOWER(display_name) = LOWER(?) AND id <> ?", name, exceptID).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao verificar unicidade do grupo '%s': %v", name, err)
+	}
+	return count > 0, err
+}
+
+// findMembers carrega os usuários correspondentes aos IDs informados.
+// IDs inexistentes são ignorados.
+func findMembers(tx *gorm.DB, ids []uuid.UUID) ([]models.User, error) {
+	if len(ids) == 0 {
+		return []models.User{}, nil
+	}
+	var users []models.User
+	if err := tx.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyLockTimeout é o tempo após o qual uma chave ainda em andamento é considerada
+// abandonada (ex: o processo caiu durante a requisição original) e pode ser reservada de novo.
+const idempotencyLockTimeout = 5 * time.Minute
+
+var (
+	// ErrIdempotencyKeyMismatch indica que a chave já foi usada com outra requisição (outro
+	// método, URL ou corpo).
+	ErrIdempotencyKeyMismatch = errors.New("a chave de idempotência já foi usada com outra requisição")
+	// ErrIdempotencyKeyInUse indica que a requisição original com a chave ainda está em andamento.
+	ErrIdempotencyKeyInUse = errors.New("a requisição com esta chave de idempotência ainda está em andamento")
+)
+
+// BeginIdempotentRequest reserva key para scope por ttl. Se a reserva foi feita, retorna o
+// registro em andamento e started true: o chamador executa a requisição e grava o resultado
+// com CompleteIdempotentRequest (ou libera a chave com ReleaseIdempotencyKey). Se a chave já
+// tem um resultado para a mesma requisição (fingerprint), retorna esse registro e started
+// false. Chaves expiradas são removidas na mesma operação.
+func BeginIdempotentRequest(scope, key, fingerprint string, ttl time.Duration) (*models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyKey{Scope: scope, Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	var created bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		created = result.RowsAffected == 1
+		return result.Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao reservar chave de idempotência (%s): %v", scope, err)
+		return nil, false, err
+	}
+	if created {
+		return &record, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := database.DB.First(&existing, "scope = ? AND key = ?", scope, key).Error; err != nil {
+		log.Printf("ERROR: Falha ao buscar chave de idempotência (%s): %v", scope, err)
+		return nil, false, err
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, false, ErrIdempotencyKeyMismatch
+	}
+	if existing.CompletedAt != nil {
+		return &existing, false, nil
+	}
+	if now.Sub(existing.CreatedAt) < idempotencyLockTimeout {
+		return nil, false, ErrIdempotencyKeyInUse
+	}
+
+	// Requisição original abandonada: assume a chave, se nenhuma outra tentativa o fez antes.
+	result := database.DB.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND completed_at IS NULL AND created_at = ?", existing.ID, existing.CreatedAt).
+		Updates(map[string]interface{}{"created_at": now, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		log.Printf("ERROR: Falha ao reservar chave de idempotência abandonada ID %s: %v", existing.ID, result.Error)
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, ErrIdempotencyKeyInUse
+	}
+	existing.CreatedAt, existing.ExpiresAt = now, now.Add(ttl)
+	return &existing, true, nil
+}
+
+// CompleteIdempotentRequest grava a resposta da requisição original, reenviada às próximas
+// tentativas com a mesma chave até a expiração.
+func CompleteIdempotentRequest(record *models.IdempotencyKey, status int, header string, body []byte) error {
+	now := time.Now()
+	record.StatusCode, record.Header, record.Body, record.CompletedAt = status, header, body, &now
+	err := database.DB.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"status_code":  status,
+		"header":       header,
+		"body":         body,
+		"completed_at": now,
+	}).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar resposta da chave de idempotência ID %s: %v", record.ID, err)
+	}
+	return err
+}
+
+// ReleaseIdempotencyKey remove uma chave em andamento, para que a requisição possa ser
+// repetida (ex: a original falhou com erro interno).
+func ReleaseIdempotencyKey(record *models.IdempotencyKey) error {
+	err := database.DB.Where("id = ? AND completed_at IS NULL", record.ID).Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao liberar chave de idempotência ID %s: %v", record.ID, err)
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"gorm.io/gorm"
+)
+
+// legacyImportBatchSize define quantos usuários são inseridos por transação.
+const legacyImportBatchSize = 500
+
+// LegacyUserRecord representa um usuário exportado de outro sistema, com o hash
+// de senha no formato original (phpass, MD5-crypt, PBKDF2 do Django/Keycloak, bcrypt...).
+type LegacyUserRecord struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+	// Line é a posição do registro no arquivo de origem, usada nos relatórios de erro.
+	Line int `json:"-"`
+}
+
+// LegacyImportFailure descreve um registro rejeitado durante a importação.
+type LegacyImportFailure struct {
+	Line   int
+	Email  string
+	Reason string
+}
+
+// LegacyImportResult resume o resultado de uma importação.
+type LegacyImportResult struct {
+	Imported int
+	Skipped  int // e-mails que já existiam no banco
+	Failed   []LegacyImportFailure
+}
+
+// ParseLegacyUsersJSON lê um array JSON de objetos {"name", "email", "password_hash"}.
+func ParseLegacyUsersJSON(r io.Reader) ([]LegacyUserRecord, error) {
+	var records []LegacyUserRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	for i := range records {
+		records[i].Line = i + 1
+	}
+	return records, nil
+}
+
+// ParseLegacyUsersCSV lê um CSV com cabeçalho contendo as colunas name, email e password_hash
+// (em qualquer ordem; colunas extras são ignoradas).
+func ParseLegacyUsersCSV(r io.Reader) ([]LegacyUserRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler cabeçalho do CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email", "password_hash"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("coluna obrigatória ausente no CSV: %s", required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		idx := columns[name]
+		if idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	var records []LegacyUserRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler linha %d do CSV: %w", line, err)
+		}
+		records = append(records, LegacyUserRecord{
+			Name:         field(row, "name"),
+			Email:        field(row, "email"),
+			PasswordHash: field(row, "password_hash"),
+			Line:         line,
+		})
+	}
+	return records, nil
+}
+
+// keycloakExport cobre tanto o export de realm quanto o de usuários do Keycloak.
+type keycloakExport struct {
+	Users []struct {
+		Username    string               `json:"username"`
+		Email       string               `json:"email"`
+		FirstName   string               `json:"firstName"`
+		LastName    string               `json:"lastName"`
+		Credentials []keycloakCredential `json:"credentials"`
+	} `json:"users"`
+}
+
+type keycloakCredential struct {
+	Type           string `json:"type"`
+	SecretData     string `json:"secretData"`
+	CredentialData string `json:"credentialData"`
+	// Campos do formato anterior ao Keycloak 12.
+	HashedSaltedValue string `json:"hashedSaltedValue"`
+	Salt              string `json:"salt"`
+	HashIterations    int    `json:"hashIterations"`
+	Algorithm         string `json:"algorithm"`
+}
+
+// ParseKeycloakExport lê um export JSON do Keycloak e converte as credenciais
+// de senha PBKDF2 para o formato PHC aceito por password.PHCPBKDF2Verifier.
+// Usuários sem credencial de senha são retornados com PasswordHash vazio
+// e serão reportados como falha na importação.
+func ParseKeycloakExport(r io.Reader) ([]LegacyUserRecord, error) {
+	var export keycloakExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("JSON do Keycloak inválido: %w", err)
+	}
+
+	records := make([]LegacyUserRecord, 0, len(export.Users))
+	for i, u := range export.Users {
+		name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		if name == "" {
+			name = u.Username
+		}
+		record := LegacyUserRecord{Name: name, Email: u.Email, Line: i + 1}
+		for _, cred := range u.Credentials {
+			if cred.Type != "password" {
+				continue
+			}
+			encoded, err := keycloakCredentialToPHC(cred)
+			if err != nil {
+				log.Printf("WARN: Credencial do Keycloak ignorada para %s: %v", u.Email, err)
+				continue
+			}
+			record.PasswordHash = encoded
+			break
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// keycloakCredentialToPHC converte uma credencial do Keycloak (formato novo ou antigo) para PHC.
+func keycloakCredentialToPHC(cred keycloakCredential) (string, error) {
+	value, salt, iterations, algorithm := cred.HashedSaltedValue, cred.Salt, cred.HashIterations, cred.Algorithm
+	if cred.SecretData != "" {
+		var secret struct {
+			Value string `json:"value"`
+			Salt  string `json:"salt"`
+		}
+		var data struct {
+			HashIterations int    `json:"hashIterations"`
+			Algorithm      string `json:"algorithm"`
+		}
+		if err := json.Unmarshal([]byte(cred.SecretData), &secret); err != nil {
+			return "", fmt.Errorf("secretData inválido: %w", err)
+		}
+		if err := json.Unmarshal([]byte(cred.CredentialData), &data); err != nil {
+			return "", fmt.Errorf("credentialData inválido: %w", err)
+		}
+		value, salt, iterations, algorithm = secret.Value, secret.Salt, data.HashIterations, data.Algorithm
+	}
+
+	saltBytes, err := decodeStdBase64(salt)
+	if err != nil {
+		return "", fmt.Errorf("salt inválido: %w", err)
+	}
+	valueBytes, err := decodeStdBase64(value)
+	if err != nil {
+		return "", fmt.Errorf("hash inválido: %w", err)
+	}
+	return password.EncodePHCPBKDF2(algorithm, iterations, saltBytes, valueBytes)
+}
+
+// ImportLegacyUsers valida e insere os registros em lotes transacionais, sem conhecer
+// as senhas em texto plano. Os hashes são armazenados como vieram e convertidos para o
+// algoritmo atual no primeiro login bem-sucedido (veja auth.LoginUser).
+// E-mails já cadastrados (sem diferenciar maiúsculas de minúsculas) são ignorados. Com dryRun, nada é gravado. Cada usuário criado é
+// registrado no log de auditoria em nome de SystemActor.
+func ImportLegacyUsers(records []LegacyUserRecord, dryRun bool) (LegacyImportResult, error) {
+	var result LegacyImportResult
+	validate := validator.New()
+	hashes := password.Default()
+	seen := make(map[string]bool, len(records))
+
+	valid := make([]models.User, 0, len(records))
+	for _, r := range records {
+		email := strings.TrimSpace(r.Email)
+		fail := func(reason string) {
+			result.Failed = append(result.Failed, LegacyImportFailure{Line: r.Line, Email: r.Email, Reason: reason})
+		}
+		switch {
+		case validate.Var(email, "required,email") != nil:
+			fail("e-mail inválido")
+		case strings.TrimSpace(r.Name) == "":
+			fail("nome ausente")
+		case r.PasswordHash == "":
+			fail("hash de senha ausente")
+		case !hashes.Recognizes(r.PasswordHash):
+			fail("formato de hash de senha não suportado")
+		case seen[strings.ToLower(email)]:
+			fail("e-mail duplicado no arquivo")
+		default:
+			seen[strings.ToLower(email)] = true
+			valid = append(valid, models.User{Name: strings.TrimSpace(r.Name), Email: email, PasswordHash: r.PasswordHash})
+		}
+	}
+
+	for start := 0; start < len(valid); start += legacyImportBatchSize {
+		batch := valid[start:min(start+legacyImportBatchSize, len(valid))]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Como em EmailInUse, e-mails que diferem apenas em maiúsculas são o mesmo usuário.
+			emails := make([]string, len(batch))
+			for i, u := range batch {
+				emails[i] = strings.ToLower(u.Email)
+			}
+			var existing []string
+			if err := tx.Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &existing).Error; err != nil {
+				return err
+			}
+			exists := make(map[string]bool, len(existing))
+			for _, e := range existing {
+				exists[e] = true
+			}
+
+			toCreate := make([]models.User, 0, len(batch))
+			for _, u := range batch {
+				if exists[strings.ToLower(u.Email)] {
+					result.Skipped++
+					continue
+				}
+				toCreate = append(toCreate, u)
+			}
+			if dryRun || len(toCreate) == 0 {
+				result.Imported += len(toCreate)
+				return nil
+			}
+			if err := tx.Create(&toCreate).Error; err != nil {
+				return err
+			}
+			for i := range toCreate {
+				if err := recordUserChange(tx, SystemActor, models.AuditActionUserCreate, nil, &toCreate[i]); err != nil {
+					return err
+				}
+			}
+			result.Imported += len(toCreate)
+			return nil
+		})
+		if err != nil {
+			log.Printf("ERROR: Falha ao importar lote de usuários legados (registros %d-%d): %v", start+1, start+len(batch), err)
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// decodeStdBase64 decodifica base64 padrão com ou sem padding.
+func decodeStdBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeycloakExport_ConvertsCredentials(t *testing.T) {
+	assert := assert.New(t)
+	export := `{"users": [
+		{"username": "jdoe", "email": "jdoe@example.com", "firstName": "John", "lastName": "Doe",
+		 "credentials": [{"type": "password",
+			"secretData": "{\"value\":\"Dr8ltg9fE3xi3yjUiRvLYpXKHIs9fM2tPGxFGokOH/g6Z4vL/u0AOm7kwJRwyjX2xstHROVCpJHbWYzP8Z8amg==\",\"salt\":\"MDEyMzQ1Njc4OWFiY2RlZg==\"}",
+			"credentialData": "{\"hashIterations\":27500,\"algorithm\":\"pbkdf2-sha256\"}"}]},
+		{"username": "nopass", "email": "nopass@example.com", "credentials": []}
+	]}`
+
+	records, err := ParseKeycloakExport(strings.NewReader(export))
+	assert.NoError(err)
+	assert.Len(records, 2)
+	assert.Equal("John Doe", records[0].Name)
+	assert.True(strings.HasPrefix(records[0].PasswordHash, "$pbkdf2-sha256$i=27500$"))
+
+	ok, rehash, err := password.Verify(records[0].PasswordHash, "password123")
+	assert.NoError(err)
+	assert.True(ok, "Imported Keycloak credential should verify with the original password")
+	assert.True(rehash)
+
+	assert.Equal("nopass", records[1].Name, "Username should be used when first/last names are missing")
+	assert.Empty(records[1].PasswordHash)
+}
+
+func TestImportLegacyUsers_WithSQLite(t *testing.T) {
+	setupTestSQLiteDB(t)
+	assert := assert.New(t)
+
+	originalGlobalDB := database.DB
+	database.DB = testDB
+	defer func() { database.DB = originalGlobalDB }()
+
+	existing := models.User{Name: "Existing", Email: "existing." + uuid.NewString() + "@example.com", PasswordHash: "dummyhash"}
+	assert.NoError(database.DB.Create(&existing).Error)
+
+	newEmail := "legacy." + uuid.NewString() + "@example.com"
+	csvDump := "email,name,password_hash\n" +
+		newEmail + ",Legacy User,$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0\n" +
+		existing.Email + ",Existing,$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0\n" +
+		"bad-email,Bad,$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0\n" +
+		"plain." + uuid.NewString() + "@example.com,Plain,not-a-hash\n"
+
+	records, err := ParseLegacyUsersCSV(strings.NewReader(csvDump))
+	assert.NoError(err)
+	assert.Len(records, 4)
+
+	dry, err := ImportLegacyUsers(records, true)
+	assert.NoError(err)
+	assert.Equal(1, dry.Imported)
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ?", newEmail).Count(&count)
+	assert.Zero(count, "Dry run must not write to the database")
+
+	result, err := ImportLegacyUsers(records, false)
+	assert.NoError(err)
+	assert.Equal(1, result.Imported)
+	assert.Equal(1, result.Skipped)
+	assert.Len(result.Failed, 2)
+	assert.Equal(4, result.Failed[0].Line)
+	assert.Equal(5, result.Failed[1].Line)
+
+	var imported models.User
+	assert.NoError(database.DB.First(&imported, "email = ?", newEmail).Error)
+	assert.Equal("$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0", imported.PasswordHash, "Original hash must be stored untouched")
+}
+
+func TestImportLegacyUsers_SkipsExistingEmailInOtherCase(t *testing.T) {
+	setupTestSQLiteDB(t)
+	assert := assert.New(t)
+
+	originalGlobalDB := database.DB
+	database.DB = testDB
+	defer func() { database.DB = originalGlobalDB }()
+
+	existing := models.User{Name: "Alice", Email: "alice." + uuid.NewString() + "@example.com", PasswordHash: "dummyhash"}
+	assert.NoError(database.DB.Create(&existing).Error)
+
+	csvDump := "email,name,password_hash\n" +
+		strings.ToUpper(existing.Email[:1]) + existing.Email[1:] + ",Alice,$1$saltsalt$4WS.Uhxmahm1YZiMsUNcc0\n"
+	records, err := ParseLegacyUsersCSV(strings.NewReader(csvDump))
+	assert.NoError(err)
+
+	result, err := ImportLegacyUsers(records, false)
+	assert.NoError(err)
+	assert.Equal(0, result.Imported)
+	assert.Equal(1, result.Skipped, "An email differing only in case must be treated as an existing user")
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("LOWER(email) = LOWER(?)", existing.Email).Count(&count)
+	assert.Equal(int64(1), count)
+}
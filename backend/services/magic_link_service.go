@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// FindUserByEmail busca um usuário pelo e-mail, sem diferenciar maiúsculas de minúsculas.
+// Retorna gorm.ErrRecordNotFound se não houver usuário com o e-mail.
+func FindUserByEmail(email string) (models.User, error) {
+	var user models.User
+	err := database.DB.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ERROR: Falha ao buscar usuário com email %s: %v", email, err)
+	}
+	return user, err
+}
+
+// CountMagicLinks conta os pedidos de login sem senha feitos para o e-mail desde since.
+func CountMagicLinks(email string, since time.Time) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.MagicLink{}).Where("email = ? AND created_at > ?", email, since).Count(&count).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao contar pedidos de login sem senha para %s: %v", email, err)
+	}
+	return count, err
+}
+
+// CreateMagicLink grava um pedido de login sem senha. Pedidos expirados e criados antes de
+// keepSince (fora da janela do limite de pedidos) são removidos na mesma operação.
+func CreateMagicLink(link *models.MagicLink, keepSince time.Time) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("expires_at < ? AND created_at < ?", time.Now(), keepSince).Delete(&models.MagicLink{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(link).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar pedido de login sem senha para %s: %v", link.Email, err)
+	}
+	return err
+}
+
+// ConsumeMagicLink busca e invalida um pedido de login sem senha, que só pode ser usado uma
+// vez, e registra o login no log de auditoria na mesma transação. O autor do registro é o
+// usuário do pedido; de actor são usados os dados da requisição (IP, user agent e ID).
+// Retorna gorm.ErrRecordNotFound se o pedido não existe, já foi usado, expirou ou não
+// pertence a um usuário ativo.
+func ConsumeMagicLink(actor AuditActor, id uuid.UUID) (models.MagicLink, error) {
+	var link models.MagicLink
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&link, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if link.UserID == nil || time.Now().After(link.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+		var user models.User
+		if err := tx.First(&user, "id = ? AND active = ?", *link.UserID, true).Error; err != nil {
+			return err
+		}
+		// O registro é mantido até expirar, para o limite de pedidos por endereço, mas não
+		// pode mais ser usado.
+		result := tx.Model(&models.MagicLink{}).Where("id = ? AND user_id IS NOT NULL", id).
+			Updates(map[string]interface{}{"user_id": nil, "code_hash": ""})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// O link foi entregue no e-mail da conta: a posse do endereço está comprovada.
+		if user.EmailVerifiedAt == nil && strings.EqualFold(user.Email, link.Email) {
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("email_verified_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		actor.Type, actor.UserID, actor.ImpersonatorID, actor.ClientID = models.AuditActorUser, link.UserID, nil, ""
+		return writeAudit(tx, actor, models.AuditActionMagicLinkLogin, "user", user.ID.String(), map[string]AuditChange{
+			"magic_link_id": {Before: id},
+		})
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao consumir pedido de login sem senha %s: %v", id, err)
+		}
+		return link, err
+	}
+	return link, nil
+}
+
+// ActiveMagicLinks lista os pedidos ainda utilizáveis para o e-mail: de um usuário, não
+// expirados e com menos de maxAttempts códigos incorretos.
+func ActiveMagicLinks(email string, maxAttempts int) ([]models.MagicLink, error) {
+	var links []models.MagicLink
+	err := database.DB.Where("email = ? AND user_id IS NOT NULL AND expires_at > ? AND attempts < ?", email, time.Now(), maxAttempts).
+		Order("created_at DESC").Find(&links).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar pedidos de login sem senha para %s: %v", email, err)
+	}
+	return links, err
+}
+
+// RecordMagicCodeFailure conta um código incorreto em todos os pedidos ativos do e-mail.
+func RecordMagicCodeFailure(email string) error {
+	err := database.DB.Model(&models.MagicLink{}).Where("email = ? AND user_id IS NOT NULL", email).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao registrar código incorreto para %s: %v", email, err)
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"gorm.io/gorm"
+)
+
+// ErrAuthorizationCodeExpired indica um código de autorização encontrado, mas fora da validade.
+var ErrAuthorizationCodeExpired = errors.New("código de autorização expirado")
+
+// CreateOAuthClient registra um cliente OpenID Connect, gerando client_id e, para clientes
+// confidenciais, o client_secret. O segredo é retornado apenas aqui; no banco fica só o hash.
+func CreateOAuthClient(client *models.OAuthClient) (string, error) {
+	client.ClientID = rand.Text()
+
+	var secret string
+	if !client.Public {
+		secret = rand.Text() + rand.Text()
+		hash, err := password.Hash(secret)
+		if err != nil {
+			log.Printf("ERROR: Falha ao gerar hash do segredo do cliente OAuth '%s': %v", client.Name, err)
+			return "", err
+		}
+		client.ClientSecretHash = hash
+	}
+
+	if err := database.DB.Create(client).Error; err != nil {
+		log.Printf("ERROR: Falha ao criar cliente OAuth '%s': %v", client.Name, err)
+		return "", err
+	}
+	return secret, nil
+}
+
+// GetOAuthClient busca um cliente pelo client_id.
+func GetOAuthClient(clientID string) (models.OAuthClient, error) {
+	var client models.OAuthClient
+	result := database.DB.First(&client, "client_id = ?", clientID)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		log.Printf("ERROR: Falha ao buscar cliente OAuth %s: %v", clientID, result.Error)
+	}
+	return client, result.Error
+}
+
+// VerifyOAuthClientSecret confere o client_secret de um cliente confidencial.
+func VerifyOAuthClientSecret(client models.OAuthClient, secret string) bool {
+	if client.Public || client.ClientSecretHash == "" || secret == "" {
+		return false
+	}
+	ok, _, err := password.Verify(client.ClientSecretHash, secret)
+	if err != nil {
+		log.Printf("ERROR: Falha ao verificar segredo do cliente OAuth %s: %v", client.ClientID, err)
+		return false
+	}
+	return ok
+}
+
+// CreateAuthorizationCode grava um novo código de autorização e retorna o código em texto plano.
+// Códigos expirados de qualquer cliente são removidos na mesma operação.
+func CreateAuthorizationCode(code *models.OAuthAuthorizationCode) (string, error) {
+	plain := rand.Text() + rand.Text()
+	code.CodeHash = hashToken(plain)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(code).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar código de autorização para usuário ID %s: %v", code.UserID, err)
+		return "", err
+	}
+	return plain, nil
+}
+
+// ConsumeAuthorizationCode busca e remove o código de autorização, garantindo que ele seja
+// usado uma única vez. Retorna gorm.ErrRecordNotFound se o código não existe ou já foi usado.
+func ConsumeAuthorizationCode(plain string) (models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&code, "code_hash = ?", hashToken(plain)).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.OAuthAuthorizationCode{}, "code_hash = ?", code.CodeHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Outra requisição consumiu o código em paralelo.
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao consumir código de autorização: %v", err)
+		}
+		return code, err
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return code, ErrAuthorizationCodeExpired
+	}
+	return code, nil
+}
+
+// HasOAuthConsent informa se o usuário já autorizou todos os escopos para o cliente.
+func HasOAuthConsent(userID uuid.UUID, clientID string, scopes []string) (bool, error) {
+	var consent models.OAuthConsent
+	result := database.DB.First(&consent, "user_id = ? AND client_id = ?", userID, clientID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if result.Error != nil {
+		log.Printf("ERROR: Falha ao buscar consentimento do usuário ID %s para o cliente %s: %v", userID, clientID, result.Error)
+		return false, result.Error
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SaveOAuthConsent registra os escopos autorizados, somando-os aos já concedidos anteriormente.
+func SaveOAuthConsent(userID uuid.UUID, clientID string, scopes []string) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var consent models.OAuthConsent
+		result := tx.First(&consent, "user_id = ? AND client_id = ?", userID, clientID)
+		if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+		granted := strings.Fields(consent.Scope)
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				granted = append(granted, scope)
+			}
+		}
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.OAuthConsent{UserID: userID, ClientID: clientID, Scope: strings.Join(granted, " ")}).Error
+		}
+		return tx.Model(&consent).Where("user_id = ? AND client_id = ?", userID, clientID).Update("scope", strings.Join(granted, " ")).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar consentimento do usuário ID %s para o cliente %s: %v", userID, clientID, err)
+	}
+	return err
+}
+
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// UserEvent é o corpo JSON publicado para os eventos de usuários (webhooks, NATS, Kafka).
+type UserEvent struct {
+	ID        uuid.UUID     `json:"id"` // ID do evento no outbox, repetido em toda nova publicação
+	Type      string        `json:"type"`
+	CreatedAt time.Time     `json:"created_at"`
+	Data      UserEventData `json:"data"`
+}
+
+// UserEventData traz o usuário (no estado anterior à remoção, em user.deleted) e os campos
+// alterados, no mesmo formato do log de auditoria.
+type UserEventData struct {
+	User    models.User            `json:"user"`
+	Changes map[string]AuditChange `json:"changes,omitempty"`
+}
+
+// userEventType traduz uma ação do log de auditoria no evento correspondente.
+func userEventType(action string, before, after *models.User) string {
+	switch action {
+	case models.AuditActionUserCreate:
+		return models.EventUserCreated
+	case models.AuditActionUserDelete:
+		return models.EventUserDeleted
+	}
+	if before != nil && after != nil && before.Active && !after.Active {
+		return models.EventUserSuspended
+	}
+	return models.EventUserUpdated
+}
+
+// writeUserEvent grava o evento da alteração de um usuário no outbox, usando tx (a transação
+// da alteração): o evento existe se, e somente se, a alteração foi confirmada.
+func writeUserEvent(tx *gorm.DB, action string, before, after *models.User, changes map[string]AuditChange) error {
+	now := time.Now().UTC()
+	event := UserEvent{
+		ID:        uuid.New(),
+		Type:      userEventType(action, before, after),
+		CreatedAt: now,
+		Data:      UserEventData{Changes: changes},
+	}
+	if after != nil {
+		event.Data.User = *after
+	} else {
+		event.Data.User = *before
+	}
+	event.Data.User.Password = ""
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		ID:            event.ID,
+		AggregateType: "user",
+		AggregateID:   event.Data.User.ID.String(),
+		EventType:     event.Type,
+		Payload:       string(payload),
+		CreatedAt:     now,
+		NextAttemptAt: &now,
+	}).Error
+}
+
+// ClaimOutboxEvents reserva até limit eventos ainda não publicados cujo horário de tentativa
+// já chegou, em ordem de criação, adiando next_attempt_at por lease. Como em
+// ClaimDueWebhookDeliveries, a reserva é uma atualização condicional, para que várias
+// instâncias do relay não publiquem o mesmo evento ao mesmo tempo.
+func ClaimOutboxEvents(limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	now := time.Now()
+	var due []models.OutboxEvent
+	err := database.DB.
+		Where("published_at IS NULL AND next_attempt_at <= ?", now).
+		Order("created_at, id").Limit(limit).Find(&due).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar eventos pendentes do outbox: %v", err)
+		return nil, err
+	}
+
+	claimed := due[:0]
+	leaseUntil := now.Add(lease)
+	for _, event := range due {
+		result := database.DB.Model(&models.OutboxEvent{}).
+			Where("id = ? AND published_at IS NULL AND next_attempt_at <= ?", event.ID, now).
+			Update("next_attempt_at", leaseUntil)
+		if result.Error != nil {
+			log.Printf("ERROR: Falha ao reservar evento do outbox ID %s: %v", event.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 1 {
+			event.NextAttemptAt = &leaseUntil
+			claimed = append(claimed, event)
+		}
+	}
+	return claimed, nil
+}
+
+// MarkOutboxEventPublished registra a publicação de um evento. Se o processo cair antes
+// disso, o evento é publicado de novo (entrega at-least-once).
+func MarkOutboxEventPublished(event *models.OutboxEvent) error {
+	now := time.Now()
+	event.Attempts++
+	event.PublishedAt = &now
+	event.NextAttemptAt = nil
+	event.LastError = ""
+	err := database.DB.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"published_at":    now,
+		"attempts":        event.Attempts,
+		"next_attempt_at": nil,
+		"last_error":      "",
+	}).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao marcar evento do outbox ID %s como publicado: %v", event.ID, err)
+	}
+	return err
+}
+
+// RecordOutboxEventFailure registra a falha de publicação de um evento e agenda a próxima
+// tentativa para next.
+func RecordOutboxEventFailure(event *models.OutboxEvent, cause error, next time.Time) error {
+	event.Attempts++
+	event.NextAttemptAt = &next
+	event.LastError = cause.Error()
+	err := database.DB.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"attempts":        event.Attempts,
+		"next_attempt_at": next,
+		"last_error":      event.LastError,
+	}).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao registrar erro de publicação do evento do outbox ID %s: %v", event.ID, err)
+	}
+	return err
+}
+
+// ListOutboxEvents retorna, em ordem de criação (created_at, id), até limit eventos
+// posteriores ao cursor (since, afterID), publicados ou não: criados depois de since ou,
+// no mesmo instante, com ID maior que afterID (uuid.Nil inclui todos os de since). Com since
+// zero, retorna os limit eventos mais recentes.
+func ListOutboxEvents(since time.Time, afterID uuid.UUID, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	var err error
+	if since.IsZero() {
+		err = database.DB.Order("created_at desc, id desc").Limit(limit).Find(&events).Error
+		slices.Reverse(events)
+	} else {
+		err = database.DB.Where("created_at > ? OR (created_at = ? AND id > ?)", since, since, afterID).
+			Order("created_at, id").Limit(limit).Find(&events).Error
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao listar eventos do outbox: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// PruneOutbox remove os eventos publicados antes de before, retornando quantos foram removidos.
+// Eventos ainda não publicados nunca são removidos.
+func PruneOutbox(before time.Time) (int64, error) {
+	result := database.DB.Where("published_at IS NOT NULL AND published_at < ?", before).Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		log.Printf("ERROR: Falha ao remover eventos publicados do outbox: %v", result.Error)
+	}
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeTokenID revoga o token com o jti informado até sua expiração. Revogar o mesmo
+// token mais de uma vez não é um erro. Registros de tokens já expirados são removidos na
+// mesma operação.
+func RevokeTokenID(jti, clientID string, expiresAt time.Time) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RevokedToken{JTI: jti, ClientID: clientID, ExpiresAt: expiresAt}).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao revogar token %s do cliente %s: %v", jti, clientID, err)
+		return err
+	}
+	log.Printf("INFO: Token %s do cliente %s revogado.", jti, clientID)
+	return nil
+}
+
+// IsTokenRevoked indica se o token com o jti informado foi revogado.
+func IsTokenRevoked(jti string) (bool, error) {
+	var revoked models.RevokedToken
+	err := database.DB.First(&revoked, "jti = ?", jti).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao consultar revogação do token %s: %v", jti, err)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval limita a frequência com que last_seen_at é gravado.
+const sessionTouchInterval = time.Minute
+
+// ErrSessionInvalid indica que a sessão não existe (foi encerrada) ou expirou.
+var ErrSessionInvalid = errors.New("sessão encerrada ou expirada")
+
+// CreateSession grava uma nova sessão. Sessões expiradas são removidas na mesma operação.
+func CreateSession(session *models.Session) error {
+	if session.DeviceLabel == "" {
+		session.DeviceLabel = DeviceLabel(session.UserAgent)
+	}
+	session.LastSeenAt = time.Now()
+	session.CSRFToken = rand.Text()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar sessão para o usuário ID %s: %v", session.UserID, err)
+	}
+	return err
+}
+
+// GetSession busca uma sessão ativa pelo ID.
+func GetSession(id uuid.UUID) (models.Session, error) {
+	var session models.Session
+	err := database.DB.First(&session, "id = ? AND expires_at > ?", id, time.Now()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, ErrSessionInvalid
+	}
+	return session, err
+}
+
+// TouchSession valida a sessão de um token e registra o último acesso (no máximo uma vez
+// por minuto, ou quando o IP muda).
+func TouchSession(id, userID uuid.UUID, clientIP string) (models.Session, error) {
+	var session models.Session
+	err := database.DB.First(&session, "id = ? AND user_id = ?", id, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, ErrSessionInvalid
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar sessão %s: %v", id, err)
+		return session, err
+	}
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		return session, ErrSessionInvalid
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval || session.IP != clientIP {
+		err := database.DB.Model(&models.Session{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"last_seen_at": now, "ip": clientIP}).Error
+		if err != nil {
+			log.Printf("WARN: Falha ao registrar acesso da sessão %s: %v", id, err)
+		}
+		session.LastSeenAt = now
+		session.IP = clientIP
+	}
+	return session, nil
+}
+
+// ListSessions lista as sessões ativas de um usuário, da mais recente para a mais antiga.
+func ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao listar sessões do usuário ID %s: %v", userID, err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession encerra uma sessão do usuário, com registro no log de auditoria em nome de
+// actor. Retorna gorm.ErrRecordNotFound se a sessão não existe ou pertence a outro usuário.
+func RevokeSession(actor AuditActor, userID, id uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.First(&session, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&session).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionSessionRevoke, "session", id.String(), map[string]AuditChange{
+			"user_id":      {Before: userID},
+			"device_label": {Before: session.DeviceLabel},
+		})
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao encerrar sessão %s: %v", id, err)
+		}
+		return err
+	}
+	log.Printf("INFO: Sessão %s do usuário ID %s encerrada.", id, userID)
+	return nil
+}
+
+// RevokeOtherSessions encerra todas as sessões do usuário, exceto keep (uuid.Nil encerra
+// todas), com registro no log de auditoria em nome de actor. Retorna a quantidade de sessões
+// encerradas.
+func RevokeOtherSessions(actor AuditActor, userID, keep uuid.UUID) (int64, error) {
+	var count int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		count, err = revokeUserSessions(tx, actor, userID, keep)
+		return err
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao encerrar sessões do usuário ID %s: %v", userID, err)
+		return 0, err
+	}
+	log.Printf("INFO: %d sessões do usuário ID %s encerradas.", count, userID)
+	return count, nil
+}
+
+// revokeUserSessions remove as sessões do usuário, exceto keep, e registra a remoção no log
+// de auditoria usando tx (a transação da alteração). Nada é registrado se não havia sessões.
+func revokeUserSessions(tx *gorm.DB, actor AuditActor, userID, keep uuid.UUID) (int64, error) {
+	result := tx.Delete(&models.Session{}, "user_id = ? AND id <> ?", userID, keep)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, result.Error
+	}
+	err := writeAudit(tx, actor, models.AuditActionSessionsRevoke, "user", userID.String(), map[string]AuditChange{
+		"revoked_sessions": {After: result.RowsAffected},
+	})
+	return result.RowsAffected, err
+}
+
+// AbortSession remove uma sessão recém-aberta cuja abertura não pôde ser concluída (ex: falha
+// ao registrar a personificação). Não há registro de auditoria: a sessão nunca foi entregue.
+func AbortSession(id uuid.UUID) error {
+	err := database.DB.Delete(&models.Session{}, "id = ?", id).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao remover sessão %s: %v", id, err)
+	}
+	return err
+}
+
+// DeviceLabel deriva um rótulo legível (ex: "Chrome no Windows") do user agent.
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Dispositivo desconhecido"
+	}
+	ua := strings.ToLower(userAgent)
+	browser := "Navegador"
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"}, {"opr/", "Opera"}, {"firefox/", "Firefox"}, {"chrome/", "Chrome"},
+		{"safari/", "Safari"}, {"curl/", "curl"}, {"python-requests", "Python"}, {"go-http-client", "Go"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, platform := range []struct{ token, name string }{
+		{"android", "Android"}, {"iphone", "iPhone"}, {"ipad", "iPad"}, {"windows", "Windows"},
+		{"mac os x", "macOS"}, {"linux", "Linux"},
+	} {
+		if strings.Contains(ua, platform.token) {
+			return browser + " no " + platform.name
+		}
+	}
+	return browser
+}
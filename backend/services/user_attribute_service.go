@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gorm.io/gorm"
+)
+
+// userAttributeSchemaID é o ID do único registro de models.UserAttributeSchema.
+const userAttributeSchemaID = 1
+
+// userAttributeSchemaURL identifica o schema compilado (não é acessado).
+const userAttributeSchemaURL = "urn:user-management:user-attributes"
+
+var (
+	// ErrInvalidUserAttributeSchema indica um JSON Schema inválido para os atributos.
+	ErrInvalidUserAttributeSchema = errors.New("JSON Schema de atributos inválido")
+	// ErrInvalidUserAttributes indica atributos que não satisfazem o JSON Schema configurado.
+	ErrInvalidUserAttributes = errors.New("atributos personalizados inválidos")
+)
+
+// userAttributeName restringe os nomes de atributos usados em filtros.
+var userAttributeName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// compiledAttributeSchema guarda o último schema compilado, recompilado apenas quando o
+// texto gravado muda (ex: alterado em outra instância).
+var compiledAttributeSchema struct {
+	sync.Mutex
+	source string
+	schema *jsonschema.Schema
+}
+
+// GetUserAttributeSchema retorna o JSON Schema dos atributos personalizados, ou
+// models.DefaultUserAttributeSchema se nenhum foi configurado.
+func GetUserAttributeSchema() (models.UserAttributeSchema, error) {
+	var record models.UserAttributeSchema
+	err := database.DB.First(&record, userAttributeSchemaID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.UserAttributeSchema{ID: userAttributeSchemaID, Schema: models.DefaultUserAttributeSchema}, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar o JSON Schema dos atributos de usuários: %v", err)
+		return record, err
+	}
+	return record, nil
+}
+
+// SetUserAttributeSchema substitui o JSON Schema dos atributos personalizados. O schema deve
+// compilar (draft 2020-12 por padrão, sem referências externas) e descrever um objeto. A
+// alteração é registrada no log de auditoria em nome de actor. Usuários já cadastrados não
+// são revalidados: o novo schema vale para as próximas criações e alterações de atributos.
+func SetUserAttributeSchema(actor AuditActor, source []byte) (models.UserAttributeSchema, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, source); err != nil {
+		return models.UserAttributeSchema{}, fmt.Errorf("%w: %v", ErrInvalidUserAttributeSchema, err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(compact.Bytes(), &root); err != nil || root["type"] != "object" {
+		return models.UserAttributeSchema{}, fmt.Errorf(`%w: a raiz do schema deve ter "type": "object"`, ErrInvalidUserAttributeSchema)
+	}
+	if _, err := compileUserAttributeSchema(compact.String()); err != nil {
+		return models.UserAttributeSchema{}, fmt.Errorf("%w: %v", ErrInvalidUserAttributeSchema, err)
+	}
+
+	before, err := GetUserAttributeSchema()
+	if err != nil {
+		return before, err
+	}
+	record := models.UserAttributeSchema{ID: userAttributeSchemaID, Schema: compact.String(), UpdatedBy: actor.UserID}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+		changes := map[string]AuditChange{"schema": {Before: json.RawMessage(before.Schema), After: json.RawMessage(record.Schema)}}
+		return writeAudit(tx, actor, models.AuditActionUserAttributeSchemaUpdate, "user_attribute_schema", fmt.Sprint(userAttributeSchemaID), changes)
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao gravar o JSON Schema dos atributos de usuários: %v", err)
+		return record, err
+	}
+	log.Printf("INFO: JSON Schema dos atributos de usuários alterado (autor: %s).", actor.Type)
+	return record, nil
+}
+
+// ValidateUserAttributes verifica os atributos com o JSON Schema configurado. Atributos nil são
+// validados como um objeto vazio (o que falha se o schema exigir algum atributo).
+func ValidateUserAttributes(attrs models.UserAttributes) error {
+	record, err := GetUserAttributeSchema()
+	if err != nil {
+		return err
+	}
+	schema, err := compileUserAttributeSchema(record.Schema)
+	if err != nil {
+		log.Printf("ERROR: JSON Schema gravado dos atributos de usuários é inválido: %v", err)
+		return err
+	}
+
+	if attrs == nil {
+		attrs = models.UserAttributes{}
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserAttributes, err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserAttributes, err)
+	}
+	err = schema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %s", ErrInvalidUserAttributes, describeValidationError(validationErr))
+	}
+	return err
+}
+
+// compileUserAttributeSchema compila o schema, reaproveitando a compilação anterior se o texto
+// não mudou. Referências externas ($ref para URLs ou arquivos) não são carregadas.
+func compileUserAttributeSchema(source string) (*jsonschema.Schema, error) {
+	compiledAttributeSchema.Lock()
+	defer compiledAttributeSchema.Unlock()
+	if compiledAttributeSchema.schema != nil && compiledAttributeSchema.source == source {
+		return compiledAttributeSchema.schema, nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.AssertFormat()
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+	if err := compiler.AddResource(userAttributeSchemaURL, doc); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(userAttributeSchemaURL)
+	if err != nil {
+		return nil, err
+	}
+	compiledAttributeSchema.source, compiledAttributeSchema.schema = source, schema
+	return schema, nil
+}
+
+// describeValidationError resume os erros de validação, um por atributo (ex: "/phone: ...").
+func describeValidationError(err *jsonschema.ValidationError) string {
+	var messages []string
+	for _, unit := range err.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+unit.Error.String())
+	}
+	if len(messages) == 0 {
+		return err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// UserAttributeFilter retorna um scope para ListUsers que seleciona os usuários cujos atributos
+// têm exatamente os valores informados (comparados como texto: "42", "true", "Engenharia").
+// Retorna erro se algum nome de atributo for inválido.
+func UserAttributeFilter(filters map[string]string) (func(*gorm.DB) *gorm.DB, error) {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		if !userAttributeName.MatchString(name) {
+			return nil, fmt.Errorf("nome de atributo inválido: %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return func(db *gorm.DB) *gorm.DB {
+		for _, name := range names {
+			if db.Dialector.Name() == "postgres" {
+				db = db.Where("attributes->>? = ?", name, filters[name])
+				continue
+			}
+			// No SQLite, json_extract retorna 1/0 para booleanos: o tipo JSON os distingue.
+			path := `$."` + name + `"`
+			db = db.Where(`CASE json_type(attributes, ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false'
+				ELSE CAST(json_extract(attributes, ?) AS TEXT) END = ?`, path, path, filters[name])
+		}
+		return db
+	}, nil
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
+	"gorm.io/gorm"
+)
+
+// Status possíveis de cada linha de uma importação em massa.
+const (
+	ImportStatusCreated  = "created"
+	ImportStatusUpdated  = "updated"
+	ImportStatusInvalid  = "invalid"
+	ImportStatusConflict = "conflict"
+	ImportStatusError    = "error"
+)
+
+// DefaultImportBatchSize é o número de linhas gravadas por transação quando não informado.
+const DefaultImportBatchSize = 100
+
+// requestValidator aplica as mesmas regras (tags `binding`) que o Gin usa para validar
+// os payloads JSON, garantindo que a importação siga as regras de UserCreateRequest.
+var requestValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+// UserImportRow é uma linha lida do arquivo de importação.
+type UserImportRow struct {
+	Line    int
+	Request models.UserCreateRequest
+	// ParseError é preenchido quando a linha não pôde ser interpretada.
+	ParseError error
+}
+
+// UserImportSource fornece as linhas de uma importação, uma por vez.
+// Next retorna io.EOF quando não houver mais linhas.
+type UserImportSource interface {
+	Next() (UserImportRow, error)
+}
+
+// UserImportOptions controla o comportamento de ImportUsers.
+type UserImportOptions struct {
+	DryRun    bool // apenas valida, sem gravar
+	Upsert    bool // atualiza (nome e atributos) usuários já existentes com o mesmo e-mail; a senha nunca é alterada
+	BatchSize int  // linhas por transação
+	// Actor é o autor registrado no log de auditoria para cada usuário criado ou atualizado.
+	Actor AuditActor
+}
+
+// UserImportRowResult é o resultado do processamento de uma linha.
+type UserImportRowResult struct {
+	Line   int               `json:"line"`
+	Email  string            `json:"email,omitempty"`
+	Status string            `json:"status"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// UserImportSummary resume uma importação em massa.
+type UserImportSummary struct {
+	DryRun  bool                  `json:"dry_run"`
+	Total   int                   `json:"total"`
+	Created int                   `json:"created"`
+	Updated int                   `json:"updated"`
+	Failed  int                   `json:"failed"`
+	Rows    []UserImportRowResult `json:"rows"`
+}
+
+// ImportUsers lê as linhas da fonte, valida cada uma com as regras de UserCreateRequest
+// e grava as válidas em lotes transacionais. Se um lote falhar no banco, ele é desfeito
+// por inteiro e suas linhas são marcadas com status "error"; os demais lotes seguem.
+func ImportUsers(source UserImportSource, opts UserImportOptions) (UserImportSummary, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	summary := UserImportSummary{DryRun: opts.DryRun, Rows: []UserImportRowResult{}}
+	seen := make(map[string]int)
+	batch := make([]UserImportRow, 0, opts.BatchSize)
+
+	for {
+		row, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.Total++
+
+		if errs := validateImportRow(row, seen); errs != nil {
+			summary.add(UserImportRowResult{Line: row.Line, Email: row.Request.Email, Status: ImportStatusInvalid, Errors: errs})
+			continue
+		}
+		seen[strings.ToLower(row.Request.Email)] = row.Line
+
+		batch = append(batch, row)
+		if len(batch) == opts.BatchSize {
+			summary.addAll(importBatch(batch, opts))
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		summary.addAll(importBatch(batch, opts))
+	}
+	// Linhas inválidas são registradas antes do lote em que estariam; reordena pelo número da linha.
+	slices.SortStableFunc(summary.Rows, func(a, b UserImportRowResult) int { return a.Line - b.Line })
+	return summary, nil
+}
+
+func (s *UserImportSummary) add(r UserImportRowResult) {
+	switch r.Status {
+	case ImportStatusCreated:
+		s.Created++
+	case ImportStatusUpdated:
+		s.Updated++
+	default:
+		s.Failed++
+	}
+	s.Rows = append(s.Rows, r)
+}
+
+func (s *UserImportSummary) addAll(results []UserImportRowResult) {
+	for _, r := range results {
+		s.add(r)
+	}
+}
+
+// validateImportRow retorna os erros de validação da linha, indexados pelo nome do campo.
+func validateImportRow(row UserImportRow, seen map[string]int) map[string]string {
+	if row.ParseError != nil {
+		return map[string]string{"row": row.ParseError.Error()}
+	}
+	if err := requestValidator.Struct(row.Request); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return map[string]string{"row": err.Error()}
+		}
+		errs := make(map[string]string, len(validationErrs))
+		for _, e := range validationErrs {
+			errs[e.Field()] = "Erro de validação no campo '" + e.Field() + "': falha na regra '" + e.Tag() + "'"
+		}
+		return errs
+	}
+	if err := ValidateUserAttributes(row.Request.Attributes); err != nil {
+		return map[string]string{"Attributes": err.Error()}
+	}
+	if line, dup := seen[strings.ToLower(row.Request.Email)]; dup {
+		return map[string]string{"Email": fmt.Sprintf("E-mail duplicado no arquivo (linha %d)", line)}
+	}
+	return nil
+}
+
+// importBatch grava um lote de linhas já validadas em uma única transação.
+func importBatch(rows []UserImportRow, opts UserImportOptions) []UserImportRowResult {
+	results := make([]UserImportRowResult, 0, len(rows))
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		results = results[:0]
+
+		emails := make([]string, len(rows))
+		for i, r := range rows {
+			emails[i] = r.Request.Email
+		}
+		var existing []models.User
+		if err := tx.Where("email IN ?", emails).Find(&existing).Error; err != nil {
+			return err
+		}
+		byEmail := make(map[string]models.User, len(existing))
+		for _, u := range existing {
+			byEmail[u.Email] = u
+		}
+
+		for _, r := range rows {
+			req := r.Request
+			current, exists := byEmail[req.Email]
+			if exists && !opts.Upsert {
+				results = append(results, UserImportRowResult{Line: r.Line, Email: req.Email, Status: ImportStatusConflict,
+					Errors: map[string]string{"Email": "Já existe um usuário com este e-mail"}})
+				continue
+			}
+
+			status := ImportStatusCreated
+			if exists {
+				status = ImportStatusUpdated
+			}
+			if opts.DryRun {
+				results = append(results, UserImportRowResult{Line: r.Line, Email: req.Email, Status: status})
+				continue
+			}
+
+			var err error
+			if exists {
+				// A senha da linha é ignorada: o upsert nunca troca a senha de uma conta existente,
+				// para que a importação não sirva para assumir contas (inclusive de administradores).
+				updated := current
+				updated.Name, updated.Version = req.Name, current.Version+1
+				columns := map[string]interface{}{"name": req.Name, "version": gorm.Expr("version + 1")}
+				// Sem atributos na linha, os do usuário existente são mantidos.
+				if req.Attributes != nil {
+					updated.Attributes, columns["attributes"] = req.Attributes, req.Attributes
+				}
+				err = tx.Model(&models.User{}).Where("id = ?", current.ID).Updates(columns).Error
+				if err == nil {
+					err = recordUserChange(tx, opts.Actor, models.AuditActionUserUpdate, &current, &updated)
+				}
+			} else {
+				var hashed string
+				hashed, err = password.Hash(req.Password)
+				if err != nil {
+					return fmt.Errorf("linha %d: falha ao gerar hash de senha: %w", r.Line, err)
+				}
+				created := models.User{Name: req.Name, Email: req.Email, PasswordHash: hashed, Attributes: req.Attributes}
+				if created.Attributes == nil {
+					created.Attributes = models.UserAttributes{}
+				}
+				err = tx.Create(&created).Error
+				if err == nil {
+					err = recordUserChange(tx, opts.Actor, models.AuditActionUserCreate, nil, &created)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("linha %d: %w", r.Line, err)
+			}
+			results = append(results, UserImportRowResult{Line: r.Line, Email: req.Email, Status: status})
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("ERROR: Falha ao importar lote de usuários (linhas %d-%d), lote desfeito: %v", rows[0].Line, rows[len(rows)-1].Line, err)
+		results = results[:0]
+		for _, r := range rows {
+			results = append(results, UserImportRowResult{Line: r.Line, Email: r.Request.Email, Status: ImportStatusError,
+				Errors: map[string]string{"row": "Lote desfeito devido a erro no banco de dados"}})
+		}
+	}
+	return results
+}
+
+// csvUserSource lê linhas de um CSV com cabeçalho contendo name, email e password (e,
+// opcionalmente, attributes).
+type csvUserSource struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVUserImportSource cria uma fonte de importação a partir de um CSV com cabeçalho
+// (colunas name, email e password, em qualquer ordem). A coluna opcional attributes contém os
+// atributos personalizados como objeto JSON, no formato da exportação.
+func NewCSVUserImportSource(r io.Reader) (UserImportSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler cabeçalho do CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email", "password"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("coluna obrigatória ausente no CSV: %s", required)
+		}
+	}
+	return &csvUserSource{reader: reader, columns: columns}, nil
+}
+
+func (s *csvUserSource) Next() (UserImportRow, error) {
+	record, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return UserImportRow{}, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return UserImportRow{Line: parseErr.StartLine, ParseError: parseErr.Err}, nil
+		}
+		return UserImportRow{}, err
+	}
+	line, _ := s.reader.FieldPos(0)
+	field := func(name string) string {
+		idx, ok := s.columns[name]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	row := UserImportRow{
+		Line: line,
+		Request: models.UserCreateRequest{
+			Name:     field("name"),
+			Email:    field("email"),
+			Password: field("password"),
+		},
+	}
+	if attributes := field("attributes"); attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &row.Request.Attributes); err != nil {
+			row.ParseError = fmt.Errorf("coluna attributes inválida: %w", err)
+		}
+	}
+	return row, nil
+}
+
+// ndjsonUserSource lê um objeto UserCreateRequest por linha (JSON Lines).
+type ndjsonUserSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewNDJSONUserImportSource cria uma fonte de importação a partir de JSON Lines.
+// Linhas em branco são ignoradas.
+func NewNDJSONUserImportSource(r io.Reader) UserImportSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ndjsonUserSource{scanner: scanner}
+}
+
+func (s *ndjsonUserSource) Next() (UserImportRow, error) {
+	for s.scanner.Scan() {
+		s.line++
+		raw := strings.TrimSpace(s.scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var req models.UserCreateRequest
+		if err := json.Unmarshal([]byte(raw), &req); err != nil {
+			return UserImportRow{Line: s.line, ParseError: errors.New("JSON inválido")}, nil
+		}
+		return UserImportRow{Line: s.line, Request: req}, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return UserImportRow{}, err
+	}
+	return UserImportRow{}, io.EOF
+}
+
+// ExportUsers percorre todos os usuários em ordem de criação chamando fn para cada um,
+// sem carregar a tabela inteira em memória (ao contrário de GetAllUsers).
+// Se fn retornar erro, a iteração é interrompida e o erro é devolvido.
+func ExportUsers(fn func(models.User) error) error {
+	rows, err := database.DB.Model(&models.User{}).Order("created_at, id").Rows()
+	if err != nil {
+		log.Printf("ERROR: Falha ao iniciar exportação de usuários: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := database.DB.ScanRows(rows, &user); err != nil {
+			log.Printf("ERROR: Falha ao ler usuário durante exportação: %v", err)
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
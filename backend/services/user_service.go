@@ -1,28 +1,46 @@
 package services
 
 import (
+	"errors"
 	"log"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/monteirobsb/user-management/backend/database"
 	"github.com/monteirobsb/user-management/backend/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/monteirobsb/user-management/backend/password"
+	"gorm.io/gorm"
 )
 
 // CreateUser cria um novo usuário no banco de dados com senha hasheada.
 // Aceita o usuário a ser criado e a senha em texto plano.
-func CreateUser(user *models.User, plainPassword string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
+// O algoritmo de hash é o configurado no pacote password (argon2id por padrão).
+// A criação é registrada no log de auditoria em nome de actor, na mesma transação.
+// Se user.Attributes não for nil, os atributos personalizados são validados com o JSON Schema
+// configurado (ErrInvalidUserAttributes); nil (ex: provisionamento por SCIM ou LDAP) grava
+// um objeto vazio sem validação.
+func CreateUser(actor AuditActor, user *models.User, plainPassword string) error {
+	if user.Attributes == nil {
+		user.Attributes = models.UserAttributes{}
+	} else if err := ValidateUserAttributes(user.Attributes); err != nil {
+		return err
+	}
+	hashedPassword, err := password.Hash(plainPassword)
 	if err != nil {
 		log.Printf("ERROR: Falha ao gerar hash de senha para novo usuário (email: %s): %v", user.Email, err)
 		return err
 	}
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
 
-	result := database.DB.Create(user)
-	if result.Error != nil {
-		log.Printf("ERROR: Falha ao criar usuário (email: %s) no banco de dados: %v", user.Email, result.Error)
-		return result.Error
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordUserChange(tx, actor, models.AuditActionUserCreate, nil, user)
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar usuário (email: %s) no banco de dados: %v", user.Email, err)
+		return err
 	}
 	return nil
 }
@@ -38,6 +56,31 @@ func GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
+// ListUsers retorna uma página de usuários e o total de registros que satisfazem o filtro.
+// scope pode ser nil; order segue a sintaxe do GORM (ex: "email desc").
+func ListUsers(scope func(*gorm.DB) *gorm.DB, order string, offset, limit int) ([]models.User, int64, error) {
+	query := database.DB.Model(&models.User{})
+	if scope != nil {
+		query = query.Scopes(scope)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("ERROR: Falha ao contar usuários: %v", err)
+		return nil, 0, err
+	}
+
+	if order == "" {
+		order = "created_at, id"
+	}
+	var users []models.User
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar usuários: %v", err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
 // GetUserByID retorna um usuário pelo seu ID.
 func GetUserByID(id uuid.UUID) (models.User, error) {
 	var user models.User
@@ -51,36 +94,190 @@ func GetUserByID(id uuid.UUID) (models.User, error) {
 	return user, nil
 }
 
+// ErrUserVersionMismatch indica que o usuário foi alterado (ou removido e recriado) depois da
+// leitura em que a alteração se baseou. O cliente deve recarregar o usuário e tentar de novo.
+var ErrUserVersionMismatch = errors.New("o usuário foi alterado por outra requisição")
+
 // UpdateUser atualiza os dados de um usuário existente.
 // O ID é usado para identificar o usuário, e o user *models.User contém os campos a serem atualizados.
-func UpdateUser(user *models.User, id uuid.UUID) error {
+// Se user.Version não for zero, a atualização só é aplicada se o usuário ainda estiver nessa
+// versão (verificado atomicamente na mesma instrução que a incrementa); caso contrário, retorna
+// ErrUserVersionMismatch. Ao final, user recebe o estado gravado, com a nova versão.
+// Se user.Attributes não for nil, os atributos personalizados são substituídos, depois de
+// validados com o JSON Schema configurado (ErrInvalidUserAttributes).
+// Os campos alterados são registrados no log de auditoria em nome de actor.
+func UpdateUser(actor AuditActor, user *models.User, id uuid.UUID) error {
+	if user.Attributes != nil {
+		if err := ValidateUserAttributes(user.Attributes); err != nil {
+			return err
+		}
+	}
 	// A lógica de hashing de senha em UpdateUser é mantida conforme original,
 	// mas o UpdateUserHandler agora não preenche user.Password.
 	// Esta lógica permaneceria para outros usos potenciais ou refatorações futuras.
 	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hashedPassword, err := password.Hash(user.Password)
 		if err != nil {
 			log.Printf("ERROR: Falha ao gerar hash de nova senha para usuário ID %s: %v", id, err)
 			return err
 		}
-		user.PasswordHash = string(hashedPassword)
+		user.PasswordHash = hashedPassword
 		user.Password = "" // Limpa a senha em texto plano
 	}
 
-	result := database.DB.Model(&models.User{}).Where("id = ?", id).Updates(user)
-	if result.Error != nil {
-		log.Printf("ERROR: Falha ao atualizar usuário ID %s no banco de dados: %v", id, result.Error)
-		return result.Error
+	after, err := updateAudited(actor, id, user.Version, func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&models.User{}).Where("id = ?", id).Omit("version").Updates(user)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrUserVersionMismatch) {
+			log.Printf("ERROR: Falha ao atualizar usuário ID %s no banco de dados: %v", id, err)
+		}
+		return err
+	}
+	*user = after
+	return nil
+}
+
+// EmailInUse informa se outro usuário (diferente de exceptID) já usa o e-mail,
+// sem diferenciar maiúsculas de minúsculas.
+func EmailInUse(email string, exceptID uuid.UUID) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.User{}).
+		Where("LOWER(email) = LOWER(?) AND id <> ?", email, exceptID).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao verificar unicidade do e-mail %s: %v", email, err)
+	}
+	return count > 0, err
+}
+
+// UpdateUserColumns atualiza colunas específicas de um usuário.
+// Diferente de UpdateUser, permite gravar valores zero (ex: active = false, external_id = NULL).
+func UpdateUserColumns(actor AuditActor, id uuid.UUID, columns map[string]interface{}) error {
+	_, err := updateAudited(actor, id, 0, func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&models.User{}).Where("id = ?", id).Updates(columns)
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ERROR: Falha ao atualizar colunas do usuário ID %s: %v", id, err)
+		}
+		return err
 	}
 	return nil
 }
 
-// DeleteUser remove um usuário do banco de dados.
-func DeleteUser(id uuid.UUID) error {
-	result := database.DB.Delete(&models.User{}, "id = ?", id)
+// updateAudited executa update em uma transação e registra no log de auditoria o diff
+// entre o estado do usuário antes e depois da alteração. A versão do usuário é incrementada
+// antes de update; com version diferente de zero, apenas se o usuário ainda estiver nela.
+// Se a alteração desativa o usuário, as suas sessões são encerradas na mesma transação.
+func updateAudited(actor AuditActor, id uuid.UUID, version int64, update func(tx *gorm.DB) *gorm.DB) (models.User, error) {
+	var after models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var before models.User
+		if err := tx.First(&before, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := bumpUserVersion(tx, id, version); err != nil {
+			return err
+		}
+		if err := update(tx).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&after, "id = ?", id).Error; err != nil {
+			return err
+		}
+		// O novo e-mail ainda não foi confirmado: não pode ser usado para vincular identidades
+		// externas pelo e-mail (LoginFederatedUser).
+		if !strings.EqualFold(before.Email, after.Email) && after.EmailVerifiedAt != nil {
+			if err := tx.Model(&models.User{}).Where("id = ?", id).UpdateColumn("email_verified_at", nil).Error; err != nil {
+				return err
+			}
+			after.EmailVerifiedAt = nil
+		}
+		// Um usuário desativado (pelo SCIM ou por um administrador) perde imediatamente as
+		// sessões abertas, em vez de manter o acesso até os tokens expirarem.
+		action := models.AuditActionUserUpdate
+		if before.Active && !after.Active {
+			action = models.AuditActionUserDeactivate
+			if _, err := revokeUserSessions(tx, actor, id, uuid.Nil); err != nil {
+				return err
+			}
+		}
+		return recordUserChange(tx, actor, action, &before, &after)
+	})
+	return after, err
+}
+
+// bumpUserVersion incrementa a versão do usuário. Com version diferente de zero, a condição
+// sobre a versão atual faz parte da mesma instrução, e a linha fica bloqueada até o fim de tx:
+// duas alterações concorrentes baseadas na mesma versão não podem ser ambas aplicadas.
+func bumpUserVersion(tx *gorm.DB, id uuid.UUID, version int64) error {
+	query := tx.Model(&models.User{}).Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.UpdateColumn("version", gorm.Expr("version + 1"))
 	if result.Error != nil {
-		log.Printf("ERROR: Falha ao deletar usuário ID %s do banco de dados: %v", id, result.Error)
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return ErrUserVersionMismatch
+	}
+	return nil
+}
+
+// DeleteUser remove um usuário do banco de dados, junto com suas participações em grupos,
+// consentimentos OAuth, identidades federadas vinculadas, tokens de API, sessões e miniaturas
+// do avatar. Retorna gorm.ErrRecordNotFound se o usuário não existir. Os registros de
+// auditoria são mantidos.
+func DeleteUser(actor AuditActor, id uuid.UUID) error {
+	return DeleteUserAtVersion(actor, id, 0)
+}
+
+// DeleteUserAtVersion remove o usuário como DeleteUser, mas, com version diferente de zero,
+// apenas se ele ainda estiver nessa versão (ErrUserVersionMismatch caso contrário).
+func DeleteUserAtVersion(actor AuditActor, id uuid.UUID, version int64) error {
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", id).Error; err != nil {
+			return err
+		}
+		// A verificação bloqueia a linha: nenhuma alteração concorrente é perdida na remoção.
+		if err := bumpUserVersion(tx, id, version); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM group_members WHERE user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.OAuthConsent{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.FederatedIdentity{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.APIToken{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Session{}, "user_id = ? OR impersonator_id = ?", id, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.MagicLink{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.User{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return recordUserChange(tx, actor, models.AuditActionUserDelete, &user, nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrUserVersionMismatch) {
+		return err
+	}
+	if err != nil {
+		log.Printf("ERROR: Falha ao deletar usuário ID %s do banco de dados: %v", id, err)
+		return err
+	}
+	if user.AvatarKey != "" {
+		deleteAvatarFiles(user.AvatarKey)
+	}
 	return nil
 }
@@ -8,9 +8,12 @@ import (
 // UserServiceInterface define as operações do serviço de usuário.
 // Esta interface será usada para mocking nos testes de handler.
 type UserServiceInterface interface {
-	CreateUser(user *models.User, plainPassword string) error
+	CreateUser(actor AuditActor, user *models.User, plainPassword string) error
 	GetAllUsers() ([]models.User, error)
 	GetUserByID(id uuid.UUID) (models.User, error)
-	UpdateUser(user *models.User, id uuid.UUID) error
-	DeleteUser(id uuid.UUID) error
+	UpdateUser(actor AuditActor, user *models.User, id uuid.UUID) error
+	DeleteUser(actor AuditActor, id uuid.UUID) error
+	DeleteUserAtVersion(actor AuditActor, id uuid.UUID, version int64) error
+	ImportUsers(source UserImportSource, opts UserImportOptions) (UserImportSummary, error)
+	ExportUsers(fn func(models.User) error) error
 }
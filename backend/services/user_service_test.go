@@ -8,8 +8,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/monteirobsb/user-management/backend/database"
 	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/password"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -33,8 +33,8 @@ func setupTestSQLiteDB(t *testing.T) {
 			t.Fatalf("FATAL: Failed to connect to test SQLite database: %v", err)
 		}
 
-		// AutoMigrate the schema for the User model.
-		err = testDB.AutoMigrate(&models.User{})
+		// Migrate the schema for all application models.
+		err = database.Migrate(testDB)
 		if err != nil {
 			t.Fatalf("FATAL: Failed to migrate test database schema: %v", err)
 		}
@@ -87,15 +87,16 @@ func TestCreateUser_PasswordHashing_WithSQLite(t *testing.T) {
 	}
 
 	// Call the CreateUser service function.
-	err := CreateUser(user, plainPassword)
+	err := CreateUser(SystemActor, user, plainPassword)
 	assert.NoError(err, "CreateUser should succeed with the test SQLite database")
 
 	// --- Assertions about password hashing ---
 	assert.NotEmpty(user.PasswordHash, "PasswordHash should not be empty after CreateUser")
 
 	// Verify the hash against the original password.
-	errCompare := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(plainPassword))
-	assert.NoError(errCompare, "Hashed password should match the original password")
+	matches, _, errCompare := password.Verify(user.PasswordHash, plainPassword)
+	assert.NoError(errCompare, "Stored hash should be in a recognized format")
+	assert.True(matches, "Hashed password should match the original password")
 
 	assert.NotEqual(plainPassword, user.PasswordHash, "PasswordHash should be different from the plaintext password")
 
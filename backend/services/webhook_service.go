@@ -0,0 +1,299 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"gorm.io/gorm"
+)
+
+// WebhookSecretPrefix identifica os segredos de assinatura de webhooks.
+const WebhookSecretPrefix = "whsec_"
+
+// EnqueueWebhookDeliveries cria uma entrega pendente do evento para cada assinatura ativa
+// inscrita no seu tipo. É idempotente: como o relay do outbox pode publicar o mesmo evento
+// mais de uma vez, assinaturas que já têm entrega do evento são ignoradas.
+func EnqueueWebhookDeliveries(event models.OutboxEvent) (int, error) {
+	created := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var subscriptions []models.WebhookSubscription
+		if err := tx.Where("active = ?", true).Find(&subscriptions).Error; err != nil {
+			return err
+		}
+		for _, subscription := range subscriptions {
+			if !subscription.Subscribes(event.EventType) {
+				continue
+			}
+			var existing int64
+			err := tx.Model(&models.WebhookDelivery{}).
+				Where("subscription_id = ? AND event_id = ? AND redelivery_of IS NULL", subscription.ID, event.ID).
+				Count(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing > 0 {
+				continue
+			}
+			now := time.Now()
+			err = tx.Create(&models.WebhookDelivery{
+				SubscriptionID: subscription.ID,
+				EventID:        event.ID,
+				EventType:      event.EventType,
+				Payload:        event.Payload,
+				Status:         models.WebhookDeliveryPending,
+				NextAttemptAt:  &now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar entregas de webhook do evento ID %s: %v", event.ID, err)
+		return 0, err
+	}
+	return created, nil
+}
+
+// CreateWebhook grava uma nova assinatura e retorna o segredo de assinatura das entregas,
+// que só é exibido nesta resposta. A criação é registrada no log de auditoria.
+func CreateWebhook(actor AuditActor, subscription *models.WebhookSubscription) (string, error) {
+	subscription.Secret = WebhookSecretPrefix + rand.Text()
+	subscription.Active = true
+	subscription.CreatedBy = actor.UserID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionWebhookCreate, "webhook", subscription.ID.String(), map[string]AuditChange{
+			"url":    {After: subscription.URL},
+			"events": {After: subscription.Events},
+		})
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao criar assinatura de webhook (%s): %v", subscription.URL, err)
+		return "", err
+	}
+	log.Printf("INFO: Assinatura de webhook ID %s criada (%s, eventos: %s).", subscription.ID, subscription.URL, subscription.Events)
+	return subscription.Secret, nil
+}
+
+// ListWebhooks lista as assinaturas, da mais antiga para a mais recente.
+func ListWebhooks() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := database.DB.Order("created_at, id").Find(&subscriptions).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar assinaturas de webhook: %v", err)
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// GetWebhook retorna uma assinatura pelo ID.
+func GetWebhook(id uuid.UUID) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := database.DB.First(&subscription, "id = ?", id).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ERROR: Falha ao buscar assinatura de webhook ID %s: %v", id, err)
+	}
+	return subscription, err
+}
+
+// UpdateWebhook altera colunas de uma assinatura, registrando o diff no log de auditoria.
+// Reativar a assinatura zera a contagem de falhas e o motivo da desativação.
+func UpdateWebhook(actor AuditActor, id uuid.UUID, columns map[string]interface{}) (models.WebhookSubscription, error) {
+	var after models.WebhookSubscription
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var before models.WebhookSubscription
+		if err := tx.First(&before, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if active, ok := columns["active"].(bool); ok && active && !before.Active {
+			columns["consecutive_failures"] = 0
+			columns["disabled_at"] = nil
+			columns["disabled_reason"] = ""
+		}
+		if err := tx.Model(&models.WebhookSubscription{}).Where("id = ?", id).Updates(columns).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&after, "id = ?", id).Error; err != nil {
+			return err
+		}
+		changes := make(map[string]AuditChange)
+		for field, values := range map[string][2]interface{}{
+			"url":         {before.URL, after.URL},
+			"description": {before.Description, after.Description},
+			"events":      {before.Events, after.Events},
+			"active":      {before.Active, after.Active},
+		} {
+			if values[0] != values[1] {
+				changes[field] = AuditChange{Before: values[0], After: values[1]}
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		return writeAudit(tx, actor, models.AuditActionWebhookUpdate, "webhook", id.String(), changes)
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ERROR: Falha ao atualizar assinatura de webhook ID %s: %v", id, err)
+	}
+	return after, err
+}
+
+// DeleteWebhook remove uma assinatura e o seu log de entregas.
+func DeleteWebhook(actor AuditActor, id uuid.UUID) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var subscription models.WebhookSubscription
+		if err := tx.First(&subscription, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.WebhookDelivery{}, "subscription_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&subscription).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, actor, models.AuditActionWebhookDelete, "webhook", id.String(), map[string]AuditChange{
+			"url":    {Before: subscription.URL},
+			"events": {Before: subscription.Events},
+		})
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ERROR: Falha ao remover assinatura de webhook ID %s: %v", id, err)
+	}
+	return err
+}
+
+// ListWebhookDeliveries retorna uma página do log de entregas de uma assinatura, da mais
+// recente para a mais antiga, e o total. status pode ser vazio.
+func ListWebhookDeliveries(subscriptionID uuid.UUID, status string, offset, limit int) ([]models.WebhookDelivery, int64, error) {
+	query := database.DB.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("ERROR: Falha ao contar entregas do webhook ID %s: %v", subscriptionID, err)
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at desc, id").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		log.Printf("ERROR: Falha ao listar entregas do webhook ID %s: %v", subscriptionID, err)
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// RedeliverWebhook agenda uma nova entrega, imediata, do mesmo evento de uma entrega
+// anterior (ex: após corrigir o endpoint). O ID do evento é mantido, para que o receptor
+// possa descartar duplicatas.
+func RedeliverWebhook(subscriptionID, deliveryID uuid.UUID) (models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := database.DB.First(&original, "id = ? AND subscription_id = ?", deliveryID, subscriptionID).Error; err != nil {
+		return original, err
+	}
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  &now,
+		RedeliveryOf:   &original.ID,
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		log.Printf("ERROR: Falha ao agendar reentrega da entrega ID %s: %v", deliveryID, err)
+		return delivery, err
+	}
+	return delivery, nil
+}
+
+// ClaimDueWebhookDeliveries reserva até limit entregas pendentes de assinaturas ativas cujo
+// horário de tentativa já chegou, adiando next_attempt_at por lease. A reserva é uma
+// atualização condicional (a entrega ainda precisa estar vencida), para que várias
+// instâncias não enviem a mesma entrega.
+func ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	now := time.Now()
+	var due []models.WebhookDelivery
+	err := database.DB.
+		Joins("JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_deliveries.subscription_id AND webhook_subscriptions.active = ?", true).
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("webhook_deliveries.next_attempt_at").Limit(limit).Find(&due).Error
+	if err != nil {
+		log.Printf("ERROR: Falha ao buscar entregas de webhook pendentes: %v", err)
+		return nil, err
+	}
+
+	claimed := due[:0]
+	leaseUntil := now.Add(lease)
+	for _, delivery := range due {
+		result := database.DB.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, models.WebhookDeliveryPending, now).
+			Update("next_attempt_at", leaseUntil)
+		if result.Error != nil {
+			log.Printf("ERROR: Falha ao reservar entrega de webhook ID %s: %v", delivery.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 1 {
+			delivery.NextAttemptAt = &leaseUntil
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+// RecordWebhookAttempt grava o resultado de uma tentativa de entrega (campos já preenchidos
+// em delivery) e atualiza a contagem de falhas da assinatura, desativando-a ao atingir
+// disableAfter falhas consecutivas. Retorna true se a assinatura foi desativada.
+func RecordWebhookAttempt(delivery *models.WebhookDelivery, succeeded bool, disableAfter int) (bool, error) {
+	disabled := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"response_status": delivery.ResponseStatus,
+			"response_body":   delivery.ResponseBody,
+			"error":           delivery.Error,
+			"duration_ms":     delivery.DurationMs,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		subscription := tx.Model(&models.WebhookSubscription{}).Where("id = ?", delivery.SubscriptionID)
+		if succeeded {
+			return subscription.Update("consecutive_failures", 0).Error
+		}
+		if err := subscription.Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error; err != nil {
+			return err
+		}
+		if disableAfter <= 0 {
+			return nil
+		}
+		now := time.Now()
+		result := tx.Model(&models.WebhookSubscription{}).
+			Where("id = ? AND active = ? AND consecutive_failures >= ?", delivery.SubscriptionID, true, disableAfter).
+			Updates(map[string]interface{}{
+				"active":          false,
+				"disabled_at":     now,
+				"disabled_reason": fmt.Sprintf("Desativada após %d falhas consecutivas", disableAfter),
+			})
+		disabled = result.RowsAffected == 1
+		return result.Error
+	})
+	if err != nil {
+		log.Printf("ERROR: Falha ao registrar tentativa da entrega de webhook ID %s: %v", delivery.ID, err)
+	}
+	return disabled, err
+}
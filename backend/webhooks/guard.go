@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ErrBlockedDestination indica que o endereço do endpoint está em uma faixa interna e não
+// foi liberado em WEBHOOK_ALLOWED_CIDRS.
+var ErrBlockedDestination = errors.New("endereço de destino do webhook não permitido")
+
+// blockedPrefixes são as faixas que um webhook não pode alcançar: loopback, redes privadas,
+// link-local (inclusive os serviços de metadados de nuvem, como 169.254.169.254) e demais
+// endereços reservados.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, que embute endereços IPv4
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// AddressPolicy decide quais endereços os webhooks podem alcançar. Allowed libera faixas
+// internas explicitamente (ex: um receptor na rede privada).
+type AddressPolicy struct {
+	Allowed []netip.Prefix
+}
+
+// ParseAllowedCIDRs lê a lista de faixas liberadas, separadas por vírgula (ex:
+// "10.0.5.0/24,192.168.1.10/32").
+func ParseAllowedCIDRs(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("faixa inválida em WEBHOOK_ALLOWED_CIDRS: %q", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Permits informa se o endereço pode ser alcançado.
+func (p AddressPolicy) Permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.Allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsGlobalUnicast()
+}
+
+// DialContext resolve o host no momento da conexão e conecta apenas aos endereços
+// permitidos. A conexão é feita ao IP já verificado, e não ao nome: um DNS que responde com
+// outro endereço na segunda consulta (DNS rebinding) não contorna a verificação.
+func (p AddressPolicy) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error = fmt.Errorf("%w: %s", ErrBlockedDestination, host)
+		for _, addr := range addrs {
+			if !p.Permits(addr) {
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// NewClient cria o cliente HTTP dos webhooks, que só alcança os endereços permitidos pela
+// política. Proxies do ambiente são ignorados (a conexão precisa ser feita ao endereço
+// verificado) e redirecionamentos não são seguidos: a resposta 3xx conta como falha.
+func NewClient(timeout time.Duration, policy AddressPolicy) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = policy.DialContext(&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second})
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,239 @@
+// Package webhooks envia aos endpoints assinados os eventos do ciclo de vida dos usuários
+// enfileirados pela camada de serviços, com assinatura HMAC-SHA256 e novas tentativas.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+)
+
+// Cabeçalhos enviados em cada entrega.
+const (
+	HeaderEventID   = "X-Webhook-ID"        // ID do evento, igual nas novas tentativas e reentregas
+	HeaderEvent     = "X-Webhook-Event"     // tipo do evento (ex: user.created)
+	HeaderTimestamp = "X-Webhook-Timestamp" // segundos Unix do envio
+	HeaderSignature = "X-Webhook-Signature" // sha256=<hex do HMAC de "<timestamp>.<corpo>">
+)
+
+const (
+	// maxResponseBody limita o trecho da resposta guardado no log de entregas.
+	maxResponseBody = 1024
+	// baseBackoff é o intervalo antes da segunda tentativa; dobra a cada falha.
+	baseBackoff = 30 * time.Second
+	// maxBackoff limita o intervalo entre tentativas.
+	maxBackoff = 6 * time.Hour
+)
+
+// Dispatcher envia as entregas pendentes. Várias instâncias podem rodar ao mesmo tempo:
+// cada entrega é reservada antes do envio.
+type Dispatcher struct {
+	Client       *http.Client
+	MaxAttempts  int           // tentativas por entrega antes de marcá-la como failed
+	DisableAfter int           // falhas consecutivas que desativam a assinatura (0 = nunca)
+	PollInterval time.Duration // intervalo entre buscas por entregas pendentes
+	BatchSize    int           // entregas reservadas por busca
+}
+
+// NewFromEnv cria o Dispatcher a partir das variáveis WEBHOOK_*, com valores padrão para
+// as que não estiverem definidas. O cliente só alcança endereços públicos e as faixas de
+// WEBHOOK_ALLOWED_CIDRS (veja NewClient).
+func NewFromEnv() (*Dispatcher, error) {
+	timeout, err := durationEnv("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := intEnv("WEBHOOK_MAX_ATTEMPTS", 8, 1)
+	if err != nil {
+		return nil, err
+	}
+	disableAfter, err := intEnv("WEBHOOK_DISABLE_AFTER", 15, 0)
+	if err != nil {
+		return nil, err
+	}
+	pollInterval, err := durationEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := ParseAllowedCIDRs(os.Getenv("WEBHOOK_ALLOWED_CIDRS"))
+	if err != nil {
+		return nil, err
+	}
+	return &Dispatcher{
+		Client:       NewClient(timeout, AddressPolicy{Allowed: allowed}),
+		MaxAttempts:  maxAttempts,
+		DisableAfter: disableAfter,
+		PollInterval: pollInterval,
+		BatchSize:    50,
+	}, nil
+}
+
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("valor inválido para %s: %q", name, raw)
+	}
+	return value, nil
+}
+
+func intEnv(name string, fallback, min int) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min {
+		return 0, fmt.Errorf("valor inválido para %s: %q", name, raw)
+	}
+	return value, nil
+}
+
+// Run envia as entregas pendentes a cada PollInterval até ctx ser cancelado.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Esvazia a fila antes de esperar o próximo ciclo.
+		for ctx.Err() == nil {
+			if d.DispatchDue(ctx) < d.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue reserva e envia um lote de entregas vencidas, retornando quantas foram enviadas.
+func (d *Dispatcher) DispatchDue(ctx context.Context) int {
+	// A reserva dura o suficiente para a tentativa terminar; se a instância cair no meio
+	// do envio, a entrega volta a ficar disponível depois desse prazo.
+	deliveries, err := services.ClaimDueWebhookDeliveries(d.BatchSize, 2*d.Client.Timeout+time.Minute)
+	if err != nil {
+		return 0
+	}
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		d.deliver(ctx, &deliveries[i])
+	}
+	return len(deliveries)
+}
+
+// deliver faz uma tentativa de envio e registra o resultado.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	var subscription models.WebhookSubscription
+	if err := database.DB.First(&subscription, "id = ?", delivery.SubscriptionID).Error; err != nil {
+		log.Printf("ERROR: Assinatura da entrega de webhook ID %s não encontrada: %v", delivery.ID, err)
+		return
+	}
+
+	start := time.Now()
+	status, body, err := d.send(ctx, subscription, delivery)
+	now := time.Now()
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.DurationMs = now.Sub(start).Milliseconds()
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	delivery.Error = ""
+	succeeded := err == nil && status >= 200 && status < 300
+	switch {
+	case succeeded:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	} else if !succeeded {
+		delivery.Error = fmt.Sprintf("resposta HTTP %d", status)
+	}
+
+	disabled, recordErr := services.RecordWebhookAttempt(delivery, succeeded, d.DisableAfter)
+	if recordErr != nil {
+		return
+	}
+	if !succeeded {
+		log.Printf("WARN: Falha na entrega de webhook ID %s (%s para %s, tentativa %d): %s", delivery.ID, delivery.EventType, subscription.URL, delivery.Attempts, delivery.Error)
+	}
+	if disabled {
+		log.Printf("WARN: Assinatura de webhook ID %s (%s) desativada após %d falhas consecutivas.", subscription.ID, subscription.URL, d.DisableAfter)
+	}
+}
+
+// send faz a requisição POST assinada e retorna o status e o início do corpo da resposta.
+func (d *Dispatcher) send(ctx context.Context, subscription models.WebhookSubscription, delivery *models.WebhookDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-management-webhooks/1.0")
+	req.Header.Set(HeaderEventID, delivery.EventID.String())
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(body), nil
+}
+
+// Sign calcula o valor do cabeçalho X-Webhook-Signature: o HMAC-SHA256, com o segredo da
+// assinatura, de "<timestamp>.<corpo>". Incluir o timestamp permite ao receptor rejeitar
+// requisições antigas reenviadas por terceiros.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff retorna o intervalo antes da próxima tentativa após attempts falhas: 30s, 1min,
+// 2min, 4min... até 6h.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
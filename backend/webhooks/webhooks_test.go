@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monteirobsb/user-management/backend/database"
+	"github.com/monteirobsb/user-management/backend/events"
+	"github.com/monteirobsb/user-management/backend/models"
+	"github.com/monteirobsb/user-management/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// receiver é um endpoint de teste que guarda as requisições e responde com status.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+	w.Write([]byte("ok"))
+}
+
+// makeDue antecipa as novas tentativas agendadas, para não esperar o backoff.
+func makeDue(t *testing.T) {
+	require.NoError(t, database.DB.Model(&models.WebhookDelivery{}).
+		Where("status = ?", models.WebhookDeliveryPending).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	originalGlobalDB := database.DB
+	database.DB = db
+	defer func() { database.DB = originalGlobalDB }()
+
+	endpoint := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	subscription := models.WebhookSubscription{URL: server.URL, Events: "user.created user.suspended"}
+	secret, err := services.CreateWebhook(services.SystemActor, &subscription)
+	require.NoError(t, err)
+	assert.Contains(t, secret, services.WebhookSecretPrefix)
+
+	// Eventos: criação e suspensão são assinados; a alteração de nome não.
+	user := models.User{Name: "Webhook", Email: "webhook@example.com", Active: true}
+	require.NoError(t, services.CreateUser(services.SystemActor, &user, "password123"))
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"name": "Renomeado"}))
+	require.NoError(t, services.UpdateUserColumns(services.SystemActor, user.ID, map[string]interface{}{"active": false}))
+
+	// Os eventos chegam às assinaturas pelo relay do outbox; publicar de novo não duplica
+	// as entregas.
+	relay := &events.Relay{Publisher: events.WebhookPublisher{}, BatchSize: 10, Lease: time.Minute}
+	assert.Equal(t, 3, relay.PublishPending(context.Background()))
+	var outbox []models.OutboxEvent
+	require.NoError(t, db.Find(&outbox).Error)
+	for _, event := range outbox {
+		created, err := services.EnqueueWebhookDeliveries(event)
+		require.NoError(t, err)
+		assert.Zero(t, created)
+	}
+
+	dispatcher := &Dispatcher{Client: server.Client(), MaxAttempts: 3, DisableAfter: 2, BatchSize: 10}
+	assert.Equal(t, 2, dispatcher.DispatchDue(context.Background()))
+	assert.Zero(t, dispatcher.DispatchDue(context.Background()), "entregas bem-sucedidas não são reenviadas")
+
+	require.Len(t, endpoint.requests, 2)
+	var event services.UserEvent
+	require.NoError(t, json.Unmarshal(endpoint.bodies[0], &event))
+	assert.Equal(t, models.EventUserCreated, event.Type)
+	assert.Equal(t, user.ID, event.Data.User.ID)
+	assert.NotContains(t, string(endpoint.bodies[0]), user.PasswordHash)
+
+	req := endpoint.requests[1]
+	assert.Equal(t, models.EventUserSuspended, req.Header.Get(HeaderEvent))
+	assert.Equal(t, Sign(secret, req.Header.Get(HeaderTimestamp), endpoint.bodies[1]), req.Header.Get(HeaderSignature))
+	assert.NotEqual(t, Sign("outro-segredo", req.Header.Get(HeaderTimestamp), endpoint.bodies[1]), req.Header.Get(HeaderSignature))
+
+	// Falhas: a entrega é reagendada com backoff e a assinatura é desativada após
+	// DisableAfter falhas consecutivas.
+	endpoint.status = http.StatusInternalServerError
+	require.NoError(t, services.DeleteUser(services.SystemActor, user.ID)) // não assinado
+	other := models.User{Name: "Outro", Email: "other@example.com"}
+	require.NoError(t, services.CreateUser(services.SystemActor, &other, "password123"))
+	assert.Equal(t, 2, relay.PublishPending(context.Background()))
+
+	assert.Equal(t, 1, dispatcher.DispatchDue(context.Background()))
+	var delivery models.WebhookDelivery
+	require.NoError(t, db.Where("status = ?", models.WebhookDeliveryPending).First(&delivery).Error)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+	assert.Equal(t, "ok", delivery.ResponseBody)
+	require.NotNil(t, delivery.NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(Backoff(1)), *delivery.NextAttemptAt, 5*time.Second)
+	assert.Zero(t, dispatcher.DispatchDue(context.Background()), "nova tentativa antes do backoff")
+
+	makeDue(t)
+	assert.Equal(t, 1, dispatcher.DispatchDue(context.Background()))
+	subscription, err = services.GetWebhook(subscription.ID)
+	require.NoError(t, err)
+	assert.False(t, subscription.Active)
+	assert.NotNil(t, subscription.DisabledAt)
+	makeDue(t)
+	assert.Zero(t, dispatcher.DispatchDue(context.Background()), "assinatura desativada não recebe entregas")
+
+	// Reativar zera as falhas; a entrega pendente esgota as tentativas e fica como failed.
+	subscription, err = services.UpdateWebhook(services.SystemActor, subscription.ID, map[string]interface{}{"active": true})
+	require.NoError(t, err)
+	assert.Zero(t, subscription.ConsecutiveFailures)
+	assert.Equal(t, 1, dispatcher.DispatchDue(context.Background()))
+	delivery = models.WebhookDelivery{}
+	require.NoError(t, db.Where("status = ?", models.WebhookDeliveryFailed).First(&delivery).Error)
+	assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	// Reentrega manual: novo registro com o mesmo ID de evento.
+	endpoint.status = http.StatusNoContent
+	redelivery, err := services.RedeliverWebhook(subscription.ID, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, dispatcher.DispatchDue(context.Background()))
+	require.NoError(t, db.First(&redelivery, "id = ?", redelivery.ID).Error)
+	assert.Equal(t, models.WebhookDeliverySucceeded, redelivery.Status)
+	assert.Equal(t, delivery.EventID, redelivery.EventID)
+	assert.Equal(t, delivery.ID, *redelivery.RedeliveryOf)
+	last := endpoint.requests[len(endpoint.requests)-1]
+	assert.Equal(t, delivery.EventID.String(), last.Header.Get(HeaderEventID))
+
+	deliveries, total, err := services.ListWebhookDeliveries(subscription.ID, models.WebhookDeliverySucceeded, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	assert.Len(t, deliveries, 3)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, maxBackoff, Backoff(50))
+}
+
+func TestAddressPolicy(t *testing.T) {
+	policy := AddressPolicy{}
+	for _, blocked := range []string{"127.0.0.1", "10.1.2.3", "172.20.0.1", "192.168.0.10", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00:ec2::254", "fe80::1", "::ffff:127.0.0.1", "224.0.0.1"} {
+		assert.False(t, policy.Permits(netip.MustParseAddr(blocked)), blocked)
+	}
+	for _, public := range []string{"8.8.8.8", "203.0.114.1", "2001:4860:4860::8888"} {
+		assert.True(t, policy.Permits(netip.MustParseAddr(public)), public)
+	}
+
+	allowed, err := ParseAllowedCIDRs(" 10.0.5.0/24, 127.0.0.1/32 ")
+	require.NoError(t, err)
+	policy = AddressPolicy{Allowed: allowed}
+	assert.True(t, policy.Permits(netip.MustParseAddr("10.0.5.7")))
+	assert.False(t, policy.Permits(netip.MustParseAddr("10.0.6.7")))
+	_, err = ParseAllowedCIDRs("10.0.0.0")
+	assert.Error(t, err)
+}
+
+func TestClientBlocksInternalDestinations(t *testing.T) {
+	endpoint := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// Por IP ou por nome que resolve para o loopback, o destino é recusado antes da conexão.
+	client := NewClient(time.Second, AddressPolicy{})
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		_, err := client.Post(url, "application/json", nil)
+		assert.ErrorIs(t, err, ErrBlockedDestination, url)
+	}
+	assert.Empty(t, endpoint.requests)
+
+	// Liberada explicitamente, a faixa pode ser usada; redirecionamentos não são seguidos.
+	client = NewClient(time.Second, AddressPolicy{Allowed: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+	resp, err := client.Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = client.Post(redirect.URL, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Len(t, endpoint.requests, 1)
+}
@@ -8,25 +8,55 @@ const apiClient = axios.create({
     },
 });
 
+const safeMethods = ['get', 'head', 'options'];
+
 // Interceptor para adicionar o token de autenticação a cada requisição
-apiClient.interceptors.request.use(config => {
+apiClient.interceptors.request.use(async config => {
     // É necessário instanciar a store dentro do interceptor
     const authStore = useAuthStore();
     const token = authStore.token;
     if (token) {
         config.headers.Authorization = `Bearer ${token}`;
+    } else if (authStore.cookieSession && !safeMethods.includes(config.method)) {
+        // Sessão por cookie: requisições que alteram estado exigem o token CSRF.
+        const csrfToken = authStore.csrfToken || await authStore.loadCsrfToken();
+        config.headers['X-CSRF-Token'] = csrfToken;
     }
     return config;
 }, error => {
     return Promise.reject(error);
 });
 
+// Lê um bloco de Server-Sent Events ("campo: valor" por linha).
+function parseEventBlock(block) {
+    const event = {};
+    for (const line of block.split('\n')) {
+        if (!line || line.startsWith(':')) continue; // comentário (keep-alive)
+        const index = line.indexOf(':');
+        const field = index === -1 ? line : line.slice(0, index);
+        const value = index === -1 ? '' : line.slice(index + 1).replace(/^ /, '');
+        event[field] = event[field] && field === 'data' ? `${event[field]}\n${value}` : value;
+    }
+    return event;
+}
+
+// Cabeçalho If-Match com o ETag de uma versão do usuário (vazio se a versão é desconhecida).
+function ifMatch(version) {
+    return version ? { 'If-Match': `"${version}"` } : {};
+}
+
 // Exporta um objeto com métodos nomeados explicitamente
 export default {
   // --- Auth ---
   login(credentials) {
     return apiClient.post('/login', credentials);
   },
+  logout() {
+    return apiClient.post('/logout');
+  },
+  getCsrfToken() {
+    return apiClient.get('/me/csrf');
+  },
 
   // --- Users ---
   getUsers() {
@@ -38,10 +68,62 @@ export default {
   createUser(user) {
     return apiClient.post('/users', user);
   },
-  updateUser(id, user) {
-    return apiClient.put(`/users/${id}`, user);
+  // version é a versão do usuário lida pelo cliente, enviada como If-Match: se outro
+  // administrador alterou o usuário nesse meio tempo, a API responde 412.
+  updateUser(id, user, version) {
+    return apiClient.put(`/users/${id}`, user, { headers: ifMatch(version) });
+  },
+  deleteUser(id, version) {
+    return apiClient.delete(`/users/${id}`, { headers: ifMatch(version) });
   },
-  deleteUser(id) {
-    return apiClient.delete(`/users/${id}`);
+
+  // Acompanha as alterações de usuários (GET /api/users/events). Usa fetch em vez de
+  // EventSource para poder enviar o cabeçalho Authorization; reconecta com Last-Event-ID
+  // após quedas. Retorna uma função que encerra a conexão.
+  subscribeUserEvents(onEvent) {
+    const authStore = useAuthStore();
+    const controller = new AbortController();
+    let lastEventId = null;
+
+    const connect = async () => {
+      while (!controller.signal.aborted) {
+        try {
+          const headers = { Accept: 'text/event-stream' };
+          if (authStore.token) {
+            headers.Authorization = `Bearer ${authStore.token}`;
+          }
+          if (lastEventId) {
+            headers['Last-Event-ID'] = lastEventId;
+          }
+          const response = await fetch('/api/users/events', { headers, credentials: 'same-origin', signal: controller.signal });
+          if (response.status === 401 || response.status === 403) {
+            return; // sem permissão: não adianta reconectar
+          }
+          if (!response.ok) {
+            throw new Error(`Stream de eventos respondeu ${response.status}`);
+          }
+          const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+          let buffer = '';
+          for (;;) {
+            const { value, done } = await reader.read();
+            if (done) break;
+            buffer += value;
+            let index;
+            while ((index = buffer.indexOf('\n\n')) !== -1) {
+              const event = parseEventBlock(buffer.slice(0, index));
+              buffer = buffer.slice(index + 2);
+              if (event.id) lastEventId = event.id;
+              if (event.event) onEvent(event.event, event.data ? JSON.parse(event.data) : null);
+            }
+          }
+        } catch (error) {
+          if (controller.signal.aborted) return;
+          console.error(error);
+        }
+        await new Promise(resolve => setTimeout(resolve, 3000));
+      }
+    };
+    connect();
+    return () => controller.abort();
   },
 };
\ No newline at end of file
@@ -2,32 +2,58 @@ import { defineStore } from 'pinia';
 import router from '@/router';
 import api from '@/services/api'; // Importa nosso serviço de API refatorado
 
+// No modo cookie (VITE_AUTH_COOKIE_MODE=true), o token de sessão fica em um cookie HttpOnly,
+// inacessível ao JavaScript; guardamos apenas o indicador de login e o token CSRF em memória.
+const cookieMode = import.meta.env.VITE_AUTH_COOKIE_MODE === 'true';
+
 export const useAuthStore = defineStore('auth', {
   state: () => ({
-    token: localStorage.getItem('token') || null,
+    token: cookieMode ? null : localStorage.getItem('token') || null,
+    cookieSession: cookieMode && localStorage.getItem('cookieSession') === 'true',
+    csrfToken: null,
   }),
   getters: {
-    isAuthenticated: (state) => !!state.token,
+    isAuthenticated: (state) => !!state.token || state.cookieSession,
   },
   actions: {
     async login(credentials) {
       try {
         // AQUI ESTÁ A MUDANÇA: Usamos a função explícita 'api.login'
-        const response = await api.login(credentials); 
-        
-        const token = response.data.token;
-        this.token = token;
-        localStorage.setItem('token', token);
+        const response = await api.login({ ...credentials, use_cookie: cookieMode });
+
+        if (cookieMode) {
+          this.csrfToken = response.data.csrf_token;
+          this.cookieSession = true;
+          localStorage.setItem('cookieSession', 'true');
+        } else {
+          const token = response.data.token;
+          this.token = token;
+          localStorage.setItem('token', token);
+        }
         router.push('/');
       } catch (error) {
         console.error("Falha no login:", error);
         throw error;
       }
     },
-    logout() {
+    // Recupera o token CSRF da sessão por cookie (ex: após recarregar a página).
+    async loadCsrfToken() {
+      const response = await api.getCsrfToken();
+      this.csrfToken = response.data.csrf_token;
+      return this.csrfToken;
+    },
+    async logout() {
+      try {
+        await api.logout();
+      } catch (error) {
+        // A sessão pode já ter expirado; o estado local é limpo de qualquer forma.
+      }
       this.token = null;
+      this.cookieSession = false;
+      this.csrfToken = null;
       localStorage.removeItem('token');
+      localStorage.removeItem('cookieSession');
       router.push('/login');
     },
   },
-});
\ No newline at end of file
+});
@@ -1,6 +1,9 @@
 import { defineStore } from 'pinia';
 import apiService from '../services/api.js';
 
+// Encerra a conexão com o stream de eventos de usuários, quando aberta.
+let stopUserEvents = null;
+
 export const useUserStore = defineStore('user', {
     state: () => ({
         users: [],
@@ -47,7 +50,8 @@ export const useUserStore = defineStore('user', {
             this.loading = true;
             this.error = null;
             try {
-                const response = await apiService.updateUser(id, user);
+                const version = this.users.find(u => u.id === id)?.version;
+                const response = await apiService.updateUser(id, user, version);
                  // Assume que response.data contém o usuário atualizado
                 if (response && response.data) {
                     const index = this.users.findIndex(u => u.id === id);
@@ -62,17 +66,53 @@ export const useUserStore = defineStore('user', {
                 }
             } catch (error) {
                 this.error = error.response?.data?.error || 'Falha ao atualizar usuário.';
+                if (error.response?.status === 412) {
+                    // Alterado por outro administrador: mostra a versão atual, mantendo o aviso.
+                    const message = this.error;
+                    await this.fetchUsers();
+                    this.error = message;
+                }
                 console.error(error);
                 throw error; // Propaga o erro para o componente
             } finally {
                 this.loading = false;
             }
         },
+        // Mantém a lista atualizada com as alterações feitas por outros administradores.
+        startLiveUpdates() {
+            if (!stopUserEvents) {
+                stopUserEvents = apiService.subscribeUserEvents((type, event) => this.applyUserEvent(type, event));
+            }
+        },
+        stopLiveUpdates() {
+            if (stopUserEvents) {
+                stopUserEvents();
+                stopUserEvents = null;
+            }
+        },
+        applyUserEvent(type, event) {
+            if (type === 'reset') {
+                // Eventos perdidos durante a desconexão: recarrega a lista completa.
+                this.fetchUsers();
+                return;
+            }
+            const user = event?.data?.user;
+            if (!user) return;
+            const index = this.users.findIndex(u => u.id === user.id);
+            if (type === 'user.deleted') {
+                if (index !== -1) this.users.splice(index, 1);
+            } else if (index !== -1) {
+                this.users[index] = user;
+            } else {
+                this.users.push(user);
+            }
+        },
         async removeUser(id) {
             this.loading = true;
             this.error = null;
             try {
-                await apiService.deleteUser(id);
+                const version = this.users.find(u => u.id === id)?.version;
+                await apiService.deleteUser(id, version);
                 // Remove o usuário da lista local
                 const initialLength = this.users.length;
                 this.users = this.users.filter(u => u.id !== id);
@@ -84,6 +124,11 @@ export const useUserStore = defineStore('user', {
                 }
             } catch (error) {
                 this.error = error.response?.data?.error || 'Falha ao remover usuário.';
+                if (error.response?.status === 412) {
+                    const message = this.error;
+                    await this.fetchUsers();
+                    this.error = message;
+                }
                 console.error(error);
                 throw error; // Propaga o erro para o componente
             } finally {